@@ -1,17 +1,16 @@
 package adb
 
 import (
-	"bufio"
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os/exec"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
-	"unicode/utf8"
-
-	"golang.org/x/text/encoding/simplifiedchinese"
-	"golang.org/x/text/transform"
 )
 
 // Device 表示一个 ADB 设备
@@ -21,87 +20,204 @@ type Device struct {
 	Model        string    // 设备型号
 	LastSeen     time.Time // 最后一次看到该设备的时间
 	FailedChecks int       // 连续失败检查次数
+
+	// 以下为 devices -l 报告的其余字段，旧版 adb 或部分平台可能缺少
+	Product     string            // product: 产品名（ro.product.name）
+	DeviceName  string            // device: 设备代号（ro.product.device）
+	TransportID string            // transport_id: 可用于 adb -t 指定设备
+	USB         string            // usb: USB 路径，仅 Linux/macOS 下的 USB 设备报告
+	Extra       map[string]string // 无法识别的其他字段
+	Transport   TransportKind     // 连接方式，由序列号与 usb 字段推断
+
+	HardwareSerial string   // 设备的 ro.serialno，用于识别同一台物理设备，未识别时为空
+	Aliases        []string // 合并后同一物理设备的其他连接（序列号）
+
+	Root         RootMethod    // 探测到的 root 方式，设备重新连接（例如 adb root 重启 adbd）后需重新探测
+	Capabilities *Capabilities // 探测到的设备能力，未探测时为 nil
 }
 
 // ADBManager ADB 管理器
 type ADBManager struct {
-	adbPath              string
+	runner               CommandRunner      // adb 命令执行器
+	router               *DeviceRouter      // 按设备分发（直连 adbd 的设备不经过 adb server）
 	managedDevices       map[string]*Device // 使用Serial作为key的设备缓存
 	lastDeviceListTime   time.Time
-	deviceOfflineTimeout time.Duration // 设备离线超时时间（超过此时间无响应则删除）
-	deviceCacheLock      sync.RWMutex  // 缓存锁
-	useBusybox           bool          // 是否使用busybox执行命令
-	busyboxLock          sync.RWMutex  // busybox开关锁
+	deviceOfflineTimeout time.Duration             // 设备离线超时时间（超过此时间无响应则删除）
+	deviceCacheLock      sync.RWMutex              // 缓存锁
+	profiles             map[string]WrapperProfile // 各设备的命令包装配置
+	profileLock          sync.RWMutex
+	supervisor           *Supervisor  // 无线设备自动重连，记住的设备不会因离线被删除
+	logger               *slog.Logger // 交给其他模块使用的 logger
+	log                  *slog.Logger // 本模块的 logger（component=adb）
+	auditor              Auditor      // 审计日志，未设置时不记录
+	limiter              *Limiter     // 全局与按设备的并发上限，所有 adb 操作都经由它排队
+
+	features    map[string][]string // 各设备 adbd 声明的特性缓存
+	listMethods map[string]int      // 各设备上次成功列出目录的方式
+	featureLock sync.Mutex
 }
 
 // NewADBManager 创建 ADB 管理器
 func NewADBManager() *ADBManager {
+	// 优先通过协议直接与本地 adb server 通信，server 未运行时回退到 adb 可执行文件（假设在系统 PATH 中）
+	return NewADBManagerWithRunner(NewServerRunner(DefaultServerAddr, NewExecRunner("adb")))
+}
+
+// NewADBManagerWithRunner 使用指定的命令执行器创建 ADB 管理器
+func NewADBManagerWithRunner(runner CommandRunner) *ADBManager {
+	router := NewDeviceRouter(runner)
 	return &ADBManager{
-		adbPath:              "adb", // 假设 adb 在系统 PATH 中
+		runner:               router,
+		router:               router,
 		managedDevices:       make(map[string]*Device),
 		deviceOfflineTimeout: 5 * time.Minute, // 5分钟内无响应的设备才删除
-		useBusybox:           false,
+		profiles:             make(map[string]WrapperProfile),
+		features:             make(map[string][]string),
+		listMethods:          make(map[string]int),
+		limiter:              NewLimiter(DefaultConcurrencyLimits()),
+		logger:               slog.Default(),
+		log:                  slog.Default().With("component", "adb"),
 	}
 }
 
-// SetBusyboxEnabled 设置是否使用busybox
-func (m *ADBManager) SetBusyboxEnabled(enabled bool) {
-	m.busyboxLock.Lock()
-	defer m.busyboxLock.Unlock()
-	m.useBusybox = enabled
-	if enabled {
-		fmt.Println("[ADB] ✅ Busybox 模式已启用 - 命令执行前将加入 'busybox' 前缀")
-	} else {
-		fmt.Println("[ADB] ❌ Busybox 模式已禁用")
+// SetLogger 设置日志输出，应在使用 ADBManager 之前调用；默认使用 slog.Default()
+// 使用 ADBManager 的批量操作、扫描、采集等模块也通过 Logger 写日志
+func (m *ADBManager) SetLogger(logger *slog.Logger) {
+	m.logger = logger
+	m.log = logger.With("component", "adb")
+}
+
+// Logger 返回 SetLogger 设置的 logger
+func (m *ADBManager) Logger() *slog.Logger {
+	return m.logger
+}
+
+// contextError ctx 已取消或超时时返回对应错误，可用 errors.Is(err, context.Canceled) 判断
+func contextError(ctx context.Context) error {
+	switch err := ctx.Err(); err {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	default:
+		return fmt.Errorf("操作已取消: %w", err)
 	}
 }
 
-// IsBusyboxEnabled 检查是否启用了busybox
-func (m *ADBManager) IsBusyboxEnabled() bool {
-	m.busyboxLock.RLock()
-	defer m.busyboxLock.RUnlock()
-	return m.useBusybox
+// run 执行 adb 命令并返回合并输出，失败时返回 *CommandError（已取消或超时则返回 ctx 对应的错误）
+func (m *ADBManager) run(ctx context.Context, args ...string) ([]byte, error) {
+	return m.runRedacted(ctx, args, args)
 }
 
-// wrapCommandWithBusybox 如果启用了busybox，则给命令加上busybox前缀
-func (m *ADBManager) wrapCommandWithBusybox(command string) string {
-	m.busyboxLock.RLock()
-	enabled := m.useBusybox
-	m.busyboxLock.RUnlock()
-	
-	if enabled && !strings.HasPrefix(command, "busybox ") {
-		return "busybox " + command
+// runRedacted 与 run 相同，但错误信息与审计日志中使用 shown 代替 args，用于隐去配对码等敏感参数
+func (m *ADBManager) runRedacted(ctx context.Context, args, shown []string) ([]byte, error) {
+	release, err := m.acquire(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	output, err := m.runner.CombinedOutput(ctx, args...)
+	release()
+	if err != nil {
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			err = ctxErr
+		} else {
+			err = newCommandError(shown, output, err)
+		}
+	}
+	m.audit(shown, start, auditExitCode(err), err)
+	return output, err
+}
+
+// deviceArgs 为指定设备拼接 adb 参数，serial 为空时不指定设备
+func deviceArgs(serial string, args ...string) []string {
+	if serial != "" {
+		return append([]string{"-s", serial}, args...)
+	}
+	return args
+}
+
+// isHostQuery 只读的主机查询（设备列表、版本等）
+// 设备列表每隔几秒轮询一次且在持有 deviceCacheLock 时执行，既不记入审计日志，也不参与并发排队
+func isHostQuery(args []string) bool {
+	serial, rest := splitSerial(args)
+	if serial != "" || len(rest) == 0 {
+		return false
+	}
+	switch rest[0] {
+	case "devices", "track-devices", "version", "mdns":
+		return true
+	}
+	return false
+}
+
+// targetSerial 命令针对的设备，connect、disconnect 等主机命令以其地址参数作为设备，不针对设备时为空
+func targetSerial(args []string) string {
+	serial, rest := splitSerial(args)
+	if serial == "" && len(rest) >= 2 {
+		switch rest[0] {
+		case "connect", "disconnect", "pair":
+			serial = rest[1]
+		}
+	}
+	return serial
+}
+
+// acquire 等待执行 args 的并发许可，执行结束后必须调用返回的 release
+// 许可只在执行器调用期间持有，release 之后才能写审计日志（审计需要读取设备缓存）
+func (m *ADBManager) acquire(ctx context.Context, args []string) (func(), error) {
+	if isHostQuery(args) {
+		return func() {}, nil
 	}
-	return command
+	return m.limiter.Acquire(ctx, targetSerial(args))
+}
+
+// SetConcurrencyLimits 修改 adb 操作的并发上限，立即生效
+func (m *ADBManager) SetConcurrencyLimits(limits ConcurrencyLimits) {
+	m.limiter.SetLimits(limits)
+}
+
+// ConcurrencyLimits 当前的并发上限
+func (m *ADBManager) ConcurrencyLimits() ConcurrencyLimits {
+	return m.limiter.Limits()
+}
+
+// QueueStats 返回并发排队的统计
+func (m *ADBManager) QueueStats() QueueStats {
+	return m.limiter.Stats()
+}
+
+// restartServer 重启 adb 服务
+func (m *ADBManager) restartServer(ctx context.Context) {
+	m.run(ctx, "kill-server")
+	time.Sleep(500 * time.Millisecond)
+	m.run(ctx, "start-server")
+	time.Sleep(1 * time.Second)
 }
 
 // ListDevices 列出所有已连接的设备（使用增量式更新，避免全量覆盖）
 func (m *ADBManager) ListDevices() ([]Device, error) {
+	return m.ListDevicesContext(context.Background())
+}
+
+// ListDevicesContext 可通过 ctx 取消的 ListDevices
+func (m *ADBManager) ListDevicesContext(ctx context.Context) ([]Device, error) {
 	m.deviceCacheLock.Lock()
 	defer m.deviceCacheLock.Unlock()
 
-	cmd := exec.Command(m.adbPath, "devices", "-l")
-	output, err := cmd.CombinedOutput() // 改用CombinedOutput来同时捕获stdout和stderr
-	outputStr := ensureUTF8(string(output))
-
-	// 检查是否有版本冲突信息
-	if strings.Contains(outputStr, "doesn't match") {
-		fmt.Println("[ADB] ⚠️ 检测到版本冲突警告!")
-		fmt.Println("[ADB] ADB客户端版本与服务器版本不匹配，ADB已自动重启")
-		fmt.Println("[ADB] 输出内容:")
-		fmt.Println(outputStr)
-		fmt.Println("[ADB] 解决方案:")
-		fmt.Println("  1. 关闭所有使用ADB的工具")
-		fmt.Println("  2. 手动执行: adb kill-server")
-		fmt.Println("  3. 重启ADB: adb start-server")
-		fmt.Println("  4. 或者更新ADB到最新版本")
+	output, err := m.run(ctx, "devices", "-l")
+	outputStr := EncodingAuto.Decode(output)
+
+	// 检查是否有版本冲突信息（adb 会自动重启 server，输出中仍带有警告）
+	if errors.Is(err, ErrServerVersionMismatch) || classifyOutput(outputStr) == ErrServerVersionMismatch {
+		m.log.Warn("检测到版本冲突", "output", strings.TrimSpace(outputStr))
 		// 保持缓存设备列表不变，不清空
-		return m.getDeviceList(), fmt.Errorf("ADB版本冲突，服务已重启，请解决版本问题后重试")
+		return m.getDeviceList(), fmt.Errorf("ADB版本冲突，服务已重启，请解决版本问题后重试: %w", ErrServerVersionMismatch)
 	}
 
 	if err != nil {
-		fmt.Printf("[ADB] 执行 adb devices 失败: %v，使用缓存的 %d 台设备\n", err, len(m.managedDevices))
-		return m.getDeviceList(), fmt.Errorf("执行 adb devices 失败: %v", err)
+		m.log.Warn("执行 adb devices 失败，使用缓存的设备列表", "error", err, "cached", len(m.managedDevices))
+		return m.getDeviceList(), fmt.Errorf("执行 adb devices 失败: %w", err)
 	}
 
 	// outputStr已经是编码转换过的，直接使用
@@ -119,56 +235,35 @@ func (m *ADBManager) ListDevices() ([]Device, error) {
 		if len(parts) >= 2 {
 			// 检测真正的异常状态：仅当 Serial 为 "adb" 且 Status 为 "[server]" 时
 			if len(parts) == 2 && parts[0] == "adb" && parts[1] == "[server]" {
-				fmt.Printf("[ADB] 检测到异常状态: %s\n", line)
-				fmt.Println("[ADB] 尝试重启 ADB 服务...")
+				m.log.Warn("检测到异常状态，尝试重启 ADB 服务", "line", line)
 
 				// 重启 ADB
-				exec.Command(m.adbPath, "kill-server").Run()
-				time.Sleep(500 * time.Millisecond)
-				exec.Command(m.adbPath, "start-server").Run()
-				time.Sleep(1 * time.Second)
+				m.restartServer(ctx)
 
-				fmt.Println("[ADB] ADB 服务已重启，请重新导入设备")
+				m.log.Info("ADB 服务已重启，请重新导入设备")
 				return nil, fmt.Errorf("ADB 服务已重启，请重新导入设备")
 			}
 
 			// 检测版本冲突信息并处理
 			if strings.Contains(line, "server version") && strings.Contains(line, "doesn't match") {
-				fmt.Printf("[ADB] 检测到版本冲突: %s\n", line)
-				fmt.Println("[ADB] 版本冲突，正在重启 ADB 服务...")
+				m.log.Warn("检测到版本冲突，正在重启 ADB 服务", "line", line)
 
 				// 重启 ADB
-				exec.Command(m.adbPath, "kill-server").Run()
-				time.Sleep(500 * time.Millisecond)
-				exec.Command(m.adbPath, "start-server").Run()
-				time.Sleep(1 * time.Second)
+				m.restartServer(ctx)
 
-				fmt.Println("[ADB] ADB 服务已重启，请重新导入设备")
-				return nil, fmt.Errorf("ADB 服务已重启，请重新导入设备")
+				m.log.Info("ADB 服务已重启，请重新导入设备")
+				return nil, fmt.Errorf("ADB 服务已重启，请重新导入设备: %w", ErrServerVersionMismatch)
 			}
 
 			// 过滤掉其他异常条目：* [daemon] 等
 			if parts[0] == "*" || parts[0] == "adb" || strings.HasPrefix(parts[1], "[") {
-				fmt.Printf("[ADB] 跳过异常条目: %s\n", line)
+				m.log.Debug("跳过异常条目", "line", line)
 				continue
 			}
 
-			serial := parts[0]
-			device := &Device{
-				Serial:   serial,
-				Status:   parts[1],
-				LastSeen: time.Now(),
-			}
-
-			// 提取设备型号
-			for _, part := range parts[2:] {
-				if strings.HasPrefix(part, "model:") {
-					device.Model = strings.TrimPrefix(part, "model:")
-					break
-				}
+			if device, ok := parseDeviceLine(line); ok {
+				currentDevices[device.Serial] = device
 			}
-
-			currentDevices[serial] = device
 		}
 	}
 
@@ -176,16 +271,13 @@ func (m *ADBManager) ListDevices() ([]Device, error) {
 	// 1. 更新本次发现的设备
 	for serial, dev := range currentDevices {
 		if existingDev, exists := m.managedDevices[serial]; exists {
-			// 设备已存在，更新状态和型号，重置失败计数
-			existingDev.Status = dev.Status
-			existingDev.Model = dev.Model
-			existingDev.LastSeen = time.Now()
-			existingDev.FailedChecks = 0
-			fmt.Printf("[ADB] 更新设备: %s -> %s\n", serial, dev.Status)
+			// 设备已存在，更新状态和各字段，重置失败计数
+			existingDev.update(dev)
+			m.log.Debug("更新设备", "device", serial, "status", dev.Status)
 		} else {
 			// 新发现的设备，加入管理
 			m.managedDevices[serial] = dev
-			fmt.Printf("[ADB] 新增设备: %s -> %s\n", serial, dev.Status)
+			m.log.Info("新增设备", "device", serial, "status", dev.Status)
 		}
 	}
 
@@ -194,13 +286,19 @@ func (m *ADBManager) ListDevices() ([]Device, error) {
 	for serial, dev := range m.managedDevices {
 		if _, found := currentDevices[serial]; !found {
 			dev.FailedChecks++
-			fmt.Printf("[ADB] 设备离线: %s (连续失败 %d 次)\n", serial, dev.FailedChecks)
+			m.log.Warn("设备离线", "device", serial, "failed_checks", dev.FailedChecks)
+
+			// 自动重连的无线设备只标记为离线，由用户手动移除
+			if m.supervisor != nil && m.supervisor.Tracks(serial) {
+				dev.Status = "offline"
+				continue
+			}
 
 			// 仅当连续失败3次（约15秒）才标记为离线
 			// 或者超过5分钟未见到该设备才删除
 			if dev.FailedChecks >= 3 || now.Sub(dev.LastSeen) > m.deviceOfflineTimeout {
-				fmt.Printf("[ADB] 删除离线设备: %s (失败次数:%d, 离线时长:%v)\n",
-					serial, dev.FailedChecks, now.Sub(dev.LastSeen))
+				m.log.Info("删除离线设备", "device", serial,
+					"failed_checks", dev.FailedChecks, "offline", now.Sub(dev.LastSeen))
 				delete(m.managedDevices, serial)
 			}
 		}
@@ -210,22 +308,111 @@ func (m *ADBManager) ListDevices() ([]Device, error) {
 	return m.getDeviceList(), nil
 }
 
+// parseDeviceLine 解析 devices -l 与 track-devices 输出中的一行，跳过 "* daemon started" 等非设备条目
+func parseDeviceLine(line string) (*Device, bool) {
+	parts := strings.Fields(line)
+	if len(parts) < 2 || parts[0] == "*" || parts[0] == "adb" || strings.HasPrefix(parts[1], "[") {
+		return nil, false
+	}
+
+	// 从行尾向前收集 key:value 字段，其余为状态（"no permissions (...)" 等状态本身带空格）
+	end := len(parts)
+	for end > 2 && isDeviceAttr(parts[end-1]) {
+		end--
+	}
+
+	device := &Device{
+		Serial:   parts[0],
+		Status:   strings.Join(parts[1:end], " "),
+		LastSeen: time.Now(),
+	}
+	for _, part := range parts[end:] {
+		key, value, _ := strings.Cut(part, ":")
+		switch key {
+		case "model":
+			device.Model = value
+		case "product":
+			device.Product = value
+		case "device":
+			device.DeviceName = value
+		case "transport_id":
+			device.TransportID = value
+		case "usb":
+			device.USB = value
+		default:
+			if device.Extra == nil {
+				device.Extra = make(map[string]string)
+			}
+			device.Extra[key] = value
+		}
+	}
+	device.Transport = transportKindOf(device.Serial, device.USB)
+	return device, true
+}
+
+// isDeviceAttr 判断是否为 devices -l 中的 key:value 字段
+func isDeviceAttr(part string) bool {
+	key, value, ok := strings.Cut(part, ":")
+	if !ok || key == "" || value == "" {
+		return false
+	}
+	for _, r := range key {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// setIfPresent 仅在 value 非空时覆盖 dst
+func setIfPresent(dst *string, value string) {
+	if value != "" {
+		*dst = value
+	}
+}
+
+// update 用新解析的设备信息更新缓存中的设备，新信息中缺少的字段（例如不带 -l 的输出）保留原值
+// transport_id 变化说明是一次新的连接，网络设备可能已换成另一台手机，需要重新识别
+func (d *Device) update(src *Device) {
+	if src.TransportID != "" && d.TransportID != "" && src.TransportID != d.TransportID {
+		d.HardwareSerial = ""
+		d.Root = RootUnknown
+		d.Capabilities = nil
+	}
+
+	d.Status = src.Status
+	setIfPresent(&d.Model, src.Model)
+	setIfPresent(&d.Product, src.Product)
+	setIfPresent(&d.DeviceName, src.DeviceName)
+	setIfPresent(&d.TransportID, src.TransportID)
+	setIfPresent(&d.USB, src.USB)
+	if src.Extra != nil {
+		d.Extra = src.Extra
+	}
+	d.Transport = transportKindOf(d.Serial, d.USB)
+	d.LastSeen = time.Now()
+	d.FailedChecks = 0
+}
+
 // DiagnoseADB 诊断ADB版本和状态
 func (m *ADBManager) DiagnoseADB() (string, error) {
+	return m.DiagnoseADBContext(context.Background())
+}
+
+// DiagnoseADBContext 可通过 ctx 取消的 DiagnoseADB
+func (m *ADBManager) DiagnoseADBContext(ctx context.Context) (string, error) {
 	var result strings.Builder
 	
 	result.WriteString("=== ADB 诊断报告 ===\n\n")
 	
 	// 1. 获取客户端版本
-	cmd := exec.Command(m.adbPath, "version")
-	output, _ := cmd.CombinedOutput()
+	output, _ := m.run(ctx, "version")
 	result.WriteString("【客户端版本】\n")
-	result.WriteString(ensureUTF8(string(output)))
+	result.WriteString(EncodingAuto.Decode(output))
 	result.WriteString("\n")
 	
 	// 2. 获取服务器信息
-	cmd = exec.Command(m.adbPath, "shell", "getprop", "ro.build.version.release")
-	output, _ = cmd.CombinedOutput()
+	output, _ = m.run(ctx, "shell", "getprop", "ro.build.version.release")
 	result.WriteString("【服务器状态】\n")
 	if len(output) == 0 {
 		result.WriteString("ADB 服务器未正常运行\n")
@@ -250,269 +437,284 @@ func (m *ADBManager) DiagnoseADB() (string, error) {
 func (m *ADBManager) getDeviceList() []Device {
 	devices := make([]Device, 0, len(m.managedDevices))
 	for _, dev := range m.managedDevices {
-		devices = append(devices, Device{
-			Serial:   dev.Serial,
-			Status:   dev.Status,
-			Model:    dev.Model,
-			LastSeen: dev.LastSeen,
-		})
+		devices = append(devices, *dev)
 	}
+	// 按序列号排序，避免每次刷新列表顺序跳动
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Serial < devices[j].Serial })
 	return devices
 }
 
+// CachedDevices 返回缓存的设备列表，不查询 adb
+func (m *ADBManager) CachedDevices() []Device {
+	m.deviceCacheLock.RLock()
+	defer m.deviceCacheLock.RUnlock()
+	return m.getDeviceList()
+}
+
+// applyDeviceEvent 将设备监视器的事件写入缓存
+// 消失的设备标记为离线并保留在列表中，超时后由 ListDevices 清理或由用户手动移除
+func (m *ADBManager) applyDeviceEvent(event DeviceEvent) {
+	m.deviceCacheLock.Lock()
+	defer m.deviceCacheLock.Unlock()
+
+	serial := event.Device.Serial
+	if event.Type == DeviceDisconnected {
+		if dev, exists := m.managedDevices[serial]; exists {
+			dev.Status = "offline"
+			dev.Root = RootUnknown // adbd 重启后 root 状态可能变化
+			dev.Capabilities = nil
+			// 同一地址下次连上的可能是另一台设备
+			if dev.Transport == TransportTCP {
+				dev.HardwareSerial = ""
+			}
+		}
+		return
+	}
+
+	dev, exists := m.managedDevices[serial]
+	if !exists {
+		dev = &Device{Serial: serial}
+		m.managedDevices[serial] = dev
+	}
+	dev.update(&event.Device)
+}
+
 // Connect 连接到指定的设备（无线连接）
 func (m *ADBManager) Connect(address string) error {
-	fmt.Printf("[ADB] 尝试连接: %s\n", address)
-	cmd := exec.Command(m.adbPath, "connect", address)
-	output, err := cmd.CombinedOutput()
-	outputStr := ensureUTF8(string(output))
+	return m.ConnectContext(context.Background(), address)
+}
+
+// ConnectContext 可通过 ctx 取消的 Connect
+func (m *ADBManager) ConnectContext(ctx context.Context, address string) error {
+	m.log.Info("尝试连接", "device", address)
+	output, err := m.run(ctx, "connect", address)
+	outputStr := EncodingAuto.Decode(output)
 
 	if err != nil {
-		fmt.Printf("[ADB] 连接失败: %s - %v\n", address, err)
-		return fmt.Errorf("连接失败: %v, 输出: %s", err, outputStr)
+		m.log.Warn("连接失败", "device", address, "error", err)
+		return fmt.Errorf("连接失败: %w", err)
 	}
 
 	if !strings.Contains(outputStr, "connected") {
-		fmt.Printf("[ADB] 连接失败: %s - %s\n", address, outputStr)
+		m.log.Warn("连接失败", "device", address, "output", strings.TrimSpace(outputStr))
 		return fmt.Errorf("连接失败: %s", outputStr)
 	}
 
-	fmt.Printf("[ADB] 连接成功: %s\n", address)
+	m.log.Info("连接成功", "device", address)
 	return nil
 }
 
 // Disconnect 断开设备连接
 func (m *ADBManager) Disconnect(serial string) error {
-	cmd := exec.Command(m.adbPath, "disconnect", serial)
-	_, err := cmd.Output()
+	return m.DisconnectContext(context.Background(), serial)
+}
+
+// DisconnectContext 可通过 ctx 取消的 Disconnect
+func (m *ADBManager) DisconnectContext(ctx context.Context, serial string) error {
+	// 用户主动断开的设备不再自动重连
+	m.forgetSupervised(serial)
+	_, err := m.run(ctx, "disconnect", serial)
+	m.forgetFeatures(serial)
 	
 	// 断开连接后，也从管理的设备列表中移除该设备
 	if err == nil {
 		m.deviceCacheLock.Lock()
 		delete(m.managedDevices, serial)
 		m.deviceCacheLock.Unlock()
-		fmt.Printf("[ADB] 已断开并移除设备: %s\n", serial)
+		m.log.Info("已断开并移除设备", "device", serial)
 	}
 	
 	return err
 }
 
+// Reconnect 重新建立与设备的连接，用于恢复处于 offline 状态的设备
+func (m *ADBManager) Reconnect(serial string) error {
+	return m.ReconnectContext(context.Background(), serial)
+}
+
+// ReconnectContext 可通过 ctx 取消的 Reconnect
+func (m *ADBManager) ReconnectContext(ctx context.Context, serial string) error {
+	m.log.Info("尝试重连", "device", serial)
+	m.forgetFeatures(serial)
+	if _, err := m.run(ctx, deviceArgs(serial, "reconnect")...); err != nil {
+		m.log.Warn("重连失败", "device", serial, "error", err)
+		return fmt.Errorf("重连失败: %w", err)
+	}
+	return nil
+}
+
 // RemoveDevice 从管理列表中移除指定设备
 func (m *ADBManager) RemoveDevice(serial string) error {
+	m.forgetSupervised(serial)
+	m.forgetFeatures(serial)
+
 	m.deviceCacheLock.Lock()
 	defer m.deviceCacheLock.Unlock()
 	
 	if _, exists := m.managedDevices[serial]; exists {
 		delete(m.managedDevices, serial)
-		fmt.Printf("[ADB] 已移除设备: %s\n", serial)
+		m.log.Info("已移除设备", "device", serial)
 		return nil
 	}
 	
 	return fmt.Errorf("设备 %s 不存在", serial)
 }
 
-// ExecuteCommand 在指定设备上执行命令
+// ExecuteCommand 在指定设备上执行命令，返回 stdout 与 stderr 合并后的输出，用于展示
+// 需要解析输出或判断命令是否成功时使用 ExecuteShell
 func (m *ADBManager) ExecuteCommand(serial, command string) (string, error) {
-	// 如果启用了busybox，给命令加上前缀
-	wrappedCommand := m.wrapCommandWithBusybox(command)
-	
-	fmt.Printf("[ADB] 执行命令: %s -> %s\n", serial, wrappedCommand)
+	return m.ExecuteCommandContext(context.Background(), serial, command)
+}
 
-	var cmd *exec.Cmd
-	if serial != "" {
-		cmd = exec.Command(m.adbPath, "-s", serial, "shell", wrappedCommand)
-	} else {
-		cmd = exec.Command(m.adbPath, "shell", wrappedCommand)
-	}
+// ExecuteCommandContext 可通过 ctx 取消的 ExecuteCommand
+func (m *ADBManager) ExecuteCommandContext(ctx context.Context, serial, command string) (string, error) {
+	// 按设备的命令包装配置处理命令
+	return m.executeCommand(ctx, serial, m.wrapCommand(serial, command))
+}
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		outputStr := ensureUTF8(string(output))
-		
-		// 检查是否有版本冲突信息
-		if strings.Contains(outputStr, "doesn't match") {
-			fmt.Println("[ADB] ⚠️ 检测到版本冲突警告!")
-			fmt.Println("[ADB] ADB客户端版本与服务器版本不匹配")
-			fmt.Println("[ADB] 解决方案: 更新ADB到最新版本或统一版本")
-			return "", fmt.Errorf("ADB版本冲突: %v", err)
-		}
-		
-		// 检查错误输出是否包含设备相关错误
-		if strings.Contains(outputStr, "device not found") ||
-			strings.Contains(outputStr, "unauthorized") ||
-			strings.Contains(outputStr, "offline") {
-			fmt.Printf("[ADB] 设备错误: %s -> %s: %v\n", serial, command, err)
-			return outputStr, err
-		}
+// executeCommand 执行已处理好前缀的命令
+func (m *ADBManager) executeCommand(ctx context.Context, serial, command string) (string, error) {
+	m.log.Debug("执行命令", "device", serial, "command", command)
 
-		// 注意：不再自动重启 ADB 服务，因为单个命令失败不应该影响其他设备的连接
-		// 如果需要重启服务，应该由用户手动操作或通过专门的诊断程序进行
-		if strings.Contains(outputStr, "daemon") || strings.Contains(outputStr, "server") {
-			fmt.Printf("[ADB] 服务相关错误: %s -> %s: %v\n", serial, command, err)
-			fmt.Println("[ADB] 提示: 如果问题持续存在，请手动执行 'adb kill-server' 和 'adb start-server'")
+	output, err := m.run(ctx, deviceArgs(serial, "shell", command)...)
+	if err != nil {
+		outputStr := m.outputEncoding(serial).Decode(output)
+
+		switch {
+		case errors.Is(err, context.Canceled), errors.Is(err, ErrTimeout):
+			m.log.Info("命令中止", "device", serial, "command", command, "error", err)
+		case errors.Is(err, ErrServerVersionMismatch):
+			// 版本冲突时输出只有 adb 的警告，不返回给调用方
+			m.log.Warn("版本冲突", "device", serial, "command", command, "error", err)
+			return "", err
+		case errors.Is(err, ErrDeviceNotFound), errors.Is(err, ErrUnauthorized), errors.Is(err, ErrOffline):
+			m.log.Warn("设备错误", "device", serial, "command", command, "error", err)
+		default:
+			// 注意：不自动重启 ADB 服务，单个命令失败不应该影响其他设备的连接
+			m.log.Warn("命令失败", "device", serial, "command", command, "error", err)
 		}
-
-		fmt.Printf("[ADB] 命令失败: %s -> %s: %v\n", serial, command, err)
 		return outputStr, err
 	}
 
-	fmt.Printf("[ADB] 命令成功: %s\n", serial)
-	return ensureUTF8(string(output)), nil
-}
-
-// ensureUTF8 确保字符串是有效的 UTF-8，尝试从 GBK 转换
-func ensureUTF8(s string) string {
-	// 如果已经是有效的 UTF-8，直接返回
-	if utf8.ValidString(s) {
-		return s
-	}
-
-	// 尝试从 GBK 转换为 UTF-8
-	decoder := simplifiedchinese.GBK.NewDecoder()
-	utf8Bytes, _, err := transform.Bytes(decoder, []byte(s))
-	if err == nil && utf8.ValidString(string(utf8Bytes)) {
-		return string(utf8Bytes)
-	}
-
-	// 如果 GBK 转换失败，尝试 GB18030
-	decoder = simplifiedchinese.GB18030.NewDecoder()
-	utf8Bytes, _, err = transform.Bytes(decoder, []byte(s))
-	if err == nil && utf8.ValidString(string(utf8Bytes)) {
-		return string(utf8Bytes)
-	}
-
-	// 如果都失败，移除无效字符
-	v := make([]rune, 0, len(s))
-	for _, r := range s {
-		if r != utf8.RuneError {
-			v = append(v, r)
-		}
-	}
-	return string(v)
+	m.log.Debug("命令成功", "device", serial, "command", command)
+	return m.outputEncoding(serial).Decode(output), nil
 }
 
 // ExecuteCommandWithTimeout 执行命令带超时
 func (m *ADBManager) ExecuteCommandWithTimeout(serial, command string, timeout time.Duration) (string, error) {
-	var cmd *exec.Cmd
-	if serial != "" {
-		cmd = exec.Command(m.adbPath, "-s", serial, "shell", command)
-	} else {
-		cmd = exec.Command(m.adbPath, "shell", command)
-	}
+	return m.ExecuteCommandWithTimeoutContext(context.Background(), serial, command, timeout)
+}
 
-	var outBuf, errBuf bytes.Buffer
-	cmd.Stdout = &outBuf
-	cmd.Stderr = &errBuf
+// ExecuteCommandWithTimeoutContext 可通过 ctx 取消的 ExecuteCommandWithTimeout
+func (m *ADBManager) ExecuteCommandWithTimeoutContext(ctx context.Context, serial, command string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	if err := cmd.Start(); err != nil {
+	args := deviceArgs(serial, "shell", m.wrapCommand(serial, command))
+	release, err := m.acquire(ctx, args)
+	if err != nil {
 		return "", err
 	}
-
-	done := make(chan error)
-	go func() {
-		done <- cmd.Wait()
-	}()
-
-	select {
-	case <-time.After(timeout):
-		if err := cmd.Process.Kill(); err != nil {
-			return "", fmt.Errorf("命令超时且无法终止: %v", err)
-		}
-		return "", fmt.Errorf("命令执行超时")
-	case err := <-done:
-		if err != nil {
-			return errBuf.String(), err
-		}
-		return outBuf.String(), nil
+	start := time.Now()
+	stdout, stderr, err := m.runner.Output(ctx, args...)
+	release()
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		m.audit(args, start, -1, ctxErr)
+		return "", ctxErr
+	}
+	if err != nil {
+		cmdErr := newCommandError(args, stderr, err)
+		m.audit(args, start, cmdErr.ExitCode, cmdErr)
+		// 失败时与 ExecuteCommand 一致返回 stdout 与 stderr 合并后的输出，调用方常需要看到命令已输出的内容
+		return m.outputEncoding(serial).Decode(append(stdout, stderr...)), cmdErr
 	}
+	m.audit(args, start, 0, nil)
+	return m.outputEncoding(serial).Decode(stdout), nil
 }
 
 // PullFile 从设备拉取文件
 func (m *ADBManager) PullFile(serial, remotePath, localPath string) error {
-	var cmd *exec.Cmd
-	if serial != "" {
-		cmd = exec.Command(m.adbPath, "-s", serial, "pull", remotePath, localPath)
-	} else {
-		cmd = exec.Command(m.adbPath, "pull", remotePath, localPath)
-	}
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("拉取文件失败: %v, 输出: %s", err, ensureUTF8(string(output)))
-	}
+	return m.PullFileContext(context.Background(), serial, remotePath, localPath)
+}
 
-	return nil
+// PullFileContext 可通过 ctx 取消的 PullFile，需要进度时使用 PullFileWithProgressContext
+func (m *ADBManager) PullFileContext(ctx context.Context, serial, remotePath, localPath string) error {
+	return m.PullFileWithProgressContext(ctx, serial, remotePath, localPath, nil)
 }
 
 // PushFile 推送文件到设备
 func (m *ADBManager) PushFile(serial, localPath, remotePath string) error {
-	var cmd *exec.Cmd
-	if serial != "" {
-		cmd = exec.Command(m.adbPath, "-s", serial, "push", localPath, remotePath)
-	} else {
-		cmd = exec.Command(m.adbPath, "push", localPath, remotePath)
-	}
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("推送文件失败: %v, 输出: %s", err, ensureUTF8(string(output)))
-	}
+	return m.PushFileContext(context.Background(), serial, localPath, remotePath)
+}
 
-	return nil
+// PushFileContext 可通过 ctx 取消的 PushFile，需要进度时使用 PushFileWithProgressContext
+func (m *ADBManager) PushFileContext(ctx context.Context, serial, localPath, remotePath string) error {
+	return m.PushFileWithProgressContext(ctx, serial, localPath, remotePath, nil)
 }
 
-// Screenshot 截屏
+// Screenshot 截屏，最长等待 10 秒
 func (m *ADBManager) Screenshot(serial, localPath string) error {
-	// 使用通道实现超时控制
-	type result struct {
-		err error
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := m.ScreenshotContext(ctx, serial, localPath)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("截图超时（10秒），请检查设备连接")
 	}
-	ch := make(chan result, 1)
+	return err
+}
 
-	go func() {
-		// 在设备上截屏
-		remotePath := "/sdcard/screenshot.png"
-		_, err := m.ExecuteCommand(serial, fmt.Sprintf("screencap -p %s", remotePath))
-		if err != nil {
-			ch <- result{err: fmt.Errorf("截屏失败: %v", err)}
-			return
-		}
+// ScreenshotContext 可通过 ctx 取消的 Screenshot，取消时会终止正在执行的 adb 进程
+func (m *ADBManager) ScreenshotContext(ctx context.Context, serial, localPath string) error {
+	if caps := m.knownCapabilities(ctx, serial); caps != nil && !caps.Has("screencap") {
+		return fmt.Errorf("截屏失败: 设备上没有 screencap 命令")
+	}
 
-		// 拉取到本地
-		if err := m.PullFile(serial, remotePath, localPath); err != nil {
-			ch <- result{err: fmt.Errorf("拉取截屏失败: %v", err)}
-			return
-		}
+	// 在设备上截屏
+	remotePath := "/sdcard/screenshot.png"
+	_, err := m.ExecuteArgsContext(ctx, serial, "screencap", "-p", remotePath)
 
-		// 删除设备上的临时文件
-		m.ExecuteCommand(serial, fmt.Sprintf("rm %s", remotePath))
+	// 删除设备上的临时文件；已取消时在后台清理，不阻塞返回
+	cleanup := func() {
+		cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		m.ExecuteArgsContext(cleanupCtx, serial, "rm", "-f", remotePath)
+	}
 
-		ch <- result{err: nil}
-	}()
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		go cleanup()
+		return ctxErr
+	}
+	if err != nil {
+		return fmt.Errorf("截屏失败: %w", err)
+	}
 
-	// 等待10秒超时
-	select {
-	case res := <-ch:
-		return res.err
-	case <-time.After(10 * time.Second):
-		return fmt.Errorf("截图超时（10秒），请检查设备连接")
+	// 拉取到本地
+	if err := m.PullFileContext(ctx, serial, remotePath, localPath); err != nil {
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			go cleanup()
+			return ctxErr
+		}
+		cleanup()
+		return fmt.Errorf("拉取截屏失败: %w", err)
 	}
+
+	cleanup()
+	return nil
 }
 
 // InstallApp 安装应用
 func (m *ADBManager) InstallApp(serial, apkPath string) error {
-	var cmd *exec.Cmd
-	if serial != "" {
-		cmd = exec.Command(m.adbPath, "-s", serial, "install", "-r", apkPath)
-	} else {
-		cmd = exec.Command(m.adbPath, "install", "-r", apkPath)
-	}
+	return m.InstallAppContext(context.Background(), serial, apkPath)
+}
 
-	output, err := cmd.CombinedOutput()
-	outputStr := ensureUTF8(string(output))
+// InstallAppContext 可通过 ctx 取消的 InstallApp
+func (m *ADBManager) InstallAppContext(ctx context.Context, serial, apkPath string) error {
+	output, err := m.run(ctx, deviceArgs(serial, "install", "-r", apkPath)...)
+	outputStr := EncodingAuto.Decode(output)
 
 	if err != nil {
-		return fmt.Errorf("安装失败: %v, 输出: %s", err, outputStr)
+		return fmt.Errorf("安装失败: %w", err)
 	}
 
 	if !strings.Contains(outputStr, "Success") {
@@ -524,18 +726,16 @@ func (m *ADBManager) InstallApp(serial, apkPath string) error {
 
 // UninstallApp 卸载应用
 func (m *ADBManager) UninstallApp(serial, packageName string) error {
-	var cmd *exec.Cmd
-	if serial != "" {
-		cmd = exec.Command(m.adbPath, "-s", serial, "uninstall", packageName)
-	} else {
-		cmd = exec.Command(m.adbPath, "uninstall", packageName)
-	}
+	return m.UninstallAppContext(context.Background(), serial, packageName)
+}
 
-	output, err := cmd.CombinedOutput()
-	outputStr := ensureUTF8(string(output))
+// UninstallAppContext 可通过 ctx 取消的 UninstallApp
+func (m *ADBManager) UninstallAppContext(ctx context.Context, serial, packageName string) error {
+	output, err := m.run(ctx, deviceArgs(serial, "uninstall", packageName)...)
+	outputStr := EncodingAuto.Decode(output)
 
 	if err != nil {
-		return fmt.Errorf("卸载失败: %v, 输出: %s", err, outputStr)
+		return fmt.Errorf("卸载失败: %w", err)
 	}
 
 	if !strings.Contains(outputStr, "Success") {
@@ -547,11 +747,16 @@ func (m *ADBManager) UninstallApp(serial, packageName string) error {
 
 // StartApp 启动应用
 func (m *ADBManager) StartApp(serial, packageName string) error {
+	return m.StartAppContext(context.Background(), serial, packageName)
+}
+
+// StartAppContext 可通过 ctx 取消的 StartApp
+func (m *ADBManager) StartAppContext(ctx context.Context, serial, packageName string) error {
 	// 获取应用的启动 Activity
-	launchActivity, err := m.ExecuteCommand(serial,
-		fmt.Sprintf("cmd package resolve-activity --brief %s | tail -n 1", packageName))
+	launchActivity, err := m.ExecuteCommandContext(ctx, serial,
+		ShellJoin("cmd", "package", "resolve-activity", "--brief", packageName)+" | tail -n 1")
 	if err != nil {
-		return fmt.Errorf("获取启动 Activity 失败: %v", err)
+		return fmt.Errorf("获取启动 Activity 失败: %w", err)
 	}
 
 	launchActivity = strings.TrimSpace(launchActivity)
@@ -560,10 +765,9 @@ func (m *ADBManager) StartApp(serial, packageName string) error {
 	}
 
 	// 启动应用
-	_, err = m.ExecuteCommand(serial,
-		fmt.Sprintf("am start -n %s", launchActivity))
+	_, err = m.shellStdout(ctx, serial, ShellJoin("am", "start", "-n", launchActivity), false)
 	if err != nil {
-		return fmt.Errorf("启动应用失败: %v", err)
+		return fmt.Errorf("启动应用失败: %w", err)
 	}
 
 	return nil
@@ -571,16 +775,31 @@ func (m *ADBManager) StartApp(serial, packageName string) error {
 
 // StopApp 停止应用
 func (m *ADBManager) StopApp(serial, packageName string) error {
-	_, err := m.ExecuteCommand(serial, fmt.Sprintf("am force-stop %s", packageName))
+	return m.StopAppContext(context.Background(), serial, packageName)
+}
+
+// StopAppContext 可通过 ctx 取消的 StopApp
+func (m *ADBManager) StopAppContext(ctx context.Context, serial, packageName string) error {
+	_, err := m.shellStdout(ctx, serial, ShellJoin("am", "force-stop", packageName), false)
 	if err != nil {
-		return fmt.Errorf("停止应用失败: %v", err)
+		return fmt.Errorf("停止应用失败: %w", err)
 	}
 	return nil
 }
 
 // ListPackages 列出所有已安装的应用包
 func (m *ADBManager) ListPackages(serial string) ([]string, error) {
-	output, err := m.ExecuteCommand(serial, "pm list packages")
+	return m.ListPackagesContext(context.Background(), serial)
+}
+
+// ListPackagesContext 可通过 ctx 取消的 ListPackages
+func (m *ADBManager) ListPackagesContext(ctx context.Context, serial string) ([]string, error) {
+	// Android 7 起 cmd package 直接调用系统服务，不必像 pm 那样启动 Java 虚拟机
+	command := "pm list packages"
+	if caps := m.knownCapabilities(ctx, serial); caps != nil && caps.SDK >= 24 && caps.Has("cmd") {
+		command = "cmd package list packages"
+	}
+	output, err := m.shellStdout(ctx, serial, command, false)
 	if err != nil {
 		return nil, err
 	}
@@ -601,178 +820,159 @@ func (m *ADBManager) ListPackages(serial string) ([]string, error) {
 
 // GetDeviceInfo 获取设备信息
 func (m *ADBManager) GetDeviceInfo(serial string) (map[string]string, error) {
+	return m.GetDeviceInfoContext(context.Background(), serial)
+}
+
+// GetDeviceInfoContext 可通过 ctx 取消的 GetDeviceInfo
+func (m *ADBManager) GetDeviceInfoContext(ctx context.Context, serial string) (map[string]string, error) {
 	info := make(map[string]string)
+	caps := m.knownCapabilities(ctx, serial)
 
 	// 获取设备型号
-	if model, err := m.ExecuteCommand(serial, "getprop ro.product.model"); err == nil {
+	if model, err := m.shellStdout(ctx, serial, "getprop ro.product.model", false); err == nil {
 		info["model"] = strings.TrimSpace(model)
 	}
 
 	// 获取 Android 版本
-	if version, err := m.ExecuteCommand(serial, "getprop ro.build.version.release"); err == nil {
+	if version, err := m.shellStdout(ctx, serial, "getprop ro.build.version.release", false); err == nil {
 		info["android_version"] = strings.TrimSpace(version)
 	}
 
 	// 获取 SDK 版本
-	if sdk, err := m.ExecuteCommand(serial, "getprop ro.build.version.sdk"); err == nil {
+	if caps != nil && caps.SDK > 0 {
+		info["sdk_version"] = strconv.Itoa(caps.SDK)
+	} else if sdk, err := m.shellStdout(ctx, serial, "getprop ro.build.version.sdk", false); err == nil {
 		info["sdk_version"] = strings.TrimSpace(sdk)
 	}
 
 	// 获取设备制造商
-	if manufacturer, err := m.ExecuteCommand(serial, "getprop ro.product.manufacturer"); err == nil {
+	if manufacturer, err := m.shellStdout(ctx, serial, "getprop ro.product.manufacturer", false); err == nil {
 		info["manufacturer"] = strings.TrimSpace(manufacturer)
 	}
 
 	// 获取设备品牌
-	if brand, err := m.ExecuteCommand(serial, "getprop ro.product.brand"); err == nil {
+	if brand, err := m.shellStdout(ctx, serial, "getprop ro.product.brand", false); err == nil {
 		info["brand"] = strings.TrimSpace(brand)
 	}
 
 	// 获取 CPU 架构
-	if abi, err := m.ExecuteCommand(serial, "getprop ro.product.cpu.abi"); err == nil {
+	if caps != nil && caps.ABI != "" {
+		info["cpu_abi"] = caps.ABI
+	} else if abi, err := m.shellStdout(ctx, serial, "getprop ro.product.cpu.abi", false); err == nil {
 		info["cpu_abi"] = strings.TrimSpace(abi)
 	}
 
 	// 获取 IP 地址
-	if ip, err := m.ExecuteCommand(serial, "ip -f inet addr show wlan0 | grep inet | awk '{print $2}' | cut -d/ -f1"); err == nil {
-		info["ip_address"] = strings.TrimSpace(ip)
+	iface := m.wifiInterface(ctx, serial)
+	if ip := m.wifiAddress(ctx, serial, iface, caps); ip != "" {
+		info["ip_address"] = ip
 	}
 
 	// 获取 MAC 地址
-	if mac, err := m.ExecuteCommand(serial, "cat /sys/class/net/wlan0/address"); err == nil {
+	if mac, err := m.shellStdout(ctx, serial, ShellJoin("cat", "/sys/class/net/"+iface+"/address"), false); err == nil {
 		info["mac_address"] = strings.TrimSpace(mac)
 	}
 
 	return info, nil
 }
 
-// ListFiles 列出目录下的文件
-func (m *ADBManager) ListFiles(serial, path string) ([]FileInfo, error) {
-	output, err := m.ExecuteCommand(serial, fmt.Sprintf("ls -la %s", path))
-	if err != nil {
-		return nil, err
-	}
-
-	lines := strings.Split(output, "\n")
-	files := make([]FileInfo, 0)
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "total") {
-			continue
-		}
-
-		fields := strings.Fields(line)
-		if len(fields) < 8 {
-			continue
-		}
-
-		fileInfo := FileInfo{
-			Permissions: fields[0],
-			Owner:       fields[1],
-			Group:       fields[2],
-			Size:        fields[3],
-			Date:        strings.Join(fields[4:7], " "),
-			Name:        strings.Join(fields[7:], " "),
-		}
-
-		files = append(files, fileInfo)
-	}
-
-	return files, nil
-}
-
-// FileInfo 文件信息
-type FileInfo struct {
-	Name        string
-	Permissions string
-	Owner       string
-	Group       string
-	Size        string
-	Date        string
-}
-
 // DeleteFile 删除文件
 func (m *ADBManager) DeleteFile(serial, path string) error {
-	_, err := m.ExecuteCommand(serial, fmt.Sprintf("rm -rf %s", path))
+	return m.DeleteFileContext(context.Background(), serial, path)
+}
+
+// DeleteFileContext 可通过 ctx 取消的 DeleteFile
+func (m *ADBManager) DeleteFileContext(ctx context.Context, serial, path string) error {
+	if path == "" || path == "/" {
+		return fmt.Errorf("拒绝删除路径: %q", path)
+	}
+	_, err := m.shellStdout(ctx, serial, ShellJoin("rm", "-rf", "--", path), false)
 	return err
 }
 
 // RenameFile 重命名文件
 func (m *ADBManager) RenameFile(serial, oldPath, newPath string) error {
-	_, err := m.ExecuteCommand(serial, fmt.Sprintf("mv %s %s", oldPath, newPath))
+	return m.RenameFileContext(context.Background(), serial, oldPath, newPath)
+}
+
+// RenameFileContext 可通过 ctx 取消的 RenameFile
+func (m *ADBManager) RenameFileContext(ctx context.Context, serial, oldPath, newPath string) error {
+	_, err := m.shellStdout(ctx, serial, ShellJoin("mv", oldPath, newPath), false)
 	return err
 }
 
 // ChangePermissions 修改文件权限
 func (m *ADBManager) ChangePermissions(serial, path, permissions string) error {
-	_, err := m.ExecuteCommand(serial, fmt.Sprintf("chmod %s %s", permissions, path))
+	return m.ChangePermissionsContext(context.Background(), serial, path, permissions)
+}
+
+// ChangePermissionsContext 可通过 ctx 取消的 ChangePermissions
+func (m *ADBManager) ChangePermissionsContext(ctx context.Context, serial, path, permissions string) error {
+	_, err := m.shellStdout(ctx, serial, ShellJoin("chmod", permissions, path), false)
 	return err
 }
 
-// TryEnableRoot 尝试获取 root 权限
+// TryEnableRoot 通过 adb root 以 root 身份重启 adbd，设备会断开并重新连接
+// 仅在用户明确要求时调用，检查 root 状态使用 DetectRootMethod
 func (m *ADBManager) TryEnableRoot(serial string) error {
-	var cmd *exec.Cmd
-	if serial != "" {
-		cmd = exec.Command(m.adbPath, "-s", serial, "root")
-	} else {
-		cmd = exec.Command(m.adbPath, "root")
-	}
+	return m.TryEnableRootContext(context.Background(), serial)
+}
 
-	output, err := cmd.CombinedOutput()
+// TryEnableRootContext 可通过 ctx 取消的 TryEnableRoot
+func (m *ADBManager) TryEnableRootContext(ctx context.Context, serial string) error {
+	_, err := m.run(ctx, deviceArgs(serial, "root")...)
+	m.setRootMethod(serial, RootUnknown)
 	if err != nil {
-		return fmt.Errorf("启用 root 失败: %v, 输出: %s", err, ensureUTF8(string(output)))
+		return fmt.Errorf("启用 root 失败: %w", err)
 	}
 
 	return nil
 }
 
-// ExecuteAsRoot 以 root 权限执行命令
+// ExecuteAsRoot 以设备探测到的 root 方式执行命令，没有 root 时返回 ErrNoRoot，不会以普通权限重试
 func (m *ADBManager) ExecuteAsRoot(serial, command string) (string, error) {
-	// 尝试使用 su 执行命令
-	rootCmd := fmt.Sprintf("su -c \"%s\"", command)
-	output, err := m.ExecuteCommand(serial, rootCmd)
+	return m.ExecuteAsRootContext(context.Background(), serial, command)
+}
 
-	// 如果 su 失败，尝试 adb root
+// ExecuteAsRootContext 可通过 ctx 取消的 ExecuteAsRoot
+func (m *ADBManager) ExecuteAsRootContext(ctx context.Context, serial, command string) (string, error) {
+	rootCmd, err := m.rootCommand(ctx, serial, command)
 	if err != nil {
-		// 尝试 adb root
-		if rootErr := m.TryEnableRoot(serial); rootErr == nil {
-			// root 成功后重新执行
-			return m.ExecuteCommand(serial, command)
-		}
+		return "", err
 	}
-
-	return output, err
+	return m.executeCommand(ctx, serial, rootCmd)
 }
 
-// CheckRootAccess 检查是否有 root 权限
+// CheckRootAccess 检查是否有 root 权限，只探测一次并缓存结果，不会调用 adb root
 func (m *ADBManager) CheckRootAccess(serial string) bool {
-	output, err := m.ExecuteCommand(serial, "su -c 'id'")
-	if err != nil {
-		// 如果 su 失败，尝试 adb root
-		if m.TryEnableRoot(serial) == nil {
-			output, err = m.ExecuteCommand(serial, "id")
-		}
-	}
-
-	if err != nil {
-		return false
-	}
+	return m.CheckRootAccessContext(context.Background(), serial)
+}
 
-	// 检查输出是否包含 uid=0 (root)
-	return strings.Contains(output, "uid=0")
+// CheckRootAccessContext 可通过 ctx 取消的 CheckRootAccess
+func (m *ADBManager) CheckRootAccessContext(ctx context.Context, serial string) bool {
+	method, err := m.DetectRootMethodContext(ctx, serial)
+	return err == nil && method.HasRoot()
 }
 
 // GetProcessList 获取进程列表
 func (m *ADBManager) GetProcessList(serial string) (string, error) {
-	// 尝试不同的 ps 命令参数（兼容不同 Android 版本）
-	output, err := m.ExecuteCommand(serial, "ps -A")
+	return m.GetProcessListContext(context.Background(), serial)
+}
+
+// GetProcessListContext 可通过 ctx 取消的 GetProcessList
+func (m *ADBManager) GetProcessListContext(ctx context.Context, serial string) (string, error) {
+	if caps := m.knownCapabilities(ctx, serial); caps != nil {
+		return m.shellStdout(ctx, serial, caps.psCommand(), false)
+	}
+
+	// 无法探测设备能力时，尝试不同的 ps 命令参数（兼容不同 Android 版本）
+	output, err := m.shellStdout(ctx, serial, "ps -A", false)
 	if err != nil {
 		// 如果 -A 失败，尝试不带参数
-		output, err = m.ExecuteCommand(serial, "ps")
+		output, err = m.shellStdout(ctx, serial, "ps", false)
 		if err != nil {
 			// 如果还失败，尝试 -ef
-			output, err = m.ExecuteCommand(serial, "ps -ef")
+			output, err = m.shellStdout(ctx, serial, "ps -ef", false)
 		}
 	}
 	return output, err
@@ -780,49 +980,116 @@ func (m *ADBManager) GetProcessList(serial string) (string, error) {
 
 // GetNetworkConnections 获取网络连接信息
 func (m *ADBManager) GetNetworkConnections(serial string) (string, error) {
-	return m.ExecuteCommand(serial, "netstat -anp")
+	return m.GetNetworkConnectionsContext(context.Background(), serial)
 }
 
-// InteractiveShell 创建交互式 shell
-func (m *ADBManager) InteractiveShell(serial string) (*exec.Cmd, error) {
-	var cmd *exec.Cmd
-	if serial != "" {
-		cmd = exec.Command(m.adbPath, "-s", serial, "shell")
-	} else {
-		cmd = exec.Command(m.adbPath, "shell")
+// GetNetworkConnectionsContext 可通过 ctx 取消的 GetNetworkConnections
+// 优先使用 netstat，其次 ss，都没有时直接读取 /proc/net 下的连接表
+func (m *ADBManager) GetNetworkConnectionsContext(ctx context.Context, serial string) (string, error) {
+	caps := m.knownCapabilities(ctx, serial)
+	switch {
+	case caps == nil || caps.Has("netstat"):
+		return m.ExecuteCommandContext(ctx, serial, "netstat -anp")
+	case caps.Has("ss"):
+		return m.ExecuteCommandContext(ctx, serial, "ss -anp")
 	}
-
-	return cmd, nil
+	return m.ExecuteCommandContext(ctx, serial, "cat /proc/net/tcp /proc/net/tcp6 /proc/net/udp /proc/net/udp6 2>/dev/null")
 }
 
-// ExecuteCommandStream 执行命令并返回输出流
-func (m *ADBManager) ExecuteCommandStream(serial, command string) (string, error) {
-	var cmd *exec.Cmd
-	if serial != "" {
-		cmd = exec.Command(m.adbPath, "-s", serial, "shell", command)
-	} else {
-		cmd = exec.Command(m.adbPath, "shell", command)
+// wifiInterface 返回设备 WiFi 网卡的名称
+// WiFi 网卡不一定叫 wlan0，优先使用系统属性中的名称
+func (m *ADBManager) wifiInterface(ctx context.Context, serial string) string {
+	if name, err := m.shellStdout(ctx, serial, "getprop wifi.interface", false); err == nil && strings.TrimSpace(name) != "" {
+		return strings.TrimSpace(name)
 	}
+	return "wlan0"
+}
 
-	stdout, err := cmd.StdoutPipe()
+// wifiAddress 返回网卡 iface 的 IPv4 地址，没有 ip 命令的设备使用 ifconfig，获取失败时为空
+func (m *ADBManager) wifiAddress(ctx context.Context, serial, iface string, caps *Capabilities) string {
+	addrCmd := ShellJoin("ifconfig", iface)
+	if caps == nil || caps.Has("ip") {
+		addrCmd = ShellJoin("ip", "-f", "inet", "addr", "show", iface)
+	}
+	output, err := m.shellStdout(ctx, serial, addrCmd, false)
 	if err != nil {
-		return "", err
+		return ""
 	}
+	return parseInetAddr(output)
+}
 
-	if err := cmd.Start(); err != nil {
-		return "", err
+// parseInetAddr 从 ip addr 或 ifconfig 的输出中取出第一个 IPv4 地址
+func parseInetAddr(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		for i := 0; i+1 < len(fields); i++ {
+			if fields[i] != "inet" {
+				continue
+			}
+			// ip: inet 192.168.1.2/24；ifconfig: inet addr:192.168.1.2 或 inet 192.168.1.2
+			addr := strings.TrimPrefix(fields[i+1], "addr:")
+			addr, _, _ = strings.Cut(addr, "/")
+			return addr
+		}
 	}
+	return ""
+}
 
-	var output strings.Builder
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
-		output.WriteString(scanner.Text())
-		output.WriteString("\n")
+// InteractiveShell 创建交互式 shell
+func (m *ADBManager) InteractiveShell(serial string) (*exec.Cmd, error) {
+	return m.InteractiveShellContext(context.Background(), serial)
+}
+
+// InteractiveShellContext 创建交互式 shell，ctx 取消时结束 adb 进程
+func (m *ADBManager) InteractiveShellContext(ctx context.Context, serial string) (*exec.Cmd, error) {
+	// 交互式 shell 需要直接持有 adb 进程，仅本地 adb 执行器支持
+	adbPath, ok := execPath(m.runner)
+	if !ok || m.router.IsDirect(serial) {
+		return nil, fmt.Errorf("当前命令执行器不支持交互式 shell")
 	}
 
-	if err := cmd.Wait(); err != nil {
-		return output.String(), err
+	// 交互式 shell 由调用方运行，审计日志只记录其创建，得不到退出码
+	args := deviceArgs(serial, "shell")
+	m.audit(args, time.Now(), -1, nil)
+	return exec.CommandContext(ctx, adbPath, args...), nil
+}
+
+// execPath 返回执行器所使用的本地 adb 可执行文件路径
+func execPath(runner CommandRunner) (string, bool) {
+	switch r := runner.(type) {
+	case *ExecRunner:
+		return r.Path, true
+	case *ServerRunner:
+		if r.Fallback != nil {
+			return execPath(r.Fallback)
+		}
+	case *DeviceRouter:
+		return execPath(r.Default)
 	}
+	return "", false
+}
 
+// ExecuteCommandStream 执行命令并返回全部输出（stdout 与 stderr 按到达顺序合并）
+// 只保留最后 DefaultStreamRetain 字节，需要边执行边处理输出时使用 StreamCommand
+func (m *ADBManager) ExecuteCommandStream(serial, command string) (string, error) {
+	return m.ExecuteCommandStreamContext(context.Background(), serial, command)
+}
+
+// ExecuteCommandStreamContext 可通过 ctx 取消的 ExecuteCommandStream
+func (m *ADBManager) ExecuteCommandStreamContext(ctx context.Context, serial, command string) (string, error) {
+	output := &tailBuffer{max: DefaultStreamRetain}
+	code, err := m.StreamCommandContext(ctx, serial, command, func(line StreamLine) {
+		output.add(line.Text)
+	})
+	if err != nil {
+		return output.String(), err
+	}
+	if code != 0 {
+		return output.String(), &CommandError{
+			Args:     deviceArgs(serial, "shell", command),
+			ExitCode: code,
+			Err:      &ShellExitError{Code: code},
+		}
+	}
 	return output.String(), nil
 }
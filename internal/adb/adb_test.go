@@ -0,0 +1,192 @@
+package adb
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newTestManager 使用指定执行器创建不输出日志的 ADB 管理器
+func newTestManager(runner CommandRunner) *ADBManager {
+	m := NewADBManagerWithRunner(runner)
+	m.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	return m
+}
+
+// auditRecorder 在内存中保存审计事件的 Auditor
+type auditRecorder struct {
+	mu     sync.Mutex
+	events []AuditEvent
+}
+
+func (r *auditRecorder) Record(event AuditEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// Events 返回已记录的事件
+func (r *auditRecorder) Events() []AuditEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]AuditEvent(nil), r.events...)
+}
+
+func TestParseDeviceLine(t *testing.T) {
+	tests := []struct {
+		line string
+		ok   bool
+		want Device
+	}{
+		{
+			line: "emulator-5554          device product:sdk_gphone64_x86_64 model:sdk_gphone64_x86_64 device:emu64xa transport_id:1",
+			ok:   true,
+			want: Device{Serial: "emulator-5554", Status: "device", Product: "sdk_gphone64_x86_64", Model: "sdk_gphone64_x86_64", DeviceName: "emu64xa", TransportID: "1", Transport: TransportEmulator},
+		},
+		{
+			line: "R58M123ABC             device usb:1-1.2 product:beyond1qltezc model:SM_G9730 device:beyond1q transport_id:3",
+			ok:   true,
+			want: Device{Serial: "R58M123ABC", Status: "device", USB: "1-1.2", Product: "beyond1qltezc", Model: "SM_G9730", DeviceName: "beyond1q", TransportID: "3", Transport: TransportUSB},
+		},
+		{
+			line: "192.168.1.20:5555      offline transport_id:7",
+			ok:   true,
+			want: Device{Serial: "192.168.1.20:5555", Status: "offline", TransportID: "7", Transport: TransportTCP},
+		},
+		{
+			line: "0123456789ABCDEF       unauthorized usb:1-4 transport_id:2",
+			ok:   true,
+			want: Device{Serial: "0123456789ABCDEF", Status: "unauthorized", USB: "1-4", TransportID: "2", Transport: TransportUSB},
+		},
+		{
+			line: "0123456789ABCDEF       no permissions (missing udev rules? user is in the plugdev group); see [http://developer.android.com/tools/device.html] usb:1-4",
+			ok:   true,
+			want: Device{Serial: "0123456789ABCDEF", Status: "no permissions (missing udev rules? user is in the plugdev group); see [http://developer.android.com/tools/device.html]", USB: "1-4", Transport: TransportUSB},
+		},
+		{
+			line: "box-01                 device product:iot model:Box vendor_flag:x1",
+			ok:   true,
+			want: Device{Serial: "box-01", Status: "device", Product: "iot", Model: "Box", Extra: map[string]string{"vendor_flag": "x1"}, Transport: TransportUSB},
+		},
+		{line: "* daemon not running; starting now at tcp:5037", ok: false},
+		{line: "* daemon started successfully", ok: false},
+		{line: "adb server [server]", ok: false},
+		{line: "List", ok: false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseDeviceLine(tt.line)
+		if ok != tt.ok {
+			t.Errorf("parseDeviceLine(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		got.LastSeen = tt.want.LastSeen
+		if !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("parseDeviceLine(%q)\n got  %+v\n want %+v", tt.line, *got, tt.want)
+		}
+	}
+}
+
+func TestListDevices(t *testing.T) {
+	runner := NewScriptedRunner().On("devices -l", ScriptedResponse{Stdout: "* daemon not running; starting now at tcp:5037\n" +
+		"* daemon started successfully\n" +
+		"List of devices attached\n" +
+		"emulator-5554          device product:sdk model:Pixel_7 device:emu transport_id:1\n" +
+		"192.168.1.20:5555      offline transport_id:2\n" +
+		"\n"})
+	m := newTestManager(runner)
+
+	devices, err := m.ListDevices()
+	if err != nil {
+		t.Fatalf("ListDevices: %v", err)
+	}
+	got := make(map[string]string)
+	for _, d := range devices {
+		got[d.Serial] = d.Status + "/" + d.Model
+	}
+	want := map[string]string{"emulator-5554": "device/Pixel_7", "192.168.1.20:5555": "offline/"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("devices = %v, want %v", got, want)
+	}
+}
+
+func TestListDevicesVersionMismatch(t *testing.T) {
+	runner := NewScriptedRunner().On("devices -l",
+		ScriptedResponse{Stdout: "List of devices attached\nemulator-5554 device model:Pixel_7\n"},
+		ScriptedResponse{Stdout: "adb server version (41) doesn't match this client (39); killing...\n" +
+			"* daemon started successfully\n" +
+			"List of devices attached\n"},
+	)
+	m := newTestManager(runner)
+
+	if _, err := m.ListDevices(); err != nil {
+		t.Fatalf("first ListDevices: %v", err)
+	}
+	devices, err := m.ListDevices()
+	if !errors.Is(err, ErrServerVersionMismatch) {
+		t.Fatalf("err = %v, want ErrServerVersionMismatch", err)
+	}
+	// 版本冲突时保留缓存的设备列表
+	if len(devices) != 1 || devices[0].Serial != "emulator-5554" {
+		t.Fatalf("devices = %+v, want cached emulator-5554", devices)
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr string
+		code   int
+		want   error
+	}{
+		{"not found", "adb: error: device 'x' not found\n", 1, ErrDeviceNotFound},
+		{"unauthorized", "error: device unauthorized.\nThis adb server's $ADB_VENDOR_KEYS is not set\n", 1, ErrUnauthorized},
+		{"offline", "error: device offline\n", 1, ErrOffline},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := NewScriptedRunner().On("-s x shell getprop", ScriptedResponse{Stderr: tt.stderr, ExitCode: tt.code})
+			m := newTestManager(runner)
+
+			_, err := m.ExecuteCommand("x", "getprop")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			var cmdErr *CommandError
+			if !errors.As(err, &cmdErr) || cmdErr.ExitCode != tt.code {
+				t.Fatalf("err = %#v, want *CommandError with exit code %d", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestDiagnoseADBAudited(t *testing.T) {
+	runner := NewScriptedRunner().
+		On("version", ScriptedResponse{Stdout: "Android Debug Bridge version 1.0.41\n"}).
+		On("shell getprop ro.build.version.release", ScriptedResponse{Stdout: "14\n"})
+	auditor := &auditRecorder{}
+	m := newTestManager(runner)
+	m.SetAuditor(auditor)
+
+	report, err := m.DiagnoseADB()
+	if err != nil {
+		t.Fatalf("DiagnoseADB: %v", err)
+	}
+	if !strings.Contains(report, "Android Debug Bridge version 1.0.41") || !strings.Contains(report, "ADB 服务器正常运行") {
+		t.Fatalf("report = %q", report)
+	}
+
+	// version 属于主机查询，不记入审计日志；getprop 在设备上执行，需要记录
+	events := auditor.Events()
+	if len(events) != 1 || !reflect.DeepEqual(events[0].Argv, []string{"shell", "getprop", "ro.build.version.release"}) {
+		t.Fatalf("audit events = %+v, want the getprop command", events)
+	}
+}
@@ -0,0 +1,458 @@
+package adb
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// adbd 传输协议的消息类型
+const (
+	cmdCNXN = 0x4e584e43
+	cmdAUTH = 0x48545541
+	cmdOPEN = 0x4e45504f
+	cmdOKAY = 0x59414b4f
+	cmdCLSE = 0x45534c43
+	cmdWRTE = 0x45545257
+)
+
+// AUTH 消息的 arg0
+const (
+	authToken        = 1
+	authSignature    = 2
+	authRSAPublicKey = 3
+)
+
+const (
+	adbdVersion = 0x01000000
+	adbdMaxData = 256 * 1024
+)
+
+// ErrAuthRejected 设备拒绝了密钥（需要在设备上确认授权），errors.Is(err, ErrUnauthorized) 成立
+var ErrAuthRejected = fmt.Errorf("设备拒绝了 RSA 密钥认证: %w", ErrUnauthorized)
+
+// adbdMessage adbd 传输协议消息，头部为 6 个小端 uint32
+type adbdMessage struct {
+	command uint32
+	arg0    uint32
+	arg1    uint32
+	data    []byte
+}
+
+// writeMessage 写出一条消息
+func writeMessage(w io.Writer, msg adbdMessage) error {
+	var checksum uint32
+	for _, b := range msg.data {
+		checksum += uint32(b)
+	}
+
+	packet := make([]byte, 24, 24+len(msg.data))
+	binary.LittleEndian.PutUint32(packet[0:], msg.command)
+	binary.LittleEndian.PutUint32(packet[4:], msg.arg0)
+	binary.LittleEndian.PutUint32(packet[8:], msg.arg1)
+	binary.LittleEndian.PutUint32(packet[12:], uint32(len(msg.data)))
+	binary.LittleEndian.PutUint32(packet[16:], checksum)
+	binary.LittleEndian.PutUint32(packet[20:], msg.command^0xffffffff)
+	_, err := w.Write(append(packet, msg.data...))
+	return err
+}
+
+// readMessage 读取一条消息
+func readMessage(r io.Reader) (adbdMessage, error) {
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return adbdMessage{}, err
+	}
+
+	msg := adbdMessage{
+		command: binary.LittleEndian.Uint32(header[0:]),
+		arg0:    binary.LittleEndian.Uint32(header[4:]),
+		arg1:    binary.LittleEndian.Uint32(header[8:]),
+	}
+	if binary.LittleEndian.Uint32(header[20:]) != msg.command^0xffffffff {
+		return adbdMessage{}, fmt.Errorf("adbd 消息校验失败")
+	}
+
+	length := binary.LittleEndian.Uint32(header[12:])
+	if length > 1024*1024 {
+		return adbdMessage{}, fmt.Errorf("adbd 消息过长: %d", length)
+	}
+	msg.data = make([]byte, length)
+	if _, err := io.ReadFull(r, msg.data); err != nil {
+		return adbdMessage{}, err
+	}
+	return msg, nil
+}
+
+// AdbdConn 与 adbd 之间的传输连接，在一条 TCP 连接上复用多个服务流
+type AdbdConn struct {
+	conn    net.Conn
+	banner  string
+	maxData uint32
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	streams map[uint32]*adbdStream
+	nextID  uint32
+	done    chan struct{}
+	err     error
+
+	// onOpen 设备端使用：处理对端打开的服务，返回 nil 表示拒绝
+	onOpen func(service string) func(io.ReadWriteCloser)
+}
+
+// DialAdbd 直接连接设备上的 adbd 并完成 RSA 认证
+// 设备尚未信任该密钥时会发送公钥，需在设备上确认，ctx 决定最长等待时间
+func DialAdbd(ctx context.Context, address string, key *rsa.PrivateKey) (*AdbdConn, error) {
+	dialer := net.Dialer{Timeout: 5 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("连接 adbd 失败: %w", err)
+	}
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	banner, maxData, err := hostHandshake(conn, key)
+	if !stop() || err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("adbd 握手超时: %w", ctx.Err())
+		}
+		return nil, err
+	}
+
+	c := newAdbdConn(conn, banner, maxData)
+	go c.readLoop()
+	return c, nil
+}
+
+// hostHandshake 主机端握手：CNXN -> AUTH(签名) -> [AUTH(公钥)] -> CNXN
+func hostHandshake(conn net.Conn, key *rsa.PrivateKey) (string, uint32, error) {
+	err := writeMessage(conn, adbdMessage{command: cmdCNXN, arg0: adbdVersion, arg1: adbdMaxData, data: []byte("host::\x00")})
+	if err != nil {
+		return "", 0, err
+	}
+
+	signed, sentKey := false, false
+	for {
+		msg, err := readMessage(conn)
+		if sentKey && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)) {
+			// 发送公钥后设备关闭连接，说明在设备上拒绝了授权
+			return "", 0, ErrAuthRejected
+		}
+		if err != nil {
+			return "", 0, fmt.Errorf("adbd 握手失败: %w", err)
+		}
+
+		switch msg.command {
+		case cmdCNXN:
+			maxData := min(msg.arg1, uint32(adbdMaxData))
+			return string(bytes.TrimRight(msg.data, "\x00")), maxData, nil
+
+		case cmdAUTH:
+			if msg.arg0 != authToken {
+				return "", 0, fmt.Errorf("adbd 返回未知的认证类型: %d", msg.arg0)
+			}
+			if key == nil {
+				return "", 0, ErrAuthRejected
+			}
+			switch {
+			case !signed:
+				signature, err := signToken(key, msg.data)
+				if err != nil {
+					return "", 0, fmt.Errorf("签名失败: %w", err)
+				}
+				signed = true
+				err = writeMessage(conn, adbdMessage{command: cmdAUTH, arg0: authSignature, data: signature})
+				if err != nil {
+					return "", 0, err
+				}
+			case !sentKey:
+				// 签名未被接受，发送公钥等待用户在设备上确认
+				pub, err := AndroidPublicKey(&key.PublicKey, keyComment())
+				if err != nil {
+					return "", 0, err
+				}
+				sentKey = true
+				err = writeMessage(conn, adbdMessage{command: cmdAUTH, arg0: authRSAPublicKey, data: append([]byte(pub), 0)})
+				if err != nil {
+					return "", 0, err
+				}
+			default:
+				return "", 0, ErrAuthRejected
+			}
+
+		default:
+			return "", 0, fmt.Errorf("adbd 握手收到意外消息: %08x", msg.command)
+		}
+	}
+}
+
+func newAdbdConn(conn net.Conn, banner string, maxData uint32) *AdbdConn {
+	return &AdbdConn{
+		conn:    conn,
+		banner:  banner,
+		maxData: maxData,
+		streams: make(map[uint32]*adbdStream),
+		done:    make(chan struct{}),
+	}
+}
+
+// Banner 返回设备在 CNXN 中的标识，例如 "device::ro.product.name=x;ro.product.model=y;..."
+func (c *AdbdConn) Banner() string {
+	return c.banner
+}
+
+// Close 关闭连接及所有服务流
+func (c *AdbdConn) Close() error {
+	return c.conn.Close()
+}
+
+// Done 连接断开时关闭
+func (c *AdbdConn) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *AdbdConn) send(msg adbdMessage) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return writeMessage(c.conn, msg)
+}
+
+// Open 打开设备服务，例如 "shell:ls"、"sync:"
+func (c *AdbdConn) Open(ctx context.Context, service string) (io.ReadWriteCloser, error) {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, c.err
+	}
+	c.nextID++
+	stream := newAdbdStream(c, c.nextID)
+	c.streams[stream.localID] = stream
+	c.mu.Unlock()
+
+	if err := c.send(adbdMessage{command: cmdOPEN, arg0: stream.localID, data: append([]byte(service), 0)}); err != nil {
+		c.removeStream(stream.localID)
+		return nil, err
+	}
+
+	select {
+	case <-stream.ready:
+		// ctx 取消时关闭服务流，与 adb server 连接的行为一致
+		context.AfterFunc(ctx, func() { stream.Close() })
+		return stream, nil
+	case <-stream.closed:
+		return nil, fmt.Errorf("设备拒绝打开服务: %s", service)
+	case <-ctx.Done():
+		stream.Close()
+		return nil, ctx.Err()
+	}
+}
+
+func (c *AdbdConn) removeStream(id uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.streams, id)
+}
+
+func (c *AdbdConn) stream(id uint32) *adbdStream {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.streams[id]
+}
+
+// readLoop 分发收到的消息，连接断开时关闭所有流
+func (c *AdbdConn) readLoop() {
+	var err error
+	for {
+		var msg adbdMessage
+		msg, err = readMessage(c.conn)
+		if err != nil {
+			break
+		}
+
+		switch msg.command {
+		case cmdOKAY:
+			if stream := c.stream(msg.arg1); stream != nil {
+				stream.onOkay(msg.arg0)
+			}
+		case cmdWRTE:
+			// OKAY 在 Read 取完数据后才回复，对端收到 OKAY 前不会再发送，未读取的数据不会无限堆积
+			if stream := c.stream(msg.arg1); stream != nil {
+				stream.onData(msg.data)
+			}
+		case cmdCLSE:
+			if stream := c.stream(msg.arg1); stream != nil {
+				stream.onClose()
+				c.removeStream(stream.localID)
+			}
+		case cmdOPEN:
+			c.acceptOpen(msg)
+		}
+	}
+
+	c.mu.Lock()
+	c.err = fmt.Errorf("adbd 连接已断开: %w", err)
+	streams := c.streams
+	c.streams = make(map[uint32]*adbdStream)
+	c.mu.Unlock()
+
+	for _, stream := range streams {
+		stream.onClose()
+	}
+	c.conn.Close()
+	close(c.done)
+}
+
+// acceptOpen 设备端处理 OPEN 请求
+func (c *AdbdConn) acceptOpen(msg adbdMessage) {
+	service := string(bytes.TrimRight(msg.data, "\x00"))
+	var handler func(io.ReadWriteCloser)
+	if c.onOpen != nil {
+		handler = c.onOpen(service)
+	}
+	if handler == nil {
+		c.send(adbdMessage{command: cmdCLSE, arg1: msg.arg0})
+		return
+	}
+
+	c.mu.Lock()
+	c.nextID++
+	stream := newAdbdStream(c, c.nextID)
+	stream.remoteID = msg.arg0
+	stream.readyOnce.Do(func() { close(stream.ready) })
+	c.streams[stream.localID] = stream
+	c.mu.Unlock()
+
+	c.send(adbdMessage{command: cmdOKAY, arg0: stream.localID, arg1: stream.remoteID})
+	go func() {
+		handler(stream)
+		stream.Close()
+	}()
+}
+
+// adbdStream 连接上的一个服务流
+type adbdStream struct {
+	conn     *AdbdConn
+	localID  uint32
+	remoteID uint32
+
+	ready     chan struct{} // 对端确认打开
+	readyOnce sync.Once
+	ack       chan struct{} // 对端确认收到上一个 WRTE
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending bytes.Buffer
+	unacked bool // 收到的 WRTE 尚未回复 OKAY
+	eof     bool
+}
+
+func newAdbdStream(conn *AdbdConn, localID uint32) *adbdStream {
+	s := &adbdStream{
+		conn:    conn,
+		localID: localID,
+		ready:   make(chan struct{}),
+		ack:     make(chan struct{}, 1),
+		closed:  make(chan struct{}),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *adbdStream) onOkay(remoteID uint32) {
+	opened := false
+	s.readyOnce.Do(func() {
+		s.remoteID = remoteID
+		close(s.ready)
+		opened = true
+	})
+	if !opened {
+		select {
+		case s.ack <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *adbdStream) onData(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending.Write(data)
+	s.unacked = true
+	s.cond.Broadcast()
+}
+
+func (s *adbdStream) onClose() {
+	s.mu.Lock()
+	s.eof = true
+	s.cond.Broadcast()
+	s.mu.Unlock()
+	s.closeOnce.Do(func() { close(s.closed) })
+}
+
+// Read 读取对端写入的数据，对端关闭后返回 io.EOF
+// 缓冲的数据全部取完后回复 OKAY，对端才会发送下一个 WRTE
+func (s *adbdStream) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	for s.pending.Len() == 0 && !s.eof {
+		s.cond.Wait()
+	}
+	if s.pending.Len() == 0 {
+		s.mu.Unlock()
+		return 0, io.EOF
+	}
+	n, _ := s.pending.Read(p)
+	ack := s.pending.Len() == 0 && s.unacked && !s.eof
+	if ack {
+		s.unacked = false
+	}
+	s.mu.Unlock()
+
+	// 发送可能阻塞，不能持有 s.mu，否则 readLoop 无法继续分发其他流的数据
+	if ack {
+		s.conn.send(adbdMessage{command: cmdOKAY, arg0: s.localID, arg1: s.remoteID})
+	}
+	return n, nil
+}
+
+// Write 按 maxData 分片发送，每片等待对端 OKAY 后再发下一片
+func (s *adbdStream) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), int(s.conn.maxData))
+		msg := adbdMessage{command: cmdWRTE, arg0: s.localID, arg1: s.remoteID, data: p[:n]}
+		if err := s.conn.send(msg); err != nil {
+			return written, err
+		}
+		select {
+		case <-s.ack:
+		case <-s.closed:
+			return written, io.ErrClosedPipe
+		}
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Close 关闭服务流
+func (s *adbdStream) Close() error {
+	select {
+	case <-s.closed:
+		return nil
+	default:
+	}
+	s.conn.send(adbdMessage{command: cmdCLSE, arg0: s.localID, arg1: s.remoteID})
+	s.onClose()
+	s.conn.removeStream(s.localID)
+	return nil
+}
@@ -0,0 +1,79 @@
+package adb
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAdbdStreamFlowControl(t *testing.T) {
+	host, device := net.Pipe()
+	defer device.Close()
+	c := newAdbdConn(host, testBanner, adbdMaxData)
+	go c.readLoop()
+	defer c.Close()
+
+	type result struct {
+		stream io.ReadWriteCloser
+		err    error
+	}
+	opened := make(chan result, 1)
+	go func() {
+		stream, err := c.Open(context.Background(), "shell:cat")
+		opened <- result{stream, err}
+	}()
+
+	// 设备端确认打开后写入一段数据
+	const remoteID = 100
+	msg, err := readMessage(device)
+	if err != nil || msg.command != cmdOPEN {
+		t.Fatalf("device read = %08x, %v, want OPEN", msg.command, err)
+	}
+	localID := msg.arg0
+	if err := writeMessage(device, adbdMessage{command: cmdOKAY, arg0: remoteID, arg1: localID}); err != nil {
+		t.Fatal(err)
+	}
+	r := <-opened
+	if r.err != nil {
+		t.Fatalf("Open: %v", r.err)
+	}
+	if err := writeMessage(device, adbdMessage{command: cmdWRTE, arg0: remoteID, arg1: localID, data: []byte("hello")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 数据未被读取前不回复 OKAY
+	device.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if msg, err := readMessage(device); err == nil {
+		t.Fatalf("device received %08x before the data was read", msg.command)
+	}
+	device.SetReadDeadline(time.Time{})
+
+	// 读取一部分仍不回复，全部取完后回复 OKAY
+	acked := make(chan adbdMessage, 1)
+	go func() {
+		msg, _ := readMessage(device)
+		acked <- msg
+	}()
+	buf := make([]byte, 3)
+	if n, err := r.stream.Read(buf); err != nil || string(buf[:n]) != "hel" {
+		t.Fatalf("Read = %q, %v", buf[:n], err)
+	}
+	select {
+	case msg := <-acked:
+		t.Fatalf("device received %08x with data still buffered", msg.command)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if n, err := r.stream.Read(buf); err != nil || string(buf[:n]) != "lo" {
+		t.Fatalf("Read = %q, %v", buf[:n], err)
+	}
+	select {
+	case msg := <-acked:
+		if msg.command != cmdOKAY || msg.arg0 != localID || msg.arg1 != remoteID {
+			t.Fatalf("device received %+v, want OKAY(%d, %d)", msg, localID, remoteID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no OKAY after the data was read")
+	}
+}
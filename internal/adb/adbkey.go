@@ -0,0 +1,190 @@
+package adb
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// adbKeyBits adb 使用的 RSA 密钥长度
+const adbKeyBits = 2048
+
+// DefaultKeyPath 返回 adb 默认的私钥路径（与 adb 命令行共用，已授权的设备无需再次确认）
+func DefaultKeyPath() string {
+	if dir := os.Getenv("ANDROID_USER_HOME"); dir != "" {
+		return filepath.Join(dir, "adbkey")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "adbkey"
+	}
+	return filepath.Join(home, ".android", "adbkey")
+}
+
+// GenerateKey 生成新的 RSA 密钥，写入 path（私钥）和 path.pub（Android 格式公钥）
+func GenerateKey(path string) (*rsa.PrivateKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, adbKeyBits)
+	if err != nil {
+		return nil, fmt.Errorf("生成 RSA 密钥失败: %v", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("创建密钥目录失败: %v", err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return nil, fmt.Errorf("写入私钥失败: %v", err)
+	}
+
+	pub, err := AndroidPublicKey(&key.PublicKey, keyComment())
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path+".pub", []byte(pub+"\n"), 0644); err != nil {
+		return nil, fmt.Errorf("写入公钥失败: %v", err)
+	}
+
+	return key, nil
+}
+
+// LoadKey 读取 PEM 格式的私钥（支持 PKCS#8 和 PKCS#1）
+func LoadKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("无效的私钥文件: %s", path)
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("解析私钥失败: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("私钥不是 RSA 密钥: %s", path)
+	}
+	return key, nil
+}
+
+// LoadOrCreateKey 读取私钥，不存在时自动生成
+func LoadOrCreateKey(path string) (*rsa.PrivateKey, error) {
+	key, err := LoadKey(path)
+	if os.IsNotExist(err) {
+		return GenerateKey(path)
+	}
+	return key, err
+}
+
+// signToken 对 adbd 下发的 20 字节令牌签名（令牌按 SHA-1 摘要处理）
+func signToken(key *rsa.PrivateKey, token []byte) ([]byte, error) {
+	return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA1, token)
+}
+
+// keyComment 公钥末尾的 user@host 注释
+func keyComment() string {
+	user := os.Getenv("USER")
+	if user == "" {
+		user = os.Getenv("USERNAME")
+	}
+	if user == "" {
+		user = "adbmanager"
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return user + "@" + host
+}
+
+// AndroidPublicKey 将公钥编码为 adbd 识别的格式（即 adb_keys 中的一行）
+// 结构为: 模数字数、n0inv、模数、R^2 mod N、指数，均为小端 uint32，整体 base64 编码
+func AndroidPublicKey(pub *rsa.PublicKey, comment string) (string, error) {
+	words := adbKeyBits / 32
+	if pub.N.BitLen() != adbKeyBits {
+		return "", fmt.Errorf("仅支持 %d 位 RSA 密钥", adbKeyBits)
+	}
+
+	buf := make([]byte, 0, 4*(3+2*words))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(words))
+
+	// n0inv = -1 / n[0] mod 2^32
+	r32 := new(big.Int).Lsh(big.NewInt(1), 32)
+	n0inv := new(big.Int).ModInverse(new(big.Int).Mod(pub.N, r32), r32)
+	n0inv.Sub(r32, n0inv)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(n0inv.Uint64()))
+
+	buf = appendLittleEndianWords(buf, pub.N, words)
+
+	// rr = (2^(2*bits)) mod n
+	rr := new(big.Int).Lsh(big.NewInt(1), uint(2*adbKeyBits))
+	rr.Mod(rr, pub.N)
+	buf = appendLittleEndianWords(buf, rr, words)
+
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(pub.E))
+
+	encoded := base64.StdEncoding.EncodeToString(buf)
+	if comment != "" {
+		encoded += " " + comment
+	}
+	return encoded, nil
+}
+
+// ParseAndroidPublicKey 解析 adbd 格式的公钥
+func ParseAndroidPublicKey(encoded string) (*rsa.PublicKey, error) {
+	encoded, _, _ = strings.Cut(strings.TrimSpace(encoded), " ")
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("无效的公钥编码: %v", err)
+	}
+	if len(data) < 4 {
+		return nil, fmt.Errorf("公钥数据过短")
+	}
+
+	words := int(binary.LittleEndian.Uint32(data))
+	if len(data) != 4*(3+2*words) {
+		return nil, fmt.Errorf("公钥长度不匹配")
+	}
+
+	n := readLittleEndianWords(data[8:], words)
+	e := binary.LittleEndian.Uint32(data[8+8*words:])
+	return &rsa.PublicKey{N: n, E: int(e)}, nil
+}
+
+// appendLittleEndianWords 以小端 uint32 数组形式追加大整数
+func appendLittleEndianWords(buf []byte, n *big.Int, words int) []byte {
+	be := n.FillBytes(make([]byte, 4*words))
+	for i := words - 1; i >= 0; i-- {
+		buf = append(buf, be[4*i+3], be[4*i+2], be[4*i+1], be[4*i])
+	}
+	return buf
+}
+
+// readLittleEndianWords 读取小端 uint32 数组表示的大整数
+func readLittleEndianWords(data []byte, words int) *big.Int {
+	be := make([]byte, 4*words)
+	for i := 0; i < words; i++ {
+		word := data[4*i : 4*i+4]
+		j := 4 * (words - 1 - i)
+		be[j], be[j+1], be[j+2], be[j+3] = word[3], word[2], word[1], word[0]
+	}
+	return new(big.Int).SetBytes(be)
+}
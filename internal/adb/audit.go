@@ -0,0 +1,68 @@
+package adb
+
+import (
+	"errors"
+	"time"
+)
+
+// AuditEvent 一次 adb 操作，ADBManager 在操作结束后交给 Auditor 记录
+type AuditEvent struct {
+	Time           time.Time // 开始时间
+	Serial         string    // 设备序列号或 IP:PORT，不针对设备的命令为空
+	HardwareSerial string    // 设备的 ro.serialno，未识别时为空
+	Model          string    // 设备型号，未知时为空
+	Argv           []string  // adb 参数（不含 adb 本身）
+	ExitCode       int       // 退出码，shell 命令为命令在设备上的退出码，未能得到时为 -1
+	Duration       time.Duration
+	Error          string // 失败原因，成功时为空
+}
+
+// Auditor 审计日志，记录 ADBManager 执行的每一次设备操作
+// Record 在执行操作的协程中同步调用，不应长时间阻塞
+type Auditor interface {
+	Record(event AuditEvent)
+}
+
+// SetAuditor 设置审计日志，应在使用 ADBManager 之前调用；未设置时不记录
+func (m *ADBManager) SetAuditor(auditor Auditor) {
+	m.auditor = auditor
+}
+
+// auditExitCode 从 run 等返回的错误中取得退出码，成功时为 0
+func auditExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.ExitCode
+	}
+	return -1
+}
+
+// audit 将一次操作交给审计日志，调用方不能持有 deviceCacheLock
+// （ListDevices 持有写锁期间只执行 devices、kill-server 等不针对设备的命令，不会读取设备缓存）
+func (m *ADBManager) audit(args []string, start time.Time, exitCode int, err error) {
+	if m.auditor == nil || isHostQuery(args) {
+		return
+	}
+
+	event := AuditEvent{
+		Time:     start,
+		Serial:   targetSerial(args),
+		Argv:     append([]string(nil), args...),
+		ExitCode: exitCode,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	if event.Serial != "" {
+		m.deviceCacheLock.RLock()
+		if dev, exists := m.managedDevices[event.Serial]; exists {
+			event.HardwareSerial, event.Model = dev.HardwareSerial, dev.Model
+		}
+		m.deviceCacheLock.RUnlock()
+	}
+	m.auditor.Record(event)
+}
@@ -0,0 +1,221 @@
+package adb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Toolbox 设备上提供基础命令（ls、ps、stat 等）的工具集
+type Toolbox int
+
+const (
+	ToolboxUnknown Toolbox = iota // 无法识别
+	ToolboxToybox                 // toybox（Android 6 及以上）
+	ToolboxToolbox                // 旧版 Android 的 toolbox，命令参数很少
+	ToolboxBusybox                // busybox（物联网设备、部分定制系统）
+)
+
+func (t Toolbox) String() string {
+	switch t {
+	case ToolboxToybox:
+		return "toybox"
+	case ToolboxToolbox:
+		return "toolbox"
+	case ToolboxBusybox:
+		return "busybox"
+	}
+	return "未知"
+}
+
+// probeBinaries 能力探测时检查的命令
+var probeBinaries = []string{"ip", "ss", "netstat", "stat", "md5sum", "screencap", "uiautomator", "cmd"}
+
+// Capabilities 设备能力，连接后探测一次并缓存在设备上，供各功能选择合适的命令
+type Capabilities struct {
+	SDK      int             // ro.build.version.sdk，读取失败时为 0
+	ABI      string          // ro.product.cpu.abi
+	Toolbox  Toolbox         // 基础命令的工具集
+	Binaries map[string]bool // probeBinaries 中在 PATH 里找到的命令
+	Features []string        // adbd 声明的特性
+	ProbedAt time.Time
+}
+
+// Has 设备的 PATH 中是否有该命令
+func (c *Capabilities) Has(binary string) bool {
+	return c.Binaries[binary]
+}
+
+// HasFeature adbd 是否声明了该特性
+func (c *Capabilities) HasFeature(feature string) bool {
+	for _, f := range c.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// String 能力的摘要，用于界面显示
+func (c *Capabilities) String() string {
+	binaries := make([]string, 0, len(c.Binaries))
+	for name := range c.Binaries {
+		binaries = append(binaries, name)
+	}
+	sort.Strings(binaries)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "SDK: %d\n", c.SDK)
+	fmt.Fprintf(&b, "ABI: %s\n", c.ABI)
+	fmt.Fprintf(&b, "工具集: %s\n", c.Toolbox)
+	fmt.Fprintf(&b, "可用命令: %s\n", strings.Join(binaries, ", "))
+	fmt.Fprintf(&b, "adbd 特性: %s\n", strings.Join(c.Features, ", "))
+	fmt.Fprintf(&b, "探测时间: %s\n", c.ProbedAt.Format("2006-01-02 15:04:05"))
+	return b.String()
+}
+
+// psCommand 列出所有进程的 ps 命令
+// Android 8 起 ps 改由 toybox 提供，需要 -A 才列出所有进程；旧版 toolbox 与 busybox 的 ps 默认列出全部且不认识 -A
+func (c *Capabilities) psCommand() string {
+	if c.Toolbox == ToolboxToybox && c.SDK >= 26 {
+		return "ps -A"
+	}
+	return "ps"
+}
+
+// capabilityProbe 探测设备能力的脚本，一次 shell 调用完成
+var capabilityProbe = `echo "sdk $(getprop ro.build.version.sdk)"
+echo "abi $(getprop ro.product.cpu.abi)"
+for c in ` + strings.Join(probeBinaries, " ") + ` toybox toolbox busybox; do command -v $c >/dev/null 2>&1 && echo "bin $c"; done
+true`
+
+// CachedCapabilities 返回设备缓存的能力，不会在设备上执行任何命令，未探测过时为 nil
+func (m *ADBManager) CachedCapabilities(serial string) *Capabilities {
+	m.deviceCacheLock.RLock()
+	defer m.deviceCacheLock.RUnlock()
+	if dev, exists := m.managedDevices[serial]; exists {
+		return dev.Capabilities
+	}
+	return nil
+}
+
+// setCapabilities 将探测结果保存在设备缓存中
+func (m *ADBManager) setCapabilities(serial string, caps *Capabilities) {
+	m.deviceCacheLock.Lock()
+	defer m.deviceCacheLock.Unlock()
+	if dev, exists := m.managedDevices[serial]; exists {
+		dev.Capabilities = caps
+	}
+}
+
+// Capabilities 返回设备的能力，未探测过时先探测
+func (m *ADBManager) Capabilities(serial string) (*Capabilities, error) {
+	return m.CapabilitiesContext(context.Background(), serial)
+}
+
+// CapabilitiesContext 可通过 ctx 取消的 Capabilities
+func (m *ADBManager) CapabilitiesContext(ctx context.Context, serial string) (*Capabilities, error) {
+	if caps := m.CachedCapabilities(serial); caps != nil {
+		return caps, nil
+	}
+	return m.ProbeCapabilitiesContext(ctx, serial)
+}
+
+// ProbeCapabilities 忽略缓存重新探测设备的能力
+func (m *ADBManager) ProbeCapabilities(serial string) (*Capabilities, error) {
+	return m.ProbeCapabilitiesContext(context.Background(), serial)
+}
+
+// ProbeCapabilitiesContext 可通过 ctx 取消的 ProbeCapabilities
+func (m *ADBManager) ProbeCapabilitiesContext(ctx context.Context, serial string) (*Capabilities, error) {
+	features, err := m.FeaturesContext(ctx, serial)
+	if err != nil {
+		return nil, fmt.Errorf("探测设备能力失败: %w", err)
+	}
+	// 探测脚本本身不能被包装
+	result, err := m.executeShell(ctx, serial, capabilityProbe)
+	if err != nil {
+		return nil, fmt.Errorf("探测设备能力失败: %w", err)
+	}
+
+	caps := parseCapabilityProbe(result.Stdout)
+	caps.Features = features
+	caps.ProbedAt = time.Now()
+	m.log.Info("设备能力", "device", serial, "sdk", caps.SDK, "abi", caps.ABI, "toolbox", caps.Toolbox)
+	m.setCapabilities(serial, caps)
+	return caps, nil
+}
+
+// ProbeAllCapabilities 探测所有在线但尚未探测的设备，个别设备失败不影响其余设备
+func (m *ADBManager) ProbeAllCapabilities() error {
+	return m.ProbeAllCapabilitiesContext(context.Background())
+}
+
+// ProbeAllCapabilitiesContext 可通过 ctx 取消的 ProbeAllCapabilities
+func (m *ADBManager) ProbeAllCapabilitiesContext(ctx context.Context) error {
+	m.deviceCacheLock.RLock()
+	pending := make([]string, 0)
+	for serial, dev := range m.managedDevices {
+		if dev.Status == "device" && dev.Capabilities == nil {
+			pending = append(pending, serial)
+		}
+	}
+	m.deviceCacheLock.RUnlock()
+	sort.Strings(pending)
+
+	var errs []error
+	for _, serial := range pending {
+		if _, err := m.ProbeCapabilitiesContext(ctx, serial); err != nil {
+			if ctxErr := contextError(ctx); ctxErr != nil {
+				return ctxErr
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", serial, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parseCapabilityProbe 解析探测脚本的输出
+func parseCapabilityProbe(output string) *Capabilities {
+	caps := &Capabilities{Binaries: make(map[string]bool)}
+	for _, line := range strings.Split(output, "\n") {
+		kind, value, _ := strings.Cut(strings.TrimSpace(line), " ")
+		value = strings.TrimSpace(value)
+		switch kind {
+		case "sdk":
+			caps.SDK, _ = strconv.Atoi(value)
+		case "abi":
+			caps.ABI = value
+		case "bin":
+			caps.Binaries[value] = true
+		}
+	}
+
+	switch {
+	case caps.Binaries["toybox"]:
+		caps.Toolbox = ToolboxToybox
+	case caps.Binaries["busybox"]:
+		caps.Toolbox = ToolboxBusybox
+	case caps.Binaries["toolbox"]:
+		caps.Toolbox = ToolboxToolbox
+	}
+	// 工具集本身不属于 probeBinaries，只用于判断 Toolbox
+	delete(caps.Binaries, "toybox")
+	delete(caps.Binaries, "toolbox")
+	delete(caps.Binaries, "busybox")
+	return caps
+}
+
+// knownCapabilities 返回设备的能力，探测失败时返回 nil，调用方按未知设备处理
+func (m *ADBManager) knownCapabilities(ctx context.Context, serial string) *Capabilities {
+	caps, err := m.CapabilitiesContext(ctx, serial)
+	if err != nil {
+		m.log.Warn("探测设备能力失败", "device", serial, "error", err)
+		return nil
+	}
+	return caps
+}
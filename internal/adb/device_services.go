@@ -0,0 +1,75 @@
+package adb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+)
+
+// serviceOpener 打开设备服务（如 shell:、sync:）的函数
+// adb server 与直连 adbd 两种后端都通过它复用文件传输、安装等逻辑
+type serviceOpener func(ctx context.Context, service string) (io.ReadWriteCloser, error)
+
+// readService 打开服务并读取全部输出
+func readService(ctx context.Context, open serviceOpener, service string) ([]byte, error) {
+	conn, err := open(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	output, err := io.ReadAll(conn)
+	if ctx.Err() != nil {
+		// 取消时连接被关闭，读到的 EOF 不代表服务正常结束
+		return output, ctx.Err()
+	}
+	return output, err
+}
+
+// openSync 打开 sync 服务，features 为设备声明的特性
+func openSync(ctx context.Context, open serviceOpener, features []string) (*SyncConn, error) {
+	conn, err := open(ctx, "sync:")
+	if err != nil {
+		return nil, err
+	}
+	return NewSyncConn(conn, features...), nil
+}
+
+// runnerLog 执行器单独使用时的日志输出；通过 ADBManager 传输文件时使用 SetLogger 设置的 logger
+func runnerLog() *slog.Logger {
+	return slog.Default().With("component", "adb")
+}
+
+// syncPull 通过 sync 服务拉取单个文件
+func syncPull(ctx context.Context, log *slog.Logger, open serviceOpener, features []string, remotePath, localPath string) ([]byte, error) {
+	if err := syncPullFile(ctx, log, open, features, remotePath, localPath, nil); err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("%s: 1 file pulled\n", remotePath)), nil
+}
+
+// syncPush 通过 sync 服务推送单个文件
+func syncPush(ctx context.Context, open serviceOpener, localPath, remotePath string) ([]byte, error) {
+	if err := syncPushFile(ctx, open, localPath, remotePath, nil); err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("%s: 1 file pushed\n", localPath)), nil
+}
+
+// errMissingAPK install 没有指定 APK 文件
+var errMissingAPK = errors.New("adb install: 缺少 APK 文件路径")
+
+// installAPK 推送 APK 到临时目录后调用 pm install
+func installAPK(ctx context.Context, open serviceOpener, apkPath string, options []string) ([]byte, error) {
+	remotePath := "/data/local/tmp/" + filepath.Base(apkPath)
+	if _, err := syncPush(ctx, open, apkPath, remotePath); err != nil {
+		return nil, err
+	}
+	defer readService(context.Background(), open, "shell:"+ShellJoin("rm", "-f", "--", remotePath))
+
+	argv := append([]string{"pm", "install"}, options...)
+	return readService(ctx, open, "shell:"+ShellJoin(append(argv, remotePath)...))
+}
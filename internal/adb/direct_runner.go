@@ -0,0 +1,578 @@
+package adb
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DirectRunner 不经过 adb server、直接与单台设备的 adbd 通信的执行器
+// 适用于无法运行 adb server 的环境，或希望绕开 server 的无线设备
+type DirectRunner struct {
+	Address string
+
+	key      *rsa.PrivateKey
+	mu       sync.Mutex
+	conn     *AdbdConn
+	onChange func() // 连接建立或断开时调用，由 DeviceRouter 设置
+}
+
+// NewDirectRunner 创建直连执行器，key 为认证用的 RSA 私钥
+func NewDirectRunner(address string, key *rsa.PrivateKey) *DirectRunner {
+	return &DirectRunner{Address: address, key: key}
+}
+
+// Connect 建立连接并完成认证，已连接时直接返回
+func (r *DirectRunner) Connect(ctx context.Context) error {
+	_, err := r.connection(ctx)
+	return err
+}
+
+// Close 断开连接
+func (r *DirectRunner) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+// Banner 返回设备标识，未连接时为空
+func (r *DirectRunner) Banner() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		return ""
+	}
+	return r.conn.Banner()
+}
+
+// connection 返回可用连接，断开后自动重连（例如 adb root 重启 adbd 之后）
+func (r *DirectRunner) connection(ctx context.Context) (*AdbdConn, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn != nil {
+		select {
+		case <-r.conn.Done():
+			r.conn = nil
+		default:
+			return r.conn, nil
+		}
+	}
+
+	conn, err := DialAdbd(ctx, r.Address, r.key)
+	if err != nil {
+		return nil, err
+	}
+	r.conn = conn
+	go r.watch(conn)
+	return conn, nil
+}
+
+// watch 在连接建立与断开时通知 DeviceRouter
+func (r *DirectRunner) watch(conn *AdbdConn) {
+	r.changed()
+	<-conn.Done()
+	r.changed()
+}
+
+func (r *DirectRunner) changed() {
+	r.mu.Lock()
+	onChange := r.onChange
+	r.mu.Unlock()
+	if onChange != nil {
+		onChange()
+	}
+}
+
+// connectedDevice 当前连接对应的设备信息，未连接或连接已断开时返回 false，不会重新连接
+func (r *DirectRunner) connectedDevice() (Device, bool) {
+	r.mu.Lock()
+	conn := r.conn
+	r.mu.Unlock()
+	if conn == nil {
+		return Device{}, false
+	}
+	select {
+	case <-conn.Done():
+		return Device{}, false
+	default:
+	}
+	device, ok := parseDeviceLine(directDeviceLine(r.Address, conn.Banner(), true))
+	if !ok {
+		return Device{}, false
+	}
+	return *device, true
+}
+
+// open 打开设备服务
+func (r *DirectRunner) open(ctx context.Context, service string) (io.ReadWriteCloser, error) {
+	conn, err := r.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn.Open(ctx, service)
+}
+
+// OpenService 打开设备服务，serial 被忽略
+func (r *DirectRunner) OpenService(ctx context.Context, serial, service string) (io.ReadWriteCloser, error) {
+	return r.open(ctx, service)
+}
+
+// CombinedOutput 执行 adb 命令，返回合并后的输出
+func (r *DirectRunner) CombinedOutput(ctx context.Context, args ...string) ([]byte, error) {
+	if _, command, ok := shellCommand(args); ok {
+		output, err := r.shell(ctx, command)
+		return output.combinedResult(err)
+	}
+
+	output, err := r.run(ctx, args)
+	if err != nil {
+		return append(output, errorOutput(err)...), err
+	}
+	return output, nil
+}
+
+// Output 执行 adb 命令，分别返回 stdout 与 stderr
+func (r *DirectRunner) Output(ctx context.Context, args ...string) ([]byte, []byte, error) {
+	if _, command, ok := shellCommand(args); ok {
+		output, err := r.shell(ctx, command)
+		return output.splitResult(err)
+	}
+
+	stdout, err := r.run(ctx, args)
+	if err != nil {
+		return stdout, []byte(errorOutput(err)), err
+	}
+	return stdout, nil, nil
+}
+
+// Start 启动流式命令，仅支持 shell
+func (r *DirectRunner) Start(ctx context.Context, args ...string) (Process, error) {
+	_, rest := splitSerial(args)
+	if len(rest) < 2 || rest[0] != "shell" {
+		return nil, errUnsupported
+	}
+	conn, err := r.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	command := strings.Join(rest[1:], " ")
+	if hasFeature(conn.Banner(), FeatureShellV2) {
+		stream, err := r.open(ctx, "shell,v2,raw:"+command)
+		if err != nil {
+			return nil, err
+		}
+		return newShellV2Process(stream), nil
+	}
+	stream, err := r.open(ctx, "shell:"+command)
+	if err != nil {
+		return nil, err
+	}
+	return newConnProcess(stream), nil
+}
+
+// shell 执行 shell 命令，设备在握手时声明支持时使用 shell 协议 v2
+func (r *DirectRunner) shell(ctx context.Context, command string) (*shellOutput, error) {
+	conn, err := r.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return runShell(ctx, r.open, command, hasFeature(conn.Banner(), FeatureShellV2))
+}
+
+// deviceFeatures 设备在 CNXN 握手时声明的特性
+func (r *DirectRunner) deviceFeatures(ctx context.Context, serial string) ([]string, error) {
+	conn, err := r.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, props := parseBanner(conn.Banner())
+	return parseFeatures(props["features"]), nil
+}
+
+// hasFeature 判断 CNXN 设备标识中是否声明了指定特性
+func hasFeature(banner, feature string) bool {
+	_, props := parseBanner(banner)
+	for _, f := range parseFeatures(props["features"]) {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// run 将命令行参数翻译为 adbd 服务请求，"-s serial" 被忽略
+func (r *DirectRunner) run(ctx context.Context, args []string) ([]byte, error) {
+	_, rest := splitSerial(args)
+	if len(rest) == 0 {
+		return nil, errUnsupported
+	}
+
+	switch rest[0] {
+	case "devices":
+		line, err := r.deviceLine(ctx, len(rest) > 1 && rest[1] == "-l")
+		if err != nil {
+			return nil, err
+		}
+		return []byte("List of devices attached\n" + line + "\n"), nil
+
+	case "features":
+		features, err := r.deviceFeatures(ctx, r.Address)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strings.Join(features, "\n") + "\n"), nil
+
+	case "connect":
+		if err := r.Connect(ctx); err != nil {
+			return nil, err
+		}
+		return []byte("connected to " + r.Address + "\n"), nil
+
+	case "reconnect":
+		r.Close()
+		if err := r.Connect(ctx); err != nil {
+			return nil, err
+		}
+		return []byte("reconnecting " + r.Address + "\n"), nil
+
+	case "disconnect":
+		r.Close()
+		return []byte("disconnected " + r.Address + "\n"), nil
+
+	case "reverse":
+		return reverseService(ctx, r.open, rest[1:])
+
+	case "root", "unroot":
+		return readService(ctx, r.open, rest[0]+":")
+
+	case "pull":
+		if len(rest) != 3 {
+			return nil, errUnsupported
+		}
+		features, err := r.deviceFeatures(ctx, r.Address)
+		if err != nil {
+			return nil, err
+		}
+		return syncPull(ctx, runnerLog().With("device", r.Address), r.open, features, rest[1], rest[2])
+
+	case "push":
+		if len(rest) != 3 {
+			return nil, errUnsupported
+		}
+		return syncPush(ctx, r.open, rest[1], rest[2])
+
+	case "install":
+		if len(rest) < 2 {
+			return nil, errMissingAPK
+		}
+		apkPath := rest[len(rest)-1]
+		return installAPK(ctx, r.open, apkPath, rest[1:len(rest)-1])
+
+	case "uninstall":
+		if len(rest) < 2 {
+			return nil, errUnsupported
+		}
+		return readService(ctx, r.open, "shell:"+ShellJoin(append([]string{"pm"}, rest...)...))
+	}
+
+	return nil, errUnsupported
+}
+
+// deviceLine 生成与 devices(-l) 输出格式一致的一行，连接失败时显示为 offline
+func (r *DirectRunner) deviceLine(ctx context.Context, long bool) (string, error) {
+	conn, err := r.connection(ctx)
+	if errors.Is(err, ErrAuthRejected) {
+		return r.Address + "\tunauthorized", nil
+	}
+	if err != nil {
+		return r.Address + "\toffline", nil
+	}
+
+	return directDeviceLine(r.Address, conn.Banner(), long), nil
+}
+
+// directDeviceLine 由设备标识生成 devices(-l) 格式的一行
+func directDeviceLine(address, banner string, long bool) string {
+	state, props := parseBanner(banner)
+	line := address + "\t" + state
+	if long {
+		for _, field := range []struct{ name, prop string }{
+			{"product", "ro.product.name"},
+			{"model", "ro.product.model"},
+			{"device", "ro.product.device"},
+		} {
+			if value := props[field.prop]; value != "" {
+				line += " " + field.name + ":" + strings.ReplaceAll(value, " ", "_")
+			}
+		}
+	}
+	return line
+}
+
+// parseBanner 解析 CNXN 中的设备标识，格式为 "device::key=value;key=value;"
+func parseBanner(banner string) (string, map[string]string) {
+	state, rest, _ := strings.Cut(banner, ":")
+	_, rest, _ = strings.Cut(rest, ":")
+
+	props := make(map[string]string)
+	for _, item := range strings.Split(rest, ";") {
+		if key, value, ok := strings.Cut(item, "="); ok {
+			props[key] = value
+		}
+	}
+	if state == "" {
+		state = "device"
+	}
+	return state, props
+}
+
+// DeviceRouter 按设备分发命令的执行器：直连设备交给各自的 DirectRunner，其余交给 Default
+type DeviceRouter struct {
+	Default CommandRunner
+
+	mu       sync.RWMutex
+	direct   map[string]*DirectRunner
+	watchers map[chan struct{}]struct{} // 直连设备变化时通知，见 watchDirect
+}
+
+// NewDeviceRouter 创建按设备分发的执行器
+func NewDeviceRouter(defaultRunner CommandRunner) *DeviceRouter {
+	return &DeviceRouter{
+		Default:  defaultRunner,
+		direct:   make(map[string]*DirectRunner),
+		watchers: make(map[chan struct{}]struct{}),
+	}
+}
+
+// AddDirect 登记直连设备，之后以其地址作为序列号的命令都走直连
+func (r *DeviceRouter) AddDirect(runner *DirectRunner) {
+	runner.mu.Lock()
+	runner.onChange = r.notifyDirect
+	runner.mu.Unlock()
+
+	r.mu.Lock()
+	old, replaced := r.direct[runner.Address]
+	r.direct[runner.Address] = runner
+	r.mu.Unlock()
+
+	if replaced && old != runner {
+		old.Close()
+	}
+	r.notifyDirect()
+}
+
+// RemoveDirect 断开并移除直连设备
+func (r *DeviceRouter) RemoveDirect(address string) bool {
+	r.mu.Lock()
+	runner, ok := r.direct[address]
+	delete(r.direct, address)
+	r.mu.Unlock()
+
+	if ok {
+		runner.Close()
+		r.notifyDirect()
+	}
+	return ok
+}
+
+// watchDirect 直连设备增减、连接或断开时向返回的通道发送通知，连续的通知会合并
+// 返回的函数用于取消订阅
+func (r *DeviceRouter) watchDirect() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	r.mu.Lock()
+	r.watchers[ch] = struct{}{}
+	r.mu.Unlock()
+	return ch, func() {
+		r.mu.Lock()
+		delete(r.watchers, ch)
+		r.mu.Unlock()
+	}
+}
+
+func (r *DeviceRouter) notifyDirect() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for ch := range r.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// directDevices 当前已连接的直连设备
+func (r *DeviceRouter) directDevices() []Device {
+	devices := make([]Device, 0)
+	for _, runner := range r.directRunners() {
+		if device, ok := runner.connectedDevice(); ok {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+// IsDirect 判断设备是否为直连设备
+func (r *DeviceRouter) IsDirect(serial string) bool {
+	return r.directRunner(serial) != nil
+}
+
+func (r *DeviceRouter) directRunner(serial string) *DirectRunner {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.direct[serial]
+}
+
+func (r *DeviceRouter) directRunners() []*DirectRunner {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	runners := make([]*DirectRunner, 0, len(r.direct))
+	for _, runner := range r.direct {
+		runners = append(runners, runner)
+	}
+	return runners
+}
+
+// route 选择执行该命令的执行器
+func (r *DeviceRouter) route(args []string) CommandRunner {
+	serial, rest := splitSerial(args)
+	if serial == "" && len(rest) == 2 && (rest[0] == "connect" || rest[0] == "disconnect") {
+		serial = rest[1]
+	}
+	if runner := r.directRunner(serial); runner != nil {
+		return runner
+	}
+	return r.Default
+}
+
+// CombinedOutput 执行 adb 命令，返回合并后的输出
+func (r *DeviceRouter) CombinedOutput(ctx context.Context, args ...string) ([]byte, error) {
+	if isDevicesCommand(args) {
+		output, err := r.Default.CombinedOutput(ctx, args...)
+		return r.mergeDevices(ctx, args, output, err)
+	}
+	output, err := r.route(args).CombinedOutput(ctx, args...)
+	r.afterDisconnect(args, err)
+	return output, err
+}
+
+// Output 执行 adb 命令，分别返回 stdout 与 stderr
+func (r *DeviceRouter) Output(ctx context.Context, args ...string) ([]byte, []byte, error) {
+	if isDevicesCommand(args) {
+		stdout, stderr, err := r.Default.Output(ctx, args...)
+		stdout, err = r.mergeDevices(ctx, args, stdout, err)
+		return stdout, stderr, err
+	}
+	stdout, stderr, err := r.route(args).Output(ctx, args...)
+	r.afterDisconnect(args, err)
+	return stdout, stderr, err
+}
+
+// Start 启动流式命令
+func (r *DeviceRouter) Start(ctx context.Context, args ...string) (Process, error) {
+	return r.route(args).Start(ctx, args...)
+}
+
+// OpenService 打开设备服务，默认执行器不支持时返回 errUnsupported
+func (r *DeviceRouter) OpenService(ctx context.Context, serial, service string) (io.ReadWriteCloser, error) {
+	if runner := r.directRunner(serial); runner != nil {
+		return runner.OpenService(ctx, serial, service)
+	}
+	if opener, ok := r.Default.(ServiceOpener); ok {
+		return opener.OpenService(ctx, serial, service)
+	}
+	return nil, errUnsupported
+}
+
+// deviceFeatures 查询设备特性，默认执行器不支持时返回 errUnsupported
+func (r *DeviceRouter) deviceFeatures(ctx context.Context, serial string) ([]string, error) {
+	if runner := r.directRunner(serial); runner != nil {
+		return runner.deviceFeatures(ctx, serial)
+	}
+	if lister, ok := r.Default.(featureLister); ok {
+		return lister.deviceFeatures(ctx, serial)
+	}
+	return nil, errUnsupported
+}
+
+// afterDisconnect 直连设备断开后从路由表中移除
+func (r *DeviceRouter) afterDisconnect(args []string, err error) {
+	if err == nil && len(args) == 2 && args[0] == "disconnect" {
+		r.RemoveDirect(args[1])
+	}
+}
+
+func isDevicesCommand(args []string) bool {
+	_, rest := splitSerial(args)
+	return len(rest) > 0 && rest[0] == "devices"
+}
+
+// mergeDevices 将直连设备追加到 devices 输出中
+// 存在直连设备时，adb server 不可用不再视为错误
+func (r *DeviceRouter) mergeDevices(ctx context.Context, args []string, output []byte, err error) ([]byte, error) {
+	runners := r.directRunners()
+	if len(runners) == 0 {
+		return output, err
+	}
+
+	text := strings.TrimRight(string(output), "\n")
+	if err != nil || !strings.Contains(text, "List of devices attached") {
+		text = "List of devices attached"
+	}
+
+	_, rest := splitSerial(args)
+	long := len(rest) > 1 && rest[1] == "-l"
+	for _, runner := range runners {
+		lineCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		line, lineErr := runner.deviceLine(lineCtx, long)
+		cancel()
+		if lineErr == nil {
+			text += "\n" + line
+		}
+	}
+	return []byte(text + "\n\n"), nil
+}
+
+// ConnectDirect 不经过 adb server，直接连接设备上的 adbd（需在设备上开启无线调试）
+// 首次连接时设备会弹出授权对话框，最长等待 60 秒
+func (m *ADBManager) ConnectDirect(address string, key *rsa.PrivateKey) error {
+	return m.ConnectDirectContext(context.Background(), address, key)
+}
+
+// ConnectDirectContext 可通过 ctx 取消的 ConnectDirect
+func (m *ADBManager) ConnectDirectContext(ctx context.Context, address string, key *rsa.PrivateKey) error {
+	if !strings.Contains(address, ":") {
+		address += ":5555"
+	}
+	m.log.Info("尝试直连 adbd", "device", address)
+
+	runner := NewDirectRunner(address, key)
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+	if err := runner.Connect(ctx); err != nil {
+		m.log.Warn("直连失败", "device", address, "error", err)
+		return fmt.Errorf("直连失败: %w", err)
+	}
+
+	m.router.AddDirect(runner)
+	m.log.Info("直连成功", "device", address, "banner", runner.Banner())
+	return nil
+}
+
+// IsDirectDevice 判断设备是否通过直连 adbd 管理
+func (m *ADBManager) IsDirectDevice(serial string) bool {
+	return m.router.IsDirect(serial)
+}
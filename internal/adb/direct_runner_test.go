@@ -0,0 +1,137 @@
+package adb
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testBanner = "device::ro.product.name=iot;ro.product.model=Box;ro.product.device=box;features=shell_v2,cmd"
+
+func TestDirectRunnerAuth(t *testing.T) {
+	dir := t.TempDir()
+	key, err := GenerateKey(filepath.Join(dir, "adbkey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := GenerateKey(filepath.Join(dir, "other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adbd, err := NewFakeAdbd(testBanner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer adbd.Close()
+	adbd.Authorize(&key.PublicKey)
+	adbd.SetShell("getprop ro.product.model", "Box\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("known key", func(t *testing.T) {
+		r := NewDirectRunner(adbd.Addr(), key)
+		defer r.Close()
+		if err := r.Connect(ctx); err != nil {
+			t.Fatalf("Connect: %v", err)
+		}
+		if r.Banner() != testBanner {
+			t.Fatalf("banner = %q", r.Banner())
+		}
+		output, err := r.CombinedOutput(ctx, "shell", "getprop", "ro.product.model")
+		if err != nil || string(output) != "Box\n" {
+			t.Fatalf("output = %q, err = %v", output, err)
+		}
+	})
+
+	t.Run("rejected key", func(t *testing.T) {
+		r := NewDirectRunner(adbd.Addr(), other)
+		defer r.Close()
+		err := r.Connect(ctx)
+		if !errors.Is(err, ErrAuthRejected) || !errors.Is(err, ErrUnauthorized) {
+			t.Fatalf("Connect with unknown key = %v, want ErrAuthRejected", err)
+		}
+
+		// 经 ConnectDirect 包装后仍能识别为未授权，界面据此提示在设备上确认
+		m := newTestManager(NewScriptedRunner())
+		err = m.ConnectDirectContext(ctx, adbd.Addr(), other)
+		if !errors.Is(err, ErrUnauthorized) {
+			t.Fatalf("ConnectDirect with unknown key = %v, want ErrUnauthorized", err)
+		}
+		if m.IsDirectDevice(adbd.Addr()) {
+			t.Error("rejected device added to the router")
+		}
+	})
+
+	t.Run("no key", func(t *testing.T) {
+		r := NewDirectRunner(adbd.Addr(), nil)
+		defer r.Close()
+		if err := r.Connect(ctx); !errors.Is(err, ErrUnauthorized) {
+			t.Fatalf("Connect without key = %v, want ErrUnauthorized", err)
+		}
+	})
+
+	t.Run("new key accepted", func(t *testing.T) {
+		adbd.SetAcceptNewKeys(true)
+		defer adbd.SetAcceptNewKeys(false)
+
+		r := NewDirectRunner(adbd.Addr(), other)
+		defer r.Close()
+		if err := r.Connect(ctx); err != nil {
+			t.Fatalf("Connect after accepting key: %v", err)
+		}
+		// 公钥被接受后，再次连接只需签名
+		adbd.SetAcceptNewKeys(false)
+		r2 := NewDirectRunner(adbd.Addr(), other)
+		defer r2.Close()
+		if err := r2.Connect(ctx); err != nil {
+			t.Fatalf("reconnect with accepted key: %v", err)
+		}
+	})
+}
+
+func TestDeviceRouterDirect(t *testing.T) {
+	key, err := GenerateKey(filepath.Join(t.TempDir(), "adbkey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	adbd, err := NewFakeAdbd(testBanner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer adbd.Close()
+	adbd.Authorize(&key.PublicKey)
+	adbd.SetShellResult("id", ShellResult{Stdout: "uid=2000(shell)\n", ExitCode: 0})
+
+	// 默认执行器没有登记任何调用，直连设备的命令不应交给它
+	m := newTestManager(NewScriptedRunner())
+	if err := m.ConnectDirect(adbd.Addr(), key); err != nil {
+		t.Fatalf("ConnectDirect: %v", err)
+	}
+	result, err := m.ExecuteShell(adbd.Addr(), "id")
+	if err != nil || result.Stdout != "uid=2000(shell)\n" || result.ExitCode != 0 {
+		t.Fatalf("result = %+v, err = %v", result, err)
+	}
+}
+
+func TestDirectInstallWithoutAPK(t *testing.T) {
+	key, err := GenerateKey(filepath.Join(t.TempDir(), "adbkey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	adbd, err := NewFakeAdbd(testBanner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer adbd.Close()
+	adbd.Authorize(&key.PublicKey)
+
+	r := NewDirectRunner(adbd.Addr(), key)
+	defer r.Close()
+	if _, err := r.CombinedOutput(context.Background(), "install"); !errors.Is(err, errMissingAPK) {
+		t.Fatalf("err = %v, want errMissingAPK", err)
+	}
+}
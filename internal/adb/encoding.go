@@ -0,0 +1,263 @@
+package adb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/transform"
+)
+
+// Encoding 命令输出的字符编码
+type Encoding int
+
+const (
+	EncodingAuto     Encoding = iota // 自动识别：有效的 UTF-8 原样保留，其余按行在 GBK、Shift-JIS、Latin-1 中选择
+	EncodingUTF8                     // UTF-8，无效字节显示为 U+FFFD
+	EncodingGBK                      // GBK / GB18030，简体中文固件
+	EncodingShiftJIS                 // Shift-JIS，日文固件
+	EncodingLatin1                   // ISO-8859-1，每个字节对应一个字符，不会丢失数据
+	EncodingRaw                      // 原始字节，不做任何转换（字符串中可能含无效的 UTF-8）
+)
+
+// encodingNames 编码在配置文件中的名称，顺序与 Encoding 一致
+var encodingNames = []string{"auto", "utf-8", "gbk", "shift-jis", "latin-1", "raw"}
+
+func (e Encoding) String() string {
+	switch e {
+	case EncodingAuto:
+		return "自动"
+	case EncodingUTF8:
+		return "UTF-8"
+	case EncodingGBK:
+		return "GBK / GB18030"
+	case EncodingShiftJIS:
+		return "Shift-JIS"
+	case EncodingLatin1:
+		return "Latin-1"
+	case EncodingRaw:
+		return "原始字节"
+	}
+	return fmt.Sprintf("Encoding(%d)", int(e))
+}
+
+// ParseEncoding 按名称（auto、utf-8、gbk、shift-jis、latin-1、raw，不区分大小写）解析编码
+func ParseEncoding(name string) (Encoding, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "", "auto":
+		return EncodingAuto, nil
+	case "utf8":
+		return EncodingUTF8, nil
+	case "gb18030", "gb2312":
+		return EncodingGBK, nil
+	case "shift_jis", "sjis":
+		return EncodingShiftJIS, nil
+	case "latin1", "iso-8859-1":
+		return EncodingLatin1, nil
+	}
+	for i, n := range encodingNames {
+		if n == name {
+			return Encoding(i), nil
+		}
+	}
+	return EncodingAuto, fmt.Errorf("未知的编码: %s", name)
+}
+
+// MarshalText 配置文件中以名称保存编码
+func (e Encoding) MarshalText() ([]byte, error) {
+	if e < 0 || int(e) >= len(encodingNames) {
+		return nil, fmt.Errorf("未知的编码: %d", int(e))
+	}
+	return []byte(encodingNames[e]), nil
+}
+
+// UnmarshalText 读取以名称保存的编码
+func (e *Encoding) UnmarshalText(text []byte) error {
+	enc, err := ParseEncoding(string(text))
+	if err != nil {
+		return err
+	}
+	*e = enc
+	return nil
+}
+
+// textEncoding 对应的单一编码，自动、UTF-8 与原始字节为 nil
+func (e Encoding) textEncoding() encoding.Encoding {
+	switch e {
+	case EncodingGBK:
+		return simplifiedchinese.GB18030 // GBK 的超集
+	case EncodingShiftJIS:
+		return japanese.ShiftJIS
+	case EncodingLatin1:
+		return charmap.ISO8859_1
+	}
+	return nil
+}
+
+// Decode 将命令输出转换为字符串，除原始字节外结果都是有效的 UTF-8
+// 无法解码的字节替换为 U+FFFD，不会被悄悄删除
+func (e Encoding) Decode(data []byte) string {
+	switch e {
+	case EncodingAuto:
+		return decodeAuto(data)
+	case EncodingRaw:
+		return string(data)
+	}
+	if enc := e.textEncoding(); enc != nil {
+		if decoded, _, err := transform.Bytes(enc.NewDecoder(), data); err == nil {
+			return string(decoded)
+		}
+	}
+	return strings.ToValidUTF8(string(data), string(utf8.RuneError))
+}
+
+// Encode 将字符串转换为该编码的字节，用于向设备发送输入；无法表示的字符替换为 ?
+// 自动、UTF-8 与原始字节原样返回
+func (e Encoding) Encode(s string) []byte {
+	enc := e.textEncoding()
+	if enc == nil {
+		return []byte(s)
+	}
+	encoded, err := enc.NewEncoder().Bytes([]byte(s))
+	if err == nil {
+		return encoded
+	}
+	var buf bytes.Buffer
+	for _, r := range s {
+		b, err := enc.NewEncoder().Bytes([]byte(string(r)))
+		if err != nil {
+			b = []byte{'?'}
+		}
+		buf.Write(b)
+	}
+	return buf.Bytes()
+}
+
+// NewReader 返回把 r 的内容逐步转换为 UTF-8 的 Reader，适合交互式终端等持续的输出
+// 自动识别需要整行数据，此处与 UTF-8、原始字节一样不做转换
+func (e Encoding) NewReader(r io.Reader) io.Reader {
+	if enc := e.textEncoding(); enc != nil {
+		return transform.NewReader(r, enc.NewDecoder())
+	}
+	return r
+}
+
+// decodeAuto 自动识别编码：整体是有效的 UTF-8 时原样返回，否则逐行识别
+// 同一输出中不同行可以是不同编码（例如文件名编码各异的目录列表）
+func decodeAuto(data []byte) string {
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	var b strings.Builder
+	b.Grow(len(data))
+	for len(data) > 0 {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line = data[:i+1]
+		}
+		data = data[len(line):]
+		if utf8.Valid(line) {
+			b.Write(line)
+			continue
+		}
+		b.WriteString(guessEncoding(line).Decode(line))
+	}
+	return b.String()
+}
+
+// legacyScorers 自动识别时尝试的非 UTF-8 编码及其评分，得分相同时靠前的优先
+var legacyScorers = []struct {
+	encoding Encoding
+	score    func(line []byte) (int, bool)
+}{
+	{EncodingGBK, gbkScore},
+	{EncodingShiftJIS, shiftJISScore},
+	{EncodingLatin1, latin1Score},
+}
+
+// guessEncoding 为一行非 UTF-8 的输出选择最可能的编码，Latin-1 可以解码任何字节，作为兜底
+func guessEncoding(line []byte) Encoding {
+	best, bestScore := EncodingLatin1, -1
+	for _, s := range legacyScorers {
+		if score, ok := s.score(line); ok && score > bestScore {
+			best, bestScore = s.encoding, score
+		}
+	}
+	return best
+}
+
+// gbkScore 按 GBK / GB18030 的字节结构评分，结构不合法时 ok 为 false
+// 两个字节都不小于 0xA1 的字符属于 GB2312，即常用汉字与符号，得分较高
+func gbkScore(b []byte) (int, bool) {
+	score := 0
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c < 0x80:
+			i++
+		case c == 0x80 || c == 0xff || i+1 >= len(b):
+			return 0, false
+		case b[i+1] >= '0' && b[i+1] <= '9':
+			// GB18030 四字节序列
+			if i+3 >= len(b) || b[i+2] < 0x81 || b[i+2] == 0xff || b[i+3] < '0' || b[i+3] > '9' {
+				return 0, false
+			}
+			i += 4
+		case b[i+1] >= 0x40 && b[i+1] != 0x7f && b[i+1] != 0xff:
+			if c >= 0xa1 && b[i+1] >= 0xa1 {
+				score += 2
+			}
+			i += 2
+		default:
+			return 0, false
+		}
+	}
+	return score, true
+}
+
+// shiftJISScore 按 Shift-JIS 的字节结构评分，结构不合法时 ok 为 false
+// 平假名与片假名（0x82、0x83 区）是日文独有的，得分最高；半角片假名与中文的 GBK 字节重叠，不计分
+func shiftJISScore(b []byte) (int, bool) {
+	score := 0
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c < 0x80:
+			i++
+		case c >= 0xa1 && c <= 0xdf:
+			i++
+		case (c >= 0x81 && c <= 0x9f || c >= 0xe0 && c <= 0xef) && i+1 < len(b) && b[i+1] >= 0x40 && b[i+1] <= 0xfc && b[i+1] != 0x7f:
+			if c == 0x82 || c == 0x83 {
+				score += 3
+			} else {
+				score += 2
+			}
+			i += 2
+		default:
+			return 0, false
+		}
+	}
+	return score, true
+}
+
+// latin1Score 为 Latin-1 评分：西文中的重音字母与符号通常单独出现在 ASCII 字符之间，
+// 而双字节编码的字节总是成对出现
+func latin1Score(b []byte) (int, bool) {
+	score := 0
+	for i, c := range b {
+		if c < 0x80 {
+			continue
+		}
+		if (i == 0 || b[i-1] < 0x80) && (i+1 == len(b) || b[i+1] < 0x80) {
+			score += 2
+		}
+	}
+	return score, true
+}
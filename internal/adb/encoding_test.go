@@ -0,0 +1,196 @@
+package adb
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// 各编码的样例行，均为设备上常见的文件名或提示信息
+var (
+	gbkLine      = []byte("\xd6\xd0\xce\xc4\xce\xc4\xbc\xfe.txt\n")               // 中文文件.txt
+	shiftJISLine = []byte("\x83\x74\x83\x40\x83\x43\x83\x8b.txt\n")               // ファイル.txt
+	hiraganaLine = []byte("\x82\xd0\x82\xe7\x82\xaa\x82\xc8\n")                   // ひらがな
+	latin1Line   = []byte("Caf\xe9 Z\xfcrich r\xe9sum\xe9\n")                     // Café Zürich résumé
+	utf8Line     = []byte("na\xc3\xafve \xe2\x9c\x93 \xe4\xb8\xad\xe6\x96\x87\n") // naïve ✓ 中文
+)
+
+func TestGuessEncoding(t *testing.T) {
+	tests := []struct {
+		name string
+		line []byte
+		want Encoding
+	}{
+		{"GBK 汉字", gbkLine, EncodingGBK},
+		{"GBK 报错", []byte("ls: /sdcard/\xb2\xe2\xca\xd4: \xc3\xbb\xd3\xd0\xc8\xa8\xcf\xde\n"), EncodingGBK}, // ls: /sdcard/测试: 没有权限
+		{"GB18030 四字节", []byte("\xd6\xd0\xce\xc4\x95\x32\x82\x36.txt\n"), EncodingGBK},                      // 中文𠀀.txt
+		{"Shift-JIS 片假名", shiftJISLine, EncodingShiftJIS},
+		{"Shift-JIS 平假名", hiraganaLine, EncodingShiftJIS},
+		{"Latin-1 重音字母", latin1Line, EncodingLatin1},
+		{"Latin-1 单个符号", []byte("\xa9 2024\n"), EncodingLatin1},
+		{"结构都不合法时兜底为 Latin-1", []byte("\xff\xfe\x80\n"), EncodingLatin1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := guessEncoding(tt.line); got != tt.want {
+				t.Errorf("guessEncoding(%q) = %v, want %v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeAutoMixed(t *testing.T) {
+	var input bytes.Buffer
+	for _, line := range [][]byte{[]byte("total 5\n"), gbkLine, shiftJISLine, latin1Line, utf8Line, hiraganaLine} {
+		input.Write(line)
+	}
+	input.WriteString("Z\xfcrich") // 最后一行没有换行
+
+	want := "total 5\n" +
+		"中文文件.txt\n" +
+		"ファイル.txt\n" +
+		"Café Zürich résumé\n" +
+		"naïve ✓ 中文\n" +
+		"ひらがな\n" +
+		"Zürich"
+	got := decodeAuto(input.Bytes())
+	if got != want {
+		t.Fatalf("decodeAuto\n got  %q\n want %q", got, want)
+	}
+	if !utf8.ValidString(got) {
+		t.Fatalf("decodeAuto 结果不是有效的 UTF-8: %q", got)
+	}
+}
+
+func TestDecodeAutoValidUTF8(t *testing.T) {
+	// 整体是有效的 UTF-8 时原样返回，即使其中的字节也能按其他编码解释
+	for _, s := range []string{"", "plain ascii\n", "中文\nファイル\nCafé\n", "\r\n\x00\t"} {
+		if got := decodeAuto([]byte(s)); got != s {
+			t.Errorf("decodeAuto(%q) = %q", s, got)
+		}
+	}
+}
+
+func TestEncodingDecode(t *testing.T) {
+	tests := []struct {
+		enc  Encoding
+		data []byte
+		want string
+	}{
+		{EncodingGBK, gbkLine, "中文文件.txt\n"},
+		{EncodingShiftJIS, shiftJISLine, "ファイル.txt\n"},
+		{EncodingLatin1, latin1Line, "Café Zürich résumé\n"},
+		{EncodingUTF8, utf8Line, "naïve ✓ 中文\n"},
+		// 无效字节替换为 U+FFFD 而不是被删除
+		{EncodingUTF8, []byte("a\xffb"), "a�b"},
+		{EncodingRaw, []byte("a\xffb"), "a\xffb"},
+		{EncodingAuto, append(append([]byte(nil), gbkLine...), utf8Line...), "中文文件.txt\nnaïve ✓ 中文\n"},
+		{EncodingGBK, []byte("\xd6\xd0\xce\xc4\x95\x32\x82\x36.txt"), "中文𠀀.txt"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.enc.Decode(tt.data); got != tt.want {
+			t.Errorf("%v.Decode(%q) = %q, want %q", tt.enc, tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestEncodingEncode(t *testing.T) {
+	tests := []struct {
+		enc  Encoding
+		s    string
+		want []byte
+	}{
+		{EncodingGBK, "中文文件.txt\n", gbkLine},
+		{EncodingShiftJIS, "ファイル.txt\n", shiftJISLine},
+		{EncodingLatin1, "Café Zürich résumé\n", latin1Line},
+		{EncodingUTF8, "中文", []byte("中文")},
+		{EncodingAuto, "中文", []byte("中文")},
+		// 无法表示的字符替换为 ?
+		{EncodingLatin1, "a中b", []byte("a?b")},
+		{EncodingShiftJIS, "ア😀イ", []byte("\x83\x41?\x83\x43")},
+	}
+
+	for _, tt := range tests {
+		if got := tt.enc.Encode(tt.s); !bytes.Equal(got, tt.want) {
+			t.Errorf("%v.Encode(%q) = %q, want %q", tt.enc, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestEncodingNewReader(t *testing.T) {
+	var got bytes.Buffer
+	if _, err := got.ReadFrom(EncodingGBK.NewReader(bytes.NewReader(gbkLine))); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != "中文文件.txt\n" {
+		t.Fatalf("NewReader = %q", got.String())
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	tests := []struct {
+		name string
+		want Encoding
+	}{
+		{"", EncodingAuto},
+		{"auto", EncodingAuto},
+		{"UTF-8", EncodingUTF8},
+		{"utf8", EncodingUTF8},
+		{"gbk", EncodingGBK},
+		{"GB18030", EncodingGBK},
+		{"gb2312", EncodingGBK},
+		{"Shift-JIS", EncodingShiftJIS},
+		{"sjis", EncodingShiftJIS},
+		{"latin-1", EncodingLatin1},
+		{"ISO-8859-1", EncodingLatin1},
+		{" raw ", EncodingRaw},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseEncoding(tt.name)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseEncoding(%q) = %v, %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+	if _, err := ParseEncoding("ebcdic"); err == nil {
+		t.Error("ParseEncoding(\"ebcdic\") 没有返回错误")
+	}
+}
+
+func TestWrapperProfileEncodingJSON(t *testing.T) {
+	for enc := EncodingAuto; enc <= EncodingRaw; enc++ {
+		data, err := json.Marshal(WrapperProfile{Kind: WrapperBusybox, Encoding: enc})
+		if err != nil {
+			t.Fatalf("Marshal %v: %v", enc, err)
+		}
+		// 自动识别是默认值，不写入配置文件
+		if enc == EncodingAuto {
+			if strings.Contains(string(data), `"encoding"`) {
+				t.Errorf("Marshal %v = %s, want no encoding field", enc, data)
+			}
+		} else if !strings.Contains(string(data), `"encoding":"`+encodingNames[enc]+`"`) {
+			t.Errorf("Marshal %v = %s, want encoding name %q", enc, data, encodingNames[enc])
+		}
+
+		var profile WrapperProfile
+		if err := json.Unmarshal(data, &profile); err != nil {
+			t.Fatalf("Unmarshal %s: %v", data, err)
+		}
+		if profile.Encoding != enc {
+			t.Errorf("Unmarshal %s: Encoding = %v, want %v", data, profile.Encoding, enc)
+		}
+	}
+
+	// 旧配置文件没有 encoding 字段
+	var profile WrapperProfile
+	if err := json.Unmarshal([]byte(`{"kind":0}`), &profile); err != nil || profile.Encoding != EncodingAuto {
+		t.Errorf("Unmarshal without encoding = %+v, %v", profile, err)
+	}
+	if err := json.Unmarshal([]byte(`{"kind":0,"encoding":"ebcdic"}`), &profile); err == nil {
+		t.Error("Unmarshal 未知编码没有返回错误")
+	}
+}
@@ -0,0 +1,111 @@
+package adb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// adb 调用失败的分类，调用方使用 errors.Is 判断
+var (
+	ErrDeviceNotFound        = errors.New("设备未找到")
+	ErrUnauthorized          = errors.New("设备未授权")
+	ErrOffline               = errors.New("设备离线")
+	ErrServerVersionMismatch = errors.New("adb 客户端与服务器版本不匹配")
+	ErrTimeout               = errors.New("命令执行超时")
+)
+
+// CommandError adb 命令执行失败，调用方使用 errors.As 获取退出码与错误输出
+// 能识别出失败原因时，errors.Is 对相应的 Err* 分类成立
+type CommandError struct {
+	Args     []string // adb 参数（不含 adb 本身）
+	ExitCode int      // 退出码，未能得到退出码时为 -1
+	Stderr   string   // 错误输出（CombinedOutput 时为全部输出）
+	Kind     error    // 失败分类，未识别时为 nil
+	Err      error    // 原始错误
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("adb %s 失败", strings.Join(e.Args, " "))
+	if e.ExitCode >= 0 {
+		msg += fmt.Sprintf(" (退出码 %d)", e.ExitCode)
+	}
+	if e.Kind != nil {
+		msg += ": " + e.Kind.Error()
+	}
+	if detail := strings.TrimSpace(e.Stderr); detail != "" {
+		msg += ": " + detail
+	} else if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap 同时暴露失败分类与原始错误
+func (e *CommandError) Unwrap() []error {
+	if e.Kind != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Err}
+}
+
+// newCommandError 根据执行结果构造 CommandError
+func newCommandError(args []string, output []byte, err error) *CommandError {
+	stderr := EncodingAuto.Decode(output)
+	kind := classifyOutput(stderr)
+	if kind == nil {
+		kind = classifyError(err)
+	}
+
+	return &CommandError{
+		Args:     args,
+		ExitCode: exitCode(err),
+		Stderr:   stderr,
+		Kind:     kind,
+		Err:      err,
+	}
+}
+
+// exitCode 从错误中提取进程退出码
+func exitCode(err error) int {
+	var coder interface{ ExitCode() int }
+	if errors.As(err, &coder) {
+		return coder.ExitCode()
+	}
+	return -1
+}
+
+// classifyOutput 根据 adb 自身的报错信息识别失败原因
+func classifyOutput(output string) error {
+	lower := strings.ToLower(output)
+	switch {
+	case strings.Contains(lower, "doesn't match this client"):
+		return ErrServerVersionMismatch
+	case strings.Contains(lower, "device unauthorized"):
+		return ErrUnauthorized
+	case strings.Contains(lower, "device offline"):
+		return ErrOffline
+	case strings.Contains(lower, "device not found"),
+		strings.Contains(lower, "error: device '") && strings.Contains(lower, "' not found"),
+		strings.Contains(lower, "no devices/emulators found"):
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+// classifyError 根据错误本身识别失败原因（协议错误、超时、直连认证失败）
+func classifyError(err error) error {
+	var serverErr *ServerError
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrTimeout
+	case errors.Is(err, ErrUnauthorized):
+		return ErrUnauthorized
+	case errors.As(err, &serverErr):
+		return classifyOutput(serverErr.Message)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package adb
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// FakeAdbd 进程内的设备端 adbd 模拟实现，使用与真实 adbd 相同的传输协议和 RSA 认证
+// 用于在没有真实设备的环境中验证 DirectRunner
+type FakeAdbd struct {
+	listener net.Listener
+	banner   string
+	device   *fakeDevice
+
+	mu         sync.Mutex
+	authorized []*rsa.PublicKey
+	acceptNew  bool
+	conns      map[net.Conn]struct{}
+	wg         sync.WaitGroup
+}
+
+// NewFakeAdbd 在本地随机端口启动模拟 adbd
+// banner 例如 "device::ro.product.name=x;ro.product.model=y;ro.product.device=z;features=shell_v2,cmd"
+func NewFakeAdbd(banner string) (*FakeAdbd, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	d := &FakeAdbd{
+		listener: listener,
+		banner:   banner,
+		device:   newFakeDevice(listener.Addr().String(), "device", ""),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	d.wg.Add(1)
+	go d.serve()
+	return d, nil
+}
+
+// Addr 返回监听地址
+func (d *FakeAdbd) Addr() string {
+	return d.listener.Addr().String()
+}
+
+// Close 停止模拟 adbd 并断开所有连接
+func (d *FakeAdbd) Close() error {
+	err := d.listener.Close()
+	d.mu.Lock()
+	for conn := range d.conns {
+		conn.Close()
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+	return err
+}
+
+// Authorize 信任指定公钥（相当于写入设备的 adb_keys）
+func (d *FakeAdbd) Authorize(pub *rsa.PublicKey) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.authorized = append(d.authorized, pub)
+}
+
+// SetAcceptNewKeys 设置收到新公钥时是否自动接受（相当于用户在设备上点击允许）
+func (d *FakeAdbd) SetAcceptNewKeys(accept bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.acceptNew = accept
+}
+
+// SetShell 登记 shell 命令的输出
+func (d *FakeAdbd) SetShell(command, output string) {
+	d.device.setShell(command, output)
+}
+
+// SetShellResult 登记 shell 命令的完整结果（stdout、stderr 与退出码）
+func (d *FakeAdbd) SetShellResult(command string, result ShellResult) {
+	d.device.setShellResult(command, result)
+}
+
+// SetFile 在设备上放置文件
+func (d *FakeAdbd) SetFile(path string, file FakeFile) {
+	d.device.setFile(path, file)
+}
+
+// File 读取设备上的文件
+func (d *FakeAdbd) File(path string) (FakeFile, bool) {
+	return d.device.file(path)
+}
+
+func (d *FakeAdbd) serve() {
+	defer d.wg.Done()
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			return
+		}
+		d.mu.Lock()
+		d.conns[conn] = struct{}{}
+		d.mu.Unlock()
+
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.handle(conn)
+
+			d.mu.Lock()
+			delete(d.conns, conn)
+			d.mu.Unlock()
+		}()
+	}
+}
+
+// handle 完成设备端握手，随后处理主机打开的服务
+func (d *FakeAdbd) handle(conn net.Conn) {
+	if !d.handshake(conn) {
+		conn.Close()
+		return
+	}
+
+	c := newAdbdConn(conn, "", adbdMaxData)
+	c.onOpen = func(service string) func(io.ReadWriteCloser) {
+		if !d.device.supports(service) {
+			return nil
+		}
+		return func(rw io.ReadWriteCloser) { d.device.serve(service, rw) }
+	}
+	c.readLoop()
+}
+
+// handshake 设备端握手：CNXN -> AUTH(令牌) -> 校验签名或接受公钥 -> CNXN
+func (d *FakeAdbd) handshake(conn net.Conn) bool {
+	msg, err := readMessage(conn)
+	if err != nil || msg.command != cmdCNXN {
+		return false
+	}
+
+	token := make([]byte, 20)
+	rand.Read(token)
+	if writeMessage(conn, adbdMessage{command: cmdAUTH, arg0: authToken, data: token}) != nil {
+		return false
+	}
+
+	for {
+		msg, err := readMessage(conn)
+		if err != nil || msg.command != cmdAUTH {
+			return false
+		}
+
+		switch msg.arg0 {
+		case authSignature:
+			if d.verify(token, msg.data) {
+				return d.sendBanner(conn)
+			}
+			rand.Read(token)
+			if writeMessage(conn, adbdMessage{command: cmdAUTH, arg0: authToken, data: token}) != nil {
+				return false
+			}
+
+		case authRSAPublicKey:
+			pub, err := ParseAndroidPublicKey(string(bytes.TrimRight(msg.data, "\x00")))
+			if err != nil {
+				return false
+			}
+			d.mu.Lock()
+			accept := d.acceptNew
+			d.mu.Unlock()
+			if !accept {
+				return false
+			}
+			d.Authorize(pub)
+			return d.sendBanner(conn)
+
+		default:
+			return false
+		}
+	}
+}
+
+func (d *FakeAdbd) verify(token, signature []byte) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, pub := range d.authorized {
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA1, token, signature) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *FakeAdbd) sendBanner(conn net.Conn) bool {
+	banner := fmt.Sprintf("%s\x00", d.banner)
+	return writeMessage(conn, adbdMessage{command: cmdCNXN, arg0: adbdVersion, arg1: adbdMaxData, data: []byte(banner)}) == nil
+}
@@ -0,0 +1,487 @@
+package adb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeDevice FakeServer / FakeAdbd 共用的模拟设备，实现设备端的 shell 与 sync 服务
+type fakeDevice struct {
+	serial   string
+	state    string
+	attrs    string // devices -l 中的附加字段，例如 "product:x model:y device:z transport_id:1"
+	features string // adbd 声明的特性，逗号分隔
+
+	mu       sync.Mutex
+	shell    map[string]ShellResult
+	files    map[string]*FakeFile
+	reverses []ForwardRule // 反向转发规则，只做登记
+	jdwp     []int         // track-jdwp 返回的进程
+}
+
+// fakeFeatures 模拟设备默认声明的特性
+const fakeFeatures = "shell_v2,cmd,stat_v2,ls_v2,fixed_push_mkdir,apex,abb,abb_exec"
+
+// FakeFile 模拟设备上的文件
+type FakeFile struct {
+	Data    []byte
+	Mode    os.FileMode
+	ModTime time.Time
+	// Size 大于 len(Data) 时为文件大小，Data 之后的部分读作零字节，用于模拟 4 GiB 以上的文件
+	Size int64
+}
+
+// size 文件大小
+func (f FakeFile) size() int64 {
+	return max(f.Size, int64(len(f.Data)))
+}
+
+// reader 从 offset 开始读取文件内容
+func (f FakeFile) reader(offset int64) io.Reader {
+	data := f.Data[min(offset, int64(len(f.Data))):]
+	zeros := f.size() - max(offset, int64(len(f.Data)))
+	return io.MultiReader(bytes.NewReader(data), io.LimitReader(zeroReader{}, zeros))
+}
+
+// zeroReader 无限的零字节
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func newFakeDevice(serial, state, attrs string) *fakeDevice {
+	return &fakeDevice{
+		serial:   serial,
+		state:    state,
+		attrs:    attrs,
+		features: fakeFeatures,
+		shell:    make(map[string]ShellResult),
+		files:    make(map[string]*FakeFile),
+	}
+}
+
+func (d *fakeDevice) setShell(command, output string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.shell[command] = ShellResult{Stdout: output}
+}
+
+func (d *fakeDevice) setShellResult(command string, result ShellResult) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.shell[command] = result
+}
+
+func (d *fakeDevice) setJDWP(pids []int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.jdwp = append([]int(nil), pids...)
+}
+
+func (d *fakeDevice) setFile(path string, file FakeFile) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.files[path] = &file
+}
+
+func (d *fakeDevice) file(path string) (FakeFile, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if file, ok := d.files[path]; ok {
+		return *file, true
+	}
+	return FakeFile{}, false
+}
+
+// shellResult 返回登记的命令结果，未登记时模拟 sh 的 not found
+// 带退出码标记的命令按 sh 的行为在输出末尾追加标记
+func (d *fakeDevice) shellResult(command string) ShellResult {
+	if inner, ok := strings.CutPrefix(command, "( "); ok {
+		if inner, ok := strings.CutSuffix(inner, "\n); "+exitStatusVar+"=$?; echo; echo "+exitSentinel+"$"+exitStatusVar); ok {
+			result := d.shellResult(inner)
+			result.Stdout = fmt.Sprintf("%s%s\n%s%d\n", result.Stdout, result.Stderr, exitSentinel, result.ExitCode)
+			result.Stderr = ""
+			result.ExitCode = 0
+			return result
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if result, ok := d.shell[command]; ok {
+		return result
+	}
+	name := command
+	if fields := strings.Fields(command); len(fields) > 0 {
+		name = fields[0]
+	}
+	return ShellResult{Stderr: fmt.Sprintf("/system/bin/sh: %s: not found\n", name), ExitCode: 127}
+}
+
+// serveTerminal 模拟交互式 shell（伪终端）：回显输入，回车后执行登记的命令，另外支持 exit、stty size 与 echo $TERM
+// v2 为 true 时按 shell_v2 收发数据包并处理窗口大小，options 为服务名中的选项（如 TERM=xterm,pty）
+func (d *fakeDevice) serveTerminal(rw io.ReadWriter, v2 bool, options string) {
+	term := ""
+	for _, option := range strings.Split(options, ",") {
+		if value, ok := strings.CutPrefix(option, "TERM="); ok {
+			term = value
+		}
+	}
+	rows, cols := 24, 80
+	prompt := d.serial + ":/ $ "
+
+	// 伪终端输出的换行为 \r\n
+	output := func(text string) {
+		text = strings.ReplaceAll(text, "\n", "\r\n")
+		if v2 {
+			writeShellPacket(rw, shellStdout, []byte(text))
+		} else {
+			io.WriteString(rw, text)
+		}
+	}
+
+	var line []byte
+	// input 处理一段键盘输入，输入 exit 时返回 true
+	input := func(data []byte) bool {
+		var echo []byte
+		for _, b := range data {
+			switch b {
+			case '\r', '\n':
+				output(string(echo) + "\n")
+				echo = echo[:0]
+				command := strings.TrimSpace(string(line))
+				line = line[:0]
+				switch command {
+				case "":
+				case "exit":
+					return true
+				case "stty size":
+					output(fmt.Sprintf("%d %d\n", rows, cols))
+				case "echo $TERM":
+					output(term + "\n")
+				default:
+					result := d.shellResult(command)
+					output(result.Stdout + result.Stderr)
+				}
+				output(prompt)
+			case 0x7f, '\b':
+				if len(line) > 0 {
+					line = line[:len(line)-1]
+					echo = append(echo, "\b \b"...)
+				}
+			case 0x03:
+				line = line[:0]
+				output(string(echo) + "^C\n" + prompt)
+				echo = echo[:0]
+			default:
+				line = append(line, b)
+				echo = append(echo, b)
+			}
+		}
+		if len(echo) > 0 {
+			output(string(echo))
+		}
+		return false
+	}
+
+	output(prompt)
+	if !v2 {
+		buf := make([]byte, 1024)
+		for {
+			n, err := rw.Read(buf)
+			if err != nil || input(buf[:n]) {
+				return
+			}
+		}
+	}
+	for {
+		id, data, err := readShellPacket(rw)
+		if err != nil {
+			return
+		}
+		switch id {
+		case shellStdin:
+			if input(data) {
+				writeShellPacket(rw, shellExit, []byte{0})
+				return
+			}
+		case shellWindowSize:
+			fmt.Sscanf(string(data), "%dx%d", &rows, &cols)
+		case shellCloseStdin:
+			writeShellPacket(rw, shellExit, []byte{0})
+			return
+		}
+	}
+}
+
+// execOutput exec 服务的原始输出，支持断点续传使用的 "tail -c +N 路径 2>/dev/null"
+func (d *fakeDevice) execOutput(command string) []byte {
+	fields := strings.Fields(command)
+	if len(fields) == 5 && fields[0] == "tail" && fields[1] == "-c" && fields[4] == "2>/dev/null" {
+		start, err := strconv.ParseInt(strings.TrimPrefix(fields[2], "+"), 10, 64)
+		file, exists := d.file(strings.Trim(fields[3], "'"))
+		if err != nil || !exists || start < 1 || start > file.size()+1 {
+			return nil
+		}
+		data, _ := io.ReadAll(file.reader(start - 1))
+		return data
+	}
+	result := d.shellResult(command)
+	return []byte(result.Stdout + result.Stderr)
+}
+
+// list 返回目录下的直接子项，更深层的文件以子目录的形式出现
+func (d *fakeDevice) list(dir string) []SyncDirEntry {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	seen := make(map[string]bool)
+	entries := make([]SyncDirEntry, 0)
+	for path, file := range d.files {
+		name, ok := strings.CutPrefix(path, prefix)
+		if !ok || name == "" {
+			continue
+		}
+		entry := SyncDirEntry{Name: name, SyncStat: SyncStat{Mode: file.Mode, Size: uint64(file.size()), ModTime: file.ModTime}}
+		if sub, _, nested := strings.Cut(name, "/"); nested {
+			entry = SyncDirEntry{Name: sub, SyncStat: SyncStat{Mode: os.ModeDir | 0771, ModTime: time.Unix(0, 0)}}
+		}
+		if !seen[entry.Name] {
+			seen[entry.Name] = true
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// isDir 路径下存在文件时视为目录
+func (d *fakeDevice) isDir(dir string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	dir = strings.TrimSuffix(dir, "/")
+	prefix := dir + "/"
+	for path := range d.files {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return dir == "" || dir == "/sdcard" || dir == "/data/local/tmp"
+}
+
+// supports 是否支持该设备服务
+func (d *fakeDevice) supports(service string) bool {
+	return strings.HasPrefix(service, "shell:") || strings.HasPrefix(service, "shell,v2,") ||
+		strings.HasPrefix(service, "exec:") || service == "root:" || service == "sync:" ||
+		strings.HasPrefix(service, "tcpip:") ||
+		strings.HasPrefix(service, "reverse:") || service == "track-jdwp"
+}
+
+// serve 处理已打开的设备服务
+func (d *fakeDevice) serve(service string, rw io.ReadWriter) {
+	switch {
+	case service == "shell:":
+		d.serveTerminal(rw, false, "")
+	case strings.HasPrefix(service, "shell,v2,") && strings.HasSuffix(service, ",pty:"):
+		d.serveTerminal(rw, true, strings.TrimSuffix(strings.TrimPrefix(service, "shell,v2,"), ":"))
+	case strings.HasPrefix(service, "shell:"):
+		// 旧协议不区分 stdout 与 stderr，也不回传退出码
+		result := d.shellResult(strings.TrimPrefix(service, "shell:"))
+		io.WriteString(rw, result.Stdout+result.Stderr)
+	case strings.HasPrefix(service, "shell,v2,"):
+		_, command, _ := strings.Cut(service, ":")
+		result := d.shellResult(command)
+		if result.Stdout != "" {
+			writeShellPacket(rw, shellStdout, []byte(result.Stdout))
+		}
+		if result.Stderr != "" {
+			writeShellPacket(rw, shellStderr, []byte(result.Stderr))
+		}
+		writeShellPacket(rw, shellExit, []byte{byte(result.ExitCode)})
+	case strings.HasPrefix(service, "exec:"):
+		rw.Write(d.execOutput(strings.TrimPrefix(service, "exec:")))
+	case service == "root:":
+		io.WriteString(rw, "restarting adbd as root\n")
+	case strings.HasPrefix(service, "tcpip:"):
+		io.WriteString(rw, "restarting in TCP mode port: "+strings.TrimPrefix(service, "tcpip:")+"\n")
+	case service == "sync:":
+		d.handleSync(rw)
+	case strings.HasPrefix(service, "reverse:"):
+		d.reverse(rw, strings.TrimPrefix(service, "reverse:"))
+	case service == "track-jdwp":
+		d.mu.Lock()
+		var list strings.Builder
+		for _, pid := range d.jdwp {
+			fmt.Fprintf(&list, "%d\n", pid)
+		}
+		d.mu.Unlock()
+		fmt.Fprintf(rw, "%04x%s", list.Len(), list.String())
+	}
+}
+
+// reverse 处理 reverse: 服务，设备端只回复一次执行结果
+func (d *fakeDevice) reverse(rw io.ReadWriter, service string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if service == "list-forward" {
+		writeOkayString(rw, formatFakeForwards(d.reverses))
+		return
+	}
+	rules, port, err := applyFakeForward(d.reverses, "UsbFfs", service)
+	if err != nil {
+		writeFail(rw, err.Error())
+		return
+	}
+	d.reverses = rules
+	if port != "" {
+		writeOkayString(rw, port)
+		return
+	}
+	io.WriteString(rw, "OKAY")
+}
+
+// handleSync 处理 sync 服务请求
+func (d *fakeDevice) handleSync(rw io.ReadWriter) {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(rw, header); err != nil {
+			return
+		}
+		id := string(header[:4])
+		length := binary.LittleEndian.Uint32(header[4:])
+		if id == "QUIT" {
+			return
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(rw, payload); err != nil {
+			return
+		}
+		path := string(payload)
+		file, exists := d.file(path)
+
+		switch id {
+		case "STAT":
+			reply := make([]byte, 16)
+			copy(reply, "STAT")
+			if exists {
+				binary.LittleEndian.PutUint32(reply[4:], fileModeToUnixMode(file.Mode))
+				binary.LittleEndian.PutUint32(reply[8:], uint32(file.size()))
+				binary.LittleEndian.PutUint32(reply[12:], uint32(file.ModTime.Unix()))
+			} else if d.isDir(path) {
+				binary.LittleEndian.PutUint32(reply[4:], fileModeToUnixMode(os.ModeDir|0771))
+			}
+			rw.Write(reply)
+
+		case "LST2", "STA2":
+			reply := make([]byte, 8+syncStatV2Len)
+			copy(reply, id)
+			switch {
+			case exists:
+				putFakeStatV2(reply[8:], SyncStat{Mode: file.Mode, Size: uint64(file.size()), ModTime: file.ModTime})
+			case d.isDir(path):
+				putFakeStatV2(reply[8:], SyncStat{Mode: os.ModeDir | 0771, ModTime: time.Unix(0, 0)})
+			default:
+				binary.LittleEndian.PutUint32(reply[4:], 2) // ENOENT
+			}
+			rw.Write(reply)
+
+		case "LIS2":
+			for _, entry := range d.list(path) {
+				reply := make([]byte, 8+syncStatV2Len+4, 8+syncStatV2Len+4+len(entry.Name))
+				copy(reply, "DNT2")
+				putFakeStatV2(reply[8:], entry.SyncStat)
+				binary.LittleEndian.PutUint32(reply[8+syncStatV2Len:], uint32(len(entry.Name)))
+				rw.Write(append(reply, entry.Name...))
+			}
+			done := make([]byte, 8+syncStatV2Len+4)
+			copy(done, "DONE")
+			rw.Write(done)
+
+		case "LIST":
+			for _, entry := range d.list(path) {
+				reply := make([]byte, 20, 20+len(entry.Name))
+				copy(reply, "DENT")
+				binary.LittleEndian.PutUint32(reply[4:], fileModeToUnixMode(entry.Mode))
+				binary.LittleEndian.PutUint32(reply[8:], uint32(entry.Size))
+				binary.LittleEndian.PutUint32(reply[12:], uint32(entry.ModTime.Unix()))
+				binary.LittleEndian.PutUint32(reply[16:], uint32(len(entry.Name)))
+				rw.Write(append(reply, entry.Name...))
+			}
+			done := make([]byte, 20)
+			copy(done, "DONE")
+			rw.Write(done)
+
+		case "RECV":
+			if !exists {
+				writeSyncPacket(rw, "FAIL", []byte("No such file or directory"))
+				continue
+			}
+			r := file.reader(0)
+			chunk := make([]byte, syncMaxChunk)
+			for {
+				n, _ := io.ReadFull(r, chunk)
+				if n == 0 {
+					break
+				}
+				writeSyncPacket(rw, "DATA", chunk[:n])
+			}
+			writeSyncPacket(rw, "DONE", nil)
+
+		case "SEND":
+			target, modeStr, _ := strings.Cut(path, ",")
+			mode, _ := strconv.ParseUint(modeStr, 10, 32)
+			var data bytes.Buffer
+			for {
+				if _, err := io.ReadFull(rw, header); err != nil {
+					return
+				}
+				chunkLen := binary.LittleEndian.Uint32(header[4:])
+				if string(header[:4]) == "DONE" {
+					d.setFile(target, FakeFile{
+						Data:    data.Bytes(),
+						Mode:    unixModeToFileMode(uint32(mode)),
+						ModTime: time.Unix(int64(chunkLen), 0),
+					})
+					writeSyncPacket(rw, "OKAY", nil)
+					break
+				}
+				if _, err := io.CopyN(&data, rw, int64(chunkLen)); err != nil {
+					return
+				}
+			}
+
+		default:
+			writeSyncPacket(rw, "FAIL", []byte("unknown sync request "+id))
+			return
+		}
+	}
+}
+
+// putFakeStatV2 按 STAT_V2 格式写入文件状态，dev、ino、nlink 等模拟设备用不到的字段为零
+func putFakeStatV2(b []byte, stat SyncStat) {
+	binary.LittleEndian.PutUint32(b[16:], fileModeToUnixMode(stat.Mode))
+	binary.LittleEndian.PutUint64(b[32:], stat.Size)
+	binary.LittleEndian.PutUint64(b[48:], uint64(stat.ModTime.Unix()))
+}
+
+func writeSyncPacket(w io.Writer, id string, data []byte) {
+	packet := make([]byte, 8, 8+len(data))
+	copy(packet, id)
+	binary.LittleEndian.PutUint32(packet[4:], uint32(len(data)))
+	w.Write(append(packet, data...))
+}
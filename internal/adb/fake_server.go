@@ -0,0 +1,526 @@
+package adb
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FakeServer 进程内的 adb server 模拟实现，使用与真实 server 相同的线协议
+// 用于在没有 adb 和真实设备的环境中验证 ServerClient / ServerRunner
+type FakeServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	devices  []*fakeDevice
+	trackers map[chan struct{}]struct{} // track-devices 连接，设备变化时通知
+	forwards []ForwardRule              // 正向转发规则，只做登记，不实际监听端口
+	mdns     []MdnsService              // mdns services 返回的服务
+	pairing  map[string]string          // 配对地址 -> 配对码
+	closed   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewFakeServer 在本地随机端口启动模拟 adb server
+func NewFakeServer() (*FakeServer, error) {
+	return NewFakeServerAt("127.0.0.1:0")
+}
+
+// NewFakeServerAt 在指定地址启动模拟 adb server，可用于模拟 server 重启
+func NewFakeServerAt(addr string) (*FakeServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &FakeServer{
+		listener: listener,
+		trackers: make(map[chan struct{}]struct{}),
+		closed:   make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr 返回监听地址，可直接传给 NewServerClient / NewServerRunner
+func (s *FakeServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close 停止模拟 server
+func (s *FakeServer) Close() error {
+	err := s.listener.Close()
+	close(s.closed)
+	s.wg.Wait()
+	return err
+}
+
+// AddDevice 添加设备，state 为 device / offline / unauthorized 等
+func (s *FakeServer) AddDevice(serial, state, attrs string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	defer s.notify()
+
+	if dev := s.findDevice(serial); dev != nil {
+		dev.state = state
+		dev.attrs = attrs
+		return
+	}
+	s.devices = append(s.devices, newFakeDevice(serial, state, attrs))
+}
+
+// AddMdnsService 登记 mdns services 返回的服务
+func (s *FakeServer) AddMdnsService(service MdnsService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mdns = append(s.mdns, service)
+}
+
+// SetPairingCode 设置配对地址的配对码，host:pair 使用其他配对码时失败
+func (s *FakeServer) SetPairingCode(address, code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pairing == nil {
+		s.pairing = make(map[string]string)
+	}
+	s.pairing[address] = code
+}
+
+// RemoveDevice 移除设备
+func (s *FakeServer) RemoveDevice(serial string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, dev := range s.devices {
+		if dev.serial == serial {
+			s.devices = append(s.devices[:i], s.devices[i+1:]...)
+			s.notify()
+			return
+		}
+	}
+}
+
+// notify 通知 track-devices 连接设备列表已变化，调用方需持有锁
+func (s *FakeServer) notify() {
+	for ch := range s.trackers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// track 持续推送设备列表，直到客户端断开或 server 关闭
+func (s *FakeServer) track(conn net.Conn, long bool) {
+	changed := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.trackers[changed] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.trackers, changed)
+		s.mu.Unlock()
+	}()
+
+	// 客户端断开时结束
+	gone := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, conn)
+		close(gone)
+	}()
+
+	io.WriteString(conn, "OKAY")
+	for {
+		list := s.deviceList(long)
+		if _, err := fmt.Fprintf(conn, "%04x%s", len(list), list); err != nil {
+			return
+		}
+		select {
+		case <-changed:
+		case <-gone:
+			return
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+// SetShell 登记 shell 命令的输出
+func (s *FakeServer) SetShell(serial, command, output string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if dev := s.findDevice(serial); dev != nil {
+		dev.setShell(command, output)
+	}
+}
+
+// SetShellResult 登记 shell 命令的完整结果（stdout、stderr 与退出码）
+func (s *FakeServer) SetShellResult(serial, command string, result ShellResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if dev := s.findDevice(serial); dev != nil {
+		dev.setShellResult(command, result)
+	}
+}
+
+// SetFeatures 设置设备声明的特性（逗号分隔），例如设为 "" 模拟不支持 shell_v2 的旧设备
+func (s *FakeServer) SetFeatures(serial, features string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if dev := s.findDevice(serial); dev != nil {
+		dev.features = features
+	}
+}
+
+// SetFile 在设备上放置文件
+func (s *FakeServer) SetFile(serial, path string, file FakeFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if dev := s.findDevice(serial); dev != nil {
+		dev.setFile(path, file)
+	}
+}
+
+// File 读取设备上的文件
+func (s *FakeServer) File(serial, path string) (FakeFile, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if dev := s.findDevice(serial); dev != nil {
+		return dev.file(path)
+	}
+	return FakeFile{}, false
+}
+
+// SetJDWP 设置设备上可调试的进程，通过 track-jdwp 服务返回
+func (s *FakeServer) SetJDWP(serial string, pids ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if dev := s.findDevice(serial); dev != nil {
+		dev.setJDWP(pids)
+	}
+}
+
+// Forwards 返回已登记的正向转发规则
+func (s *FakeServer) Forwards() []ForwardRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ForwardRule(nil), s.forwards...)
+}
+
+// forward 处理 host:forward 类请求，与真实 server 一致，执行结果之前先回复一次 OKAY
+func (s *FakeServer) forward(conn net.Conn, serial, service string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if service == "list-forward" {
+		writeOkayString(conn, formatFakeForwards(s.forwards))
+		return
+	}
+	if serial == "" && len(s.devices) > 0 {
+		serial = s.devices[0].serial
+	}
+	if s.findDevice(serial) == nil {
+		writeFail(conn, fmt.Sprintf("device '%s' not found", serial))
+		return
+	}
+
+	io.WriteString(conn, "OKAY")
+	rules, port, err := applyFakeForward(s.forwards, serial, service)
+	if err != nil {
+		writeFail(conn, err.Error())
+		return
+	}
+	s.forwards = rules
+	if port != "" {
+		writeOkayString(conn, port)
+		return
+	}
+	io.WriteString(conn, "OKAY")
+}
+
+// splitForwardRequest 拆分 host:SERVICE 或 host-serial:SERIAL:SERVICE 形式的转发请求（序列号中可能含有冒号）
+func splitForwardRequest(request string) (serial, service string, ok bool) {
+	if service, ok := strings.CutPrefix(request, "host:"); ok {
+		return "", service, isForwardService(service)
+	}
+	rest, ok := strings.CutPrefix(request, "host-serial:")
+	if !ok {
+		return "", "", false
+	}
+	for _, name := range []string{":forward:", ":killforward:", ":killforward-all", ":list-forward"} {
+		if i := strings.Index(rest, name); i >= 0 {
+			return rest[:i], rest[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// isForwardRequest 是否为转发相关的 host 请求
+func isForwardRequest(request string) bool {
+	_, _, ok := splitForwardRequest(request)
+	return ok
+}
+
+// isForwardService 是否为转发相关的服务
+func isForwardService(service string) bool {
+	return strings.HasPrefix(service, "forward:") || strings.HasPrefix(service, "killforward:") ||
+		service == "killforward-all" || service == "list-forward"
+}
+
+// applyFakeForward 在 rules 上执行转发请求，返回新的规则与分配的端口（local 为 tcp:0 时）
+func applyFakeForward(rules []ForwardRule, serial, service string) ([]ForwardRule, string, error) {
+	switch {
+	case service == "killforward-all":
+		kept := make([]ForwardRule, 0, len(rules))
+		for _, rule := range rules {
+			if rule.Serial != serial {
+				kept = append(kept, rule)
+			}
+		}
+		return kept, "", nil
+
+	case strings.HasPrefix(service, "killforward:"):
+		local := strings.TrimPrefix(service, "killforward:")
+		for i, rule := range rules {
+			if rule.Local == local {
+				return append(rules[:i:i], rules[i+1:]...), "", nil
+			}
+		}
+		return rules, "", fmt.Errorf("listener '%s' not found", local)
+
+	case strings.HasPrefix(service, "forward:"):
+		spec, noRebind := strings.CutPrefix(strings.TrimPrefix(service, "forward:"), "norebind:")
+		local, remote, ok := strings.Cut(spec, ";")
+		if !ok {
+			return rules, "", fmt.Errorf("malformed forward spec '%s'", spec)
+		}
+		port := ""
+		if local == "tcp:0" {
+			port = strconv.Itoa(40000 + len(rules))
+			local = "tcp:" + port
+		}
+		for i, rule := range rules {
+			if rule.Local != local {
+				continue
+			}
+			if noRebind {
+				return rules, "", fmt.Errorf("cannot rebind existing socket")
+			}
+			rules = append(rules[:i:i], rules[i+1:]...)
+			break
+		}
+		return append(rules, ForwardRule{Serial: serial, Local: local, Remote: remote}), port, nil
+	}
+	return rules, "", fmt.Errorf("unknown forward service '%s'", service)
+}
+
+// formatFakeForwards 生成 list-forward 的应答，每行为 "序列号 本端 对端"
+func formatFakeForwards(rules []ForwardRule) string {
+	var b strings.Builder
+	for _, rule := range rules {
+		fmt.Fprintf(&b, "%s %s %s\n", rule.Serial, rule.Local, rule.Remote)
+	}
+	return b.String()
+}
+
+// findDevice 调用方需持有锁
+func (s *FakeServer) findDevice(serial string) *fakeDevice {
+	for _, dev := range s.devices {
+		if dev.serial == serial {
+			return dev
+		}
+	}
+	return nil
+}
+
+// deviceList 生成 host:devices(-l) 的应答
+func (s *FakeServer) deviceList(long bool) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var b strings.Builder
+	for _, dev := range s.devices {
+		b.WriteString(dev.serial + "\t" + dev.state)
+		if long && dev.attrs != "" {
+			b.WriteString(" " + dev.attrs)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (s *FakeServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(conn)
+		}()
+	}
+}
+
+// handle 处理一个客户端连接
+func (s *FakeServer) handle(conn net.Conn) {
+	var device *fakeDevice
+
+	for {
+		request, err := readHexString(conn)
+		if err != nil {
+			return
+		}
+
+		switch {
+		case request == "host:version":
+			writeOkayString(conn, fmt.Sprintf("%04x", 41))
+			return
+
+		case request == "host:devices" || request == "host:devices-l":
+			writeOkayString(conn, s.deviceList(request == "host:devices-l"))
+			return
+
+		case request == "host:track-devices" || request == "host:track-devices-l":
+			s.track(conn, request == "host:track-devices-l")
+			return
+
+		case request == "host:kill":
+			io.WriteString(conn, "OKAY")
+			return
+
+		case request == "host:features" || strings.HasPrefix(request, "host-serial:") && strings.HasSuffix(request, ":features"):
+			serial := strings.TrimSuffix(strings.TrimPrefix(request, "host-serial:"), ":features")
+			s.mu.Lock()
+			var dev *fakeDevice
+			if request == "host:features" {
+				if len(s.devices) > 0 {
+					dev = s.devices[0]
+				}
+			} else {
+				dev = s.findDevice(serial)
+			}
+			var features string
+			if dev != nil {
+				features = dev.features
+			}
+			s.mu.Unlock()
+			if dev == nil {
+				writeFail(conn, fmt.Sprintf("device '%s' not found", serial))
+				return
+			}
+			writeOkayString(conn, features)
+			return
+
+		case strings.HasPrefix(request, "host-serial:") && strings.HasSuffix(request, ":reconnect"):
+			serial := strings.TrimSuffix(strings.TrimPrefix(request, "host-serial:"), ":reconnect")
+			s.mu.Lock()
+			dev := s.findDevice(serial)
+			if dev != nil && dev.state == "offline" {
+				dev.state = "device"
+				s.notify()
+			}
+			s.mu.Unlock()
+			if dev == nil {
+				writeFail(conn, fmt.Sprintf("device '%s' not found", serial))
+				return
+			}
+			writeOkayString(conn, "reconnecting "+serial)
+			return
+
+		case strings.HasPrefix(request, "host:connect:"):
+			address := strings.TrimPrefix(request, "host:connect:")
+			s.AddDevice(address, "device", "")
+			writeOkayString(conn, "connected to "+address)
+			return
+
+		case request == "host:mdns:check":
+			writeOkayString(conn, "mdns daemon version [fake]")
+			return
+
+		case request == "host:mdns:services":
+			s.mu.Lock()
+			var b strings.Builder
+			for _, service := range s.mdns {
+				fmt.Fprintf(&b, "%s\t%s.\t%s\n", service.Name, service.Type, service.Address)
+			}
+			s.mu.Unlock()
+			writeOkayString(conn, b.String())
+			return
+
+		case strings.HasPrefix(request, "host:pair:"):
+			code, address, _ := strings.Cut(strings.TrimPrefix(request, "host:pair:"), ":")
+			s.mu.Lock()
+			expected, ok := s.pairing[address]
+			s.mu.Unlock()
+			if !ok || expected != code {
+				writeOkayString(conn, "Failed: Wrong password or connection was dropped.")
+				return
+			}
+			writeOkayString(conn, "Successfully paired to "+address+" [guid=adb-fake]")
+			return
+
+		case strings.HasPrefix(request, "host:disconnect:"):
+			address := strings.TrimPrefix(request, "host:disconnect:")
+			s.RemoveDevice(address)
+			writeOkayString(conn, "disconnected "+address)
+			return
+
+		case isForwardRequest(request):
+			serial, service, _ := splitForwardRequest(request)
+			s.forward(conn, serial, service)
+			return
+
+		case request == "host:transport-any" || strings.HasPrefix(request, "host:transport:"):
+			s.mu.Lock()
+			if request == "host:transport-any" {
+				if len(s.devices) > 0 {
+					device = s.devices[0]
+				}
+			} else {
+				device = s.findDevice(strings.TrimPrefix(request, "host:transport:"))
+			}
+			s.mu.Unlock()
+
+			if device == nil {
+				writeFail(conn, fmt.Sprintf("device '%s' not found", strings.TrimPrefix(request, "host:transport:")))
+				return
+			}
+			if device.state != "device" {
+				writeFail(conn, "device "+device.state)
+				return
+			}
+			io.WriteString(conn, "OKAY")
+
+		case device != nil && device.supports(request):
+			io.WriteString(conn, "OKAY")
+			device.serve(request, conn)
+			return
+
+		default:
+			writeFail(conn, "unknown host service")
+			return
+		}
+	}
+}
+
+func writeOkayString(w io.Writer, payload string) {
+	fmt.Fprintf(w, "OKAY%04x%s", len(payload), payload)
+}
+
+func writeFail(w io.Writer, message string) {
+	fmt.Fprintf(w, "FAIL%04x%s", len(message), message)
+}
@@ -0,0 +1,324 @@
+package adb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ForwardRule 端口转发规则
+// 正向转发（adb forward）中 Local 为本机端、Remote 为设备端；反向转发（adb reverse）中 Local 为设备端、Remote 为本机端
+type ForwardRule struct {
+	Serial  string // 正向转发所属的设备；反向转发为设备端报告的连接名（例如 UsbFfs）
+	Local   string // 例如 tcp:8081
+	Remote  string // 例如 tcp:8081、localabstract:chrome_devtools_remote、jdwp:1234
+	Reverse bool
+}
+
+func (r ForwardRule) String() string {
+	if r.Reverse {
+		return "设备 " + r.Local + " -> 本机 " + r.Remote
+	}
+	return "本机 " + r.Local + " -> 设备 " + r.Remote
+}
+
+// forwardSpecKinds 转发端点支持的类型，jdwp 与 dev 只在设备端有意义
+var forwardSpecKinds = []string{"tcp", "localabstract", "localreserved", "localfilesystem", "jdwp", "dev", "vsock", "acceptfd"}
+
+// ValidateForwardSpec 检查转发端点的格式，例如 tcp:8080、localabstract:name、jdwp:1234
+func ValidateForwardSpec(spec string) error {
+	kind, value, ok := strings.Cut(spec, ":")
+	if !ok || value == "" {
+		return fmt.Errorf("无效的转发端点 %q，格式为 类型:值，例如 tcp:8080", spec)
+	}
+	found := false
+	for _, k := range forwardSpecKinds {
+		found = found || k == kind
+	}
+	if !found {
+		return fmt.Errorf("不支持的转发端点类型 %q，可用类型: %s", kind, strings.Join(forwardSpecKinds, "、"))
+	}
+	if kind == "tcp" || kind == "jdwp" {
+		if n, err := strconv.Atoi(value); err != nil || n < 0 || (kind == "tcp" && n > 65535) {
+			return fmt.Errorf("无效的转发端点 %q，%s 后应为数字", spec, kind)
+		}
+	}
+	return nil
+}
+
+// forwardRequest 将 forward / reverse 的命令行参数翻译为服务请求，返回请求、应答是否为列表、是否需要读取分配的端口
+//
+//	--list                     -> list-forward
+//	--remove LOCAL             -> killforward:LOCAL
+//	--remove-all               -> killforward-all
+//	[--no-rebind] LOCAL REMOTE -> forward:[norebind:]LOCAL;REMOTE
+func forwardRequest(args []string) (request string, list bool, resolvePort bool, err error) {
+	switch {
+	case len(args) == 1 && args[0] == "--list":
+		return "list-forward", true, false, nil
+	case len(args) == 1 && args[0] == "--remove-all":
+		return "killforward-all", false, false, nil
+	case len(args) == 2 && args[0] == "--remove":
+		return "killforward:" + args[1], false, false, nil
+	case len(args) == 3 && args[0] == "--no-rebind":
+		return "forward:norebind:" + args[1] + ";" + args[2], false, args[1] == "tcp:0", nil
+	case len(args) == 2 && !strings.HasPrefix(args[0], "--"):
+		return "forward:" + args[0] + ";" + args[1], false, args[0] == "tcp:0", nil
+	}
+	return "", false, false, errUnsupported
+}
+
+// reverseService 通过设备的 reverse: 服务执行反向转发请求，输出与 adb reverse 命令行一致
+// 设备只回复一次执行结果，列表与分配的端口随后以带长度前缀的字符串返回
+func reverseService(ctx context.Context, open serviceOpener, args []string) ([]byte, error) {
+	request, list, resolvePort, err := forwardRequest(args)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := open(ctx, "reverse:"+request)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := readStatus(conn, "reverse:"+request); err != nil {
+		return nil, err
+	}
+	if !list && !resolvePort {
+		return nil, nil
+	}
+	output, err := readHexString(conn)
+	if err != nil {
+		return nil, err
+	}
+	return forwardOutput(output, resolvePort), nil
+}
+
+// parseForwardList 解析 forward --list / reverse --list 的输出，每行为 "序列号 本端 对端"
+func parseForwardList(output string, reverse bool) []ForwardRule {
+	rules := make([]ForwardRule, 0)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		rules = append(rules, ForwardRule{Serial: fields[0], Local: fields[1], Remote: fields[2], Reverse: reverse})
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].Local < rules[j].Local })
+	return rules
+}
+
+// ListForwards 列出正向转发规则，serial 为空时列出所有设备的规则
+func (m *ADBManager) ListForwards(serial string) ([]ForwardRule, error) {
+	return m.ListForwardsContext(context.Background(), serial)
+}
+
+// ListForwardsContext 可通过 ctx 取消的 ListForwards
+func (m *ADBManager) ListForwardsContext(ctx context.Context, serial string) ([]ForwardRule, error) {
+	output, err := m.run(ctx, "forward", "--list")
+	if err != nil {
+		return nil, fmt.Errorf("获取端口转发列表失败: %w", err)
+	}
+
+	rules := make([]ForwardRule, 0)
+	for _, rule := range parseForwardList(string(output), false) {
+		if serial == "" || rule.Serial == serial {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// Forward 将本机端口转发到设备，例如 Forward(serial, "tcp:9222", "localabstract:chrome_devtools_remote", false)
+// local 为 tcp:0 时由 adb server 分配端口，返回实际使用的本机端点；noRebind 为 true 时本机端口已被占用则失败
+func (m *ADBManager) Forward(serial, local, remote string, noRebind bool) (string, error) {
+	return m.ForwardContext(context.Background(), serial, local, remote, noRebind)
+}
+
+// ForwardContext 可通过 ctx 取消的 Forward
+func (m *ADBManager) ForwardContext(ctx context.Context, serial, local, remote string, noRebind bool) (string, error) {
+	if err := validateForwardPair(local, remote); err != nil {
+		return "", err
+	}
+	if m.router.IsDirect(serial) {
+		// 正向转发需要在本机监听端口，由 adb server 负责
+		return "", fmt.Errorf("直连 adbd 的设备不支持正向端口转发，请通过 adb server 连接设备")
+	}
+
+	args := []string{"forward"}
+	if noRebind {
+		args = append(args, "--no-rebind")
+	}
+	output, err := m.run(ctx, deviceArgs(serial, append(args, local, remote)...)...)
+	if err != nil {
+		return "", fmt.Errorf("创建端口转发失败: %w", err)
+	}
+	return resolvedLocal(local, string(output)), nil
+}
+
+// RemoveForward 删除本机端点为 local 的正向转发
+func (m *ADBManager) RemoveForward(serial, local string) error {
+	return m.RemoveForwardContext(context.Background(), serial, local)
+}
+
+// RemoveForwardContext 可通过 ctx 取消的 RemoveForward
+func (m *ADBManager) RemoveForwardContext(ctx context.Context, serial, local string) error {
+	if _, err := m.run(ctx, deviceArgs(serial, "forward", "--remove", local)...); err != nil {
+		return fmt.Errorf("删除端口转发失败: %w", err)
+	}
+	return nil
+}
+
+// RemoveAllForwards 删除设备的所有正向转发
+// 不使用 forward --remove-all，它会删除 adb server 上所有设备的转发
+func (m *ADBManager) RemoveAllForwards(serial string) error {
+	return m.RemoveAllForwardsContext(context.Background(), serial)
+}
+
+// RemoveAllForwardsContext 可通过 ctx 取消的 RemoveAllForwards
+func (m *ADBManager) RemoveAllForwardsContext(ctx context.Context, serial string) error {
+	rules, err := m.ListForwardsContext(ctx, serial)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if err := m.RemoveForwardContext(ctx, serial, rule.Local); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListReverses 列出设备的反向转发规则
+func (m *ADBManager) ListReverses(serial string) ([]ForwardRule, error) {
+	return m.ListReversesContext(context.Background(), serial)
+}
+
+// ListReversesContext 可通过 ctx 取消的 ListReverses
+func (m *ADBManager) ListReversesContext(ctx context.Context, serial string) ([]ForwardRule, error) {
+	output, err := m.run(ctx, deviceArgs(serial, "reverse", "--list")...)
+	if err != nil {
+		return nil, fmt.Errorf("获取反向转发列表失败: %w", err)
+	}
+	return parseForwardList(string(output), true), nil
+}
+
+// Reverse 将设备端口反向转发到本机，例如 Reverse(serial, "tcp:8081", "tcp:8081", false) 让设备访问本机的 Metro
+// local 为设备端、remote 为本机端；local 为 tcp:0 时由设备分配端口，返回实际使用的设备端点
+func (m *ADBManager) Reverse(serial, local, remote string, noRebind bool) (string, error) {
+	return m.ReverseContext(context.Background(), serial, local, remote, noRebind)
+}
+
+// ReverseContext 可通过 ctx 取消的 Reverse
+func (m *ADBManager) ReverseContext(ctx context.Context, serial, local, remote string, noRebind bool) (string, error) {
+	if err := validateForwardPair(local, remote); err != nil {
+		return "", err
+	}
+
+	args := []string{"reverse"}
+	if noRebind {
+		args = append(args, "--no-rebind")
+	}
+	output, err := m.run(ctx, deviceArgs(serial, append(args, local, remote)...)...)
+	if err != nil {
+		return "", fmt.Errorf("创建反向转发失败: %w", err)
+	}
+	return resolvedLocal(local, string(output)), nil
+}
+
+// RemoveReverse 删除设备端点为 local 的反向转发
+func (m *ADBManager) RemoveReverse(serial, local string) error {
+	return m.RemoveReverseContext(context.Background(), serial, local)
+}
+
+// RemoveReverseContext 可通过 ctx 取消的 RemoveReverse
+func (m *ADBManager) RemoveReverseContext(ctx context.Context, serial, local string) error {
+	if _, err := m.run(ctx, deviceArgs(serial, "reverse", "--remove", local)...); err != nil {
+		return fmt.Errorf("删除反向转发失败: %w", err)
+	}
+	return nil
+}
+
+// RemoveAllReverses 删除设备的所有反向转发
+func (m *ADBManager) RemoveAllReverses(serial string) error {
+	return m.RemoveAllReversesContext(context.Background(), serial)
+}
+
+// RemoveAllReversesContext 可通过 ctx 取消的 RemoveAllReverses
+func (m *ADBManager) RemoveAllReversesContext(ctx context.Context, serial string) error {
+	if _, err := m.run(ctx, deviceArgs(serial, "reverse", "--remove-all")...); err != nil {
+		return fmt.Errorf("删除反向转发失败: %w", err)
+	}
+	return nil
+}
+
+// ListJDWP 列出设备上可调试（带 JDWP）的进程 ID，可用作 jdwp:PID 转发目标
+func (m *ADBManager) ListJDWP(serial string) ([]int, error) {
+	return m.ListJDWPContext(context.Background(), serial)
+}
+
+// ListJDWPContext 可通过 ctx 取消的 ListJDWP
+// 使用 track-jdwp 服务并只读取第一次推送的列表（adb jdwp 命令行会一直等待，不适合回退）
+func (m *ADBManager) ListJDWPContext(ctx context.Context, serial string) ([]int, error) {
+	var pids []int
+	err := m.transfer(ctx, serial, []string{"jdwp"}, false, func(open serviceOpener) error {
+		conn, err := open(ctx, "track-jdwp")
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		list, err := readHexString(conn)
+		if err != nil {
+			return err
+		}
+		pids = parseJDWPList(list)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("获取 JDWP 进程失败: %w", err)
+	}
+	return pids, nil
+}
+
+// parseJDWPList 解析 track-jdwp 推送的进程列表，每行一个进程 ID
+func parseJDWPList(list string) []int {
+	pids := make([]int, 0)
+	for _, line := range strings.Split(list, "\n") {
+		if pid, err := strconv.Atoi(strings.TrimSpace(line)); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	sort.Ints(pids)
+	return pids
+}
+
+// validateForwardPair 检查转发两端的格式
+func validateForwardPair(local, remote string) error {
+	if err := ValidateForwardSpec(local); err != nil {
+		return err
+	}
+	return ValidateForwardSpec(remote)
+}
+
+// resolvedLocal local 为 tcp:0 时根据命令输出（分配的端口号）返回实际端点
+func resolvedLocal(local, output string) string {
+	if local != "tcp:0" {
+		return local
+	}
+	if port, err := strconv.Atoi(strings.TrimSpace(output)); err == nil {
+		return "tcp:" + strconv.Itoa(port)
+	}
+	return local
+}
+
+// forwardOutput 按 adb forward 命令行的格式输出结果：列表原样输出，分配的端口单独一行
+func forwardOutput(output string, resolvePort bool) []byte {
+	if resolvePort {
+		return []byte(output + "\n")
+	}
+	return []byte(output)
+}
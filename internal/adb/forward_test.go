@@ -0,0 +1,222 @@
+package adb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateForwardSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		ok   bool
+	}{
+		{"tcp:8080", true},
+		{"tcp:0", true},
+		{"tcp:65535", true},
+		{"localabstract:chrome_devtools_remote", true},
+		{"localreserved:name", true},
+		{"localfilesystem:/data/local/tmp/socket", true},
+		{"jdwp:1234", true},
+		{"dev:/dev/ttyS0", true},
+		{"vsock:2:5555", true},
+		{"", false},
+		{"tcp", false},
+		{"tcp:", false},
+		{"tcp:65536", false},
+		{"tcp:-1", false},
+		{"tcp:http", false},
+		{"jdwp:app", false},
+		{"udp:53", false},
+		{"TCP:8080", false},
+	}
+	for _, tt := range tests {
+		if err := ValidateForwardSpec(tt.spec); (err == nil) != tt.ok {
+			t.Errorf("ValidateForwardSpec(%q) = %v, want ok %v", tt.spec, err, tt.ok)
+		}
+	}
+}
+
+func TestForwardRequest(t *testing.T) {
+	tests := []struct {
+		args        []string
+		request     string
+		list        bool
+		resolvePort bool
+		ok          bool
+	}{
+		{[]string{"--list"}, "list-forward", true, false, true},
+		{[]string{"--remove-all"}, "killforward-all", false, false, true},
+		{[]string{"--remove", "tcp:8080"}, "killforward:tcp:8080", false, false, true},
+		{[]string{"tcp:8080", "tcp:80"}, "forward:tcp:8080;tcp:80", false, false, true},
+		{[]string{"tcp:0", "tcp:80"}, "forward:tcp:0;tcp:80", false, true, true},
+		{[]string{"--no-rebind", "tcp:8080", "tcp:80"}, "forward:norebind:tcp:8080;tcp:80", false, false, true},
+		{[]string{"--no-rebind", "tcp:0", "tcp:80"}, "forward:norebind:tcp:0;tcp:80", false, true, true},
+		{nil, "", false, false, false},
+		{[]string{"--list", "extra"}, "", false, false, false},
+		{[]string{"--bogus", "tcp:80"}, "", false, false, false},
+	}
+	for _, tt := range tests {
+		request, list, resolvePort, err := forwardRequest(tt.args)
+		if (err == nil) != tt.ok || request != tt.request || list != tt.list || resolvePort != tt.resolvePort {
+			t.Errorf("forwardRequest(%q) = %q, %v, %v, %v, want %q, %v, %v, ok %v",
+				tt.args, request, list, resolvePort, err, tt.request, tt.list, tt.resolvePort, tt.ok)
+		}
+	}
+}
+
+func TestParseForwardList(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		reverse bool
+		want    []ForwardRule
+	}{
+		{
+			name:   "空列表",
+			output: "",
+			want:   []ForwardRule{},
+		},
+		{
+			name: "forward --list 按本机端点排序",
+			output: "emulator-5554 tcp:9222 localabstract:chrome_devtools_remote\n" +
+				"192.168.1.5:5555 tcp:8700 jdwp:1234\n",
+			want: []ForwardRule{
+				{Serial: "192.168.1.5:5555", Local: "tcp:8700", Remote: "jdwp:1234"},
+				{Serial: "emulator-5554", Local: "tcp:9222", Remote: "localabstract:chrome_devtools_remote"},
+			},
+		},
+		{
+			name:    "reverse --list",
+			output:  "UsbFfs tcp:8081 tcp:8081\r\nUsbFfs tcp:5037 tcp:5037\r\n",
+			reverse: true,
+			want: []ForwardRule{
+				{Serial: "UsbFfs", Local: "tcp:5037", Remote: "tcp:5037", Reverse: true},
+				{Serial: "UsbFfs", Local: "tcp:8081", Remote: "tcp:8081", Reverse: true},
+			},
+		},
+		{
+			name:   "忽略无法解析的行",
+			output: "* daemon started successfully\n\nemulator-5554 tcp:9222 tcp:9222\nerror: closed\n",
+			want:   []ForwardRule{{Serial: "emulator-5554", Local: "tcp:9222", Remote: "tcp:9222"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseForwardList(tt.output, tt.reverse); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseForwardList = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListForwardsFiltersDevice(t *testing.T) {
+	runner := NewScriptedRunner().On("forward --list", ScriptedResponse{
+		Stdout: "emulator-5554 tcp:9222 tcp:9222\nemulator-5556 tcp:8700 jdwp:1234\nemulator-5554 tcp:8080 tcp:80\n",
+	})
+	m := newTestManager(runner)
+
+	rules, err := m.ListForwards("emulator-5554")
+	if err != nil {
+		t.Fatalf("ListForwards: %v", err)
+	}
+	want := []ForwardRule{
+		{Serial: "emulator-5554", Local: "tcp:8080", Remote: "tcp:80"},
+		{Serial: "emulator-5554", Local: "tcp:9222", Remote: "tcp:9222"},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("ListForwards = %+v, want %+v", rules, want)
+	}
+	if all, _ := m.ListForwards(""); len(all) != 3 {
+		t.Errorf("ListForwards(\"\") = %+v, want 3 rules", all)
+	}
+}
+
+func TestForwardAndReverse(t *testing.T) {
+	srv, err := NewFakeServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.AddDevice("emulator-5554", "device", "")
+	srv.AddDevice("emulator-5556", "device", "")
+	m := newTestManager(NewServerRunner(srv.Addr(), nil))
+
+	// tcp:0 返回 adb server 分配的端口
+	local, err := m.Forward("emulator-5554", "tcp:0", "localabstract:chrome_devtools_remote", false)
+	if err != nil || local == "tcp:0" {
+		t.Fatalf("Forward = %q, %v, want an allocated port", local, err)
+	}
+	if _, err := m.Forward("emulator-5556", "tcp:8700", "jdwp:1234", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Forward("emulator-5554", local, "tcp:80", true); err == nil {
+		t.Error("Forward with --no-rebind replaced an existing rule")
+	}
+	if _, err := m.Forward("emulator-5554", "tcp:8080", "udp:80", false); err == nil {
+		t.Error("Forward accepted an invalid spec")
+	}
+
+	// 只删除该设备的规则
+	if err := m.RemoveAllForwards("emulator-5554"); err != nil {
+		t.Fatal(err)
+	}
+	want := []ForwardRule{{Serial: "emulator-5556", Local: "tcp:8700", Remote: "jdwp:1234"}}
+	if got := srv.Forwards(); !reflect.DeepEqual(got, want) {
+		t.Errorf("forwards = %+v, want %+v", got, want)
+	}
+
+	if _, err := m.Reverse("emulator-5554", "tcp:8081", "tcp:8081", false); err != nil {
+		t.Fatalf("Reverse: %v", err)
+	}
+	rules, err := m.ListReverses("emulator-5554")
+	wantReverse := []ForwardRule{{Serial: "UsbFfs", Local: "tcp:8081", Remote: "tcp:8081", Reverse: true}}
+	if err != nil || !reflect.DeepEqual(rules, wantReverse) {
+		t.Fatalf("ListReverses = %+v, %v, want %+v", rules, err, wantReverse)
+	}
+	if err := m.RemoveReverse("emulator-5554", "tcp:8081"); err != nil {
+		t.Fatal(err)
+	}
+	if rules, _ := m.ListReverses("emulator-5554"); len(rules) != 0 {
+		t.Errorf("reverses after remove = %+v", rules)
+	}
+}
+
+func TestListJDWP(t *testing.T) {
+	srv, err := NewFakeServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.AddDevice("emulator-5554", "device", "")
+	srv.AddDevice("emulator-5556", "device", "")
+	srv.SetJDWP("emulator-5554", 4321, 1234, 987)
+	m := newTestManager(NewServerRunner(srv.Addr(), nil))
+
+	pids, err := m.ListJDWP("emulator-5554")
+	if err != nil {
+		t.Fatalf("ListJDWP: %v", err)
+	}
+	if want := []int{987, 1234, 4321}; !reflect.DeepEqual(pids, want) {
+		t.Errorf("ListJDWP = %v, want %v", pids, want)
+	}
+	if pids, err := m.ListJDWP("emulator-5556"); err != nil || len(pids) != 0 {
+		t.Errorf("ListJDWP without debuggable processes = %v, %v", pids, err)
+	}
+}
+
+func TestParseJDWPList(t *testing.T) {
+	tests := []struct {
+		list string
+		want []int
+	}{
+		{"", []int{}},
+		{"1234\n", []int{1234}},
+		{"4321\n1234\r\n 987 \n", []int{987, 1234, 4321}},
+		{"1234\nnot-a-pid\n\n5678", []int{1234, 5678}},
+	}
+	for _, tt := range tests {
+		if got := parseJDWPList(tt.list); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseJDWPList(%q) = %v, want %v", tt.list, got, tt.want)
+		}
+	}
+}
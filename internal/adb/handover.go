@@ -0,0 +1,113 @@
+package adb
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTCPPort adb tcpip 的默认端口
+const DefaultTCPPort = 5555
+
+// handoverWait 执行 tcpip 后等待 adbd 在网络端口上重新监听的时间
+const handoverWait = 15 * time.Second
+
+// handoverInterval 等待 adbd 重启时的重试间隔
+const handoverInterval = time.Second
+
+// SwitchToTCP 将 USB 设备切换为无线连接，返回无线连接的地址（IP:PORT）
+// 依次执行：读取 ro.serialno 与 WiFi IP、adb tcpip、adb connect、确认新连接的 ro.serialno 与 USB 设备一致
+func (m *ADBManager) SwitchToTCP(serial string, port int) (string, error) {
+	return m.SwitchToTCPContext(context.Background(), serial, port)
+}
+
+// SwitchToTCPContext 可通过 ctx 取消的 SwitchToTCP
+func (m *ADBManager) SwitchToTCPContext(ctx context.Context, serial string, port int) (string, error) {
+	if port <= 0 || port > 65535 {
+		return "", fmt.Errorf("端口无效: %d", port)
+	}
+
+	m.deviceCacheLock.RLock()
+	dev, exists := m.managedDevices[serial]
+	var transport TransportKind
+	var status string
+	if exists {
+		transport, status = dev.Transport, dev.Status
+	}
+	m.deviceCacheLock.RUnlock()
+	if !exists {
+		return "", fmt.Errorf("设备不存在: %s", serial)
+	}
+	if transport != TransportUSB {
+		return "", fmt.Errorf("只有 USB 设备可以切换为无线连接: %s [%s]", serial, transport)
+	}
+	if status != "device" {
+		return "", fmt.Errorf("设备不在线: %s [%s]", serial, status)
+	}
+
+	// tcpip 会重启 adbd，USB 连接随之短暂断开，所需信息要在此之前读取
+	hardwareSerial, err := m.ResolveIdentityContext(ctx, serial)
+	if err != nil {
+		return "", err
+	}
+	if hardwareSerial == "" {
+		return "", fmt.Errorf("设备没有 ro.serialno，无法确认切换后的连接是否为同一台设备: %s", serial)
+	}
+	caps := m.knownCapabilities(ctx, serial)
+	iface := m.wifiInterface(ctx, serial)
+	ip := m.wifiAddress(ctx, serial, iface, caps)
+	if ip == "" {
+		return "", fmt.Errorf("未获取到 %s 的 IP 地址，请确认设备已连接 WiFi: %s", iface, serial)
+	}
+	address := net.JoinHostPort(ip, strconv.Itoa(port))
+
+	m.log.Info("切换为无线连接", "device", serial, "address", address)
+	output, err := m.run(ctx, deviceArgs(serial, "tcpip", strconv.Itoa(port))...)
+	if err != nil {
+		return "", fmt.Errorf("adb tcpip 失败: %w", err)
+	}
+	if outputStr := strings.TrimSpace(EncodingAuto.Decode(output)); strings.HasPrefix(outputStr, "error") {
+		return "", fmt.Errorf("adb tcpip 失败: %s", outputStr)
+	}
+	m.forgetFeatures(serial)
+
+	if err := m.connectHandover(ctx, address, hardwareSerial); err != nil {
+		return "", err
+	}
+	m.log.Info("已切换为无线连接", "device", serial, "address", address)
+	return address, nil
+}
+
+// connectHandover 等待 adbd 以 TCP 模式重启后连接 address，并确认其 ro.serialno 为 hardwareSerial
+// adb connect 可能在 adbd 就绪前就返回 connected，因此以读取 ro.serialno 成功作为连接可用的标志
+func (m *ADBManager) connectHandover(ctx context.Context, address, hardwareSerial string) error {
+	waitCtx, cancel := context.WithTimeout(ctx, handoverWait)
+	defer cancel()
+
+	var lastErr error
+	for {
+		if lastErr = m.ConnectContext(waitCtx, address); lastErr == nil {
+			var output string
+			output, lastErr = m.shellStdout(waitCtx, address, ShellJoin("getprop", "ro.serialno"), false)
+			if lastErr == nil {
+				if got := strings.TrimSpace(output); got != hardwareSerial {
+					m.DisconnectContext(ctx, address)
+					return fmt.Errorf("%s 上的设备 ro.serialno 为 %q，与 USB 设备的 %q 不一致，已断开", address, got, hardwareSerial)
+				}
+				return nil
+			}
+		}
+
+		select {
+		case <-waitCtx.Done():
+			if ctxErr := contextError(ctx); ctxErr != nil {
+				return ctxErr
+			}
+			return fmt.Errorf("已执行 adb tcpip，但 %v 内无法连接 %s: %v", handoverWait, address, lastErr)
+		case <-time.After(handoverInterval):
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package adb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// TransportKind 设备的连接方式
+type TransportKind int
+
+const (
+	TransportUnknown  TransportKind = iota // 未知
+	TransportUSB                           // USB 连接
+	TransportTCP                           // 网络连接（adb connect、无线调试、直连 adbd）
+	TransportEmulator                      // 本机模拟器
+)
+
+func (k TransportKind) String() string {
+	switch k {
+	case TransportUSB:
+		return "USB"
+	case TransportTCP:
+		return "网络"
+	case TransportEmulator:
+		return "模拟器"
+	}
+	return "未知"
+}
+
+// transportKindOf 根据序列号与 usb 字段推断连接方式
+// Windows 下 devices -l 不报告 usb 字段，其余既不是网络地址也不是模拟器的设备只能是 USB 设备
+func transportKindOf(serial, usb string) TransportKind {
+	switch {
+	case serial == "":
+		return TransportUnknown
+	case usb != "":
+		return TransportUSB
+	case strings.HasPrefix(serial, "emulator-"):
+		return TransportEmulator
+	case strings.Contains(serial, "._adb-tls-connect._tcp") || strings.Contains(serial, "._adb._tcp"):
+		return TransportTCP // 通过 mDNS 发现的无线调试设备
+	}
+	if _, port, err := net.SplitHostPort(serial); err == nil && port != "" {
+		return TransportTCP
+	}
+	return TransportUSB
+}
+
+// ResolveIdentity 读取设备的 ro.serialno 并保存在设备缓存中，已识别过的设备直接返回缓存
+func (m *ADBManager) ResolveIdentity(serial string) (string, error) {
+	return m.ResolveIdentityContext(context.Background(), serial)
+}
+
+// ResolveIdentityContext 可通过 ctx 取消的 ResolveIdentity
+func (m *ADBManager) ResolveIdentityContext(ctx context.Context, serial string) (string, error) {
+	m.deviceCacheLock.RLock()
+	dev, exists := m.managedDevices[serial]
+	var hardwareSerial string
+	if exists {
+		hardwareSerial = dev.HardwareSerial
+	}
+	m.deviceCacheLock.RUnlock()
+	if hardwareSerial != "" {
+		return hardwareSerial, nil
+	}
+
+	output, err := m.shellStdout(ctx, serial, ShellJoin("getprop", "ro.serialno"), false)
+	if err != nil {
+		return "", fmt.Errorf("读取 ro.serialno 失败: %w", err)
+	}
+	hardwareSerial = strings.TrimSpace(output)
+	if hardwareSerial == "" {
+		return "", nil // 部分模拟器与定制系统没有 ro.serialno，无法识别
+	}
+
+	m.deviceCacheLock.Lock()
+	if dev, exists := m.managedDevices[serial]; exists {
+		dev.HardwareSerial = hardwareSerial
+	}
+	m.deviceCacheLock.Unlock()
+	return hardwareSerial, nil
+}
+
+// ResolveIdentities 识别所有在线但尚未识别的设备，个别设备失败不影响其余设备
+func (m *ADBManager) ResolveIdentities() error {
+	return m.ResolveIdentitiesContext(context.Background())
+}
+
+// ResolveIdentitiesContext 可通过 ctx 取消的 ResolveIdentities
+func (m *ADBManager) ResolveIdentitiesContext(ctx context.Context) error {
+	m.deviceCacheLock.RLock()
+	pending := make([]string, 0)
+	for serial, dev := range m.managedDevices {
+		if dev.Status == "device" && dev.HardwareSerial == "" {
+			pending = append(pending, serial)
+		}
+	}
+	m.deviceCacheLock.RUnlock()
+	sort.Strings(pending)
+
+	var errs []error
+	for _, serial := range pending {
+		if _, err := m.ResolveIdentityContext(ctx, serial); err != nil {
+			if ctxErr := contextError(ctx); ctxErr != nil {
+				return ctxErr
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", serial, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MergeDevices 将 ro.serialno 相同的设备合并为一项（例如同一台手机的 USB 序列号与 IP:5555）
+// 保留在线且速度最快的连接作为主序列号，其余连接记录在 Aliases 中；未识别的设备原样保留
+func MergeDevices(devices []Device) []Device {
+	merged := make([]Device, 0, len(devices))
+	groups := make(map[string][]Device)
+	for _, dev := range devices {
+		if dev.HardwareSerial == "" {
+			merged = append(merged, dev)
+			continue
+		}
+		groups[dev.HardwareSerial] = append(groups[dev.HardwareSerial], dev)
+	}
+
+	for _, group := range groups {
+		sort.SliceStable(group, func(i, j int) bool {
+			return connectionRank(group[i]) < connectionRank(group[j])
+		})
+		primary := group[0]
+		primary.Aliases = nil
+		for _, alias := range group[1:] {
+			primary.Aliases = append(primary.Aliases, alias.Serial)
+		}
+		merged = append(merged, primary)
+	}
+
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Serial < merged[j].Serial })
+	return merged
+}
+
+// connectionRank 合并时选择主连接的优先级，数值越小越优先：在线优先，其次 USB 优先于网络
+func connectionRank(dev Device) int {
+	rank := 0
+	if dev.Status != "device" {
+		rank += 10
+	}
+	switch dev.Transport {
+	case TransportUSB:
+	case TransportTCP:
+		rank += 1
+	default:
+		rank += 2
+	}
+	return rank
+}
@@ -0,0 +1,333 @@
+package adb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ConcurrencyLimits adb 操作的并发上限，0 表示不限制
+type ConcurrencyLimits struct {
+	Global    int `json:"global"`     // 同时执行的 adb 操作总数
+	PerDevice int `json:"per_device"` // 同一设备上同时执行的 adb 操作数
+}
+
+// DefaultConcurrencyLimits 默认上限：总共 16 个，每台设备 4 个
+// 同时启动过多 adb 进程时 adb server 容易反复重启，批量操作几百台设备时尤其明显
+func DefaultConcurrencyLimits() ConcurrencyLimits {
+	return ConcurrencyLimits{Global: 16, PerDevice: 4}
+}
+
+// DefaultConcurrencyPath 返回并发上限配置文件的默认路径
+func DefaultConcurrencyPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "concurrency.json"
+	}
+	return filepath.Join(dir, "adbmanager", "concurrency.json")
+}
+
+// LoadConcurrencyLimits 读取并发上限配置，文件不存在时返回默认上限
+func LoadConcurrencyLimits(path string) (ConcurrencyLimits, error) {
+	limits := DefaultConcurrencyLimits()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return limits, nil
+	}
+	if err != nil {
+		return limits, err
+	}
+	if err := json.Unmarshal(data, &limits); err != nil {
+		return DefaultConcurrencyLimits(), fmt.Errorf("解析 %s 失败: %v", path, err)
+	}
+	return limits, nil
+}
+
+// SaveConcurrencyLimits 保存并发上限配置
+func SaveConcurrencyLimits(path string, limits ConcurrencyLimits) error {
+	data, err := json.MarshalIndent(limits, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建配置目录失败: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("保存并发上限失败: %v", err)
+	}
+	return nil
+}
+
+// QueueStats 并发限制器的统计
+type QueueStats struct {
+	Limits     ConcurrencyLimits
+	Running    int                         // 正在执行的操作数
+	Queued     int                         // 正在排队的操作数
+	PeakQueued int                         // 排队数的历史峰值
+	Granted    int64                       // 已获得执行许可的操作总数
+	TotalWait  time.Duration               // 所有操作排队等待的累计时长
+	Devices    map[string]DeviceQueueStats // 有操作在执行或排队的设备，不针对设备的命令以空字符串为键
+}
+
+// AvgWait 平均排队等待时长
+func (s QueueStats) AvgWait() time.Duration {
+	if s.Granted == 0 {
+		return 0
+	}
+	return s.TotalWait / time.Duration(s.Granted)
+}
+
+// SortedDevices 按序列号排序的设备列表，便于显示
+func (s QueueStats) SortedDevices() []string {
+	serials := make([]string, 0, len(s.Devices))
+	for serial := range s.Devices {
+		serials = append(serials, serial)
+	}
+	sort.Strings(serials)
+	return serials
+}
+
+// DeviceQueueStats 单台设备的并发统计
+type DeviceQueueStats struct {
+	Running int
+	Queued  int
+}
+
+// limiterWaiter 排队中的操作
+type limiterWaiter struct {
+	ready   chan struct{} // 获得许可时关闭
+	granted bool
+}
+
+// limiterDevice 单台设备的执行数与等待队列
+type limiterDevice struct {
+	running int
+	waiters []*limiterWaiter
+}
+
+// Limiter 全局与按设备的并发限制器
+// 每台设备各自排队，总数有空闲时在有操作排队的设备间轮流放行，
+// 一台设备排了大量操作也不会让其他设备一直等待
+type Limiter struct {
+	mu      sync.Mutex
+	limits  ConcurrencyLimits
+	running int
+	devices map[string]*limiterDevice
+	ring    []string // 有操作排队的设备，按轮转顺序
+	next    int      // 下次从 ring 的哪个位置开始查找
+
+	queued     int
+	peakQueued int
+	granted    int64
+	totalWait  time.Duration
+}
+
+// NewLimiter 创建并发限制器
+func NewLimiter(limits ConcurrencyLimits) *Limiter {
+	return &Limiter{
+		limits:  limits,
+		devices: make(map[string]*limiterDevice),
+	}
+}
+
+// SetLimits 修改并发上限，立即生效；调低上限不会中断正在执行的操作
+func (l *Limiter) SetLimits(limits ConcurrencyLimits) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits = limits
+	l.dispatch()
+}
+
+// Limits 当前的并发上限
+func (l *Limiter) Limits() ConcurrencyLimits {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limits
+}
+
+// Stats 返回当前统计
+func (l *Limiter) Stats() QueueStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := QueueStats{
+		Limits:     l.limits,
+		Running:    l.running,
+		Queued:     l.queued,
+		PeakQueued: l.peakQueued,
+		Granted:    l.granted,
+		TotalWait:  l.totalWait,
+		Devices:    make(map[string]DeviceQueueStats, len(l.devices)),
+	}
+	for serial, dev := range l.devices {
+		stats.Devices[serial] = DeviceQueueStats{Running: dev.running, Queued: len(dev.waiters)}
+	}
+	return stats
+}
+
+// Acquire 等待 serial 上的执行许可，成功后必须调用返回的 release
+// ctx 在排队期间取消时返回 ctx 对应的错误
+func (l *Limiter) Acquire(ctx context.Context, serial string) (func(), error) {
+	start := time.Now()
+	l.mu.Lock()
+	dev := l.device(serial)
+	if len(dev.waiters) == 0 && l.available(dev) {
+		l.grant(dev)
+		l.mu.Unlock()
+		return l.releaser(serial), nil
+	}
+
+	waiter := &limiterWaiter{ready: make(chan struct{})}
+	if len(dev.waiters) == 0 {
+		l.ring = append(l.ring, serial)
+	}
+	dev.waiters = append(dev.waiters, waiter)
+	l.queued++
+	if l.queued > l.peakQueued {
+		l.peakQueued = l.queued
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-waiter.ready:
+		l.mu.Lock()
+		l.totalWait += time.Since(start)
+		l.mu.Unlock()
+		return l.releaser(serial), nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		if waiter.granted {
+			// 取消与放行同时发生，归还许可
+			l.mu.Unlock()
+			l.releaser(serial)()
+		} else {
+			l.removeWaiter(serial, waiter)
+			l.mu.Unlock()
+		}
+		return nil, contextError(ctx)
+	}
+}
+
+// device 返回设备的状态，不存在时创建，调用方需持有锁
+func (l *Limiter) device(serial string) *limiterDevice {
+	dev, exists := l.devices[serial]
+	if !exists {
+		dev = &limiterDevice{}
+		l.devices[serial] = dev
+	}
+	return dev
+}
+
+// available 总数与该设备是否都还有空闲，调用方需持有锁
+func (l *Limiter) available(dev *limiterDevice) bool {
+	if l.limits.Global > 0 && l.running >= l.limits.Global {
+		return false
+	}
+	return l.limits.PerDevice <= 0 || dev.running < l.limits.PerDevice
+}
+
+// grant 为设备占用一个许可，调用方需持有锁
+func (l *Limiter) grant(dev *limiterDevice) {
+	l.running++
+	dev.running++
+	l.granted++
+}
+
+// releaser 返回归还许可的函数，多次调用只归还一次
+func (l *Limiter) releaser(serial string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.running--
+			dev := l.devices[serial]
+			dev.running--
+			l.forget(serial, dev)
+			l.dispatch()
+		})
+	}
+}
+
+// dispatch 在有操作排队的设备间轮流放行，直到总数用满或没有可放行的操作，调用方需持有锁
+func (l *Limiter) dispatch() {
+	for len(l.ring) > 0 {
+		if l.limits.Global > 0 && l.running >= l.limits.Global {
+			return
+		}
+
+		granted := false
+		for i := 0; i < len(l.ring); i++ {
+			pos := (l.next + i) % len(l.ring)
+			serial := l.ring[pos]
+			dev := l.devices[serial]
+			if !l.available(dev) {
+				continue
+			}
+
+			waiter := dev.waiters[0]
+			dev.waiters = dev.waiters[1:]
+			l.queued--
+			l.grant(dev)
+			waiter.granted = true
+			close(waiter.ready)
+
+			// 下次从下一台设备开始，没有排队的设备移出轮转
+			l.next = pos + 1
+			if len(dev.waiters) == 0 {
+				l.ring = append(l.ring[:pos], l.ring[pos+1:]...)
+				l.next = pos
+			}
+			if len(l.ring) > 0 {
+				l.next %= len(l.ring)
+			}
+			granted = true
+			break
+		}
+		if !granted {
+			return
+		}
+	}
+}
+
+// removeWaiter 将取消的操作移出队列，调用方需持有锁
+func (l *Limiter) removeWaiter(serial string, waiter *limiterWaiter) {
+	dev := l.devices[serial]
+	for i, w := range dev.waiters {
+		if w == waiter {
+			dev.waiters = append(dev.waiters[:i], dev.waiters[i+1:]...)
+			l.queued--
+			break
+		}
+	}
+	if len(dev.waiters) == 0 {
+		for i, s := range l.ring {
+			if s == serial {
+				l.ring = append(l.ring[:i], l.ring[i+1:]...)
+				if l.next > i {
+					l.next--
+				}
+				break
+			}
+		}
+		if len(l.ring) > 0 {
+			l.next %= len(l.ring)
+		} else {
+			l.next = 0
+		}
+	}
+	l.forget(serial, dev)
+}
+
+// forget 设备没有执行中与排队的操作时删除其状态，调用方需持有锁
+func (l *Limiter) forget(serial string, dev *limiterDevice) {
+	if dev.running == 0 && len(dev.waiters) == 0 {
+		delete(l.devices, serial)
+	}
+}
@@ -0,0 +1,271 @@
+package adb
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// acquireResult 后台 Acquire 的结果
+type acquireResult struct {
+	serial  string
+	release func()
+	err     error
+}
+
+// acquireAsync 在后台等待许可，并等到它进入队列或已获得许可
+func acquireAsync(t *testing.T, l *Limiter, ctx context.Context, serial string) <-chan acquireResult {
+	t.Helper()
+	before := l.Stats()
+	result := make(chan acquireResult, 1)
+	go func() {
+		release, err := l.Acquire(ctx, serial)
+		result <- acquireResult{serial, release, err}
+	}()
+	waitStats(t, l, func(s QueueStats) bool {
+		return s.Queued > before.Queued || s.Granted > before.Granted
+	})
+	return result
+}
+
+// waitStats 等待统计满足条件
+func waitStats(t *testing.T, l *Limiter, ok func(QueueStats) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !ok(l.Stats()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("stats = %+v", l.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// mustAcquire 立即获得许可，需要排队时失败
+func mustAcquire(t *testing.T, l *Limiter, serial string) func() {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	release, err := l.Acquire(ctx, serial)
+	if err != nil {
+		t.Fatalf("Acquire(%q) had to wait: %v", serial, err)
+	}
+	return release
+}
+
+// expectGranted 等待后台的 Acquire 获得许可
+func expectGranted(t *testing.T, result <-chan acquireResult) acquireResult {
+	t.Helper()
+	select {
+	case r := <-result:
+		if r.err != nil {
+			t.Fatalf("Acquire(%q): %v", r.serial, r.err)
+		}
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("Acquire was not granted")
+		return acquireResult{}
+	}
+}
+
+// expectWaiting 确认后台的 Acquire 仍在排队
+func expectWaiting(t *testing.T, result <-chan acquireResult) {
+	t.Helper()
+	select {
+	case r := <-result:
+		t.Fatalf("Acquire(%q) returned while it should be queued: %v", r.serial, r.err)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestLimiterPerDevice(t *testing.T) {
+	l := NewLimiter(ConcurrencyLimits{PerDevice: 2})
+	ctx := context.Background()
+
+	releaseA1 := mustAcquire(t, l, "emulator-5554")
+	mustAcquire(t, l, "emulator-5554")
+	third := acquireAsync(t, l, ctx, "emulator-5554")
+	expectWaiting(t, third)
+
+	// 其他设备不受影响
+	releaseB := mustAcquire(t, l, "emulator-5556")
+
+	stats := l.Stats()
+	want := map[string]DeviceQueueStats{
+		"emulator-5554": {Running: 2, Queued: 1},
+		"emulator-5556": {Running: 1},
+	}
+	if !reflect.DeepEqual(stats.Devices, want) || stats.Running != 3 || stats.Queued != 1 {
+		t.Fatalf("stats = %+v, want devices %+v", stats, want)
+	}
+
+	releaseB()
+	expectWaiting(t, third)
+	releaseA1()
+	releaseA1() // 重复调用只归还一次
+	expectGranted(t, third)
+	if stats := l.Stats(); stats.Running != 2 || stats.Queued != 0 {
+		t.Errorf("stats = %+v, want 2 running", stats)
+	}
+}
+
+func TestLimiterGlobal(t *testing.T) {
+	l := NewLimiter(ConcurrencyLimits{Global: 2})
+	ctx := context.Background()
+
+	releaseA := mustAcquire(t, l, "emulator-5554")
+	mustAcquire(t, l, "emulator-5556")
+	// 不针对设备的命令同样受总数限制
+	queued := acquireAsync(t, l, ctx, "")
+	expectWaiting(t, queued)
+
+	releaseA()
+	r := expectGranted(t, queued)
+	r.release()
+	if stats := l.Stats(); stats.Running != 1 || stats.Granted != 3 {
+		t.Errorf("stats = %+v, want 1 running and 3 granted", stats)
+	}
+}
+
+func TestLimiterFairness(t *testing.T) {
+	l := NewLimiter(ConcurrencyLimits{Global: 1})
+	ctx := context.Background()
+
+	// 繁忙设备先排了三个操作，空闲设备随后排一个
+	release := mustAcquire(t, l, "busy")
+	granted := make(chan acquireResult, 4)
+	for _, serial := range []string{"busy", "busy", "busy", "idle"} {
+		result := acquireAsync(t, l, ctx, serial)
+		go func() { granted <- <-result }()
+	}
+	if stats := l.Stats(); stats.Queued != 4 || stats.PeakQueued != 4 {
+		t.Fatalf("stats = %+v, want 4 queued", stats)
+	}
+
+	// 两台设备轮流放行，空闲设备不必等繁忙设备的队列排空
+	var order []string
+	for range 4 {
+		release()
+		r := expectGranted(t, granted)
+		order = append(order, r.serial)
+		release = r.release
+	}
+	release()
+
+	want := []string{"busy", "idle", "busy", "busy"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("grant order = %q, want %q", order, want)
+	}
+}
+
+func TestLimiterCancelQueued(t *testing.T) {
+	l := NewLimiter(ConcurrencyLimits{PerDevice: 1})
+
+	release := mustAcquire(t, l, "emulator-5554")
+	ctx, cancel := context.WithCancel(context.Background())
+	queued := acquireAsync(t, l, ctx, "emulator-5554")
+	other := acquireAsync(t, l, context.Background(), "emulator-5554")
+	if stats := l.Stats(); stats.Queued != 2 || stats.Devices["emulator-5554"].Queued != 2 {
+		t.Fatalf("stats = %+v, want 2 queued", stats)
+	}
+
+	cancel()
+	select {
+	case r := <-queued:
+		if !errors.Is(r.err, context.Canceled) || r.release != nil {
+			t.Fatalf("cancelled Acquire = %v, want context.Canceled", r.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled Acquire did not return")
+	}
+	stats := l.Stats()
+	if stats.Queued != 1 || stats.PeakQueued != 2 || stats.Devices["emulator-5554"] != (DeviceQueueStats{Running: 1, Queued: 1}) {
+		t.Fatalf("stats after cancel = %+v", stats)
+	}
+
+	// 取消的操作不占用名额，后面排队的操作照常放行
+	release()
+	r := expectGranted(t, other)
+	r.release()
+	stats = l.Stats()
+	if stats.Running != 0 || stats.Queued != 0 || len(stats.Devices) != 0 || stats.Granted != 2 {
+		t.Errorf("final stats = %+v, want idle with 2 granted", stats)
+	}
+}
+
+func TestLimiterGrantCancelRace(t *testing.T) {
+	l := NewLimiter(ConcurrencyLimits{PerDevice: 1})
+	mustAcquire(t, l, "emulator-5554")
+	ctx, cancel := context.WithCancel(context.Background())
+	queued := acquireAsync(t, l, ctx, "emulator-5554")
+
+	// 持有锁期间取消，排队的操作进入取消分支后等待锁；
+	// 随后代替 release 归还许可并放行它，模拟取消与放行同时发生
+	l.mu.Lock()
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	l.running--
+	l.devices["emulator-5554"].running--
+	l.dispatch()
+	granted := l.devices["emulator-5554"].running == 1
+	l.mu.Unlock()
+	if !granted {
+		t.Fatal("dispatch did not grant the queued waiter")
+	}
+
+	select {
+	case r := <-queued:
+		if !errors.Is(r.err, context.Canceled) {
+			t.Fatalf("Acquire = %v, want context.Canceled", r.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Acquire did not return")
+	}
+	// 已放行的许可被归还
+	stats := l.Stats()
+	if stats.Running != 0 || stats.Queued != 0 || len(stats.Devices) != 0 {
+		t.Fatalf("stats = %+v, want no running or queued operations", stats)
+	}
+	mustAcquire(t, l, "emulator-5554")
+}
+
+func TestLimiterSetLimits(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("lower", func(t *testing.T) {
+		l := NewLimiter(ConcurrencyLimits{PerDevice: 3})
+		releases := []func(){
+			mustAcquire(t, l, "emulator-5554"),
+			mustAcquire(t, l, "emulator-5554"),
+			mustAcquire(t, l, "emulator-5554"),
+		}
+
+		// 调低上限不中断正在执行的操作，新操作等到执行数低于新上限
+		l.SetLimits(ConcurrencyLimits{PerDevice: 1})
+		if stats := l.Stats(); stats.Running != 3 || stats.Limits.PerDevice != 1 {
+			t.Fatalf("stats = %+v, want 3 running under the new limit", stats)
+		}
+		queued := acquireAsync(t, l, ctx, "emulator-5554")
+		releases[0]()
+		releases[1]()
+		expectWaiting(t, queued)
+		releases[2]()
+		expectGranted(t, queued)
+	})
+
+	t.Run("raise", func(t *testing.T) {
+		l := NewLimiter(ConcurrencyLimits{PerDevice: 1})
+		mustAcquire(t, l, "emulator-5554")
+		first := acquireAsync(t, l, ctx, "emulator-5554")
+		second := acquireAsync(t, l, ctx, "emulator-5554")
+
+		// 调高上限后立即放行排队的操作
+		l.SetLimits(ConcurrencyLimits{PerDevice: 3})
+		expectGranted(t, first)
+		expectGranted(t, second)
+		if stats := l.Stats(); stats.Running != 3 || stats.Queued != 0 {
+			t.Errorf("stats = %+v, want 3 running", stats)
+		}
+	})
+}
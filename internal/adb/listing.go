@@ -0,0 +1,410 @@
+package adb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileInfo 文件信息
+type FileInfo struct {
+	Name        string
+	Permissions string // ls 风格的权限，例如 drwxr-xr-x
+	Owner       string // 通过 sync 服务列出时为空
+	Group       string
+	Size        int64
+	ModTime     time.Time
+	ModTimeStep time.Duration // ModTime 的精度，ls 只显示到分钟时为 1 分钟，只显示日期时为 1 天；精确到秒时为 0
+	Mode        os.FileMode
+	IsDir       bool
+	IsSymlink   bool
+	LinkTarget  string // 符号链接指向的路径，无法得到时为空
+}
+
+// statFormat stat -c 的输出格式：十六进制 st_mode、大小、修改时间（秒）、属主、属组、带链接目标的文件名
+// 前五项不含空格，文件名放在最后
+const statFormat = "%f %s %Y %U %G %N"
+
+// dirLister 列出目录的一种方式，无法使用或输出无法解析时返回错误
+type dirLister func(ctx context.Context, serial, dir string) ([]FileInfo, error)
+
+// ListFiles 列出目录下的文件，不包含 "." 与 ".."
+func (m *ADBManager) ListFiles(serial, path string) ([]FileInfo, error) {
+	return m.ListFilesContext(context.Background(), serial, path)
+}
+
+// ListFilesContext 可通过 ctx 取消的 ListFiles
+// 依次尝试 stat -c（精确的时间与权限）、ls -la（兼容 toybox、busybox 与旧版 toolbox）、sync 服务的 LIST（不依赖设备上的命令，但没有属主与链接目标），
+// 成功列出非空目录的方式会被记住，之后优先使用
+func (m *ADBManager) ListFilesContext(ctx context.Context, serial, path string) ([]FileInfo, error) {
+	listers := []dirLister{m.listWithStat, m.listWithLs, m.listWithSync}
+
+	m.featureLock.Lock()
+	start := m.listMethods[serial]
+	m.featureLock.Unlock()
+
+	// 已知设备上没有 stat 时不再尝试
+	noStat := false
+	if caps := m.CachedCapabilities(serial); caps != nil && !caps.Has("stat") {
+		noStat = true
+	}
+
+	var firstErr error
+	for i := range listers {
+		method := (start + i) % len(listers)
+		if method == 0 && noStat {
+			continue
+		}
+		files, err := listers[method](ctx, serial, path)
+		if err == nil {
+			sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+			if len(files) > 0 {
+				m.featureLock.Lock()
+				m.listMethods[serial] = method
+				m.featureLock.Unlock()
+			}
+			return files, nil
+		}
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// listWithStat 通过 stat -c 列出目录，toybox（Android 6+）、busybox 与 GNU coreutils 均支持
+func (m *ADBManager) listWithStat(ctx context.Context, serial, dir string) ([]FileInfo, error) {
+	// 目录部分转义，通配符部分不转义，由设备上的 shell 展开
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	quoted := ShellQuote(strings.TrimSuffix(dir, "/"))
+	command := ShellJoin("stat", "-c", statFormat, "--") + " " + quoted + "/.* " + quoted + "/*"
+
+	output, err := m.shellStdout(ctx, serial, command, true)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]FileInfo, 0)
+	recognized := false
+	for _, line := range strings.Split(output, "\n") {
+		file, ok := parseStatLine(strings.TrimRight(line, "\r"), prefix)
+		if !ok {
+			continue
+		}
+		recognized = true
+		if file.Name != "." && file.Name != ".." && file.Name != "*" && file.Name != ".*" {
+			files = append(files, file)
+		}
+	}
+	if !recognized && strings.TrimSpace(output) != "" {
+		return nil, fmt.Errorf("无法解析 stat 输出: %s", firstLine(output))
+	}
+	return files, nil
+}
+
+// listWithLs 通过 ls -la 列出目录，目录后加 "/" 使符号链接指向的目录被展开、普通文件报错
+func (m *ADBManager) listWithLs(ctx context.Context, serial, dir string) ([]FileInfo, error) {
+	output, err := m.shellStdout(ctx, serial, ShellJoin("ls", "-la", strings.TrimSuffix(dir, "/")+"/"), true)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]FileInfo, 0)
+	unrecognized := ""
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "total ") {
+			continue
+		}
+		file, ok := parseLsLine(line, time.Now())
+		if !ok {
+			if unrecognized == "" {
+				unrecognized = line
+			}
+			continue
+		}
+		if file.Name != "." && file.Name != ".." {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 && unrecognized != "" {
+		return nil, fmt.Errorf("无法解析 ls 输出: %s", unrecognized)
+	}
+	return files, nil
+}
+
+// listWithSync 通过 sync 服务的 LIST 列出目录，不依赖设备上的命令
+func (m *ADBManager) listWithSync(ctx context.Context, serial, dir string) ([]FileInfo, error) {
+	entries, err := m.ListDirectoryContext(ctx, serial, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		files = append(files, FileInfo{
+			Name:        entry.Name,
+			Permissions: lsPermissions(entry.Mode),
+			Size:        int64(entry.Size),
+			ModTime:     entry.ModTime,
+			Mode:        entry.Mode,
+			IsDir:       entry.Mode.IsDir(),
+			IsSymlink:   entry.Mode&os.ModeSymlink != 0,
+		})
+	}
+	return files, nil
+}
+
+// parseStatLine 解析一行 stat -c statFormat 的输出，prefix 为需要从文件名中去掉的目录前缀
+//
+//	toybox:   41f9 3488 1704163200 root sdcard_rw /sdcard/Download
+//	toybox:   a1ff 21 1230768000 root root /sdcard -> `/storage/self/primary'
+//	busybox:  a1ff 11 1704163200 root root '/etc/localtime' -> '/usr/share/zoneinfo/UTC'
+//	GNU:      81a4 1234 1704163200 pi pi '/home/pi/a b.txt'
+func parseStatLine(line, prefix string) (FileInfo, bool) {
+	fields := strings.SplitN(line, " ", 6)
+	if len(fields) != 6 {
+		return FileInfo{}, false
+	}
+	rawMode, err := strconv.ParseUint(fields[0], 16, 32)
+	if err != nil {
+		return FileInfo{}, false
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return FileInfo{}, false
+	}
+	mtime, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return FileInfo{}, false
+	}
+
+	mode := unixModeToFileMode(uint32(rawMode))
+	file := FileInfo{
+		Permissions: lsPermissions(mode),
+		Owner:       fields[3],
+		Group:       fields[4],
+		Size:        size,
+		ModTime:     time.Unix(mtime, 0),
+		Mode:        mode,
+		IsDir:       mode.IsDir(),
+		IsSymlink:   mode&os.ModeSymlink != 0,
+	}
+
+	name := fields[5]
+	if file.IsSymlink {
+		if before, after, ok := strings.Cut(name, " -> "); ok {
+			name = before
+			file.LinkTarget = unquoteStatName(after)
+		}
+	}
+	name = unquoteStatName(name)
+	file.Name = strings.TrimPrefix(name, prefix)
+	return file, true
+}
+
+// unquoteStatName 去掉 stat %N 给文件名加的引号，兼容 'name'、`name' 与不加引号三种形式
+// GNU coreutils 会把名称中的单引号写成先闭合引号、转义、再重新开始引号的形式
+func unquoteStatName(name string) string {
+	if len(name) >= 2 && (name[0] == '\'' || name[0] == '`') && name[len(name)-1] == '\'' {
+		name = name[1 : len(name)-1]
+		name = strings.ReplaceAll(name, `'\''`, "'")
+	}
+	return name
+}
+
+// lsLinePattern ls -l 的一行：权限、中间列（链接数、属主、属组、大小或设备号）、日期、文件名
+// 日期支持 toybox / toolbox 的 "2024-01-02 12:34"（含秒、时区的 --full-time 形式）与 busybox / GNU 的 "Jan  2 12:34"、"Jan  2  2023"
+var lsLinePattern = regexp.MustCompile(`^([-bcdlps?][-rwxsStT?]{9})[.+@]?\s+(.*?)\s+` +
+	`(\d{4}-\d{2}-\d{2}\s+\d{1,2}:\d{2}(?::\d{2}(?:\.\d+)?)?(?:\s+[-+]\d{4})?|[A-Z][a-z]{2}\s+\d{1,2}\s+(?:\d{1,2}:\d{2}|\d{4}))` +
+	`\s(.*)$`)
+
+// parseLsLine 解析一行 ls -la 的输出，now 用于补全 busybox 省略的年份
+//
+//	toybox (Android 6+):  drwxrwx--x  4 root sdcard_rw  3488 2024-01-02 12:34 Download
+//	toybox 符号链接:       lrwxrwxrwx  1 root root         21 2009-01-01 08:00 sdcard -> /storage/self/primary
+//	toybox 设备节点:       crw-rw-rw-  1 root root     1,   3 2024-01-02 12:34 null
+//	toolbox (Android 5):  drwxrwx--- root     sdcard_r          2015-03-04 12:00 Alarms
+//	toolbox 文件:          -rw-rw---- root     sdcard_r     1234 2015-03-04 12:00 a b.txt
+//	busybox:              -rw-r--r--    1 root     root          1234 Jan  2 12:34 a.txt
+//	busybox 往年文件:      -rw-r--r--    1 root     root          1234 Jan  2  2023 a.txt
+func parseLsLine(line string, now time.Time) (FileInfo, bool) {
+	match := lsLinePattern.FindStringSubmatch(line)
+	if match == nil {
+		return FileInfo{}, false
+	}
+	perms, middle, date, name := match[1], strings.Fields(match[2]), match[3], match[4]
+
+	mode := parseLsPermissions(perms)
+	file := FileInfo{
+		Name:        name,
+		Permissions: perms,
+		Mode:        mode,
+		IsDir:       mode.IsDir(),
+		IsSymlink:   mode&os.ModeSymlink != 0,
+	}
+
+	// 设备节点的大小列为 "主设备号, 次设备号" 或 "主,次"，按大小 0 处理
+	switch n := len(middle); {
+	case mode&os.ModeDevice != 0 && n >= 2 && strings.HasSuffix(middle[n-2], ","):
+		middle = middle[:n-2]
+	case mode&os.ModeDevice != 0 && n >= 1 && strings.Contains(middle[n-1], ","):
+		middle = middle[:n-1]
+	case n >= 3:
+		// toolbox 不输出链接数，目录与符号链接也不输出大小，此时中间只有属主与属组
+		if size, err := strconv.ParseInt(middle[n-1], 10, 64); err == nil {
+			file.Size = size
+			middle = middle[:n-1]
+		}
+	}
+	if len(middle) >= 3 {
+		middle = middle[len(middle)-2:] // 去掉链接数
+	}
+	if len(middle) == 2 {
+		file.Owner, file.Group = middle[0], middle[1]
+	} else if len(middle) == 1 {
+		file.Owner = middle[0]
+	}
+
+	if file.IsSymlink {
+		if before, after, ok := strings.Cut(name, " -> "); ok {
+			file.Name, file.LinkTarget = before, after
+		}
+	}
+
+	modTime, step, ok := parseLsDate(date, now)
+	if !ok {
+		return FileInfo{}, false
+	}
+	file.ModTime, file.ModTimeStep = modTime, step
+	return file, true
+}
+
+// parseLsDate 解析 ls 输出中的日期，同时返回其精度（见 FileInfo.ModTimeStep），设备时区未知，按本机时区处理
+func parseLsDate(date string, now time.Time) (time.Time, time.Duration, bool) {
+	date = strings.Join(strings.Fields(date), " ")
+	for _, format := range []struct {
+		layout string
+		step   time.Duration
+	}{
+		{"2006-01-02 15:04", time.Minute},
+		{"2006-01-02 15:04:05", 0},
+		{"2006-01-02 15:04:05.999999999", 0},
+		{"2006-01-02 15:04:05.999999999 -0700", 0},
+		{"Jan 2 2006", 24 * time.Hour},
+	} {
+		if t, err := time.ParseInLocation(format.layout, date, time.Local); err == nil {
+			return t, format.step, true
+		}
+	}
+
+	// 半年内的文件不显示年份，取当前年份，若因此落在将来则属于去年
+	t, err := time.ParseInLocation("Jan 2 15:04 2006", date+" "+strconv.Itoa(now.Year()), time.Local)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t, time.Minute, true
+}
+
+// parseLsPermissions 将 ls 的权限字符串转换为 os.FileMode
+func parseLsPermissions(perms string) os.FileMode {
+	var mode os.FileMode
+	switch perms[0] {
+	case 'd':
+		mode |= os.ModeDir
+	case 'l':
+		mode |= os.ModeSymlink
+	case 'c':
+		mode |= os.ModeDevice | os.ModeCharDevice
+	case 'b':
+		mode |= os.ModeDevice
+	case 'p':
+		mode |= os.ModeNamedPipe
+	case 's':
+		mode |= os.ModeSocket
+	}
+
+	for i, c := range perms[1:10] {
+		bit := os.FileMode(0400) >> i
+		switch c {
+		case 'r', 'w', 'x':
+			mode |= bit
+		case 's':
+			mode |= bit
+			fallthrough
+		case 'S':
+			if i == 2 {
+				mode |= os.ModeSetuid
+			} else {
+				mode |= os.ModeSetgid
+			}
+		case 't':
+			mode |= bit
+			fallthrough
+		case 'T':
+			mode |= os.ModeSticky
+		}
+	}
+	return mode
+}
+
+// lsPermissions 将 os.FileMode 转换为 ls 风格的权限字符串
+func lsPermissions(mode os.FileMode) string {
+	perms := []byte("----------")
+	switch {
+	case mode.IsDir():
+		perms[0] = 'd'
+	case mode&os.ModeSymlink != 0:
+		perms[0] = 'l'
+	case mode&os.ModeCharDevice != 0:
+		perms[0] = 'c'
+	case mode&os.ModeDevice != 0:
+		perms[0] = 'b'
+	case mode&os.ModeNamedPipe != 0:
+		perms[0] = 'p'
+	case mode&os.ModeSocket != 0:
+		perms[0] = 's'
+	}
+
+	for i, c := range "rwxrwxrwx" {
+		if mode&(os.FileMode(0400)>>i) != 0 {
+			perms[i+1] = byte(c)
+		}
+	}
+	for _, special := range []struct {
+		flag     os.FileMode
+		index    int
+		set, off byte
+	}{
+		{os.ModeSetuid, 3, 's', 'S'},
+		{os.ModeSetgid, 6, 's', 'S'},
+		{os.ModeSticky, 9, 't', 'T'},
+	} {
+		if mode&special.flag == 0 {
+			continue
+		}
+		if perms[special.index] == 'x' {
+			perms[special.index] = special.set
+		} else {
+			perms[special.index] = special.off
+		}
+	}
+	return string(perms)
+}
+
+// firstLine 返回输出的第一行，用于错误信息
+func firstLine(output string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(output), "\n")
+	return line
+}
@@ -0,0 +1,327 @@
+package adb
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// localTime 本机时区的时间，ls 输出中的日期按本机时区解析
+func localTime(year int, month time.Month, day, hour, min, sec int) time.Time {
+	return time.Date(year, month, day, hour, min, sec, 0, time.Local)
+}
+
+func TestParseLsLine(t *testing.T) {
+	now := localTime(2024, time.June, 15, 12, 0, 0)
+	tests := []struct {
+		name string
+		line string
+		want FileInfo
+	}{
+		// 注释中的示例
+		{
+			name: "toybox 目录",
+			line: "drwxrwx--x  4 root sdcard_rw  3488 2024-01-02 12:34 Download",
+			want: FileInfo{Name: "Download", Permissions: "drwxrwx--x", Owner: "root", Group: "sdcard_rw", Size: 3488, ModTime: localTime(2024, time.January, 2, 12, 34, 0), Mode: os.ModeDir | 0771, IsDir: true},
+		},
+		{
+			name: "toybox 符号链接",
+			line: "lrwxrwxrwx  1 root root         21 2009-01-01 08:00 sdcard -> /storage/self/primary",
+			want: FileInfo{Name: "sdcard", Permissions: "lrwxrwxrwx", Owner: "root", Group: "root", Size: 21, ModTime: localTime(2009, time.January, 1, 8, 0, 0), Mode: os.ModeSymlink | 0777, IsSymlink: true, LinkTarget: "/storage/self/primary"},
+		},
+		{
+			name: "toybox 设备节点",
+			line: "crw-rw-rw-  1 root root     1,   3 2024-01-02 12:34 null",
+			want: FileInfo{Name: "null", Permissions: "crw-rw-rw-", Owner: "root", Group: "root", ModTime: localTime(2024, time.January, 2, 12, 34, 0), Mode: os.ModeDevice | os.ModeCharDevice | 0666},
+		},
+		{
+			name: "toolbox 目录",
+			line: "drwxrwx--- root     sdcard_r          2015-03-04 12:00 Alarms",
+			want: FileInfo{Name: "Alarms", Permissions: "drwxrwx---", Owner: "root", Group: "sdcard_r", ModTime: localTime(2015, time.March, 4, 12, 0, 0), Mode: os.ModeDir | 0770, IsDir: true},
+		},
+		{
+			name: "toolbox 文件",
+			line: "-rw-rw---- root     sdcard_r     1234 2015-03-04 12:00 a b.txt",
+			want: FileInfo{Name: "a b.txt", Permissions: "-rw-rw----", Owner: "root", Group: "sdcard_r", Size: 1234, ModTime: localTime(2015, time.March, 4, 12, 0, 0), Mode: 0660},
+		},
+		{
+			name: "busybox 文件",
+			line: "-rw-r--r--    1 root     root          1234 Jan  2 12:34 a.txt",
+			want: FileInfo{Name: "a.txt", Permissions: "-rw-r--r--", Owner: "root", Group: "root", Size: 1234, ModTime: localTime(2024, time.January, 2, 12, 34, 0), Mode: 0644},
+		},
+		{
+			name: "busybox 往年文件",
+			line: "-rw-r--r--    1 root     root          1234 Jan  2  2023 a.txt",
+			want: FileInfo{Name: "a.txt", Permissions: "-rw-r--r--", Owner: "root", Group: "root", Size: 1234, ModTime: localTime(2023, time.January, 2, 0, 0, 0), Mode: 0644},
+		},
+
+		// Android 5 / 6 toolbox
+		{
+			name: "Android 5 toolbox 符号链接",
+			line: "lrwxrwxrwx root     root              2015-03-04 12:00 sdcard -> /storage/emulated/legacy",
+			want: FileInfo{Name: "sdcard", Permissions: "lrwxrwxrwx", Owner: "root", Group: "root", ModTime: localTime(2015, time.March, 4, 12, 0, 0), Mode: os.ModeSymlink | 0777, IsSymlink: true, LinkTarget: "/storage/emulated/legacy"},
+		},
+		{
+			name: "Android 5 toolbox 设备节点",
+			line: "crw-rw-rw- root     root       1,   3 2015-03-04 11:58 null",
+			want: FileInfo{Name: "null", Permissions: "crw-rw-rw-", Owner: "root", Group: "root", ModTime: localTime(2015, time.March, 4, 11, 58, 0), Mode: os.ModeDevice | os.ModeCharDevice | 0666},
+		},
+		{
+			name: "Android 6 toolbox 块设备",
+			line: "brw------- root     root     179,   0 2016-08-01 09:15 mmcblk0",
+			want: FileInfo{Name: "mmcblk0", Permissions: "brw-------", Owner: "root", Group: "root", ModTime: localTime(2016, time.August, 1, 9, 15, 0), Mode: os.ModeDevice | 0600},
+		},
+		{
+			name: "Android 6 toolbox 应用数据",
+			line: "-rw-rw---- u0_a57   u0_a57      16384 2016-08-01 09:20 webview.db",
+			want: FileInfo{Name: "webview.db", Permissions: "-rw-rw----", Owner: "u0_a57", Group: "u0_a57", Size: 16384, ModTime: localTime(2016, time.August, 1, 9, 20, 0), Mode: 0660},
+		},
+
+		// Android 7 - 15 toybox
+		{
+			name: "Android 7 toybox setuid",
+			line: "-rwsr-x---  1 root shell     10336 2017-03-01 10:00 run-as",
+			want: FileInfo{Name: "run-as", Permissions: "-rwsr-x---", Owner: "root", Group: "shell", Size: 10336, ModTime: localTime(2017, time.March, 1, 10, 0, 0), Mode: os.ModeSetuid | 0750},
+		},
+		{
+			name: "Android 9 toybox 粘滞位",
+			line: "drwxrwx--t 42 system cache     4096 2019-05-06 07:08 cache",
+			want: FileInfo{Name: "cache", Permissions: "drwxrwx--t", Owner: "system", Group: "cache", Size: 4096, ModTime: localTime(2019, time.May, 6, 7, 8, 0), Mode: os.ModeDir | os.ModeSticky | 0771, IsDir: true},
+		},
+		{
+			name: "Android 10 toybox 根目录",
+			line: "dr-xr-xr-x  64 root root          0 1970-01-01 08:00 proc",
+			want: FileInfo{Name: "proc", Permissions: "dr-xr-xr-x", Owner: "root", Group: "root", ModTime: localTime(1970, time.January, 1, 8, 0, 0), Mode: os.ModeDir | 0555, IsDir: true},
+		},
+		{
+			name: "Android 11 toybox setgid 目录",
+			line: "drwxrws---  2 u0_a234 media_rw  3452 2024-05-06 07:08 Download",
+			want: FileInfo{Name: "Download", Permissions: "drwxrws---", Owner: "u0_a234", Group: "media_rw", Size: 3452, ModTime: localTime(2024, time.May, 6, 7, 8, 0), Mode: os.ModeDir | os.ModeSetgid | 0770, IsDir: true},
+		},
+		{
+			name: "Android 13 toybox 文件名含空格与箭头以外的符号",
+			line: "-rw-rw----  1 u0_a234 media_rw 2048576 2024-05-06 07:08 IMG_20240506 (1).jpg",
+			want: FileInfo{Name: "IMG_20240506 (1).jpg", Permissions: "-rw-rw----", Owner: "u0_a234", Group: "media_rw", Size: 2048576, ModTime: localTime(2024, time.May, 6, 7, 8, 0), Mode: 0660},
+		},
+		{
+			name: "Android 14 toybox --full-time",
+			line: "-rw-r--r--  1 root root      1024 2024-03-01 10:20:30.123456789 +0800 build.prop",
+			want: FileInfo{Name: "build.prop", Permissions: "-rw-r--r--", Owner: "root", Group: "root", Size: 1024, ModTime: time.Date(2024, time.March, 1, 10, 20, 30, 123456789, time.FixedZone("", 8*3600)), Mode: 0644},
+		},
+		{
+			name: "Android 15 toybox 套接字",
+			line: "srw-rw----  1 root system        0 2024-10-01 00:00 property_service",
+			want: FileInfo{Name: "property_service", Permissions: "srw-rw----", Owner: "root", Group: "system", ModTime: localTime(2024, time.October, 1, 0, 0, 0), Mode: os.ModeSocket | 0660},
+		},
+		{
+			name: "Android 15 toybox 管道",
+			line: "prw-------  1 system system       0 2024-10-01 00:00 fifo",
+			want: FileInfo{Name: "fifo", Permissions: "prw-------", Owner: "system", Group: "system", ModTime: localTime(2024, time.October, 1, 0, 0, 0), Mode: os.ModeNamedPipe | 0600},
+		},
+
+		// busybox IoT 固件
+		{
+			name: "busybox 设备节点",
+			line: "crw-rw----    1 root     root       10, 130 Jan  1 00:00 watchdog",
+			want: FileInfo{Name: "watchdog", Permissions: "crw-rw----", Owner: "root", Group: "root", ModTime: localTime(2024, time.January, 1, 0, 0, 0), Mode: os.ModeDevice | os.ModeCharDevice | 0660},
+		},
+		{
+			name: "busybox 符号链接",
+			line: "lrwxrwxrwx    1 root     root             7 Jan  1  1970 localtime -> /tmp/TZ",
+			want: FileInfo{Name: "localtime", Permissions: "lrwxrwxrwx", Owner: "root", Group: "root", Size: 7, ModTime: localTime(1970, time.January, 1, 0, 0, 0), Mode: os.ModeSymlink | 0777, IsSymlink: true, LinkTarget: "/tmp/TZ"},
+		},
+		{
+			name: "busybox 数字属主",
+			line: "-rwxr-xr-x    1 1000     1000        524288 May 30 18:02 app.bin",
+			want: FileInfo{Name: "app.bin", Permissions: "-rwxr-xr-x", Owner: "1000", Group: "1000", Size: 524288, ModTime: localTime(2024, time.May, 30, 18, 2, 0), Mode: 0755},
+		},
+		{
+			name: "busybox 去年年底的文件",
+			line: "-rw-r--r--    1 root     root            64 Dec 31 23:59 hosts",
+			want: FileInfo{Name: "hosts", Permissions: "-rw-r--r--", Owner: "root", Group: "root", Size: 64, ModTime: localTime(2023, time.December, 31, 23, 59, 0), Mode: 0644},
+		},
+		{
+			name: "GNU coreutils ACL 标记",
+			line: "drwxr-xr-x+ 2 pi pi 4096 Jun 10 09:00 shared",
+			want: FileInfo{Name: "shared", Permissions: "drwxr-xr-x", Owner: "pi", Group: "pi", Size: 4096, ModTime: localTime(2024, time.June, 10, 9, 0, 0), Mode: os.ModeDir | 0755, IsDir: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseLsLine(tt.line, now)
+			if !ok {
+				t.Fatalf("parseLsLine(%q) 无法解析", tt.line)
+			}
+			if !got.ModTime.Equal(tt.want.ModTime) {
+				t.Errorf("ModTime = %v, want %v", got.ModTime, tt.want.ModTime)
+			}
+			got.ModTime, tt.want.ModTime = time.Time{}, time.Time{}
+			got.ModTimeStep = 0 // 精度由 TestParseLsDate 检查
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseLsLine(%q)\n got  %+v\n want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLsLineRejects(t *testing.T) {
+	now := localTime(2024, time.June, 15, 12, 0, 0)
+	for _, line := range []string{
+		"",
+		"total 24",
+		"ls: /data: Permission denied",
+		"ls: cannot access '/x/': No such file or directory",
+		"/system/bin/sh: ls: not found",
+		"drwxr-xr-x  2 root root 4096 2024-13-45 99:99 bad",
+	} {
+		if file, ok := parseLsLine(line, now); ok {
+			t.Errorf("parseLsLine(%q) = %+v, want 无法解析", line, file)
+		}
+	}
+}
+
+func TestParseStatLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		prefix string
+		want   FileInfo
+	}{
+		// 注释中的示例
+		{
+			name:   "toybox 目录",
+			line:   "41f9 3488 1704163200 root sdcard_rw /sdcard/Download",
+			prefix: "/sdcard/",
+			want:   FileInfo{Name: "Download", Permissions: "drwxrwx--x", Owner: "root", Group: "sdcard_rw", Size: 3488, ModTime: time.Unix(1704163200, 0), Mode: os.ModeDir | 0771, IsDir: true},
+		},
+		{
+			name:   "toybox 符号链接",
+			line:   "a1ff 21 1230768000 root root /sdcard -> `/storage/self/primary'",
+			prefix: "/",
+			want:   FileInfo{Name: "sdcard", Permissions: "lrwxrwxrwx", Owner: "root", Group: "root", Size: 21, ModTime: time.Unix(1230768000, 0), Mode: os.ModeSymlink | 0777, IsSymlink: true, LinkTarget: "/storage/self/primary"},
+		},
+		{
+			name:   "busybox 符号链接",
+			line:   "a1ff 11 1704163200 root root '/etc/localtime' -> '/usr/share/zoneinfo/UTC'",
+			prefix: "/etc/",
+			want:   FileInfo{Name: "localtime", Permissions: "lrwxrwxrwx", Owner: "root", Group: "root", Size: 11, ModTime: time.Unix(1704163200, 0), Mode: os.ModeSymlink | 0777, IsSymlink: true, LinkTarget: "/usr/share/zoneinfo/UTC"},
+		},
+		{
+			name:   "GNU 文件名含空格",
+			line:   "81a4 1234 1704163200 pi pi '/home/pi/a b.txt'",
+			prefix: "/home/pi/",
+			want:   FileInfo{Name: "a b.txt", Permissions: "-rw-r--r--", Owner: "pi", Group: "pi", Size: 1234, ModTime: time.Unix(1704163200, 0), Mode: 0644},
+		},
+
+		// 设备上的真实输出
+		{
+			name:   "Android 7 toybox setuid",
+			line:   "89e8 10336 1488362400 root shell /system/bin/run-as",
+			prefix: "/system/bin/",
+			want:   FileInfo{Name: "run-as", Permissions: "-rwsr-x---", Owner: "root", Group: "shell", Size: 10336, ModTime: time.Unix(1488362400, 0), Mode: os.ModeSetuid | 0750},
+		},
+		{
+			name:   "Android 11 toybox setgid 目录",
+			line:   "45f8 3452 1714979280 u0_a234 media_rw /storage/emulated/0/Download",
+			prefix: "/storage/emulated/0/",
+			want:   FileInfo{Name: "Download", Permissions: "drwxrws---", Owner: "u0_a234", Group: "media_rw", Size: 3452, ModTime: time.Unix(1714979280, 0), Mode: os.ModeDir | os.ModeSetgid | 0770, IsDir: true},
+		},
+		{
+			name:   "Android 15 toybox 文件名含空格",
+			line:   "81b0 2048576 1727740800 u0_a234 media_rw /storage/emulated/0/DCIM/IMG_20240506 (1).jpg",
+			prefix: "/storage/emulated/0/DCIM/",
+			want:   FileInfo{Name: "IMG_20240506 (1).jpg", Permissions: "-rw-rw----", Owner: "u0_a234", Group: "media_rw", Size: 2048576, ModTime: time.Unix(1727740800, 0), Mode: 0660},
+		},
+		{
+			name:   "toybox 通配符未匹配时的 .",
+			line:   "41ed 4096 1704163200 root root /data/local/tmp/.",
+			prefix: "/data/local/tmp/",
+			want:   FileInfo{Name: ".", Permissions: "drwxr-xr-x", Owner: "root", Group: "root", Size: 4096, ModTime: time.Unix(1704163200, 0), Mode: os.ModeDir | 0755, IsDir: true},
+		},
+		{
+			name:   "busybox 字符设备",
+			line:   "21b6 0 1704067200 root root '/dev/null'",
+			prefix: "/dev/",
+			want:   FileInfo{Name: "null", Permissions: "crw-rw-rw-", Owner: "root", Group: "root", ModTime: time.Unix(1704067200, 0), Mode: os.ModeDevice | os.ModeCharDevice | 0666},
+		},
+		{
+			name:   "busybox 粘滞位",
+			line:   "43ff 160 1704067200 root root '/tmp'",
+			prefix: "/",
+			want:   FileInfo{Name: "tmp", Permissions: "drwxrwxrwt", Owner: "root", Group: "root", Size: 160, ModTime: time.Unix(1704067200, 0), Mode: os.ModeDir | os.ModeSticky | 0777, IsDir: true},
+		},
+		{
+			name:   "GNU 文件名含单引号",
+			line:   `81a4 0 1704163200 pi pi '/home/pi/it'\''s.txt'`,
+			prefix: "/home/pi/",
+			want:   FileInfo{Name: "it's.txt", Permissions: "-rw-r--r--", Owner: "pi", Group: "pi", ModTime: time.Unix(1704163200, 0), Mode: 0644},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseStatLine(tt.line, tt.prefix)
+			if !ok {
+				t.Fatalf("parseStatLine(%q) 无法解析", tt.line)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseStatLine(%q)\n got  %+v\n want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStatLineRejects(t *testing.T) {
+	for _, line := range []string{
+		"",
+		"stat: cannot stat '/sdcard/*': No such file or directory",
+		"stat: '/sdcard/*': No such file or directory",
+		"/system/bin/sh: stat: not found",
+		"41f9 3488 1704163200 root sdcard_rw",
+		"zzzz 3488 1704163200 root root /x",
+		"41f9 -- 1704163200 root root /x",
+	} {
+		if file, ok := parseStatLine(line, "/"); ok {
+			t.Errorf("parseStatLine(%q) = %+v, want 无法解析", line, file)
+		}
+	}
+}
+
+func TestParseLsDate(t *testing.T) {
+	now := localTime(2024, time.June, 15, 12, 0, 0)
+	tests := []struct {
+		date string
+		ok   bool
+		want time.Time
+		step time.Duration
+	}{
+		{"2024-01-02 12:34", true, localTime(2024, time.January, 2, 12, 34, 0), time.Minute},
+		{"2024-01-02  9:05", true, localTime(2024, time.January, 2, 9, 5, 0), time.Minute},
+		{"2024-01-02 12:34:56", true, localTime(2024, time.January, 2, 12, 34, 56), 0},
+		{"2024-01-02 12:34:56.500000000", true, time.Date(2024, time.January, 2, 12, 34, 56, 500000000, time.Local), 0},
+		{"2024-01-02 12:34:56.000000000 +0000", true, time.Date(2024, time.January, 2, 12, 34, 56, 0, time.UTC), 0},
+		{"Jan  2  2023", true, localTime(2023, time.January, 2, 0, 0, 0), 24 * time.Hour},
+		{"Jan  2 12:34", true, localTime(2024, time.January, 2, 12, 34, 0), time.Minute},
+		{"Jun 15 23:00", true, localTime(2024, time.June, 15, 23, 0, 0), time.Minute},
+		// 超过当前时间一天以上，属于去年
+		{"Jun 17 08:00", true, localTime(2023, time.June, 17, 8, 0, 0), time.Minute},
+		{"Dec 31 23:59", true, localTime(2023, time.December, 31, 23, 59, 0), time.Minute},
+		{"", false, time.Time{}, 0},
+		{"yesterday", false, time.Time{}, 0},
+		{"2024-02-30 12:00", false, time.Time{}, 0},
+		{"Foo  2 12:34", false, time.Time{}, 0},
+	}
+
+	for _, tt := range tests {
+		got, step, ok := parseLsDate(tt.date, now)
+		if ok != tt.ok {
+			t.Errorf("parseLsDate(%q) ok = %v, want %v", tt.date, ok, tt.ok)
+			continue
+		}
+		if ok && (!got.Equal(tt.want) || step != tt.step) {
+			t.Errorf("parseLsDate(%q) = %v, %v, want %v, %v", tt.date, got, step, tt.want, tt.step)
+		}
+	}
+}
@@ -0,0 +1,483 @@
+package adb
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MirrorDirection 目录镜像的方向
+type MirrorDirection int
+
+const (
+	MirrorPush MirrorDirection = iota // 本地目录 -> 设备目录
+	MirrorPull                        // 设备目录 -> 本地目录
+)
+
+func (d MirrorDirection) String() string {
+	if d == MirrorPull {
+		return "设备 -> 本地"
+	}
+	return "本地 -> 设备"
+}
+
+// MirrorOptions 目录镜像选项
+type MirrorOptions struct {
+	Direction MirrorDirection
+	LocalDir  string
+	RemoteDir string
+	Checksum  bool     // 大小相同时比较 md5（设备端使用 md5sum），不再依据修改时间判断
+	Delete    bool     // 删除目标端多出来的文件与目录
+	Exclude   []string // 按名称排除的文件或目录，支持通配符，例如 ".git"、"*.tmp"
+}
+
+// MirrorActionType 镜像计划中的操作类型
+type MirrorActionType int
+
+const (
+	MirrorMkdir  MirrorActionType = iota // 在目标端创建目录
+	MirrorCopy                           // 目标端不存在的文件
+	MirrorUpdate                         // 两端内容不同的文件
+	MirrorDelete                         // 删除目标端多余的文件或目录
+)
+
+func (t MirrorActionType) String() string {
+	switch t {
+	case MirrorMkdir:
+		return "创建目录"
+	case MirrorCopy:
+		return "新增"
+	case MirrorUpdate:
+		return "更新"
+	case MirrorDelete:
+		return "删除"
+	}
+	return fmt.Sprintf("MirrorActionType(%d)", int(t))
+}
+
+// MirrorAction 镜像计划中的一项操作
+type MirrorAction struct {
+	Type    MirrorActionType
+	Path    string    // 相对于镜像根目录的路径，以 "/" 分隔
+	Size    int64     // 需要传输的字节数
+	ModTime time.Time // 源文件的修改时间
+	Reason  string    // 需要更新的原因，例如 "大小不同"
+}
+
+// MirrorPlan 镜像计划，Apply 前可先展示给用户确认（dry-run）
+type MirrorPlan struct {
+	Options   MirrorOptions
+	Actions   []MirrorAction
+	Unchanged int      // 内容相同、无需传输的文件数
+	Conflicts []string // 两端类型不同（一端是文件、另一端是目录）而跳过的路径
+}
+
+// TransferBytes 需要传输的总字节数
+func (p *MirrorPlan) TransferBytes() int64 {
+	var total int64
+	for _, action := range p.Actions {
+		if action.Type == MirrorCopy || action.Type == MirrorUpdate {
+			total += action.Size
+		}
+	}
+	return total
+}
+
+// Count 指定类型的操作数量
+func (p *MirrorPlan) Count(actionType MirrorActionType) int {
+	count := 0
+	for _, action := range p.Actions {
+		if action.Type == actionType {
+			count++
+		}
+	}
+	return count
+}
+
+// Summary 计划摘要，例如 "新增 3，更新 1，删除 0，创建目录 2，未变化 120"
+func (p *MirrorPlan) Summary() string {
+	text := fmt.Sprintf("新增 %d，更新 %d，删除 %d，创建目录 %d，未变化 %d",
+		p.Count(MirrorCopy), p.Count(MirrorUpdate), p.Count(MirrorDelete), p.Count(MirrorMkdir), p.Unchanged)
+	if len(p.Conflicts) > 0 {
+		text += fmt.Sprintf("，类型冲突 %d", len(p.Conflicts))
+	}
+	return text
+}
+
+// MirrorProgress 镜像执行进度
+type MirrorProgress struct {
+	Action     MirrorAction
+	Completed  int   // 已完成的操作数
+	Total      int   // 操作总数
+	BytesDone  int64 // 已传输的字节数，包含当前文件已传输的部分
+	BytesTotal int64
+	File       TransferProgress // 当前文件的传输进度
+}
+
+// MirrorProgressFunc 镜像进度回调，在执行所在的协程中调用
+type MirrorProgressFunc func(progress MirrorProgress)
+
+// mirrorTimeWindow 修改时间的容差，FAT/exFAT 存储卡的时间精度为 2 秒
+const mirrorTimeWindow = 2 * time.Second
+
+// mirrorChecksumBatch 每条 md5sum 命令最多计算的文件数，避免命令行过长
+const mirrorChecksumBatch = 50
+
+// mirrorEntry 扫描得到的文件或目录
+type mirrorEntry struct {
+	size    int64
+	modTime time.Time
+	step    time.Duration // modTime 的精度，见 FileInfo.ModTimeStep
+	isDir   bool
+}
+
+// PlanMirror 对比两端目录并生成镜像计划，不做任何修改
+func (m *ADBManager) PlanMirror(serial string, opts MirrorOptions) (*MirrorPlan, error) {
+	return m.PlanMirrorContext(context.Background(), serial, opts)
+}
+
+// PlanMirrorContext 可通过 ctx 取消的 PlanMirror
+func (m *ADBManager) PlanMirrorContext(ctx context.Context, serial string, opts MirrorOptions) (*MirrorPlan, error) {
+	if opts.LocalDir == "" || opts.RemoteDir == "" {
+		return nil, fmt.Errorf("本地目录与设备目录均不能为空")
+	}
+	opts.RemoteDir = path.Clean(opts.RemoteDir)
+	if opts.RemoteDir == "/" {
+		return nil, fmt.Errorf("拒绝镜像设备根目录")
+	}
+
+	local, err := scanLocalDir(opts.LocalDir, opts.Exclude, opts.Direction == MirrorPush)
+	if err != nil {
+		return nil, fmt.Errorf("扫描本地目录失败: %w", err)
+	}
+	remote, err := m.scanRemoteDir(ctx, serial, opts.RemoteDir, opts.Exclude, opts.Direction == MirrorPull)
+	if err != nil {
+		return nil, fmt.Errorf("扫描设备目录失败: %w", err)
+	}
+
+	src, dst := local, remote
+	if opts.Direction == MirrorPull {
+		src, dst = remote, local
+	}
+
+	plan := &MirrorPlan{Options: opts}
+	candidates := make([]string, 0) // 大小相同、需要比较 md5 的文件
+	for _, rel := range sortedKeys(src) {
+		s := src[rel]
+		d, exists := dst[rel]
+		switch {
+		case exists && s.isDir != d.isDir:
+			plan.Conflicts = append(plan.Conflicts, rel)
+		case s.isDir:
+			if !exists {
+				plan.Actions = append(plan.Actions, MirrorAction{Type: MirrorMkdir, Path: rel})
+			}
+		case !exists:
+			plan.Actions = append(plan.Actions, MirrorAction{Type: MirrorCopy, Path: rel, Size: s.size, ModTime: s.modTime})
+		case s.size != d.size:
+			plan.Actions = append(plan.Actions, MirrorAction{Type: MirrorUpdate, Path: rel, Size: s.size, ModTime: s.modTime, Reason: "大小不同"})
+		case opts.Checksum:
+			candidates = append(candidates, rel)
+		case !sameModTime(s.modTime, d.modTime, max(s.step, d.step)):
+			plan.Actions = append(plan.Actions, MirrorAction{Type: MirrorUpdate, Path: rel, Size: s.size, ModTime: s.modTime, Reason: "修改时间不同"})
+		default:
+			plan.Unchanged++
+		}
+	}
+
+	if len(candidates) > 0 {
+		localSums := make(map[string]string)
+		for _, rel := range candidates {
+			if sum, err := localChecksum(filepath.Join(opts.LocalDir, filepath.FromSlash(rel))); err == nil {
+				localSums[rel] = sum
+			}
+		}
+		remoteSums, err := m.remoteChecksums(ctx, serial, opts.RemoteDir, candidates)
+		if err != nil {
+			return nil, fmt.Errorf("计算设备端 md5 失败: %w", err)
+		}
+		for _, rel := range candidates {
+			if localSums[rel] != "" && localSums[rel] == remoteSums[rel] {
+				plan.Unchanged++
+				continue
+			}
+			s := src[rel]
+			plan.Actions = append(plan.Actions, MirrorAction{Type: MirrorUpdate, Path: rel, Size: s.size, ModTime: s.modTime, Reason: "md5 不同"})
+		}
+	}
+
+	if opts.Delete {
+		for _, rel := range sortedKeys(dst) {
+			if _, exists := src[rel]; exists || hasDeletedParent(plan.Actions, rel) {
+				continue
+			}
+			plan.Actions = append(plan.Actions, MirrorAction{Type: MirrorDelete, Path: rel, Size: dst[rel].size})
+		}
+	}
+
+	// 先创建目录，再传输文件，最后删除
+	sort.SliceStable(plan.Actions, func(i, j int) bool {
+		a, b := plan.Actions[i], plan.Actions[j]
+		if a.Type == MirrorUpdate {
+			a.Type = MirrorCopy
+		}
+		if b.Type == MirrorUpdate {
+			b.Type = MirrorCopy
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Path < b.Path
+	})
+	return plan, nil
+}
+
+// ApplyMirror 执行镜像计划，单个文件失败不影响其余文件，返回所有失败的汇总
+func (m *ADBManager) ApplyMirror(serial string, plan *MirrorPlan, progress MirrorProgressFunc) error {
+	return m.ApplyMirrorContext(context.Background(), serial, plan, progress)
+}
+
+// ApplyMirrorContext 可通过 ctx 取消的 ApplyMirror，取消后不再执行剩余的操作
+func (m *ADBManager) ApplyMirrorContext(ctx context.Context, serial string, plan *MirrorPlan, progress MirrorProgressFunc) error {
+	opts := plan.Options
+	state := MirrorProgress{Total: len(plan.Actions), BytesTotal: plan.TransferBytes()}
+	report := func() {
+		if progress != nil {
+			progress(state)
+		}
+	}
+
+	var errs []error
+	for _, action := range plan.Actions {
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			return ctxErr
+		}
+		state.Action = action
+		state.File = TransferProgress{Path: action.Path, Total: action.Size}
+		report()
+
+		localPath := filepath.Join(opts.LocalDir, filepath.FromSlash(action.Path))
+		remotePath := path.Join(opts.RemoteDir, action.Path)
+		bytesBefore := state.BytesDone
+		onFile := func(p TransferProgress) {
+			state.File = p
+			state.BytesDone = bytesBefore + p.Transferred
+			report()
+		}
+
+		var err error
+		switch {
+		case action.Type == MirrorMkdir && opts.Direction == MirrorPush:
+			_, err = m.shellStdout(ctx, serial, ShellJoin("mkdir", "-p", remotePath), false)
+		case action.Type == MirrorMkdir:
+			err = os.MkdirAll(localPath, 0755)
+		case action.Type == MirrorDelete && opts.Direction == MirrorPush:
+			err = m.DeleteFileContext(ctx, serial, remotePath)
+		case action.Type == MirrorDelete:
+			err = os.RemoveAll(localPath)
+		case opts.Direction == MirrorPush:
+			err = m.PushFileWithProgressContext(ctx, serial, localPath, remotePath, onFile)
+		default:
+			if err = os.MkdirAll(filepath.Dir(localPath), 0755); err == nil {
+				err = m.PullFileWithProgressContext(ctx, serial, remotePath, localPath, onFile)
+			}
+			if err == nil {
+				// adb pull 回退时不保留修改时间，按扫描时的时间补上，避免下次又被判为需要更新
+				os.Chtimes(localPath, action.ModTime, action.ModTime)
+			}
+		}
+
+		if err != nil {
+			if ctxErr := contextError(ctx); ctxErr != nil {
+				return ctxErr
+			}
+			m.log.Warn("镜像失败", "device", serial, "action", action.Type, "path", action.Path, "error", err)
+			errs = append(errs, fmt.Errorf("%s %s: %w", action.Type, action.Path, err))
+		}
+		state.BytesDone = bytesBefore + action.Size
+		state.Completed++
+	}
+	state.File.Done = true
+	report()
+	return errors.Join(errs...)
+}
+
+// scanLocalDir 递归扫描本地目录，返回以 "/" 分隔的相对路径；目录不存在且 mustExist 为 false 时返回空结果
+// 符号链接与特殊文件被忽略，.adbpart 临时文件也不参与对比
+func scanLocalDir(root string, exclude []string, mustExist bool) (map[string]mirrorEntry, error) {
+	entries := make(map[string]mirrorEntry)
+	info, err := os.Stat(root)
+	if errors.Is(err, os.ErrNotExist) && !mustExist {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s 不是目录", root)
+	}
+
+	err = filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		if isExcluded(d.Name(), exclude) || strings.HasSuffix(d.Name(), partSuffix) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		entries[filepath.ToSlash(rel)] = mirrorEntry{size: info.Size(), modTime: info.ModTime(), isDir: d.IsDir()}
+		return nil
+	})
+	return entries, err
+}
+
+// scanRemoteDir 递归扫描设备目录，符号链接与设备节点等特殊文件被忽略
+// 目录不存在且 mustExist 为 false 时返回空结果
+func (m *ADBManager) scanRemoteDir(ctx context.Context, serial, root string, exclude []string, mustExist bool) (map[string]mirrorEntry, error) {
+	entries := make(map[string]mirrorEntry)
+	result, err := m.ExecuteArgsContext(ctx, serial, "test", "-d", root)
+	if err != nil {
+		return nil, err
+	}
+	if result.ExitCode != 0 {
+		if mustExist {
+			return nil, fmt.Errorf("%s 不存在或不是目录", root)
+		}
+		return entries, nil
+	}
+
+	pending := []string{""}
+	for len(pending) > 0 {
+		dir := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		files, err := m.ListFilesContext(ctx, serial, path.Join(root, dir))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if isExcluded(file.Name, exclude) || file.IsSymlink || (!file.IsDir && !file.Mode.IsRegular()) {
+				continue
+			}
+			rel := path.Join(dir, file.Name)
+			entries[rel] = mirrorEntry{size: file.Size, modTime: file.ModTime, step: file.ModTimeStep, isDir: file.IsDir}
+			if file.IsDir {
+				pending = append(pending, rel)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// remoteChecksums 通过设备上的 md5sum 计算文件的 md5，返回相对路径到 md5 的映射
+// 无法读取的文件不会出现在结果中
+func (m *ADBManager) remoteChecksums(ctx context.Context, serial, root string, rels []string) (map[string]string, error) {
+	if caps := m.knownCapabilities(ctx, serial); caps != nil && len(rels) > 0 && !caps.Has("md5sum") {
+		return nil, fmt.Errorf("设备上没有 md5sum 命令，无法比较 md5")
+	}
+
+	sums := make(map[string]string)
+	for start := 0; start < len(rels); start += mirrorChecksumBatch {
+		batch := rels[start:min(start+mirrorChecksumBatch, len(rels))]
+		argv := []string{"md5sum"}
+		byPath := make(map[string]string, len(batch))
+		for _, rel := range batch {
+			remotePath := path.Join(root, rel)
+			argv = append(argv, remotePath)
+			byPath[remotePath] = rel
+		}
+
+		output, err := m.shellStdout(ctx, serial, ShellJoin(argv...), true)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(output, "\n") {
+			sum, remotePath, ok := strings.Cut(strings.TrimRight(line, "\r"), " ")
+			if !ok {
+				continue
+			}
+			if rel, found := byPath[strings.TrimLeft(remotePath, " *")]; found {
+				sums[rel] = strings.ToLower(sum)
+			}
+		}
+	}
+	return sums, nil
+}
+
+// localChecksum 计算本地文件的 md5
+func localChecksum(name string) (string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// isExcluded 名称是否匹配任一排除规则
+func isExcluded(name string, exclude []string) bool {
+	for _, pattern := range exclude {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// sameModTime 修改时间是否在容差范围内相同
+// step 为其中一方的时间精度，例如设备只能用 ls 列出目录时只精确到分钟，容差相应放宽
+func sameModTime(a, b time.Time, step time.Duration) bool {
+	window := mirrorTimeWindow + step
+	diff := a.Sub(b)
+	return diff > -window && diff < window
+}
+
+// hasDeletedParent 上级目录是否已在删除列表中，目录整体删除后无需再逐个删除其中的文件
+func hasDeletedParent(actions []MirrorAction, rel string) bool {
+	for _, action := range actions {
+		if action.Type == MirrorDelete && strings.HasPrefix(rel, action.Path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys 按路径排序的键，保证父目录排在其内容之前
+func sortedKeys(entries map[string]mirrorEntry) []string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,131 @@
+package adb
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const mirrorRemote = "/sdcard/mirror"
+
+// newMirrorTest 创建模拟设备与本地目录，设备上的 mirrorRemote 目录存在
+func newMirrorTest(t *testing.T) (*FakeServer, *ADBManager, string) {
+	t.Helper()
+	srv, err := NewFakeServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	srv.AddDevice("emulator-5554", "device", "")
+	srv.SetShell("emulator-5554", ShellJoin("test", "-d", mirrorRemote), "")
+	return srv, newTestManager(NewServerRunner(srv.Addr(), nil)), t.TempDir()
+}
+
+// writeLocalFile 写入本地文件并设置修改时间
+func writeLocalFile(t *testing.T, dir, rel, content string, modTime time.Time) {
+	t.Helper()
+	name := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(name, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// actionList 将计划中的操作转换为 "类型 路径 原因" 便于比较
+func actionList(plan *MirrorPlan) []string {
+	actions := make([]string, 0, len(plan.Actions))
+	for _, action := range plan.Actions {
+		line := action.Type.String() + " " + action.Path
+		if action.Reason != "" {
+			line += " " + action.Reason
+		}
+		actions = append(actions, line)
+	}
+	return actions
+}
+
+func TestPlanMirrorLsMinutePrecision(t *testing.T) {
+	srv, m, local := newMirrorTest(t)
+	// 设备上没有 stat，只能通过 ls 列出目录，时间只精确到分钟
+	srv.SetShell("emulator-5554", ShellJoin("ls", "-la", mirrorRemote+"/"),
+		"total 16\n"+
+			"drwxrwx--x 2 root sdcard_rw 3488 2024-01-02 12:00 .\n"+
+			"drwxrwx--x 4 root sdcard_rw 3488 2024-01-02 12:00 ..\n"+
+			"-rw-rw---- 1 root sdcard_rw    5 2024-01-02 12:34 same.txt\n"+
+			"-rw-rw---- 1 root sdcard_rw    5 2024-01-02 12:34 changed.txt\n"+
+			"-rw-rw---- 1 root sdcard_rw    3 2024-01-02 12:34 bigger.txt\n"+
+			"-rw-rw---- 1 root sdcard_rw    9 2024-01-02 12:34 old.txt\n")
+
+	writeLocalFile(t, local, "same.txt", "hello", localTime(2024, time.January, 2, 12, 34, 45))
+	writeLocalFile(t, local, "changed.txt", "hello", localTime(2024, time.January, 2, 12, 40, 10))
+	writeLocalFile(t, local, "bigger.txt", "hello", localTime(2024, time.January, 2, 12, 34, 0))
+	writeLocalFile(t, local, "new.txt", "new", localTime(2024, time.January, 2, 12, 34, 0))
+	writeLocalFile(t, local, "sub/a.txt", "a", localTime(2024, time.January, 2, 12, 34, 0))
+
+	plan, err := m.PlanMirror("emulator-5554", MirrorOptions{Direction: MirrorPush, LocalDir: local, RemoteDir: mirrorRemote + "/", Delete: true})
+	if err != nil {
+		t.Fatalf("PlanMirror: %v", err)
+	}
+
+	// 同一分钟内的修改时间视为相同，不再把所有文件都判为需要更新
+	want := []string{
+		"创建目录 sub",
+		"更新 bigger.txt 大小不同",
+		"更新 changed.txt 修改时间不同",
+		"新增 new.txt",
+		"新增 sub/a.txt",
+		"删除 old.txt",
+	}
+	if got := actionList(plan); !reflect.DeepEqual(got, want) {
+		t.Errorf("actions =\n%q\nwant\n%q", got, want)
+	}
+	if plan.Unchanged != 1 {
+		t.Errorf("Unchanged = %d, want 1", plan.Unchanged)
+	}
+	if plan.TransferBytes() != 5+5+3+1 {
+		t.Errorf("TransferBytes = %d, want 14", plan.TransferBytes())
+	}
+}
+
+func TestPlanMirrorStatPrecision(t *testing.T) {
+	srv, m, local := newMirrorTest(t)
+	// stat 精确到秒，仍只容许 2 秒的误差
+	minute := localTime(2024, time.January, 2, 12, 34, 0)
+	quoted := ShellQuote(mirrorRemote)
+	srv.SetShell("emulator-5554", ShellJoin("stat", "-c", statFormat, "--")+" "+quoted+"/.* "+quoted+"/*",
+		"41f9 3488 "+strconv.FormatInt(minute.Unix(), 10)+" root sdcard_rw /sdcard/mirror/.\n"+
+			"81a4 5 "+strconv.FormatInt(minute.Unix(), 10)+" root sdcard_rw /sdcard/mirror/a.txt\n"+
+			"81a4 5 "+strconv.FormatInt(minute.Unix()+1, 10)+" root sdcard_rw /sdcard/mirror/b.txt\n")
+
+	writeLocalFile(t, local, "a.txt", "hello", minute.Add(45*time.Second))
+	writeLocalFile(t, local, "b.txt", "hello", minute)
+
+	plan, err := m.PlanMirror("emulator-5554", MirrorOptions{Direction: MirrorPush, LocalDir: local, RemoteDir: mirrorRemote})
+	if err != nil {
+		t.Fatalf("PlanMirror: %v", err)
+	}
+	want := []string{"更新 a.txt 修改时间不同"}
+	if got := actionList(plan); !reflect.DeepEqual(got, want) || plan.Unchanged != 1 {
+		t.Errorf("actions = %q, unchanged %d, want %q and 1 unchanged", got, plan.Unchanged, want)
+	}
+}
+
+func TestPlanMirrorPullMissingRemote(t *testing.T) {
+	srv, m, local := newMirrorTest(t)
+	srv.SetShellResult("emulator-5554", ShellJoin("test", "-d", mirrorRemote), ShellResult{ExitCode: 1})
+
+	if _, err := m.PlanMirror("emulator-5554", MirrorOptions{Direction: MirrorPull, LocalDir: local, RemoteDir: mirrorRemote}); err == nil {
+		t.Fatal("PlanMirror pulling a missing directory succeeded")
+	}
+	if _, err := m.PlanMirror("emulator-5554", MirrorOptions{Direction: MirrorPush, LocalDir: local, RemoteDir: "/"}); err == nil {
+		t.Fatal("PlanMirror to the device root succeeded")
+	}
+}
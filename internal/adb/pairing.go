@@ -0,0 +1,230 @@
+package adb
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+)
+
+// 无线调试（Android 11+）通过 mDNS 广播的服务类型
+const (
+	MdnsTLSConnect = "_adb-tls-connect._tcp" // 已配对设备的连接端口
+	MdnsTLSPairing = "_adb-tls-pairing._tcp" // 处于配对界面的设备的配对端口
+	MdnsLegacy     = "_adb._tcp"             // 旧版 adb over TCP
+)
+
+// discoverInterval 配对后等待设备广播连接端口、二维码配对等待手机扫码时的查询间隔
+const discoverInterval = time.Second
+
+// connectWait 配对后等待设备广播连接端口的时间
+const connectWait = 30 * time.Second
+
+// qrPairingTimeout WaitQRPairing 等待扫码的时间
+const qrPairingTimeout = 5 * time.Minute
+
+// redactedArg 错误信息与审计日志中代替配对码的文字
+const redactedArg = "<redacted>"
+
+// MdnsService adb server 通过 mDNS 发现的服务
+type MdnsService struct {
+	Name    string // 实例名，例如 adb-R5CR1234567-AbCdEf
+	Type    string // 服务类型，例如 _adb-tls-connect._tcp
+	Address string // IP:PORT
+}
+
+// Host 服务所在设备的 IP
+func (s MdnsService) Host() string {
+	host, _, err := net.SplitHostPort(s.Address)
+	if err != nil {
+		return s.Address
+	}
+	return host
+}
+
+// IsPairing 是否为配对服务
+func (s MdnsService) IsPairing() bool {
+	return s.Type == MdnsTLSPairing
+}
+
+// IsConnect 是否为可以直接 adb connect 的服务
+func (s MdnsService) IsConnect() bool {
+	return s.Type == MdnsTLSConnect || s.Type == MdnsLegacy
+}
+
+// parseMdnsServices 解析 adb mdns services 的输出（实例名、类型、地址以 tab 分隔）
+func parseMdnsServices(output string) []MdnsService {
+	services := make([]MdnsService, 0)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || !strings.HasPrefix(fields[1], "_") {
+			continue // 表头 "List of discovered mdns services"
+		}
+		services = append(services, MdnsService{
+			Name:    fields[0],
+			Type:    strings.TrimSuffix(fields[1], "."),
+			Address: fields[2],
+		})
+	}
+	return services
+}
+
+// Discover 列出 adb server 在本网段通过 mDNS 发现的无线调试服务
+func (m *ADBManager) Discover() ([]MdnsService, error) {
+	return m.DiscoverContext(context.Background())
+}
+
+// DiscoverContext 可通过 ctx 取消的 Discover
+func (m *ADBManager) DiscoverContext(ctx context.Context) ([]MdnsService, error) {
+	output, err := m.run(ctx, "mdns", "services")
+	if err != nil {
+		return nil, fmt.Errorf("查询 mDNS 服务失败: %w", err)
+	}
+	return parseMdnsServices(EncodingAuto.Decode(output)), nil
+}
+
+// Pair 使用六位配对码与设备配对，address 为设备「使用配对码配对设备」界面显示的 IP:PORT
+func (m *ADBManager) Pair(address, code string) error {
+	return m.PairContext(context.Background(), address, code)
+}
+
+// PairContext 可通过 ctx 取消的 Pair
+func (m *ADBManager) PairContext(ctx context.Context, address, code string) error {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return fmt.Errorf("配对码不能为空")
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return fmt.Errorf("配对地址格式错误（应为 IP:PORT）: %s", address)
+	}
+
+	m.log.Info("尝试配对", "address", address)
+	// 配对码（二维码配对时为二维码中的密码）不写入审计日志与错误信息
+	output, err := m.runRedacted(ctx, []string{"pair", address, code}, []string{"pair", address, redactedArg})
+	if err != nil {
+		return fmt.Errorf("配对失败: %w", err)
+	}
+	outputStr := strings.TrimSpace(EncodingAuto.Decode(output))
+	if !strings.Contains(outputStr, "Successfully paired") {
+		return fmt.Errorf("配对失败: %s", outputStr)
+	}
+	m.log.Info("配对成功", "address", address)
+	return nil
+}
+
+// PairAndConnect 配对后等待设备广播连接端口并自动连接，返回连接后的序列号（IP:PORT）
+// 无线调试的连接端口与配对端口不同，且每次开启无线调试都会变化，只能通过 mDNS 得知
+func (m *ADBManager) PairAndConnect(address, code string) (string, error) {
+	return m.PairAndConnectContext(context.Background(), address, code)
+}
+
+// PairAndConnectContext 可通过 ctx 取消的 PairAndConnect
+func (m *ADBManager) PairAndConnectContext(ctx context.Context, address, code string) (string, error) {
+	if err := m.PairContext(ctx, address, code); err != nil {
+		return "", err
+	}
+	host, _, _ := net.SplitHostPort(address)
+	return m.connectDiscovered(ctx, host)
+}
+
+// connectDiscovered 等待 host 广播连接服务后连接
+func (m *ADBManager) connectDiscovered(ctx context.Context, host string) (string, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, connectWait)
+	defer cancel()
+
+	for {
+		services, err := m.DiscoverContext(waitCtx)
+		if err == nil {
+			for _, service := range services {
+				if service.Type == MdnsTLSConnect && service.Host() == host {
+					if err := m.ConnectContext(ctx, service.Address); err != nil {
+						return "", err
+					}
+					return service.Address, nil
+				}
+			}
+		}
+
+		select {
+		case <-waitCtx.Done():
+			if ctxErr := contextError(ctx); ctxErr != nil {
+				return "", ctxErr
+			}
+			return "", fmt.Errorf("已配对，但 %v 内未发现设备的连接端口，请在设备的无线调试页面查看 IP 与端口后手动连接", connectWait)
+		case <-time.After(discoverInterval):
+		}
+	}
+}
+
+// QRPairing 二维码配对信息，二维码内容由 Payload 生成，手机在「使用二维码配对设备」中扫码
+// 扫码后手机以 Name 为实例名广播配对服务，电脑发现后使用 Password 配对
+type QRPairing struct {
+	Name     string
+	Password string
+}
+
+// NewQRPairing 生成随机的服务名与配对密码
+func NewQRPairing() (QRPairing, error) {
+	name, err := randomString(10)
+	if err != nil {
+		return QRPairing{}, err
+	}
+	password, err := randomString(12)
+	if err != nil {
+		return QRPairing{}, err
+	}
+	return QRPairing{Name: "adbmanager-" + name, Password: password}, nil
+}
+
+// Payload 二维码的内容，格式与 Android Studio 相同
+func (q QRPairing) Payload() string {
+	return "WIFI:T:ADB;S:" + q.Name + ";P:" + q.Password + ";;"
+}
+
+// randomString 生成由字母与数字组成的随机字符串
+func randomString(n int) (string, error) {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	b := make([]byte, n)
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", fmt.Errorf("生成配对密码失败: %w", err)
+		}
+		b[i] = alphabet[idx.Int64()]
+	}
+	return string(b), nil
+}
+
+// WaitQRPairing 等待手机扫码后广播的配对服务，配对并自动连接，返回连接后的序列号（IP:PORT）
+// 超过 qrPairingTimeout 仍未扫码时返回超时错误
+func (m *ADBManager) WaitQRPairing(qr QRPairing) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), qrPairingTimeout)
+	defer cancel()
+	return m.WaitQRPairingContext(ctx, qr)
+}
+
+// WaitQRPairingContext 可通过 ctx 取消的 WaitQRPairing，不设超时，用户关闭二维码时取消 ctx
+func (m *ADBManager) WaitQRPairingContext(ctx context.Context, qr QRPairing) (string, error) {
+	for {
+		services, err := m.DiscoverContext(ctx)
+		if err == nil {
+			for _, service := range services {
+				if service.IsPairing() && service.Name == qr.Name {
+					if err := m.PairContext(ctx, service.Address, qr.Password); err != nil {
+						return "", err
+					}
+					return m.connectDiscovered(ctx, service.Host())
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", contextError(ctx)
+		case <-time.After(discoverInterval):
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package adb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPairRedactsCode(t *testing.T) {
+	srv, err := NewFakeServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.SetPairingCode("192.168.1.20:37015", "482913")
+
+	auditor := &auditRecorder{}
+	m := newTestManager(NewServerRunner(srv.Addr(), nil))
+	m.SetAuditor(auditor)
+
+	if err := m.Pair("192.168.1.20:37015", "482913"); err != nil {
+		t.Fatalf("Pair: %v", err)
+	}
+	if err := m.Pair("192.168.1.20:37015", "000000"); err == nil {
+		t.Fatal("Pair with wrong code succeeded")
+	}
+
+	events := auditor.Events()
+	if len(events) != 2 {
+		t.Fatalf("audit events = %d, want 2", len(events))
+	}
+	want := []string{"pair", "192.168.1.20:37015", redactedArg}
+	for _, event := range events {
+		if !reflect.DeepEqual(event.Argv, want) {
+			t.Errorf("audit argv = %q, want %q", event.Argv, want)
+		}
+	}
+}
+
+func TestPairErrorRedactsCode(t *testing.T) {
+	runner := NewScriptedRunner().On("pair 192.168.1.20:37015 482913",
+		ScriptedResponse{Stderr: "error: protocol fault (couldn't read status message): Success\n", ExitCode: 1})
+	auditor := &auditRecorder{}
+	m := newTestManager(runner)
+	m.SetAuditor(auditor)
+
+	err := m.Pair("192.168.1.20:37015", "482913")
+	if err == nil {
+		t.Fatal("Pair succeeded")
+	}
+	if strings.Contains(err.Error(), "482913") {
+		t.Errorf("error contains pairing code: %v", err)
+	}
+
+	events := auditor.Events()
+	if len(events) != 1 {
+		t.Fatalf("audit events = %d, want 1", len(events))
+	}
+	event := events[0]
+	if event.ExitCode != 1 {
+		t.Errorf("audit exit code = %d, want 1", event.ExitCode)
+	}
+	for _, s := range append(event.Argv, event.Error) {
+		if strings.Contains(s, "482913") {
+			t.Errorf("audit event contains pairing code: %+v", event)
+		}
+	}
+}
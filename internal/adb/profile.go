@@ -0,0 +1,169 @@
+package adb
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// WrapperKind 命令包装方式
+type WrapperKind int
+
+const (
+	WrapperNone    WrapperKind = iota // 直接交给设备 shell 执行
+	WrapperBusybox                    // 以 busybox 执行，适合缺少常用命令的物联网设备
+	WrapperToybox                     // 以 toybox 执行，适合 PATH 中没有链接 toybox 命令的精简系统
+	WrapperShell                      // 交给厂商 shell 执行（shell -c 命令）
+)
+
+func (k WrapperKind) String() string {
+	switch k {
+	case WrapperNone:
+		return "无"
+	case WrapperBusybox:
+		return "busybox"
+	case WrapperToybox:
+		return "toybox"
+	case WrapperShell:
+		return "厂商 shell"
+	}
+	return fmt.Sprintf("WrapperKind(%d)", int(k))
+}
+
+// WrapperProfile 设备的命令包装配置，同时记录设备输出的字符编码
+type WrapperProfile struct {
+	Kind         WrapperKind `json:"kind"`
+	Path         string      `json:"path,omitempty"`          // busybox / toybox / shell 的路径，busybox 与 toybox 为空时使用 PATH 中的程序
+	AutoDetected bool        `json:"auto_detected,omitempty"` // 由 DetectWrapperProfile 检测得到，而不是用户指定
+	Encoding     Encoding    `json:"encoding,omitempty"`      // 命令输出的编码，默认自动识别
+}
+
+func (p WrapperProfile) String() string {
+	s := p.Kind.String()
+	if p.Kind != WrapperNone && p.Path != "" {
+		s += " (" + p.Path + ")"
+	}
+	if p.Encoding != EncodingAuto {
+		s += "，编码 " + p.Encoding.String()
+	}
+	return s
+}
+
+// program 包装所用的程序，busybox / toybox 未指定路径时使用 PATH 中的同名程序
+func (p WrapperProfile) program() string {
+	if p.Path != "" {
+		return p.Path
+	}
+	switch p.Kind {
+	case WrapperBusybox:
+		return "busybox"
+	case WrapperToybox:
+		return "toybox"
+	}
+	return ""
+}
+
+// Wrap 按配置包装 shell 命令
+// busybox / toybox 加在命令前（已有相同前缀时不重复添加），厂商 shell 以 "shell -c 命令" 的形式执行整条命令
+func (p WrapperProfile) Wrap(command string) string {
+	program := p.program()
+	switch {
+	case program == "":
+		return command
+	case p.Kind == WrapperShell:
+		return ShellJoin(program, "-c", command)
+	}
+
+	first, _, _ := strings.Cut(strings.TrimSpace(command), " ")
+	if first == program || first == path.Base(program) {
+		return command
+	}
+	return ShellQuote(program) + " " + command
+}
+
+// wrapperProbe 检测命令包装方式的脚本：列出缺少的常用命令，以及可用的 busybox、toybox
+const wrapperProbe = `for c in ls cat grep ps stat; do command -v $c >/dev/null 2>&1 || echo "missing $c"; done
+for p in busybox toybox; do f=$(command -v $p 2>/dev/null) && echo "$p $f"; done
+for f in /data/local/tmp/busybox /system/xbin/busybox /system/bin/busybox /sbin/busybox /bin/busybox; do [ -x $f ] && echo "busybox $f"; done
+true`
+
+// WrapperProfile 返回设备当前使用的命令包装配置，未设置时为 WrapperNone
+func (m *ADBManager) WrapperProfile(serial string) WrapperProfile {
+	m.profileLock.RLock()
+	defer m.profileLock.RUnlock()
+	return m.profiles[serial]
+}
+
+// SetWrapperProfile 设置设备的命令包装配置，只影响该设备
+func (m *ADBManager) SetWrapperProfile(serial string, profile WrapperProfile) {
+	m.profileLock.Lock()
+	defer m.profileLock.Unlock()
+
+	if profile.Kind == WrapperNone && profile.Encoding == EncodingAuto {
+		delete(m.profiles, serial)
+	} else {
+		m.profiles[serial] = profile
+	}
+	m.log.Info("命令包装", "device", serial, "profile", profile)
+}
+
+// wrapCommand 按设备的配置包装命令
+func (m *ADBManager) wrapCommand(serial, command string) string {
+	return m.WrapperProfile(serial).Wrap(command)
+}
+
+// outputEncoding 设备输出使用的编码
+func (m *ADBManager) outputEncoding(serial string) Encoding {
+	return m.WrapperProfile(serial).Encoding
+}
+
+// DetectWrapperProfile 检测设备需要的命令包装方式
+// 常用命令齐全时不包装；缺少命令时优先使用 busybox，其次 toybox；厂商 shell 无法自动识别，需要手动指定
+// 输出编码无法可靠检测，保留设备当前的设置
+func (m *ADBManager) DetectWrapperProfile(serial string) (WrapperProfile, error) {
+	return m.DetectWrapperProfileContext(context.Background(), serial)
+}
+
+// DetectWrapperProfileContext 可通过 ctx 取消的 DetectWrapperProfile
+func (m *ADBManager) DetectWrapperProfileContext(ctx context.Context, serial string) (WrapperProfile, error) {
+	// 检测脚本本身不能被包装
+	result, err := m.executeShell(ctx, serial, wrapperProbe)
+	if err != nil {
+		return WrapperProfile{}, fmt.Errorf("检测命令包装方式失败: %w", err)
+	}
+	profile := parseWrapperProbe(result.Stdout)
+	profile.Encoding = m.outputEncoding(serial)
+	return profile, nil
+}
+
+// parseWrapperProbe 根据检测脚本的输出选择包装方式
+func parseWrapperProbe(output string) WrapperProfile {
+	missing := false
+	found := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		kind, value, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		switch kind {
+		case "missing":
+			missing = true
+		case "busybox", "toybox":
+			if _, exists := found[kind]; !exists && strings.HasPrefix(value, "/") {
+				found[kind] = value
+			}
+		}
+	}
+
+	profile := WrapperProfile{Kind: WrapperNone, AutoDetected: true}
+	if !missing {
+		return profile
+	}
+	if busybox, ok := found["busybox"]; ok {
+		profile.Kind, profile.Path = WrapperBusybox, busybox
+	} else if toybox, ok := found["toybox"]; ok {
+		profile.Kind, profile.Path = WrapperToybox, toybox
+	}
+	return profile
+}
@@ -0,0 +1,47 @@
+package adb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWrapperProfileWrap(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile WrapperProfile
+		command string
+		want    string
+	}{
+		{"none", WrapperProfile{}, "ls -l /sdcard", "ls -l /sdcard"},
+		{"busybox in PATH", WrapperProfile{Kind: WrapperBusybox}, "ls -l /sdcard", "busybox ls -l /sdcard"},
+		{"busybox path", WrapperProfile{Kind: WrapperBusybox, Path: "/data/local/tmp/busybox"}, "ps", "/data/local/tmp/busybox ps"},
+		{"busybox path with space", WrapperProfile{Kind: WrapperBusybox, Path: "/data/local/tmp/my tools/busybox"}, "ps", "'/data/local/tmp/my tools/busybox' ps"},
+		{"busybox already prefixed", WrapperProfile{Kind: WrapperBusybox}, "busybox ls", "busybox ls"},
+		{"busybox prefixed by path", WrapperProfile{Kind: WrapperBusybox, Path: "/system/xbin/busybox"}, "busybox ls", "busybox ls"},
+		{"busybox full path prefixed", WrapperProfile{Kind: WrapperBusybox, Path: "/system/xbin/busybox"}, "/system/xbin/busybox ls", "/system/xbin/busybox ls"},
+		{"toybox", WrapperProfile{Kind: WrapperToybox}, "stat -c %s /x", "toybox stat -c %s /x"},
+		{"vendor shell", WrapperProfile{Kind: WrapperShell, Path: "/vendor/bin/vsh"}, "ls -l 'a b'", `/vendor/bin/vsh -c 'ls -l '\''a b'\'''`},
+		{"argv command", WrapperProfile{Kind: WrapperBusybox}, ShellJoin("rm", "-rf", "--", "/sdcard/it's"), `busybox rm -rf -- '/sdcard/it'\''s'`},
+	}
+	for _, tt := range tests {
+		if got := tt.profile.Wrap(tt.command); got != tt.want {
+			t.Errorf("%s: Wrap(%q) = %s, want %s", tt.name, tt.command, got, tt.want)
+		}
+	}
+}
+
+// 包装后的命令在 shell 中展开，参数与原命令一致
+func TestWrapperProfileWrapNested(t *testing.T) {
+	sh := shellPath(t)
+	// 用 shell 函数模拟 busybox 与厂商 shell
+	const fakeTools = `busybox() { "$@"; }; vsh() { sh "$@"; }; `
+
+	argv := []string{"it's", "$HOME", "a b", "*"}
+	command := ShellJoin(append([]string{"printf", `%s\0`}, argv...)...)
+	for _, profile := range []WrapperProfile{{Kind: WrapperBusybox}, {Kind: WrapperShell, Path: "vsh"}} {
+		got := shellArgs(t, sh, fakeTools+profile.Wrap(command))
+		if !reflect.DeepEqual(got, argv) {
+			t.Errorf("%v: got %q, want %q", profile, got, argv)
+		}
+	}
+}
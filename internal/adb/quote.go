@@ -0,0 +1,37 @@
+package adb
+
+import "strings"
+
+// ShellQuote 为设备上的 sh 转义单个参数，结果在 shell 中展开后与原参数完全一致
+// 仅含安全字符的参数原样返回，其余用单引号包裹；参数中的单引号先闭合引号、转义后再重新开始引号
+func ShellQuote(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	if isShellSafe(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// ShellJoin 将参数列表转义后拼接为一条 shell 命令
+func ShellJoin(argv ...string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		quoted[i] = ShellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// isShellSafe 参数是否只包含不需要转义的字符
+func isShellSafe(arg string) bool {
+	for _, r := range arg {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("@%+=:,./-_", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+package adb
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// shellPath 返回本机的 sh，找不到时跳过测试
+func shellPath(t testing.TB) string {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("找不到 sh")
+	}
+	return sh
+}
+
+// shellArgs 在本机 sh 中执行 command，返回其中 printf '%s\0' 输出的各个参数
+func shellArgs(t testing.TB, sh, command string) []string {
+	t.Helper()
+	out, err := exec.Command(sh, "-c", command).Output()
+	if err != nil {
+		t.Fatalf("sh -c %q: %v", command, err)
+	}
+	args := strings.Split(string(out), "\x00")
+	return args[:len(args)-1]
+}
+
+var quoteSeeds = []string{
+	"", "plain", "with space", "it's", `"double"`, "$HOME", "$(id)", "`id`", "a;b", "a&&b", "a|b",
+	"*", "?", "[a]", "~", "#comment", "new\nline", "tab\there", `back\slash`, "'", "''", "-rf", "--",
+	"中文 路径", "\xff\xfe", "a'b'c", `'\''`, "!", "{a,b}", "a=b", "@%+=:,./-_",
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want string
+	}{
+		{"", "''"},
+		{"/sdcard/DCIM", "/sdcard/DCIM"},
+		{"a b", "'a b'"},
+		{"it's", `'it'\''s'`},
+		{"$HOME", "'$HOME'"},
+		{"key=value,x:1", "key=value,x:1"},
+	}
+	for _, tt := range tests {
+		if got := ShellQuote(tt.arg); got != tt.want {
+			t.Errorf("ShellQuote(%q) = %s, want %s", tt.arg, got, tt.want)
+		}
+	}
+}
+
+func FuzzShellQuote(f *testing.F) {
+	for _, seed := range quoteSeeds {
+		f.Add(seed)
+	}
+	sh := shellPath(f)
+	f.Fuzz(func(t *testing.T, arg string) {
+		if strings.ContainsRune(arg, 0) {
+			t.Skip("命令行参数不能包含 NUL")
+		}
+		out, err := exec.Command(sh, "-c", "printf %s "+ShellQuote(arg)).Output()
+		if err != nil {
+			t.Fatalf("ShellQuote(%q) = %s: %v", arg, ShellQuote(arg), err)
+		}
+		if string(out) != arg {
+			t.Fatalf("ShellQuote(%q) = %s, sh expanded to %q", arg, ShellQuote(arg), out)
+		}
+	})
+}
+
+func FuzzShellJoin(f *testing.F) {
+	for i, seed := range quoteSeeds {
+		f.Add(seed, quoteSeeds[(i+1)%len(quoteSeeds)], quoteSeeds[(i+7)%len(quoteSeeds)])
+	}
+	sh := shellPath(f)
+	f.Fuzz(func(t *testing.T, a, b, c string) {
+		argv := []string{a, b, c}
+		for _, arg := range argv {
+			if strings.ContainsRune(arg, 0) {
+				t.Skip("命令行参数不能包含 NUL")
+			}
+		}
+		got := shellArgs(t, sh, `printf '%s\0' `+ShellJoin(argv...))
+		if !reflect.DeepEqual(got, argv) {
+			t.Fatalf("ShellJoin(%q) = %s, sh expanded to %q", argv, ShellJoin(argv...), got)
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package adb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNoRoot 设备上没有可用的 root 方式
+var ErrNoRoot = errors.New("设备没有可用的 root 权限")
+
+// rootProbeTimeout 单个 root 方式的探测超时，Magisk 首次授权时会在设备上弹出确认框
+const rootProbeTimeout = 10 * time.Second
+
+// RootMethod 在设备上以 root 身份执行命令的方式
+type RootMethod int
+
+const (
+	RootUnknown RootMethod = iota // 尚未探测
+	RootNone                      // 没有可用的 root 方式
+	RootAdbd                      // adbd 以 root 运行（adb root、eng/userdebug 版本），命令直接执行
+	RootMagisk                    // su -c 命令（Magisk、SuperSU 等）
+	RootSu0                       // su 0 命令（AOSP userdebug 自带的 su）
+	RootSuRoot                    // su root 命令（部分定制系统）
+)
+
+// rootProbeOrder 探测顺序：先检查 adbd 本身是否为 root，不需要 su
+var rootProbeOrder = []RootMethod{RootAdbd, RootMagisk, RootSu0, RootSuRoot}
+
+func (r RootMethod) String() string {
+	switch r {
+	case RootUnknown:
+		return "未检测"
+	case RootNone:
+		return "无 root"
+	case RootAdbd:
+		return "adbd root"
+	case RootMagisk:
+		return "su -c"
+	case RootSu0:
+		return "su 0"
+	case RootSuRoot:
+		return "su root"
+	}
+	return fmt.Sprintf("RootMethod(%d)", int(r))
+}
+
+// HasRoot 是否可以以 root 身份执行命令
+func (r RootMethod) HasRoot() bool {
+	return r != RootUnknown && r != RootNone
+}
+
+// Command 构造以该方式执行 command 的 shell 命令，command 作为单个参数整体转义
+func (r RootMethod) Command(command string) string {
+	switch r {
+	case RootMagisk:
+		return "su -c " + ShellQuote(command)
+	case RootSu0:
+		return "su 0 sh -c " + ShellQuote(command)
+	case RootSuRoot:
+		return "su root sh -c " + ShellQuote(command)
+	}
+	return command
+}
+
+// CachedRootMethod 返回设备缓存的 root 方式，不会在设备上执行任何命令，未探测过时为 RootUnknown
+func (m *ADBManager) CachedRootMethod(serial string) RootMethod {
+	m.deviceCacheLock.RLock()
+	defer m.deviceCacheLock.RUnlock()
+	if dev, exists := m.managedDevices[serial]; exists {
+		return dev.Root
+	}
+	return RootUnknown
+}
+
+// setRootMethod 将探测结果保存在设备缓存中
+func (m *ADBManager) setRootMethod(serial string, method RootMethod) {
+	m.deviceCacheLock.Lock()
+	defer m.deviceCacheLock.Unlock()
+	if dev, exists := m.managedDevices[serial]; exists {
+		dev.Root = method
+	}
+}
+
+// DetectRootMethod 返回设备的 root 方式，未探测过时先探测，结果缓存在设备上直到设备重新连接
+func (m *ADBManager) DetectRootMethod(serial string) (RootMethod, error) {
+	return m.DetectRootMethodContext(context.Background(), serial)
+}
+
+// DetectRootMethodContext 可通过 ctx 取消的 DetectRootMethod
+func (m *ADBManager) DetectRootMethodContext(ctx context.Context, serial string) (RootMethod, error) {
+	if method := m.CachedRootMethod(serial); method != RootUnknown {
+		return method, nil
+	}
+	return m.ProbeRootMethodContext(ctx, serial)
+}
+
+// ProbeRootMethod 忽略缓存重新探测设备的 root 方式
+// 依次尝试 adbd root、su -c、su 0、su root，只执行 id，不会调用 adb root 或重启 adbd
+func (m *ADBManager) ProbeRootMethod(serial string) (RootMethod, error) {
+	return m.ProbeRootMethodContext(context.Background(), serial)
+}
+
+// ProbeRootMethodContext 可通过 ctx 取消的 ProbeRootMethod
+func (m *ADBManager) ProbeRootMethodContext(ctx context.Context, serial string) (RootMethod, error) {
+	for _, method := range rootProbeOrder {
+		ok, err := m.probeRoot(ctx, serial, method)
+		if err != nil {
+			return RootUnknown, fmt.Errorf("探测 root 方式失败: %w", err)
+		}
+		if ok {
+			m.log.Info("root 方式", "device", serial, "method", method)
+			m.setRootMethod(serial, method)
+			return method, nil
+		}
+	}
+
+	m.log.Info("root 方式", "device", serial, "method", RootNone)
+	m.setRootMethod(serial, RootNone)
+	return RootNone, nil
+}
+
+// probeRoot 以指定方式执行 id，输出 uid=0 时可用
+// 只有 adb 本身失败（设备离线、未授权）才返回错误；su 不存在、被拒绝或等待授权超时都视为不可用
+func (m *ADBManager) probeRoot(ctx context.Context, serial string, method RootMethod) (bool, error) {
+	probeCtx, cancel := context.WithTimeout(ctx, rootProbeTimeout)
+	defer cancel()
+
+	result, err := m.executeShell(probeCtx, serial, method.Command("id"))
+	if err != nil {
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			return false, ctxErr
+		}
+		if errors.Is(err, ErrTimeout) {
+			return false, nil
+		}
+		return false, err
+	}
+	return result.ExitCode == 0 && strings.Contains(result.Stdout, "uid=0"), nil
+}
+
+// rootCommand 按设备的 root 方式构造命令，没有 root 时返回 ErrNoRoot
+func (m *ADBManager) rootCommand(ctx context.Context, serial, command string) (string, error) {
+	method, err := m.DetectRootMethodContext(ctx, serial)
+	if err != nil {
+		return "", err
+	}
+	if !method.HasRoot() {
+		return "", fmt.Errorf("%s: %w", serial, ErrNoRoot)
+	}
+	// 命令包装（busybox 等）加在 su 内部的命令上
+	return method.Command(m.wrapCommand(serial, command)), nil
+}
@@ -0,0 +1,42 @@
+package adb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRootMethodCommand(t *testing.T) {
+	command := ShellJoin("rm", "-rf", "--", "/data/local/tmp/it's a $dir")
+	tests := []struct {
+		method RootMethod
+		want   string
+	}{
+		{RootUnknown, `rm -rf -- '/data/local/tmp/it'\''s a $dir'`},
+		{RootNone, `rm -rf -- '/data/local/tmp/it'\''s a $dir'`},
+		{RootAdbd, `rm -rf -- '/data/local/tmp/it'\''s a $dir'`},
+		{RootMagisk, `su -c 'rm -rf -- '\''/data/local/tmp/it'\''\'\'''\''s a $dir'\'''`},
+		{RootSu0, `su 0 sh -c 'rm -rf -- '\''/data/local/tmp/it'\''\'\'''\''s a $dir'\'''`},
+		{RootSuRoot, `su root sh -c 'rm -rf -- '\''/data/local/tmp/it'\''\'\'''\''s a $dir'\'''`},
+	}
+	for _, tt := range tests {
+		if got := tt.method.Command(command); got != tt.want {
+			t.Errorf("%v.Command:\n got  %s\n want %s", tt.method, got, tt.want)
+		}
+	}
+}
+
+// 在本机 sh 中用 shell 函数模拟 su，验证两层转义后参数原样到达命令
+func TestRootMethodCommandNested(t *testing.T) {
+	sh := shellPath(t)
+	// su -c 命令 → sh -c 命令；su 0 / su root 后面的参数即要执行的命令
+	const fakeSu = `su() { case "$1" in -c) sh -c "$2" ;; *) shift; "$@" ;; esac; }; `
+
+	argv := []string{"/sdcard/it's here", "$(id)", "a;b", "`x`", "中文", `\n`, ""}
+	command := ShellJoin(append([]string{"printf", `%s\0`}, argv...)...)
+	for _, method := range []RootMethod{RootAdbd, RootMagisk, RootSu0, RootSuRoot} {
+		got := shellArgs(t, sh, fakeSu+method.Command(command))
+		if !reflect.DeepEqual(got, argv) {
+			t.Errorf("%v: got %q, want %q", method, got, argv)
+		}
+	}
+}
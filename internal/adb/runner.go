@@ -0,0 +1,122 @@
+package adb
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+	"time"
+)
+
+// CommandRunner adb 命令执行器接口
+// ADBManager 的所有 adb 调用都经由它完成，便于替换为其他实现（如测试用的脚本化实现）
+type CommandRunner interface {
+	// CombinedOutput 执行 adb 命令，返回合并后的 stdout 与 stderr
+	CombinedOutput(ctx context.Context, args ...string) ([]byte, error)
+	// Output 执行 adb 命令，分别返回 stdout 与 stderr
+	Output(ctx context.Context, args ...string) (stdout, stderr []byte, err error)
+	// Start 启动一个 adb 进程，用于流式读取输出
+	Start(ctx context.Context, args ...string) (Process, error)
+}
+
+// ServiceOpener 可以直接打开设备服务（如 sync:、exec:）的执行器
+// 文件传输等需要二进制协议的功能通过它完成，不支持时回退到 adb 命令行
+type ServiceOpener interface {
+	OpenService(ctx context.Context, serial, service string) (io.ReadWriteCloser, error)
+}
+
+// featureLister 可以直接查询设备特性的执行器，查询不计入审计日志
+type featureLister interface {
+	deviceFeatures(ctx context.Context, serial string) ([]string, error)
+}
+
+// Process 已启动的 adb 进程
+type Process interface {
+	Stdin() io.WriteCloser
+	Stdout() io.Reader
+	Stderr() io.Reader
+	// Wait 等待进程结束
+	Wait() error
+	// Kill 强制结束进程
+	Kill() error
+}
+
+// ExecRunner 通过启动本地 adb 可执行文件来执行命令
+type ExecRunner struct {
+	Path string // adb 可执行文件路径
+}
+
+// NewExecRunner 创建基于本地 adb 可执行文件的执行器
+func NewExecRunner(path string) *ExecRunner {
+	return &ExecRunner{Path: path}
+}
+
+// command 创建 adb 进程，ctx 取消时进程被终止
+func (r *ExecRunner) command(ctx context.Context, args []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, r.Path, args...)
+	// adb 可能顺带拉起常驻的 server 进程并继承输出管道，
+	// 进程被终止后不再无限等待管道关闭
+	cmd.WaitDelay = 2 * time.Second
+	return cmd
+}
+
+// CombinedOutput 执行 adb 命令，返回合并后的 stdout 与 stderr
+func (r *ExecRunner) CombinedOutput(ctx context.Context, args ...string) ([]byte, error) {
+	return r.command(ctx, args).CombinedOutput()
+}
+
+// Output 执行 adb 命令，分别返回 stdout 与 stderr
+func (r *ExecRunner) Output(ctx context.Context, args ...string) ([]byte, []byte, error) {
+	cmd := r.command(ctx, args)
+
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+
+	err := cmd.Run()
+	return outBuf.Bytes(), errBuf.Bytes(), err
+}
+
+// Start 启动一个 adb 进程
+func (r *ExecRunner) Start(ctx context.Context, args ...string) (Process, error) {
+	cmd := r.command(ctx, args)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return &execProcess{cmd: cmd, stdin: stdin, stdout: stdout, stderr: stderr}, nil
+}
+
+// execProcess 基于 exec.Cmd 的进程
+type execProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.Reader
+	stderr io.Reader
+}
+
+func (p *execProcess) Stdin() io.WriteCloser { return p.stdin }
+func (p *execProcess) Stdout() io.Reader     { return p.stdout }
+func (p *execProcess) Stderr() io.Reader     { return p.stderr }
+func (p *execProcess) Wait() error           { return p.cmd.Wait() }
+
+func (p *execProcess) Kill() error {
+	if p.cmd.Process == nil {
+		return nil
+	}
+	return p.cmd.Process.Kill()
+}
@@ -0,0 +1,223 @@
+package adb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ScriptedResponse 一次 adb 调用的录制结果
+type ScriptedResponse struct {
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	ExitCode int    `json:"exit_code,omitempty"`
+	Error    string `json:"error,omitempty"` // 非退出码类错误（如找不到 adb）
+}
+
+// ScriptedCall 录制脚本中的一条记录
+type ScriptedCall struct {
+	Args     []string         `json:"args"`
+	Response ScriptedResponse `json:"response"`
+}
+
+// ScriptedRunner 按脚本回放 adb 输出的执行器，不依赖真实的 adb 和设备
+// 同一命令可登记多个响应，按顺序回放，最后一个响应会被重复使用
+type ScriptedRunner struct {
+	mu        sync.Mutex
+	responses map[string][]ScriptedResponse
+	calls     [][]string
+}
+
+// NewScriptedRunner 创建脚本化执行器
+func NewScriptedRunner() *ScriptedRunner {
+	return &ScriptedRunner{
+		responses: make(map[string][]ScriptedResponse),
+	}
+}
+
+// LoadScriptedRunner 从 JSON Lines 格式的录制文件创建脚本化执行器
+func LoadScriptedRunner(r io.Reader) (*ScriptedRunner, error) {
+	runner := NewScriptedRunner()
+	decoder := json.NewDecoder(r)
+	for {
+		var call ScriptedCall
+		if err := decoder.Decode(&call); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("解析录制文件失败: %v", err)
+		}
+		runner.On(strings.Join(call.Args, " "), call.Response)
+	}
+	return runner, nil
+}
+
+// On 登记命令的响应，command 为以空格拼接的 adb 参数，例如 "-s emulator-5554 shell getprop ro.product.model"
+func (r *ScriptedRunner) On(command string, responses ...ScriptedResponse) *ScriptedRunner {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responses[command] = append(r.responses[command], responses...)
+	return r
+}
+
+// Calls 返回已发生的调用记录
+func (r *ScriptedRunner) Calls() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	calls := make([][]string, len(r.calls))
+	copy(calls, r.calls)
+	return calls
+}
+
+// next 取出命令的下一个响应
+func (r *ScriptedRunner) next(args []string) (ScriptedResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = append(r.calls, append([]string(nil), args...))
+
+	command := strings.Join(args, " ")
+	queue, ok := r.responses[command]
+	if !ok || len(queue) == 0 {
+		return ScriptedResponse{}, fmt.Errorf("未登记的 adb 调用: adb %s", command)
+	}
+	if len(queue) > 1 {
+		r.responses[command] = queue[1:]
+	}
+	return queue[0], nil
+}
+
+// CombinedOutput 回放合并后的输出
+func (r *ScriptedRunner) CombinedOutput(ctx context.Context, args ...string) ([]byte, error) {
+	stdout, stderr, err := r.Output(ctx, args...)
+	return append(stdout, stderr...), err
+}
+
+// Output 回放 stdout 与 stderr
+func (r *ScriptedRunner) Output(ctx context.Context, args ...string) ([]byte, []byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	resp, err := r.next(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []byte(resp.Stdout), []byte(resp.Stderr), resp.err()
+}
+
+// Start 回放一个已结束的进程
+func (r *ScriptedRunner) Start(ctx context.Context, args ...string) (Process, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	resp, err := r.next(args)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, resp.err()
+	}
+	return &scriptedProcess{resp: resp}, nil
+}
+
+// err 将响应转换为对应的错误
+func (resp ScriptedResponse) err() error {
+	if resp.Error != "" {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	if resp.ExitCode != 0 {
+		return &ScriptedExitError{Code: resp.ExitCode}
+	}
+	return nil
+}
+
+// ScriptedExitError 模拟 adb 进程以非零状态退出
+type ScriptedExitError struct {
+	Code int
+}
+
+func (e *ScriptedExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExitCode 返回退出码，与 exec.ExitError 保持一致
+func (e *ScriptedExitError) ExitCode() int {
+	return e.Code
+}
+
+// scriptedProcess 回放用的进程
+type scriptedProcess struct {
+	resp ScriptedResponse
+}
+
+func (p *scriptedProcess) Stdin() io.WriteCloser { return nopWriteCloser{io.Discard} }
+func (p *scriptedProcess) Stdout() io.Reader     { return strings.NewReader(p.resp.Stdout) }
+func (p *scriptedProcess) Stderr() io.Reader     { return strings.NewReader(p.resp.Stderr) }
+func (p *scriptedProcess) Wait() error           { return p.resp.err() }
+func (p *scriptedProcess) Kill() error           { return nil }
+
+// RecordingRunner 包装另一个执行器，记录每次调用及其结果，可保存为 ScriptedRunner 的录制文件
+type RecordingRunner struct {
+	runner CommandRunner
+	mu     sync.Mutex
+	calls  []ScriptedCall
+}
+
+// NewRecordingRunner 创建录制执行器
+func NewRecordingRunner(runner CommandRunner) *RecordingRunner {
+	return &RecordingRunner{runner: runner}
+}
+
+// CombinedOutput 执行并录制
+func (r *RecordingRunner) CombinedOutput(ctx context.Context, args ...string) ([]byte, error) {
+	output, err := r.runner.CombinedOutput(ctx, args...)
+	r.record(args, output, nil, err)
+	return output, err
+}
+
+// Output 执行并录制
+func (r *RecordingRunner) Output(ctx context.Context, args ...string) ([]byte, []byte, error) {
+	stdout, stderr, err := r.runner.Output(ctx, args...)
+	r.record(args, stdout, stderr, err)
+	return stdout, stderr, err
+}
+
+// Start 流式进程不做录制，直接透传
+func (r *RecordingRunner) Start(ctx context.Context, args ...string) (Process, error) {
+	return r.runner.Start(ctx, args...)
+}
+
+func (r *RecordingRunner) record(args []string, stdout, stderr []byte, err error) {
+	resp := ScriptedResponse{Stdout: string(stdout), Stderr: string(stderr)}
+	if err != nil {
+		if exitErr, ok := err.(interface{ ExitCode() int }); ok && exitErr.ExitCode() > 0 {
+			resp.ExitCode = exitErr.ExitCode()
+		} else {
+			resp.Error = err.Error()
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, ScriptedCall{Args: append([]string(nil), args...), Response: resp})
+}
+
+// Save 以 JSON Lines 格式写出录制内容
+func (r *RecordingRunner) Save(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, call := range r.calls {
+		if err := encoder.Encode(call); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,52 @@
+package adb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRecordingRunnerReplay(t *testing.T) {
+	source := NewScriptedRunner().
+		On("devices -l", ScriptedResponse{Stdout: "List of devices attached\nemulator-5554 device model:Pixel_7\n"}).
+		On("-s emulator-5554 shell ls /data", ScriptedResponse{Stderr: "ls: /data: Permission denied\n", ExitCode: 1}).
+		On("-s gone shell id", ScriptedResponse{Error: "adb: 找不到可执行文件"})
+	recorder := NewRecordingRunner(source)
+	ctx := context.Background()
+
+	recorder.CombinedOutput(ctx, "devices", "-l")
+	recorder.Output(ctx, "-s", "emulator-5554", "shell", "ls", "/data")
+	recorder.Output(ctx, "-s", "gone", "shell", "id")
+
+	var recording bytes.Buffer
+	if err := recorder.Save(&recording); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	replay, err := LoadScriptedRunner(&recording)
+	if err != nil {
+		t.Fatalf("LoadScriptedRunner: %v", err)
+	}
+
+	output, err := replay.CombinedOutput(ctx, "devices", "-l")
+	if err != nil || string(output) != "List of devices attached\nemulator-5554 device model:Pixel_7\n" {
+		t.Fatalf("devices -l = %q, %v", output, err)
+	}
+	stdout, stderr, err := replay.Output(ctx, "-s", "emulator-5554", "shell", "ls", "/data")
+	var exitErr *ScriptedExitError
+	if len(stdout) != 0 || string(stderr) != "ls: /data: Permission denied\n" || !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Fatalf("ls /data = %q, %q, %v", stdout, stderr, err)
+	}
+	if _, _, err := replay.Output(ctx, "-s", "gone", "shell", "id"); err == nil || err.Error() != "adb: 找不到可执行文件" {
+		t.Fatalf("id error = %v", err)
+	}
+	if _, _, err := replay.Output(ctx, "version"); err == nil {
+		t.Fatal("unrecorded call succeeded")
+	}
+
+	want := [][]string{{"devices", "-l"}, {"-s", "emulator-5554", "shell", "ls", "/data"}, {"-s", "gone", "shell", "id"}, {"version"}}
+	if !reflect.DeepEqual(replay.Calls(), want) {
+		t.Fatalf("calls = %q, want %q", replay.Calls(), want)
+	}
+}
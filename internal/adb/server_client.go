@@ -0,0 +1,231 @@
+package adb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// DefaultServerAddr 本地 adb server 的默认地址
+const DefaultServerAddr = "127.0.0.1:5037"
+
+// ServerClient 通过 smart socket 协议直接与本地 adb server 通信的客户端
+// 每个请求格式为 4 位十六进制长度 + 请求内容，server 以 OKAY 或 FAIL + 错误信息应答
+type ServerClient struct {
+	Addr        string        // adb server 地址
+	DialTimeout time.Duration // 连接超时
+}
+
+// NewServerClient 创建 adb server 客户端
+func NewServerClient(addr string) *ServerClient {
+	if addr == "" {
+		addr = DefaultServerAddr
+	}
+	return &ServerClient{
+		Addr:        addr,
+		DialTimeout: 2 * time.Second,
+	}
+}
+
+// ServerError adb server 返回的 FAIL 应答
+type ServerError struct {
+	Request string
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("adb server 拒绝请求 %s: %s", e.Request, e.Message)
+}
+
+// dial 连接 adb server，ctx 取消时连接会被关闭
+func (c *ServerClient) dial(ctx context.Context) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: c.DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return nil, err
+	}
+	return &ctxConn{Conn: conn, stop: context.AfterFunc(ctx, func() { conn.Close() })}, nil
+}
+
+// ctxConn 绑定了 context 的连接，关闭时解除绑定
+type ctxConn struct {
+	net.Conn
+	stop func() bool
+}
+
+func (c *ctxConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
+
+// CloseWrite 关闭写方向（底层连接支持时）
+func (c *ctxConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
+
+// writeRequest 发送一个 smart socket 请求并读取应答状态
+func writeRequest(conn io.ReadWriter, request string) error {
+	if _, err := fmt.Fprintf(conn, "%04x%s", len(request), request); err != nil {
+		return err
+	}
+	return readStatus(conn, request)
+}
+
+// readStatus 读取 OKAY/FAIL 应答
+func readStatus(r io.Reader, request string) error {
+	status := make([]byte, 4)
+	if _, err := io.ReadFull(r, status); err != nil {
+		return fmt.Errorf("读取 adb server 应答失败: %v", err)
+	}
+
+	switch string(status) {
+	case "OKAY":
+		return nil
+	case "FAIL":
+		message, err := readHexString(r)
+		if err != nil {
+			return fmt.Errorf("读取 adb server 错误信息失败: %v", err)
+		}
+		return &ServerError{Request: request, Message: message}
+	default:
+		return fmt.Errorf("adb server 返回未知应答: %q", status)
+	}
+}
+
+// readHexString 读取 4 位十六进制长度前缀的字符串
+func readHexString(r io.Reader) (string, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", err
+	}
+	length, err := strconv.ParseUint(string(header), 16, 16)
+	if err != nil {
+		return "", fmt.Errorf("无效的长度前缀: %q", header)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
+
+// Query 发送 host 请求并读取带长度前缀的应答，例如 host:devices-l、host:version
+func (c *ServerClient) Query(ctx context.Context, request string) (string, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if err := writeRequest(conn, request); err != nil {
+		return "", err
+	}
+	return readHexString(conn)
+}
+
+// Command 发送不带应答内容的 host 请求，例如 host:kill
+func (c *ServerClient) Command(ctx context.Context, request string) error {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return writeRequest(conn, request)
+}
+
+// Forward 发送 forward / killforward 请求，adb server 先确认收到请求，执行完成后再回复一次状态
+// resolvePort 为 true 时（本机端点为 tcp:0）读取实际分配的端口
+func (c *ServerClient) Forward(ctx context.Context, request string, resolvePort bool) (string, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if err := writeRequest(conn, request); err != nil {
+		return "", err
+	}
+	if err := readStatus(conn, request); err != nil {
+		return "", err
+	}
+	if !resolvePort {
+		return "", nil
+	}
+	return readHexString(conn)
+}
+
+// OpenHost 发送 host 请求并返回连接，用于 host:track-devices 等持续推送的请求
+func (c *ServerClient) OpenHost(ctx context.Context, request string) (net.Conn, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeRequest(conn, request); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Version 获取 adb server 的内部协议版本
+func (c *ServerClient) Version(ctx context.Context) (int, error) {
+	output, err := c.Query(ctx, "host:version")
+	if err != nil {
+		return 0, err
+	}
+	version, err := strconv.ParseInt(output, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("无效的版本号: %q", output)
+	}
+	return int(version), nil
+}
+
+// OpenService 切换到指定设备的传输通道并打开设备服务（如 shell:、sync:）
+// serial 为空时使用唯一连接的设备
+func (c *ServerClient) OpenService(ctx context.Context, serial, service string) (net.Conn, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := "host:transport-any"
+	if serial != "" {
+		transport = "host:transport:" + serial
+	}
+	if err := writeRequest(conn, transport); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := writeRequest(conn, service); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Shell 在设备上执行 shell 命令并读取全部输出
+func (c *ServerClient) Shell(ctx context.Context, serial, command string) ([]byte, error) {
+	conn, err := c.OpenService(ctx, serial, "shell:"+command)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return io.ReadAll(conn)
+}
+
+// Sync 打开设备的 sync 服务，用于文件传输
+func (c *ServerClient) Sync(ctx context.Context, serial string) (*SyncConn, error) {
+	conn, err := c.OpenService(ctx, serial, "sync:")
+	if err != nil {
+		return nil, err
+	}
+	return NewSyncConn(conn), nil
+}
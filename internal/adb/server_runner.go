@@ -0,0 +1,404 @@
+package adb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// ServerRunner 将 adb 命令行参数翻译为 adb server 协议请求的执行器
+// 不再为每次调用启动 adb 进程；server 未运行或遇到不支持的命令时交给 Fallback 执行
+type ServerRunner struct {
+	Client   *ServerClient
+	Fallback CommandRunner // 可为 nil
+
+	mu       sync.Mutex
+	features map[string][]string // 各设备 adbd 声明的特性
+}
+
+// NewServerRunner 创建基于 adb server 协议的执行器
+func NewServerRunner(addr string, fallback CommandRunner) *ServerRunner {
+	return &ServerRunner{
+		Client:   NewServerClient(addr),
+		Fallback: fallback,
+	}
+}
+
+// errUnsupported 表示该命令需要交给 Fallback 执行
+var errUnsupported = errors.New("adb server 执行器不支持该命令")
+
+// splitSerial 拆分出 "-s serial" 前缀
+func splitSerial(args []string) (string, []string) {
+	if len(args) >= 2 && args[0] == "-s" {
+		return args[1], args[2:]
+	}
+	return "", args
+}
+
+// CombinedOutput 执行 adb 命令，返回合并后的输出
+func (r *ServerRunner) CombinedOutput(ctx context.Context, args ...string) ([]byte, error) {
+	if serial, command, ok := shellCommand(args); ok {
+		output, err := r.shell(ctx, serial, command)
+		if r.shouldFallback(err) {
+			return r.Fallback.CombinedOutput(ctx, args...)
+		}
+		return output.combinedResult(err)
+	}
+
+	output, err := r.run(ctx, args)
+	if r.shouldFallback(err) {
+		return r.Fallback.CombinedOutput(ctx, args...)
+	}
+	if err != nil {
+		// 与 adb 命令行一致，附带错误信息
+		return append(output, errorOutput(err)...), err
+	}
+	return output, nil
+}
+
+// Output 执行 adb 命令，分别返回 stdout 与 stderr
+func (r *ServerRunner) Output(ctx context.Context, args ...string) ([]byte, []byte, error) {
+	if serial, command, ok := shellCommand(args); ok {
+		output, err := r.shell(ctx, serial, command)
+		if r.shouldFallback(err) {
+			return r.Fallback.Output(ctx, args...)
+		}
+		return output.splitResult(err)
+	}
+
+	stdout, err := r.run(ctx, args)
+	if r.shouldFallback(err) {
+		return r.Fallback.Output(ctx, args...)
+	}
+	if err != nil {
+		return stdout, []byte(errorOutput(err)), err
+	}
+	return stdout, nil, nil
+}
+
+// shouldFallback 判断是否需要交给 Fallback 执行
+func (r *ServerRunner) shouldFallback(err error) bool {
+	if err == nil || r.Fallback == nil {
+		return false
+	}
+	return err == errUnsupported || isServerUnavailable(err)
+}
+
+// Start 启动流式命令，支持 shell 与 track-devices
+// track-devices 的输出与 adb 命令行一致，为连续的带长度前缀的设备列表
+func (r *ServerRunner) Start(ctx context.Context, args ...string) (Process, error) {
+	serial, rest := splitSerial(args)
+	if len(rest) >= 2 && rest[0] == "shell" {
+		// 设备支持时使用 shell 协议 v2，分离 stdout 与 stderr 并取得退出码
+		v2, err := r.supportsShellV2(ctx, serial)
+		if err == nil {
+			service := "shell:"
+			if v2 {
+				service = "shell,v2,raw:"
+			}
+			var conn net.Conn
+			conn, err = r.Client.OpenService(ctx, serial, service+strings.Join(rest[1:], " "))
+			if err == nil && v2 {
+				return newShellV2Process(conn), nil
+			}
+			if err == nil {
+				return newConnProcess(conn), nil
+			}
+		}
+		if !r.shouldFallback(err) {
+			return nil, err
+		}
+	} else if len(rest) >= 1 && rest[0] == "track-devices" {
+		request := "host:track-devices"
+		if len(rest) > 1 && rest[1] == "-l" {
+			request = "host:track-devices-l"
+		}
+		conn, err := r.Client.OpenHost(ctx, request)
+		if err == nil {
+			return newConnProcess(conn), nil
+		}
+		if !r.shouldFallback(err) {
+			return nil, err
+		}
+	} else if r.Fallback == nil {
+		return nil, errUnsupported
+	}
+	return r.Fallback.Start(ctx, args...)
+}
+
+// shell 执行 shell 命令，设备支持时使用 shell 协议 v2
+func (r *ServerRunner) shell(ctx context.Context, serial, command string) (*shellOutput, error) {
+	v2, err := r.supportsShellV2(ctx, serial)
+	if err != nil {
+		return nil, err
+	}
+	return runShell(ctx, r.opener(serial), command, v2)
+}
+
+// supportsShellV2 查询设备是否支持 shell 协议 v2
+func (r *ServerRunner) supportsShellV2(ctx context.Context, serial string) (bool, error) {
+	features, err := r.deviceFeatures(ctx, serial)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(features, FeatureShellV2), nil
+}
+
+// deviceFeatures 查询设备声明的特性，结果按设备缓存
+func (r *ServerRunner) deviceFeatures(ctx context.Context, serial string) ([]string, error) {
+	r.mu.Lock()
+	features, ok := r.features[serial]
+	r.mu.Unlock()
+	if ok {
+		return features, nil
+	}
+
+	output, err := r.Client.Query(ctx, featuresRequest(serial))
+	if err != nil {
+		return nil, err
+	}
+	features = parseFeatures(output)
+
+	// 未指定设备时实际连接的设备可能变化，不缓存
+	if serial != "" {
+		r.mu.Lock()
+		if r.features == nil {
+			r.features = make(map[string][]string)
+		}
+		r.features[serial] = features
+		r.mu.Unlock()
+	}
+	return features, nil
+}
+
+// forget 设备重连或断开后清除缓存的特性
+func (r *ServerRunner) forget(serial string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.features, serial)
+}
+
+// featuresRequest 返回查询设备特性的 host 请求
+func featuresRequest(serial string) string {
+	if serial == "" {
+		return "host:features"
+	}
+	return "host-serial:" + serial + ":features"
+}
+
+// run 将命令行参数翻译为协议请求
+func (r *ServerRunner) run(ctx context.Context, args []string) ([]byte, error) {
+	serial, rest := splitSerial(args)
+	if len(rest) == 0 {
+		return nil, errUnsupported
+	}
+
+	switch rest[0] {
+	case "devices":
+		request := "host:devices"
+		if len(rest) > 1 && rest[1] == "-l" {
+			request = "host:devices-l"
+		}
+		output, err := r.Client.Query(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		return []byte("List of devices attached\n" + output + "\n"), nil
+
+	case "version":
+		version, err := r.Client.Version(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(fmt.Sprintf("Android Debug Bridge version 1.0.%d\n", version)), nil
+
+	case "start-server":
+		_, err := r.Client.Version(ctx)
+		return nil, err
+
+	case "kill-server":
+		return nil, r.Client.Command(ctx, "host:kill")
+
+	case "features":
+		output, err := r.Client.Query(ctx, featuresRequest(serial))
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strings.Join(parseFeatures(output), "\n") + "\n"), nil
+
+	case "reconnect":
+		r.forget(serial)
+		request := "host:reconnect"
+		if serial != "" {
+			request = "host-serial:" + serial + ":reconnect"
+		}
+		output, err := r.Client.Query(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(output + "\n"), nil
+
+	case "connect", "disconnect":
+		if len(rest) < 2 {
+			return nil, errUnsupported
+		}
+		r.forget(rest[1])
+		output, err := r.Client.Query(ctx, "host:"+rest[0]+":"+rest[1])
+		if err != nil {
+			return nil, err
+		}
+		return []byte(output + "\n"), nil
+
+	case "pair":
+		// 未给出配对码时 adb 会在终端中提示输入，只能交给 adb 可执行文件
+		if len(rest) < 3 {
+			return nil, errUnsupported
+		}
+		output, err := r.Client.Query(ctx, "host:pair:"+rest[2]+":"+rest[1])
+		if err != nil {
+			return nil, err
+		}
+		return []byte(output + "\n"), nil
+
+	case "mdns":
+		if len(rest) < 2 || (rest[1] != "services" && rest[1] != "check") {
+			return nil, errUnsupported
+		}
+		output, err := r.Client.Query(ctx, "host:mdns:"+rest[1])
+		if err != nil {
+			return nil, err
+		}
+		if rest[1] == "services" {
+			output = "List of discovered mdns services\n" + output
+		}
+		return []byte(output), nil
+
+	case "forward":
+		request, list, resolvePort, err := forwardRequest(rest[1:])
+		if err != nil {
+			return nil, err
+		}
+		prefix := "host:"
+		if serial != "" {
+			prefix = "host-serial:" + serial + ":"
+		}
+		var output string
+		if list {
+			output, err = r.Client.Query(ctx, prefix+request)
+		} else {
+			output, err = r.Client.Forward(ctx, prefix+request, resolvePort)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return forwardOutput(output, resolvePort), nil
+
+	case "reverse":
+		return reverseService(ctx, r.opener(serial), rest[1:])
+
+	case "root", "unroot", "usb":
+		return readService(ctx, r.opener(serial), rest[0]+":")
+
+	case "tcpip":
+		if len(rest) != 2 {
+			return nil, errUnsupported
+		}
+		return readService(ctx, r.opener(serial), "tcpip:"+rest[1])
+
+	case "pull":
+		if len(rest) != 3 {
+			return nil, errUnsupported
+		}
+		features, err := r.deviceFeatures(ctx, serial)
+		if err != nil {
+			return nil, err
+		}
+		return syncPull(ctx, runnerLog().With("device", serial), r.opener(serial), features, rest[1], rest[2])
+
+	case "push":
+		if len(rest) != 3 {
+			return nil, errUnsupported
+		}
+		return syncPush(ctx, r.opener(serial), rest[1], rest[2])
+
+	case "install":
+		if len(rest) < 2 {
+			return nil, errMissingAPK
+		}
+		apkPath := rest[len(rest)-1]
+		return installAPK(ctx, r.opener(serial), apkPath, rest[1:len(rest)-1])
+
+	case "uninstall":
+		if len(rest) < 2 {
+			return nil, errUnsupported
+		}
+		return r.Client.Shell(ctx, serial, ShellJoin(append([]string{"pm"}, rest...)...))
+	}
+
+	return nil, errUnsupported
+}
+
+// OpenService 通过 adb server 打开设备服务
+func (r *ServerRunner) OpenService(ctx context.Context, serial, service string) (io.ReadWriteCloser, error) {
+	return r.Client.OpenService(ctx, serial, service)
+}
+
+// opener 返回打开指定设备服务的函数
+func (r *ServerRunner) opener(serial string) serviceOpener {
+	return func(ctx context.Context, service string) (io.ReadWriteCloser, error) {
+		return r.Client.OpenService(ctx, serial, service)
+	}
+}
+
+// isServerUnavailable 判断是否因为 adb server 未运行而失败
+func isServerUnavailable(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// errorOutput 以 adb 命令行的格式输出错误信息
+func errorOutput(err error) string {
+	var serverErr *ServerError
+	if errors.As(err, &serverErr) {
+		return "adb: error: " + serverErr.Message + "\n"
+	}
+	return "adb: error: " + err.Error() + "\n"
+}
+
+// connProcess 基于协议连接的流式进程
+type connProcess struct {
+	conn   io.ReadWriteCloser
+	stdout io.Reader
+}
+
+func newConnProcess(conn io.ReadWriteCloser) *connProcess {
+	return &connProcess{conn: conn, stdout: conn}
+}
+
+// nopWriteCloser 关闭时什么都不做的 io.WriteCloser
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// Stdin 关闭时不断开连接：adb 服务会将半关闭当作会话结束
+func (p *connProcess) Stdin() io.WriteCloser { return nopWriteCloser{p.conn} }
+func (p *connProcess) Stdout() io.Reader     { return p.stdout }
+func (p *connProcess) Stderr() io.Reader     { return bytes.NewReader(nil) }
+func (p *connProcess) Kill() error           { return p.conn.Close() }
+
+func (p *connProcess) Wait() error {
+	p.conn.Close()
+	return nil
+}
@@ -0,0 +1,130 @@
+package adb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServerRunnerRoundTrip(t *testing.T) {
+	srv, err := NewFakeServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.AddDevice("emulator-5554", "device", "product:sdk model:Pixel_7 device:emu64")
+	srv.SetFeatures("emulator-5554", "shell_v2,cmd,stat_v2")
+	srv.SetShellResult("emulator-5554", "ls /data", ShellResult{Stdout: "app\n", Stderr: "ls: /data/x: Permission denied\n", ExitCode: 1})
+
+	m := newTestManager(NewServerRunner(srv.Addr(), nil))
+
+	devices, err := m.ListDevices()
+	if err != nil {
+		t.Fatalf("ListDevices: %v", err)
+	}
+	if len(devices) != 1 || devices[0].Serial != "emulator-5554" || devices[0].Model != "Pixel_7" || devices[0].DeviceName != "emu64" {
+		t.Fatalf("devices = %+v", devices)
+	}
+
+	result, err := m.ExecuteShell("emulator-5554", "ls /data")
+	if err != nil {
+		t.Fatalf("ExecuteShell: %v", err)
+	}
+	if result.Stdout != "app\n" || result.Stderr != "ls: /data/x: Permission denied\n" || result.ExitCode != 1 {
+		t.Fatalf("result = %+v", result)
+	}
+
+	// 经 sync 协议推送后再拉取，内容与修改时间保持不变
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("adb sync round trip\n"), 10000)
+	local := filepath.Join(dir, "in.bin")
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.WriteFile(local, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(local, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.PushFile("emulator-5554", local, "/sdcard/in.bin"); err != nil {
+		t.Fatalf("PushFile: %v", err)
+	}
+	file, ok := srv.File("emulator-5554", "/sdcard/in.bin")
+	if !ok || !bytes.Equal(file.Data, data) || !file.ModTime.Equal(modTime) {
+		t.Fatalf("pushed file = %v, %d bytes, mtime %v", ok, len(file.Data), file.ModTime)
+	}
+
+	out := filepath.Join(dir, "out.bin")
+	if err := m.PullFile("emulator-5554", "/sdcard/in.bin", out); err != nil {
+		t.Fatalf("PullFile: %v", err)
+	}
+	pulled, err := os.ReadFile(out)
+	if err != nil || !bytes.Equal(pulled, data) {
+		t.Fatalf("pulled %d bytes, err %v", len(pulled), err)
+	}
+}
+
+func TestServerRunnerUnknownDevice(t *testing.T) {
+	srv, err := NewFakeServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	m := newTestManager(NewServerRunner(srv.Addr(), nil))
+	if _, err := m.ExecuteShell("missing", "true"); err == nil {
+		t.Fatal("ExecuteShell on unknown device succeeded")
+	}
+}
+
+func TestInstallWithoutAPK(t *testing.T) {
+	srv, err := NewFakeServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.AddDevice("emulator-5554", "device", "")
+
+	r := NewServerRunner(srv.Addr(), nil)
+	for _, args := range [][]string{{"install"}, {"-s", "emulator-5554", "install"}} {
+		if _, err := r.CombinedOutput(context.Background(), args...); !errors.Is(err, errMissingAPK) {
+			t.Errorf("%v: err = %v, want errMissingAPK", args, err)
+		}
+	}
+}
+
+// APK 文件名中的空格与 shell 特殊字符不会被设备上的 shell 解释
+func TestInstallQuoting(t *testing.T) {
+	srv, err := NewFakeServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.AddDevice("emulator-5554", "device", "")
+
+	name := "my app;$(id)*.apk"
+	remote := "/data/local/tmp/" + name
+	srv.SetShell("emulator-5554", "pm install -r '"+remote+"'", "Success\n")
+	srv.SetShell("emulator-5554", "pm uninstall -k com.example.app", "Success\n")
+
+	apk := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(apk, []byte("PK\x03\x04"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := newTestManager(NewServerRunner(srv.Addr(), nil))
+	if err := m.InstallApp("emulator-5554", apk); err != nil {
+		t.Fatalf("InstallApp: %v", err)
+	}
+	if _, ok := srv.File("emulator-5554", remote); !ok {
+		t.Fatalf("APK not pushed to %q", remote)
+	}
+
+	r := NewServerRunner(srv.Addr(), nil)
+	output, err := r.CombinedOutput(context.Background(), "-s", "emulator-5554", "uninstall", "-k", "com.example.app")
+	if err != nil || string(output) != "Success\n" {
+		t.Fatalf("uninstall output = %q, err = %v", output, err)
+	}
+}
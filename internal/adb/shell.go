@@ -0,0 +1,276 @@
+package adb
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ShellResult shell 命令的执行结果
+type ShellResult struct {
+	Stdout   string
+	Stderr   string // 设备不支持 shell_v2 时 stderr 混在 Stdout 中，此处为空
+	ExitCode int    // 命令退出码，无法得到时为 -1
+}
+
+// RawShellResult shell 命令未经编码转换的执行结果
+type RawShellResult struct {
+	Stdout   []byte
+	Stderr   []byte // 设备不支持 shell_v2 时 stderr 混在 Stdout 中，此处为空
+	ExitCode int    // 命令退出码，无法得到时为 -1
+}
+
+// Decode 按 enc 转换输出
+func (r *RawShellResult) Decode(enc Encoding) *ShellResult {
+	return &ShellResult{Stdout: enc.Decode(r.Stdout), Stderr: enc.Decode(r.Stderr), ExitCode: r.ExitCode}
+}
+
+// exitSentinel 设备不支持 shell_v2 时，在输出末尾回传退出码的标记
+const exitSentinel = "__ADBM_EXIT__:"
+
+// withExitSentinel 在命令执行完后输出退出码标记
+// 命令放在子 shell 中执行，其中的 exit、末尾的 & 或注释都不会影响标记输出；
+// 退出码需在 echo 之前保存，否则 $? 是 echo 的退出码
+func withExitSentinel(command string) string {
+	return "( " + command + "\n); " + exitStatusVar + "=$?; echo; echo " + exitSentinel + "$" + exitStatusVar
+}
+
+// exitStatusVar withExitSentinel 保存退出码所用的 shell 变量
+const exitStatusVar = "__adbm_rc"
+
+// splitExitSentinel 从输出中拆出退出码标记，找不到标记时退出码为 -1
+// pty 为 true 表示输出经过旧设备的 PTY，标记前的换行为 \r\n；exec 服务没有 PTY，输出末尾的 \r 属于数据本身
+func splitExitSentinel(output string, pty bool) (string, int) {
+	idx := strings.LastIndex(output, "\n"+exitSentinel)
+	if idx < 0 {
+		return output, -1
+	}
+	code, err := strconv.Atoi(strings.TrimSpace(output[idx+1+len(exitSentinel):]))
+	if err != nil {
+		return output, -1
+	}
+	// 去掉标记前额外输出的换行
+	if pty {
+		return strings.TrimSuffix(output[:idx], "\r"), code
+	}
+	return output[:idx], code
+}
+
+// Features 返回设备 adbd 声明的特性（如 shell_v2、cmd、stat_v2）
+func (m *ADBManager) Features(serial string) ([]string, error) {
+	return m.FeaturesContext(context.Background(), serial)
+}
+
+// FeaturesContext 可通过 ctx 取消的 Features，结果按设备缓存
+func (m *ADBManager) FeaturesContext(ctx context.Context, serial string) ([]string, error) {
+	m.featureLock.Lock()
+	features, ok := m.features[serial]
+	m.featureLock.Unlock()
+	if ok {
+		return features, nil
+	}
+
+	output, err := m.run(ctx, deviceArgs(serial, "features")...)
+	if err != nil {
+		return nil, err
+	}
+	features = parseFeatures(string(output))
+
+	if serial != "" {
+		m.featureLock.Lock()
+		m.features[serial] = features
+		m.featureLock.Unlock()
+	}
+	return features, nil
+}
+
+// HasFeature 判断设备是否支持指定特性，查询失败时视为不支持
+func (m *ADBManager) HasFeature(serial, feature string) bool {
+	return m.HasFeatureContext(context.Background(), serial, feature)
+}
+
+// HasFeatureContext 可通过 ctx 取消的 HasFeature
+func (m *ADBManager) HasFeatureContext(ctx context.Context, serial, feature string) bool {
+	features, err := m.FeaturesContext(ctx, serial)
+	if err != nil {
+		return false
+	}
+	for _, f := range features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// forgetFeatures 设备重连或断开后清除缓存的特性与设备能力
+func (m *ADBManager) forgetFeatures(serial string) {
+	m.featureLock.Lock()
+	delete(m.features, serial)
+	delete(m.listMethods, serial)
+	m.featureLock.Unlock()
+
+	m.setCapabilities(serial, nil)
+}
+
+// ExecuteShell 执行 shell 命令，分别返回 stdout、stderr 与命令的退出码
+func (m *ADBManager) ExecuteShell(serial, command string) (*ShellResult, error) {
+	return m.ExecuteShellContext(context.Background(), serial, command)
+}
+
+// ExecuteShellContext 可通过 ctx 取消的 ExecuteShell
+// 设备支持 shell_v2 时使用协议分离 stdout 与 stderr 并取得真实退出码，否则通过输出末尾的标记取得退出码
+// 命令以非零状态退出不视为错误，返回的 error 仅表示 adb 本身失败（设备离线、未授权、超时等）
+// 输出按设备的编码设置转换；需要其他编码时使用 ExecuteShellWithEncoding，二进制数据使用 ExecuteShellRaw
+func (m *ADBManager) ExecuteShellContext(ctx context.Context, serial, command string) (*ShellResult, error) {
+	return m.executeShell(ctx, serial, m.wrapCommand(serial, command))
+}
+
+// executeShell 执行已处理好前缀的命令，按设备的编码设置转换输出
+func (m *ADBManager) executeShell(ctx context.Context, serial, wrappedCommand string) (*ShellResult, error) {
+	raw, err := m.executeShellRaw(ctx, serial, wrappedCommand, false)
+	if err != nil {
+		return nil, err
+	}
+	return raw.Decode(m.outputEncoding(serial)), nil
+}
+
+// ExecuteShellWithEncoding 以指定的编码转换输出的 ExecuteShell，不使用设备的编码设置
+func (m *ADBManager) ExecuteShellWithEncoding(serial, command string, enc Encoding) (*ShellResult, error) {
+	return m.ExecuteShellWithEncodingContext(context.Background(), serial, command, enc)
+}
+
+// ExecuteShellWithEncodingContext 可通过 ctx 取消的 ExecuteShellWithEncoding
+func (m *ADBManager) ExecuteShellWithEncodingContext(ctx context.Context, serial, command string, enc Encoding) (*ShellResult, error) {
+	raw, err := m.executeShellRaw(ctx, serial, m.wrapCommand(serial, command), false)
+	if err != nil {
+		return nil, err
+	}
+	return raw.Decode(enc), nil
+}
+
+// ExecuteShellRaw 执行 shell 命令，原样返回输出的字节，适合读取二进制数据（如 cat 数据库文件）
+// 设备不支持 shell_v2 时改用 exec 服务（adb exec-out），避免旧设备的伪终端把 \n 转换为 \r\n，
+// 此时 stderr 混在 Stdout 中，读取二进制数据时应将其重定向到 /dev/null
+func (m *ADBManager) ExecuteShellRaw(serial, command string) (*RawShellResult, error) {
+	return m.ExecuteShellRawContext(context.Background(), serial, command)
+}
+
+// ExecuteShellRawContext 可通过 ctx 取消的 ExecuteShellRaw
+func (m *ADBManager) ExecuteShellRawContext(ctx context.Context, serial, command string) (*RawShellResult, error) {
+	return m.executeShellRaw(ctx, serial, m.wrapCommand(serial, command), true)
+}
+
+// executeShellRaw 执行已处理好前缀的命令，返回未转换的输出
+// binary 为 true 时，不支持 shell_v2 的设备改用 exec 服务，保证输出与设备上的字节一致
+func (m *ADBManager) executeShellRaw(ctx context.Context, serial, wrappedCommand string, binary bool) (*RawShellResult, error) {
+	v2 := m.HasFeatureContext(ctx, serial, FeatureShellV2)
+	service := "shell"
+	if binary && !v2 {
+		service = "exec-out"
+	}
+	// 审计日志记录的是不带退出码标记的命令
+	auditArgs := deviceArgs(serial, service, wrappedCommand)
+	if !v2 {
+		wrappedCommand = withExitSentinel(wrappedCommand)
+	}
+
+	args := deviceArgs(serial, service, wrappedCommand)
+	release, err := m.acquire(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	var stdout, stderr []byte
+	if service == "exec-out" {
+		stdout, stderr, err = m.execOut(ctx, serial, wrappedCommand, args)
+	} else {
+		stdout, stderr, err = m.runner.Output(ctx, args...)
+	}
+	release()
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		m.audit(auditArgs, start, -1, ctxErr)
+		return nil, ctxErr
+	}
+
+	result := &RawShellResult{Stdout: stdout, Stderr: stderr}
+	if err != nil {
+		cmdErr := newCommandError(args, stderr, err)
+		// 只有 shell_v2 下 adb 的退出码才是命令的退出码，且需排除 adb 自身的报错
+		if !v2 || cmdErr.Kind != nil || cmdErr.ExitCode < 0 {
+			m.audit(auditArgs, start, cmdErr.ExitCode, cmdErr)
+			return nil, cmdErr
+		}
+		result.ExitCode = cmdErr.ExitCode
+	}
+	if !v2 {
+		var output string
+		output, result.ExitCode = splitExitSentinel(string(result.Stdout), service != "exec-out")
+		result.Stdout = []byte(output)
+	}
+	m.audit(auditArgs, start, result.ExitCode, nil)
+	return result, nil
+}
+
+// execOut 通过 exec 服务执行命令，执行器不能直接打开设备服务时改为执行 adb exec-out（args）
+func (m *ADBManager) execOut(ctx context.Context, serial, command string, args []string) ([]byte, []byte, error) {
+	output, err := readService(ctx, m.serviceOpener(serial), "exec:"+command)
+	if errors.Is(err, errUnsupported) || isServerUnavailable(err) {
+		return m.runner.Output(ctx, args...)
+	}
+	if err != nil {
+		return output, []byte(errorOutput(err)), err
+	}
+	return output, nil, nil
+}
+
+// ExecuteArgs 以参数列表的形式执行命令，每个参数都会为设备上的 shell 正确转义
+// 路径等参数中的空格、引号、$ 不会被 shell 解释
+func (m *ADBManager) ExecuteArgs(serial string, argv ...string) (*ShellResult, error) {
+	return m.ExecuteArgsContext(context.Background(), serial, argv...)
+}
+
+// ExecuteArgsContext 可通过 ctx 取消的 ExecuteArgs
+func (m *ADBManager) ExecuteArgsContext(ctx context.Context, serial string, argv ...string) (*ShellResult, error) {
+	return m.ExecuteShellContext(ctx, serial, ShellJoin(argv...))
+}
+
+// ExecuteArgsAsRoot 以设备探测到的 root 方式执行参数列表，su 的命令参数会再转义一层
+// 没有 root 时返回 ErrNoRoot
+func (m *ADBManager) ExecuteArgsAsRoot(serial string, argv ...string) (*ShellResult, error) {
+	return m.ExecuteArgsAsRootContext(context.Background(), serial, argv...)
+}
+
+// ExecuteArgsAsRootContext 可通过 ctx 取消的 ExecuteArgsAsRoot
+func (m *ADBManager) ExecuteArgsAsRootContext(ctx context.Context, serial string, argv ...string) (*ShellResult, error) {
+	rootCmd, err := m.rootCommand(ctx, serial, ShellJoin(argv...))
+	if err != nil {
+		return nil, err
+	}
+	return m.executeShell(ctx, serial, rootCmd)
+}
+
+// shellStdout 执行命令并只返回 stdout，命令以非零状态退出时返回 *CommandError
+// allowPartial 为 true 时，只要有输出就不视为失败（例如 ls 中部分条目无权限）
+func (m *ADBManager) shellStdout(ctx context.Context, serial, command string, allowPartial bool) (string, error) {
+	result, err := m.ExecuteShellContext(ctx, serial, command)
+	if err != nil {
+		return "", err
+	}
+	if result.ExitCode == 0 || (allowPartial && strings.TrimSpace(result.Stdout) != "") {
+		return result.Stdout, nil
+	}
+
+	stderr := result.Stderr
+	if stderr == "" {
+		stderr = result.Stdout // 不支持 shell_v2 时错误信息在 stdout 中
+	}
+	return result.Stdout, &CommandError{
+		Args:     deviceArgs(serial, "shell", command),
+		ExitCode: result.ExitCode,
+		Stderr:   stderr,
+		Err:      &ShellExitError{Code: result.ExitCode},
+	}
+}
@@ -0,0 +1,229 @@
+package adb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FeatureShellV2 设备在 features 中声明此项时支持 shell 协议 v2
+const FeatureShellV2 = "shell_v2"
+
+// shell 协议 v2 的数据包类型，每个包为 1 字节类型 + 4 字节小端长度 + 数据
+const (
+	shellStdin      = 0
+	shellStdout     = 1
+	shellStderr     = 2
+	shellExit       = 3
+	shellCloseStdin = 4
+	shellWindowSize = 5
+)
+
+// ShellExitError 设备上的 shell 命令以非零状态退出，与 exec.ExitError 一样提供 ExitCode
+type ShellExitError struct {
+	Code int
+}
+
+func (e *ShellExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExitCode 返回命令的退出码
+func (e *ShellExitError) ExitCode() int {
+	return e.Code
+}
+
+// writeShellPacket 写出一个 shell v2 数据包
+func writeShellPacket(w io.Writer, id byte, data []byte) error {
+	packet := make([]byte, 5, 5+len(data))
+	packet[0] = id
+	binary.LittleEndian.PutUint32(packet[1:], uint32(len(data)))
+	_, err := w.Write(append(packet, data...))
+	return err
+}
+
+// readShellPacket 读取一个 shell v2 数据包
+func readShellPacket(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	data := make([]byte, binary.LittleEndian.Uint32(header[1:]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, err
+	}
+	return header[0], data, nil
+}
+
+// shellOutput 一次 shell 调用的输出，combined 保留 stdout 与 stderr 的到达顺序
+type shellOutput struct {
+	stdout   []byte
+	stderr   []byte
+	combined []byte
+}
+
+// runShell 执行 shell 命令
+// v2 为 true 时使用 shell 协议 v2，分离 stdout 与 stderr，并在退出码非零时返回 *ShellExitError；
+// 否则 stderr 混在 stdout 中，无法得到退出码
+func runShell(ctx context.Context, open serviceOpener, command string, v2 bool) (*shellOutput, error) {
+	if !v2 {
+		output, err := readService(ctx, open, "shell:"+command)
+		return &shellOutput{stdout: output, combined: output}, err
+	}
+
+	conn, err := open(ctx, "shell,v2,raw:"+command)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var stdout, stderr, combined bytes.Buffer
+	for {
+		id, data, err := readShellPacket(conn)
+		output := &shellOutput{stdout: stdout.Bytes(), stderr: stderr.Bytes(), combined: combined.Bytes()}
+		if ctx.Err() != nil {
+			return output, ctx.Err()
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF // 没有收到退出码就断开
+			}
+			return output, err
+		}
+
+		switch id {
+		case shellStdout:
+			stdout.Write(data)
+			combined.Write(data)
+		case shellStderr:
+			stderr.Write(data)
+			combined.Write(data)
+		case shellExit:
+			if len(data) > 0 && data[0] != 0 {
+				return output, &ShellExitError{Code: int(data[0])}
+			}
+			return output, nil
+		}
+	}
+}
+
+// shellV2Process 基于 shell 协议 v2 连接的流式进程，分离 stdout 与 stderr，Wait 返回命令的退出码
+// 与 exec.Cmd 的管道一样，调用方需同时读取 Stdout 与 Stderr，否则输出会阻塞
+type shellV2Process struct {
+	conn   io.ReadWriteCloser
+	stdout *io.PipeReader
+	stderr *io.PipeReader
+	done   chan struct{}
+	err    error
+}
+
+func newShellV2Process(conn io.ReadWriteCloser) *shellV2Process {
+	stdoutR, stdoutW := io.Pipe()
+	stderrR, stderrW := io.Pipe()
+	p := &shellV2Process{conn: conn, stdout: stdoutR, stderr: stderrR, done: make(chan struct{})}
+
+	go func() {
+		defer close(p.done)
+		defer stdoutW.Close()
+		defer stderrW.Close()
+		for {
+			id, data, err := readShellPacket(conn)
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					err = io.ErrUnexpectedEOF // 没有收到退出码就断开
+				}
+				p.err = err
+				return
+			}
+			switch id {
+			case shellStdout:
+				stdoutW.Write(data)
+			case shellStderr:
+				stderrW.Write(data)
+			case shellExit:
+				if len(data) > 0 && data[0] != 0 {
+					p.err = &ShellExitError{Code: int(data[0])}
+				}
+				return
+			}
+		}
+	}()
+	return p
+}
+
+func (p *shellV2Process) Stdin() io.WriteCloser { return shellV2Stdin{p.conn} }
+func (p *shellV2Process) Stdout() io.Reader     { return p.stdout }
+func (p *shellV2Process) Stderr() io.Reader     { return p.stderr }
+func (p *shellV2Process) Kill() error           { return p.conn.Close() }
+
+func (p *shellV2Process) Wait() error {
+	<-p.done
+	p.conn.Close()
+	return p.err
+}
+
+// shellV2Stdin 将写入的数据封装为 stdin 数据包，Close 时通知设备端 stdin 已结束
+type shellV2Stdin struct {
+	conn io.Writer
+}
+
+func (w shellV2Stdin) Write(data []byte) (int, error) {
+	if err := writeShellPacket(w.conn, shellStdin, data); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (w shellV2Stdin) Close() error {
+	return writeShellPacket(w.conn, shellCloseStdin, nil)
+}
+
+// combinedResult 以 CombinedOutput 的形式返回，退出码以外的错误与 adb 命令行一样附带错误信息
+func (o *shellOutput) combinedResult(err error) ([]byte, error) {
+	var exitErr *ShellExitError
+	switch {
+	case o == nil:
+		return []byte(errorOutput(err)), err
+	case err != nil && !errors.As(err, &exitErr):
+		return append(o.combined, errorOutput(err)...), err
+	}
+	return o.combined, err
+}
+
+// splitResult 以 Output 的形式返回
+func (o *shellOutput) splitResult(err error) ([]byte, []byte, error) {
+	var exitErr *ShellExitError
+	switch {
+	case o == nil:
+		return nil, []byte(errorOutput(err)), err
+	case err != nil && !errors.As(err, &exitErr):
+		return o.stdout, append(o.stderr, errorOutput(err)...), err
+	}
+	return o.stdout, o.stderr, err
+}
+
+// shellCommand 识别非交互式的 shell 调用，返回设备序列号与命令
+func shellCommand(args []string) (string, string, bool) {
+	serial, rest := splitSerial(args)
+	if len(rest) < 2 || rest[0] != "shell" {
+		return "", "", false
+	}
+	return serial, strings.Join(rest[1:], " "), true
+}
+
+// parseFeatures 解析 features 列表，兼容逗号分隔（协议应答）与逐行输出（adb features）
+func parseFeatures(output string) []string {
+	features := make([]string, 0)
+	for _, field := range strings.FieldsFunc(output, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r'
+	}) {
+		if field = strings.TrimSpace(field); field != "" {
+			features = append(features, field)
+		}
+	}
+	return features
+}
@@ -0,0 +1,212 @@
+package adb
+
+import (
+	"bytes"
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWithExitSentinel(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("找不到 sh")
+	}
+
+	tests := []struct {
+		command string
+		output  string
+		code    int
+	}{
+		{"true", "", 0},
+		{"false", "", 1},
+		{"echo hi; exit 3", "hi\n", 3},
+		{"printf x", "x", 0},
+		{"echo a # 注释", "a\n", 0},
+		{"true &", "", 0},
+		{"sh -c 'exit 42'", "", 42},
+	}
+
+	for _, tt := range tests {
+		out, err := exec.Command(sh, "-c", withExitSentinel(tt.command)).Output()
+		if err != nil {
+			t.Fatalf("%q: %v", tt.command, err)
+		}
+		output, code := splitExitSentinel(string(out), false)
+		if output != tt.output || code != tt.code {
+			t.Errorf("%q: got (%q, %d), want (%q, %d)", tt.command, output, code, tt.output, tt.code)
+		}
+	}
+}
+
+func TestSplitExitSentinel(t *testing.T) {
+	tests := []struct {
+		raw    string
+		pty    bool
+		output string
+		code   int
+	}{
+		{"data\n\n" + exitSentinel + "0\n", false, "data\n", 0},
+		{"data\n" + exitSentinel + "1\n", false, "data", 1},
+		{"data\r\n\r\n" + exitSentinel + "127\r\n", true, "data\r\n", 127},
+		// exec 服务没有 PTY，数据末尾的 \r 需保留
+		{"data\r\n" + exitSentinel + "0\n", false, "data\r", 0},
+		{"no marker\n", false, "no marker\n", -1},
+		{"x\n" + exitSentinel + "abc\n", false, "x\n" + exitSentinel + "abc\n", -1},
+	}
+	for _, tt := range tests {
+		output, code := splitExitSentinel(tt.raw, tt.pty)
+		if output != tt.output || code != tt.code {
+			t.Errorf("splitExitSentinel(%q, %v) = (%q, %d), want (%q, %d)", tt.raw, tt.pty, output, code, tt.output, tt.code)
+		}
+	}
+}
+
+// 不支持 shell_v2 的设备通过退出码标记取得命令的真实退出码
+func TestExecuteShellWithoutShellV2(t *testing.T) {
+	srv, err := NewFakeServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.AddDevice("old", "device", "")
+	srv.SetFeatures("old", "cmd")
+	srv.SetShellResult("old", "ls /data", ShellResult{Stderr: "ls: /data: Permission denied\n", ExitCode: 1})
+	srv.SetShellResult("old", "rm -rf -- /system/app", ShellResult{Stderr: "rm: /system/app: Read-only file system\n", ExitCode: 1})
+	srv.SetShellResult("old", "echo ok", ShellResult{Stdout: "ok\n"})
+
+	m := newTestManager(NewServerRunner(srv.Addr(), nil))
+
+	result, err := m.ExecuteShell("old", "ls /data")
+	if err != nil {
+		t.Fatalf("ExecuteShell: %v", err)
+	}
+	if result.ExitCode != 1 || result.Stdout != "ls: /data: Permission denied\n" {
+		t.Fatalf("result = %+v, want exit code 1", result)
+	}
+
+	result, err = m.ExecuteShell("old", "echo ok")
+	if err != nil || result.ExitCode != 0 || result.Stdout != "ok\n" {
+		t.Fatalf("result = %+v, err = %v", result, err)
+	}
+
+	if err := m.DeleteFile("old", "/system/app"); err == nil {
+		t.Fatal("DeleteFile on read-only path succeeded")
+	}
+}
+
+func TestExecuteShellRawExecOut(t *testing.T) {
+	srv, err := NewFakeServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.AddDevice("old", "device", "")
+	srv.SetFeatures("old", "cmd")
+	srv.AddDevice("new", "device", "")
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"SQLite 文件头", "SQLite format 3\x00\x10\x00\x01\x01\x00@  \x00\x00\x00\x02"},
+		{"换行与回车", "line\nCRLF\r\n\n\r\r\n"},
+		{"以回车结尾", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\r"},
+		{"全部字节", string(allBytes())},
+		{"空输出", ""},
+	}
+
+	auditor := &auditRecorder{}
+	m := newTestManager(NewServerRunner(srv.Addr(), nil))
+	m.SetAuditor(auditor)
+
+	for _, serial := range []string{"old", "new"} {
+		for _, tt := range tests {
+			t.Run(serial+"/"+tt.name, func(t *testing.T) {
+				command := "cat /data/local/tmp/" + tt.name
+				srv.SetShell(serial, command, tt.data)
+
+				result, err := m.ExecuteShellRaw(serial, command)
+				if err != nil {
+					t.Fatalf("ExecuteShellRaw: %v", err)
+				}
+				if !bytes.Equal(result.Stdout, []byte(tt.data)) {
+					t.Fatalf("Stdout = %q, want %q", result.Stdout, tt.data)
+				}
+				if result.ExitCode != 0 {
+					t.Fatalf("ExitCode = %d, want 0", result.ExitCode)
+				}
+			})
+		}
+	}
+
+	// 不支持 shell_v2 的设备走 exec 服务，审计日志中记录的是不带退出码标记的命令
+	var execArgs [][]string
+	for _, event := range auditor.Events() {
+		if event.Serial == "old" && len(event.Argv) > 2 && event.Argv[2] == "exec-out" {
+			execArgs = append(execArgs, event.Argv)
+		}
+	}
+	if len(execArgs) != len(tests) {
+		t.Fatalf("audit events for old = %d, want %d", len(execArgs), len(tests))
+	}
+	if want := []string{"-s", "old", "exec-out", "cat /data/local/tmp/" + tests[0].name}; !reflect.DeepEqual(execArgs[0], want) {
+		t.Fatalf("audit argv = %q, want %q", execArgs[0], want)
+	}
+}
+
+func TestExecuteShellRawExecOutExitCode(t *testing.T) {
+	srv, err := NewFakeServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.AddDevice("old", "device", "")
+	srv.SetFeatures("old", "cmd")
+	srv.SetShellResult("old", "cat /data/data/x.db", ShellResult{Stderr: "cat: /data/data/x.db: Permission denied\n", ExitCode: 1})
+
+	m := newTestManager(NewServerRunner(srv.Addr(), nil))
+
+	result, err := m.ExecuteShellRaw("old", "cat /data/data/x.db")
+	if err != nil {
+		t.Fatalf("ExecuteShellRaw: %v", err)
+	}
+	// exec 服务不区分 stdout 与 stderr
+	if result.ExitCode != 1 || string(result.Stdout) != "cat: /data/data/x.db: Permission denied\n" || len(result.Stderr) != 0 {
+		t.Fatalf("result = %+v, want exit code 1 with stderr in Stdout", result)
+	}
+}
+
+// allBytes 返回 0x00 到 0xff 的全部字节
+func allBytes() []byte {
+	b := make([]byte, 256)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
+
+// 命令失败时返回 stdout 与 stderr 合并后的输出
+func TestExecuteCommandWithTimeoutFailureOutput(t *testing.T) {
+	srv, err := NewFakeServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.AddDevice("emulator-5554", "device", "")
+	srv.SetShellResult("emulator-5554", "pm install /data/local/tmp/app.apk", ShellResult{
+		Stdout:   "Performing Streamed Install\n",
+		Stderr:   "Failure [INSTALL_FAILED_VERSION_DOWNGRADE]\n",
+		ExitCode: 1,
+	})
+
+	m := newTestManager(NewServerRunner(srv.Addr(), nil))
+	output, err := m.ExecuteCommandWithTimeout("emulator-5554", "pm install /data/local/tmp/app.apk", 5*time.Second)
+	if err == nil {
+		t.Fatal("ExecuteCommandWithTimeout succeeded, want exit code 1")
+	}
+	if want := "Performing Streamed Install\nFailure [INSTALL_FAILED_VERSION_DOWNGRADE]\n"; output != want {
+		t.Errorf("output = %q, want %q", output, want)
+	}
+}
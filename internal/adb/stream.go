@@ -0,0 +1,156 @@
+package adb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxStreamLine 单行的长度上限，更长的行按此长度分块交付
+const maxStreamLine = 64 * 1024
+
+// DefaultStreamRetain ExecuteCommandStream 最多保留的输出字节数，超出时丢弃最早的行
+const DefaultStreamRetain = 1 << 20
+
+// stderrTailSize 为判断 adb 自身是否失败而保留的 stderr 末尾字节数
+const stderrTailSize = 4096
+
+// StreamLine 流式命令的一行输出
+type StreamLine struct {
+	Text   string // 不含行尾换行符，已按设备的编码设置转换
+	Stderr bool   // 来自 stderr；设备不支持 shell_v2 时 stderr 混在 stdout 中
+}
+
+// StreamCommand 执行命令，每行输出一到达就调用 onLine，命令结束后返回其退出码
+// 适用于 top、logcat、ping 等长时间运行的命令，需要中途结束时使用 StreamCommandContext
+func (m *ADBManager) StreamCommand(serial, command string, onLine func(line StreamLine)) (int, error) {
+	return m.StreamCommandContext(context.Background(), serial, command, onLine)
+}
+
+// StreamCommandContext 可通过 ctx 取消的 StreamCommand，取消时结束设备上的命令
+// 命令按设备的命令包装配置处理；onLine 在读取输出的协程中依次调用（不会并发），不应长时间阻塞
+// 命令以非零状态退出不视为错误；设备不支持 shell_v2 且不经过本地 adb 时得不到退出码，总为 0
+// 并发上限只约束启动命令，运行期间不占用名额，否则 logcat 这类不会自行结束的命令会一直占着名额
+func (m *ADBManager) StreamCommandContext(ctx context.Context, serial, command string, onLine func(line StreamLine)) (int, error) {
+	args := deviceArgs(serial, "shell", m.wrapCommand(serial, command))
+	release, err := m.acquire(ctx, args)
+	if err != nil {
+		return -1, err
+	}
+	start := time.Now()
+	proc, err := m.runner.Start(ctx, args...)
+	release()
+	if err != nil {
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			err = ctxErr
+		}
+		m.audit(args, start, -1, err)
+		return -1, err
+	}
+	proc.Stdin().Close()
+
+	// 协议连接不会随 ctx 自动断开，取消时主动结束
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			proc.Kill()
+		case <-stop:
+		}
+	}()
+
+	var mu sync.Mutex
+	var stderrTail string
+	deliver := func(line StreamLine) {
+		mu.Lock()
+		defer mu.Unlock()
+		if line.Stderr {
+			stderrTail += line.Text + "\n"
+			if len(stderrTail) > stderrTailSize {
+				stderrTail = stderrTail[len(stderrTail)-stderrTailSize:]
+			}
+		}
+		if onLine != nil {
+			onLine(line)
+		}
+	}
+
+	enc := m.outputEncoding(serial)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		readLines(proc.Stdout(), false, enc, deliver)
+	}()
+	go func() {
+		defer wg.Done()
+		readLines(proc.Stderr(), true, enc, deliver)
+	}()
+	wg.Wait()
+	err = proc.Wait()
+	close(stop)
+
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		m.audit(args, start, -1, ctxErr)
+		return -1, ctxErr
+	}
+	if err != nil {
+		// 退出码以外的错误，或 adb 自身报错（设备离线、未授权等）
+		cmdErr := newCommandError(args, []byte(stderrTail), err)
+		if cmdErr.ExitCode < 0 || cmdErr.Kind != nil {
+			m.audit(args, start, cmdErr.ExitCode, cmdErr)
+			return -1, cmdErr
+		}
+		m.audit(args, start, cmdErr.ExitCode, nil)
+		return cmdErr.ExitCode, nil
+	}
+	m.audit(args, start, 0, nil)
+	return 0, nil
+}
+
+// readLines 按行读取输出并按 enc 转换后交付，超过 maxStreamLine 的行分块交付，结尾没有换行的部分作为最后一行
+func readLines(r io.Reader, stderr bool, enc Encoding, deliver func(line StreamLine)) {
+	reader := bufio.NewReaderSize(r, maxStreamLine)
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if len(chunk) > 0 {
+			text := bytes.TrimSuffix(bytes.TrimSuffix(chunk, []byte("\n")), []byte("\r"))
+			deliver(StreamLine{Text: enc.Decode(text), Stderr: stderr})
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// tailBuffer 只保留最后 max 字节（按整行丢弃）的输出
+type tailBuffer struct {
+	lines []string
+	size  int
+	max   int
+}
+
+func (b *tailBuffer) add(line string) {
+	b.lines = append(b.lines, line)
+	b.size += len(line) + 1
+	drop := 0
+	for b.size > b.max && drop < len(b.lines)-1 {
+		b.size -= len(b.lines[drop]) + 1
+		drop++
+	}
+	b.lines = b.lines[drop:]
+}
+
+func (b *tailBuffer) String() string {
+	if len(b.lines) == 0 {
+		return ""
+	}
+	return strings.Join(b.lines, "\n") + "\n"
+}
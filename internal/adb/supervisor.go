@@ -0,0 +1,353 @@
+package adb
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ConnState 无线设备的连接状态
+type ConnState int
+
+const (
+	ConnConnected  ConnState = iota // 在线
+	ConnWaiting                     // 已断开，等待下次重连
+	ConnConnecting                  // 正在执行 adb connect
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case ConnConnected:
+		return "在线"
+	case ConnWaiting:
+		return "等待重连"
+	case ConnConnecting:
+		return "重连中"
+	}
+	return fmt.Sprintf("ConnState(%d)", int(s))
+}
+
+// ConnStats 无线设备的连接状态与统计
+type ConnStats struct {
+	Target         string // IP:PORT
+	State          ConnState
+	FirstSeen      time.Time     // 第一次连接成功的时间
+	ConnectedSince time.Time     // 本次上线的时间，未连接时为零值
+	TotalUptime    time.Duration // 之前各次在线的累计时长，不含本次
+	Flaps          int           // 在线后又断开的次数
+	Attempts       int           // 本次断开后已尝试重连的次数
+	NextRetry      time.Time     // 下次重连的时间，仅 ConnWaiting 时有效
+	LastError      error         // 最近一次重连失败的原因
+}
+
+// Uptime 本次在线的时长，未连接时为 0
+func (s ConnStats) Uptime() time.Duration {
+	if s.State != ConnConnected || s.ConnectedSince.IsZero() {
+		return 0
+	}
+	return time.Since(s.ConnectedSince)
+}
+
+// supervisedTarget 记住的无线设备
+type supervisedTarget struct {
+	stats  ConnStats
+	cancel context.CancelFunc // 正在运行的重连协程，没有时为 nil
+}
+
+// Supervisor 无线设备自动重连
+// 记住每个连接成功过的 IP:PORT，断开后以指数退避（带随机抖动）重试 adb connect；
+// 记住的设备在 ListDevices 中保留为 offline，只有用户移除或断开时才会被忘记
+// 记住的设备只保存在内存中，按 IP:PORT 而不是设备身份记录：程序重启后需要设备重新上线一次才会再次记住，
+// 设备换了 IP 或端口（例如 DHCP 重新分配、无线调试重启）后也会被当作新设备，旧地址继续重连直到被移除
+type Supervisor struct {
+	MinBackoff     time.Duration // 首次重连前的等待时间，之后每次加倍
+	MaxBackoff     time.Duration // 重连等待时间上限
+	ConnectTimeout time.Duration // 单次 adb connect 的超时时间
+
+	adbMgr *ADBManager
+	ctx    context.Context
+	stop   context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu          sync.Mutex
+	targets     map[string]*supervisedTarget
+	subscribers map[int]func(ConnStats)
+	nextID      int
+}
+
+// NewSupervisor 创建无线设备自动重连管理器，调用 Watch 后开始工作
+func NewSupervisor(adbMgr *ADBManager) *Supervisor {
+	ctx, stop := context.WithCancel(context.Background())
+	s := &Supervisor{
+		MinBackoff:     2 * time.Second,
+		MaxBackoff:     5 * time.Minute,
+		ConnectTimeout: 10 * time.Second,
+		adbMgr:         adbMgr,
+		ctx:            ctx,
+		stop:           stop,
+		targets:        make(map[string]*supervisedTarget),
+		subscribers:    make(map[int]func(ConnStats)),
+	}
+
+	adbMgr.deviceCacheLock.Lock()
+	adbMgr.supervisor = s
+	adbMgr.deviceCacheLock.Unlock()
+	return s
+}
+
+// isReconnectTarget 是否为可以通过 adb connect 重连的地址
+// mDNS 发现的无线调试设备由 adb server 自动重连，不在此处理
+func isReconnectTarget(serial string) bool {
+	if transportKindOf(serial, "") != TransportTCP {
+		return false
+	}
+	_, _, err := net.SplitHostPort(serial)
+	return err == nil
+}
+
+// Watch 订阅设备事件，记住上线的无线设备并在其断开后自动重连，返回取消订阅的函数
+func (s *Supervisor) Watch(watcher *DeviceWatcher) func() {
+	return watcher.Subscribe(func(event DeviceEvent) {
+		serial := event.Device.Serial
+		if !isReconnectTarget(serial) {
+			return
+		}
+		switch {
+		case event.Type != DeviceDisconnected && event.Device.Status == "device":
+			s.markConnected(serial)
+		case event.Type == DeviceDisconnected || event.Device.Status == "offline":
+			s.markDisconnected(serial)
+		}
+	})
+}
+
+// Stop 停止所有重连并等待后台协程退出
+func (s *Supervisor) Stop() {
+	s.stop()
+	s.wg.Wait()
+}
+
+// Subscribe 订阅连接状态变化，返回取消订阅的函数
+// 回调在重连协程或设备监视器的协程中调用，不应长时间阻塞
+func (s *Supervisor) Subscribe(callback func(stats ConnStats)) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextID
+	s.nextID++
+	s.subscribers[id] = callback
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subscribers, id)
+	}
+}
+
+// publish 通知订阅者，调用方不能持有锁
+func (s *Supervisor) publish(stats ConnStats) {
+	s.mu.Lock()
+	callbacks := make([]func(ConnStats), 0, len(s.subscribers))
+	for _, callback := range s.subscribers {
+		callbacks = append(callbacks, callback)
+	}
+	s.mu.Unlock()
+
+	for _, callback := range callbacks {
+		callback(stats)
+	}
+}
+
+// Tracks 是否记住了该设备
+func (s *Supervisor) Tracks(serial string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, exists := s.targets[serial]
+	return exists
+}
+
+// Stats 返回所有记住的无线设备的连接统计
+func (s *Supervisor) Stats() []ConnStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make([]ConnStats, 0, len(s.targets))
+	for _, target := range s.targets {
+		stats = append(stats, target.stats)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Target < stats[j].Target })
+	return stats
+}
+
+// StatsFor 返回指定设备的连接统计，未记住该设备时返回 false
+func (s *Supervisor) StatsFor(serial string) (ConnStats, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if target, exists := s.targets[serial]; exists {
+		return target.stats, true
+	}
+	return ConnStats{}, false
+}
+
+// Forget 忘记设备并停止重连，用户移除或主动断开设备时调用
+func (s *Supervisor) Forget(serial string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	target, exists := s.targets[serial]
+	if !exists {
+		return
+	}
+	if target.cancel != nil {
+		target.cancel()
+	}
+	delete(s.targets, serial)
+	s.adbMgr.log.Info("不再自动重连", "device", serial)
+}
+
+// markConnected 设备上线，第一次上线时开始记住该设备
+func (s *Supervisor) markConnected(serial string) {
+	s.mu.Lock()
+	target, exists := s.targets[serial]
+	if !exists {
+		target = &supervisedTarget{stats: ConnStats{Target: serial, State: ConnWaiting, FirstSeen: time.Now()}}
+		s.targets[serial] = target
+	}
+	if target.cancel != nil {
+		target.cancel()
+		target.cancel = nil
+	}
+	if target.stats.State == ConnConnected {
+		s.mu.Unlock()
+		return
+	}
+	target.stats.State = ConnConnected
+	target.stats.ConnectedSince = time.Now()
+	target.stats.Attempts = 0
+	target.stats.NextRetry = time.Time{}
+	target.stats.LastError = nil
+	stats := target.stats
+	s.mu.Unlock()
+
+	s.adbMgr.log.Info("无线设备在线", "device", serial)
+	s.publish(stats)
+}
+
+// markDisconnected 记住的设备断开，开始自动重连
+func (s *Supervisor) markDisconnected(serial string) {
+	s.mu.Lock()
+	target, exists := s.targets[serial]
+	if !exists || s.ctx.Err() != nil {
+		s.mu.Unlock()
+		return
+	}
+	if target.stats.State == ConnConnected {
+		target.stats.Flaps++
+		target.stats.TotalUptime += time.Since(target.stats.ConnectedSince)
+		target.stats.ConnectedSince = time.Time{}
+		target.stats.State = ConnWaiting
+	}
+	if target.cancel != nil {
+		// 已在重连
+		s.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	target.cancel = cancel
+	stats := target.stats
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	s.adbMgr.log.Warn("无线设备断开，开始自动重连", "device", serial, "flaps", stats.Flaps)
+	s.publish(stats)
+	go s.reconnect(ctx, serial)
+}
+
+// backoff 第 attempt 次重连前的等待时间：指数增长到上限，再在后一半区间内随机抖动，避免多台设备同时重连
+func (s *Supervisor) backoff(attempt int) time.Duration {
+	delay := s.MaxBackoff
+	if attempt < 30 && s.MinBackoff<<attempt < s.MaxBackoff {
+		delay = s.MinBackoff << attempt
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int64N(int64(half)+1))
+}
+
+// update 修改设备的统计并通知订阅者，设备已被忘记时返回 false
+func (s *Supervisor) update(serial string, change func(stats *ConnStats)) bool {
+	s.mu.Lock()
+	target, exists := s.targets[serial]
+	if !exists {
+		s.mu.Unlock()
+		return false
+	}
+	change(&target.stats)
+	stats := target.stats
+	s.mu.Unlock()
+
+	s.publish(stats)
+	return true
+}
+
+// reconnect 按退避间隔重试 adb connect，直到设备上线、被忘记或 Supervisor 停止
+func (s *Supervisor) reconnect(ctx context.Context, serial string) {
+	defer s.wg.Done()
+
+	for attempt := 0; ; attempt++ {
+		delay := s.backoff(attempt)
+		if !s.update(serial, func(stats *ConnStats) {
+			stats.State = ConnWaiting
+			stats.NextRetry = time.Now().Add(delay)
+		}) {
+			return
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if !s.update(serial, func(stats *ConnStats) {
+			stats.State = ConnConnecting
+			stats.Attempts = attempt + 1
+		}) {
+			return
+		}
+
+		connectCtx, cancel := context.WithTimeout(ctx, s.ConnectTimeout)
+		err := s.adbMgr.reconnectTarget(connectCtx, serial)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			s.markConnected(serial)
+			return
+		}
+		s.update(serial, func(stats *ConnStats) { stats.LastError = err })
+	}
+}
+
+// reconnectTarget 重新连接无线设备
+// Wi-Fi 中断后 adb server 可能仍保留处于 offline 的旧连接，此时 connect 只会返回 already connected，需要先断开
+func (m *ADBManager) reconnectTarget(ctx context.Context, address string) error {
+	m.run(ctx, "disconnect", address)
+	m.forgetFeatures(address)
+	return m.ConnectContext(ctx, address)
+}
+
+// forgetSupervised 用户移除或断开设备时停止自动重连
+func (m *ADBManager) forgetSupervised(serial string) {
+	m.deviceCacheLock.RLock()
+	supervisor := m.supervisor
+	m.deviceCacheLock.RUnlock()
+	if supervisor != nil {
+		supervisor.Forget(serial)
+	}
+}
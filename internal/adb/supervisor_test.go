@@ -0,0 +1,166 @@
+package adb
+
+import (
+	"testing"
+	"time"
+)
+
+const supervisedAddr = "192.168.1.5:5555"
+
+// newTestSupervisor 创建使用脚本化执行器、退避时间很短的 Supervisor
+func newTestSupervisor(t *testing.T, runner *ScriptedRunner) (*Supervisor, <-chan ConnStats) {
+	t.Helper()
+	s := NewSupervisor(newTestManager(runner))
+	s.MinBackoff = time.Millisecond
+	s.MaxBackoff = 4 * time.Millisecond
+	s.ConnectTimeout = time.Second
+	t.Cleanup(s.Stop)
+
+	updates := make(chan ConnStats, 256)
+	s.Subscribe(func(stats ConnStats) { updates <- stats })
+	return s, updates
+}
+
+// waitConnStats 等待满足条件的状态通知，返回期间收到的所有通知
+func waitConnStats(t *testing.T, updates <-chan ConnStats, ok func(ConnStats) bool) []ConnStats {
+	t.Helper()
+	var seen []ConnStats
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case stats := <-updates:
+			seen = append(seen, stats)
+			if ok(stats) {
+				return seen
+			}
+		case <-deadline:
+			t.Fatalf("timed out, updates = %+v", seen)
+			return nil
+		}
+	}
+}
+
+func TestSupervisorBackoff(t *testing.T) {
+	s := NewSupervisor(newTestManager(NewScriptedRunner()))
+	defer s.Stop()
+	s.MinBackoff = time.Second
+	s.MaxBackoff = time.Minute
+
+	for attempt := range 64 {
+		base := min(time.Second<<min(attempt, 30), time.Minute)
+		for range 50 {
+			// 指数增长到上限，抖动只在后一半区间内
+			if delay := s.backoff(attempt); delay < base/2 || delay > base {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", attempt, delay, base/2, base)
+			}
+		}
+	}
+}
+
+func TestSupervisorReconnect(t *testing.T) {
+	runner := NewScriptedRunner().
+		On("disconnect "+supervisedAddr, ScriptedResponse{Stdout: "disconnected " + supervisedAddr + "\n"}).
+		On("connect "+supervisedAddr,
+			ScriptedResponse{Stdout: "failed to connect to '" + supervisedAddr + "': Connection refused\n"},
+			ScriptedResponse{Stdout: "connected to " + supervisedAddr + "\n"})
+	s, updates := newTestSupervisor(t, runner)
+
+	// 未记住的设备断开时不重连
+	s.markDisconnected(supervisedAddr)
+	if s.Tracks(supervisedAddr) {
+		t.Fatal("supervisor tracks a device that was never connected")
+	}
+
+	s.markConnected(supervisedAddr)
+	<-updates
+	stats, ok := s.StatsFor(supervisedAddr)
+	if !ok || stats.State != ConnConnected || stats.FirstSeen.IsZero() || stats.ConnectedSince.IsZero() {
+		t.Fatalf("stats after connect = %+v, %v", stats, ok)
+	}
+	firstSeen := stats.FirstSeen
+	time.Sleep(20 * time.Millisecond)
+
+	// 断开后累计在线时长，第一次重连失败，第二次成功
+	s.markDisconnected(supervisedAddr)
+	seen := waitConnStats(t, updates, func(stats ConnStats) bool {
+		return stats.State == ConnConnected
+	})
+	if first := seen[0]; first.State != ConnWaiting || first.Flaps != 1 || first.TotalUptime < 20*time.Millisecond || !first.ConnectedSince.IsZero() {
+		t.Fatalf("first update = %+v, want waiting after 1 flap", first)
+	}
+	var failed, retried bool
+	for _, stats := range seen {
+		failed = failed || stats.LastError != nil
+		retried = retried || (stats.State == ConnConnecting && stats.Attempts == 2)
+	}
+	if !failed || !retried {
+		t.Errorf("updates = %+v, want a failed first attempt and a second attempt", seen)
+	}
+
+	stats, _ = s.StatsFor(supervisedAddr)
+	if stats.Flaps != 1 || stats.Attempts != 0 || stats.LastError != nil || !stats.FirstSeen.Equal(firstSeen) || stats.Uptime() < 0 {
+		t.Errorf("stats after reconnect = %+v", stats)
+	}
+	uptime := stats.TotalUptime
+
+	// 再次断开时在之前的累计时长上继续累加
+	time.Sleep(10 * time.Millisecond)
+	s.markDisconnected(supervisedAddr)
+	waitConnStats(t, updates, func(stats ConnStats) bool {
+		return stats.State == ConnConnected
+	})
+	stats, _ = s.StatsFor(supervisedAddr)
+	if stats.Flaps != 2 || stats.TotalUptime < uptime+10*time.Millisecond {
+		t.Errorf("stats after second flap = %+v, want 2 flaps and uptime above %v", stats, uptime+10*time.Millisecond)
+	}
+}
+
+func TestSupervisorForgetStopsReconnect(t *testing.T) {
+	runner := NewScriptedRunner().
+		On("disconnect "+supervisedAddr, ScriptedResponse{}).
+		On("connect "+supervisedAddr, ScriptedResponse{Stdout: "failed to connect to '" + supervisedAddr + "': No route to host\n"})
+	s, updates := newTestSupervisor(t, runner)
+
+	s.markConnected(supervisedAddr)
+	s.markDisconnected(supervisedAddr)
+	waitConnStats(t, updates, func(stats ConnStats) bool {
+		return stats.Attempts >= 3
+	})
+
+	s.Forget(supervisedAddr)
+	if s.Tracks(supervisedAddr) || len(s.Stats()) != 0 {
+		t.Fatalf("supervisor still tracks %s after Forget", supervisedAddr)
+	}
+
+	// 正在进行的一次重连结束后不再发起新的 adb connect
+	time.Sleep(50 * time.Millisecond)
+	calls := len(runner.Calls())
+	time.Sleep(50 * time.Millisecond)
+	if n := len(runner.Calls()); n != calls {
+		t.Fatalf("adb was called %d more times after Forget", n-calls)
+	}
+
+	// 被忘记的设备断开时不再重连
+	s.markDisconnected(supervisedAddr)
+	if s.Tracks(supervisedAddr) {
+		t.Fatal("Forget did not stop tracking")
+	}
+}
+
+func TestIsReconnectTarget(t *testing.T) {
+	tests := []struct {
+		serial string
+		want   bool
+	}{
+		{"192.168.1.5:5555", true},
+		{"[fe80::1]:5555", true},
+		{"emulator-5554", false},
+		{"R58M123ABC", false},
+		{"adb-R58M123ABC-abcdef._adb-tls-connect._tcp", false},
+	}
+	for _, tt := range tests {
+		if got := isReconnectTarget(tt.serial); got != tt.want {
+			t.Errorf("isReconnectTarget(%q) = %v, want %v", tt.serial, got, tt.want)
+		}
+	}
+}
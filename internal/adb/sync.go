@@ -0,0 +1,380 @@
+package adb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// syncMaxChunk sync 协议单个 DATA 包的最大长度
+const syncMaxChunk = 64 * 1024
+
+// 设备在 features 中声明这两项时支持 64 位文件大小的 STAT_V2 与 LIST_V2 请求
+const (
+	FeatureStatV2 = "stat_v2"
+	FeatureLsV2   = "ls_v2"
+)
+
+// SyncConn sync 服务连接，协议格式为 4 字节命令 + 4 字节小端长度 + 数据
+type SyncConn struct {
+	rw     io.ReadWriteCloser
+	statV2 bool // Stat 使用 LST2 请求
+	lsV2   bool // List 使用 LIS2 请求
+}
+
+// NewSyncConn 基于已打开的 sync: 服务连接创建 SyncConn
+// features 为设备声明的特性，未声明 stat_v2 / ls_v2 时使用只有 32 位文件大小的旧版请求
+func NewSyncConn(rw io.ReadWriteCloser, features ...string) *SyncConn {
+	c := &SyncConn{rw: rw}
+	for _, feature := range features {
+		switch feature {
+		case FeatureStatV2:
+			c.statV2 = true
+		case FeatureLsV2:
+			c.lsV2 = true
+		}
+	}
+	return c
+}
+
+// SyncStat 远程文件状态
+type SyncStat struct {
+	Mode    os.FileMode
+	Size    uint64
+	ModTime time.Time
+
+	size32 bool // Size 来自旧版请求的 32 位字段，4 GiB 以上的文件只有低 32 位
+}
+
+// Exists 文件是否存在（STAT 对不存在的文件返回全零）
+func (s SyncStat) Exists() bool {
+	return s.Mode != 0 || s.Size != 0 || !s.ModTime.Equal(time.Unix(0, 0))
+}
+
+// sizeMatches 判断 n 是否与文件大小一致，Size 只有低 32 位时只比较低 32 位
+func (s SyncStat) sizeMatches(n int64) bool {
+	if s.size32 {
+		return uint32(n) == uint32(s.Size)
+	}
+	return uint64(n) == s.Size
+}
+
+// IsDir 是否为目录
+func (s SyncStat) IsDir() bool {
+	return s.Mode.IsDir()
+}
+
+// SyncDirEntry LIST 返回的目录项
+type SyncDirEntry struct {
+	Name string
+	SyncStat
+}
+
+// sendRequest 发送 sync 请求
+func (c *SyncConn) sendRequest(id string, payload []byte) error {
+	header := make([]byte, 8)
+	copy(header, id)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(payload)))
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	_, err := c.rw.Write(payload)
+	return err
+}
+
+// readHeader 读取 sync 应答头
+func (c *SyncConn) readHeader() (string, uint32, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(c.rw, header); err != nil {
+		return "", 0, err
+	}
+	return string(header[:4]), binary.LittleEndian.Uint32(header[4:]), nil
+}
+
+// readFail 读取 FAIL 应答中的错误信息
+func (c *SyncConn) readFail(length uint32) error {
+	message := make([]byte, length)
+	if _, err := io.ReadFull(c.rw, message); err != nil {
+		return err
+	}
+	return fmt.Errorf("%s", message)
+}
+
+// Stat 获取远程文件状态
+func (c *SyncConn) Stat(path string) (SyncStat, error) {
+	if c.statV2 {
+		return c.statV2Request(path)
+	}
+	if err := c.sendRequest("STAT", []byte(path)); err != nil {
+		return SyncStat{}, err
+	}
+
+	// STAT 应答: "STAT" + mode + size + mtime，均为小端 uint32
+	reply := make([]byte, 16)
+	if _, err := io.ReadFull(c.rw, reply); err != nil {
+		return SyncStat{}, err
+	}
+	if string(reply[:4]) != "STAT" {
+		return SyncStat{}, fmt.Errorf("无效的 STAT 应答: %q", reply[:4])
+	}
+
+	return SyncStat{
+		Mode:    unixModeToFileMode(binary.LittleEndian.Uint32(reply[4:])),
+		Size:    uint64(binary.LittleEndian.Uint32(reply[8:])),
+		ModTime: time.Unix(int64(binary.LittleEndian.Uint32(reply[12:])), 0),
+		size32:  true,
+	}, nil
+}
+
+// statV2Request 通过 LST2 获取远程文件状态，与 STAT 一样不跟随符号链接
+func (c *SyncConn) statV2Request(path string) (SyncStat, error) {
+	if err := c.sendRequest("LST2", []byte(path)); err != nil {
+		return SyncStat{}, err
+	}
+
+	// LST2 应答: "LST2" + errno + 与 LIS2 目录项相同的 64 字节状态
+	reply := make([]byte, 8+syncStatV2Len)
+	if _, err := io.ReadFull(c.rw, reply); err != nil {
+		return SyncStat{}, err
+	}
+	if string(reply[:4]) != "LST2" {
+		return SyncStat{}, fmt.Errorf("无效的 LST2 应答: %q", reply[:4])
+	}
+	// 与 STAT 一致，lstat 失败（通常是文件不存在）时返回全零
+	if binary.LittleEndian.Uint32(reply[4:]) != 0 {
+		return SyncStat{}, nil
+	}
+	return parseStatV2(reply[8:]), nil
+}
+
+// syncStatV2Len STAT_V2 状态的长度：dev、ino 各 8 字节，mode、nlink、uid、gid 各 4 字节，size、atime、mtime、ctime 各 8 字节
+const syncStatV2Len = 64
+
+// parseStatV2 解析 STAT_V2 状态
+func parseStatV2(b []byte) SyncStat {
+	return SyncStat{
+		Mode:    unixModeToFileMode(binary.LittleEndian.Uint32(b[16:])),
+		Size:    binary.LittleEndian.Uint64(b[32:]),
+		ModTime: time.Unix(int64(binary.LittleEndian.Uint64(b[48:])), 0),
+	}
+}
+
+// List 列出远程目录的内容，不包含 "." 与 ".."
+func (c *SyncConn) List(path string) ([]SyncDirEntry, error) {
+	if c.lsV2 {
+		return c.listV2(path)
+	}
+	if err := c.sendRequest("LIST", []byte(path)); err != nil {
+		return nil, err
+	}
+
+	// 每项应答: "DENT" + mode + size + mtime + 名称长度 + 名称，以同样格式的 "DONE" 结束
+	entries := make([]SyncDirEntry, 0)
+	rest := make([]byte, 12)
+	for {
+		id, mode, err := c.readHeader()
+		if err != nil {
+			return nil, err
+		}
+		switch id {
+		case "DENT", "DONE":
+		case "FAIL":
+			return nil, c.readFail(mode)
+		default:
+			return nil, fmt.Errorf("无效的 LIST 应答: %q", id)
+		}
+		if _, err := io.ReadFull(c.rw, rest); err != nil {
+			return nil, err
+		}
+		if id == "DONE" {
+			return entries, nil
+		}
+
+		name := make([]byte, binary.LittleEndian.Uint32(rest[8:]))
+		if _, err := io.ReadFull(c.rw, name); err != nil {
+			return nil, err
+		}
+		if string(name) == "." || string(name) == ".." {
+			continue
+		}
+		entries = append(entries, SyncDirEntry{
+			Name: string(name),
+			SyncStat: SyncStat{
+				Mode:    unixModeToFileMode(mode),
+				Size:    uint64(binary.LittleEndian.Uint32(rest[0:])),
+				ModTime: time.Unix(int64(binary.LittleEndian.Uint32(rest[4:])), 0),
+				size32:  true,
+			},
+		})
+	}
+}
+
+// listV2 通过 LIS2 列出远程目录，文件大小为 64 位
+func (c *SyncConn) listV2(path string) ([]SyncDirEntry, error) {
+	if err := c.sendRequest("LIS2", []byte(path)); err != nil {
+		return nil, err
+	}
+
+	// 每项应答: "DNT2" + errno + STAT_V2 状态 + 名称长度 + 名称，以同样格式的 "DONE" 结束
+	entries := make([]SyncDirEntry, 0)
+	rest := make([]byte, syncStatV2Len+4)
+	for {
+		id, length, err := c.readHeader()
+		if err != nil {
+			return nil, err
+		}
+		switch id {
+		case "DNT2", "DONE":
+		case "FAIL":
+			return nil, c.readFail(length)
+		default:
+			return nil, fmt.Errorf("无效的 LIS2 应答: %q", id)
+		}
+		if _, err := io.ReadFull(c.rw, rest); err != nil {
+			return nil, err
+		}
+		if id == "DONE" {
+			return entries, nil
+		}
+
+		name := make([]byte, binary.LittleEndian.Uint32(rest[syncStatV2Len:]))
+		if _, err := io.ReadFull(c.rw, name); err != nil {
+			return nil, err
+		}
+		if string(name) == "." || string(name) == ".." {
+			continue
+		}
+		entries = append(entries, SyncDirEntry{Name: string(name), SyncStat: parseStatV2(rest)})
+	}
+}
+
+// Send 将 r 中的内容写入远程文件
+func (c *SyncConn) Send(path string, mode os.FileMode, mtime time.Time, r io.Reader) error {
+	target := fmt.Sprintf("%s,%d", path, fileModeToUnixMode(mode))
+	if err := c.sendRequest("SEND", []byte(target)); err != nil {
+		return err
+	}
+
+	buf := make([]byte, syncMaxChunk)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if werr := c.sendRequest("DATA", buf[:n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// DONE 的长度字段携带文件修改时间
+	done := make([]byte, 8)
+	copy(done, "DONE")
+	binary.LittleEndian.PutUint32(done[4:], uint32(mtime.Unix()))
+	if _, err := c.rw.Write(done); err != nil {
+		return err
+	}
+
+	id, length, err := c.readHeader()
+	if err != nil {
+		return err
+	}
+	switch id {
+	case "OKAY":
+		return nil
+	case "FAIL":
+		return c.readFail(length)
+	default:
+		return fmt.Errorf("无效的 SEND 应答: %q", id)
+	}
+}
+
+// Recv 读取远程文件内容写入 w
+func (c *SyncConn) Recv(path string, w io.Writer) error {
+	if err := c.sendRequest("RECV", []byte(path)); err != nil {
+		return err
+	}
+
+	for {
+		id, length, err := c.readHeader()
+		if err != nil {
+			return err
+		}
+		switch id {
+		case "DATA":
+			if _, err := io.CopyN(w, c.rw, int64(length)); err != nil {
+				return err
+			}
+		case "DONE":
+			return nil
+		case "FAIL":
+			return c.readFail(length)
+		default:
+			return fmt.Errorf("无效的 RECV 应答: %q", id)
+		}
+	}
+}
+
+// Close 结束 sync 会话并关闭连接
+func (c *SyncConn) Close() error {
+	c.sendRequest("QUIT", nil)
+	return c.rw.Close()
+}
+
+// unixModeToFileMode 将 st_mode 转换为 os.FileMode
+func unixModeToFileMode(mode uint32) os.FileMode {
+	fileMode := os.FileMode(mode & 0777)
+	switch mode & 0170000 {
+	case 0040000:
+		fileMode |= os.ModeDir
+	case 0120000:
+		fileMode |= os.ModeSymlink
+	case 0020000:
+		fileMode |= os.ModeDevice | os.ModeCharDevice
+	case 0060000:
+		fileMode |= os.ModeDevice
+	case 0010000:
+		fileMode |= os.ModeNamedPipe
+	case 0140000:
+		fileMode |= os.ModeSocket
+	}
+	if mode&04000 != 0 {
+		fileMode |= os.ModeSetuid
+	}
+	if mode&02000 != 0 {
+		fileMode |= os.ModeSetgid
+	}
+	if mode&01000 != 0 {
+		fileMode |= os.ModeSticky
+	}
+	return fileMode
+}
+
+// fileModeToUnixMode 将 os.FileMode 转换为 st_mode
+func fileModeToUnixMode(mode os.FileMode) uint32 {
+	unixMode := uint32(mode.Perm())
+	switch {
+	case mode.IsDir():
+		unixMode |= 0040000
+	case mode&os.ModeSymlink != 0:
+		unixMode |= 0120000
+	default:
+		unixMode |= 0100000
+	}
+	if mode&os.ModeSetuid != 0 {
+		unixMode |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		unixMode |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		unixMode |= 01000
+	}
+	return unixMode
+}
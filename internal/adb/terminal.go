@@ -0,0 +1,285 @@
+package adb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTerm 终端会话向设备声明的终端类型
+const DefaultTerm = "xterm-256color"
+
+// TerminalSession 设备上带伪终端的交互式 shell，cd、环境变量、su 会话与 vi、top 等交互程序都能正常使用
+// Read 读到终端的输出（含控制序列，stdout 与 stderr 已由伪终端合并），Write 写入键盘输入
+// 设备的编码设置为 GBK、Shift-JIS 或 Latin-1 时，输出转换为 UTF-8，输入转换为设备的编码
+type TerminalSession struct {
+	output    *io.PipeReader
+	reader    io.Reader // 按编码转换后的 output
+	enc       Encoding
+	write     func(data []byte) error
+	resize    func(cols, rows int) error // 为 nil 时不支持调整大小
+	kill      func() error
+	done      chan struct{}
+	closeOnce sync.Once
+	closed    atomic.Bool // 由 Close 结束
+	writeMu   sync.Mutex
+
+	exitCode int
+	err      error
+}
+
+// OpenTerminal 打开 cols 列 rows 行的终端会话
+// 设备支持 shell_v2 时使用其 pty 模式，可调整大小并取得退出码；否则使用旧的 shell 服务，
+// 执行器不能直接打开设备服务时改为运行 adb shell -tt，这两种情况下无法调整大小
+func (m *ADBManager) OpenTerminal(serial string, cols, rows int) (*TerminalSession, error) {
+	return m.OpenTerminalContext(context.Background(), serial, cols, rows)
+}
+
+// OpenTerminalContext 可通过 ctx 取消的 OpenTerminal，ctx 取消时结束会话
+// 并发上限只约束打开会话，会话期间不占用名额
+func (m *ADBManager) OpenTerminalContext(ctx context.Context, serial string, cols, rows int) (*TerminalSession, error) {
+	args := deviceArgs(serial, "shell")
+	release, err := m.acquire(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	session, protocol, err := m.openTerminal(ctx, serial, cols, rows)
+	release()
+	if err != nil {
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			err = ctxErr
+		} else {
+			err = newCommandError(args, []byte(errorOutput(err)), err)
+		}
+		m.audit(args, start, -1, err)
+		return nil, err
+	}
+	session.setEncoding(m.outputEncoding(serial))
+	m.log.Info("打开终端", "device", serial, "protocol", protocol)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			session.Close()
+		case <-session.done:
+		}
+	}()
+	go func() {
+		code, err := session.Wait()
+		m.log.Info("终端会话结束", "device", serial, "exit_code", code, "duration", time.Since(start).Round(time.Millisecond))
+		m.audit(args, start, code, err)
+	}()
+	return session, nil
+}
+
+// openTerminal 按设备与执行器的能力选择打开方式，返回会话与所用的协议
+func (m *ADBManager) openTerminal(ctx context.Context, serial string, cols, rows int) (*TerminalSession, string, error) {
+	opener, ok := m.runner.(ServiceOpener)
+	if ok {
+		if m.HasFeatureContext(ctx, serial, FeatureShellV2) {
+			conn, err := opener.OpenService(ctx, serial, "shell,v2,TERM="+DefaultTerm+",pty:")
+			if err == nil {
+				session := newShellV2Terminal(conn)
+				session.Resize(cols, rows)
+				return session, FeatureShellV2, nil
+			}
+			if !errors.Is(err, errUnsupported) && !isServerUnavailable(err) {
+				return nil, "", err
+			}
+		} else {
+			conn, err := opener.OpenService(ctx, serial, "shell:")
+			if err == nil {
+				return newConnTerminal(conn), "shell", nil
+			}
+			if !errors.Is(err, errUnsupported) && !isServerUnavailable(err) {
+				return nil, "", err
+			}
+		}
+	}
+
+	// 本地 adb 进程：-tt 使设备端在 stdin 不是终端时也分配伪终端
+	adbPath, ok := execPath(m.runner)
+	if !ok || m.router.IsDirect(serial) {
+		return nil, "", fmt.Errorf("当前命令执行器不支持交互式终端")
+	}
+	session, err := newExecTerminal(adbPath, deviceArgs(serial, "shell", "-t", "-t"))
+	if err != nil {
+		return nil, "", err
+	}
+	return session, "adb", nil
+}
+
+// newShellV2Terminal 基于 shell_v2 pty 模式连接的会话
+func newShellV2Terminal(conn io.ReadWriteCloser) *TerminalSession {
+	reader, writer := io.Pipe()
+	s := &TerminalSession{output: reader, kill: conn.Close, done: make(chan struct{})}
+	s.write = func(data []byte) error {
+		return writeShellPacket(conn, shellStdin, data)
+	}
+	s.resize = func(cols, rows int) error {
+		// 与 adb 客户端相同的格式：行x列,宽x高（像素）
+		size := fmt.Sprintf("%dx%d,%dx%d", rows, cols, 0, 0)
+		return writeShellPacket(conn, shellWindowSize, []byte(size))
+	}
+
+	go func() {
+		defer conn.Close()
+		for {
+			id, data, err := readShellPacket(conn)
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					err = io.ErrUnexpectedEOF // 没有收到退出码就断开
+				}
+				s.finish(-1, err)
+				writer.Close()
+				return
+			}
+			switch id {
+			case shellStdout, shellStderr:
+				writer.Write(data)
+			case shellExit:
+				code := 0
+				if len(data) > 0 {
+					code = int(data[0])
+				}
+				s.finish(code, nil)
+				writer.Close()
+				return
+			}
+		}
+	}()
+	return s
+}
+
+// newConnTerminal 基于旧 shell 服务连接的会话，得不到退出码
+func newConnTerminal(conn io.ReadWriteCloser) *TerminalSession {
+	reader, writer := io.Pipe()
+	s := &TerminalSession{output: reader, kill: conn.Close, done: make(chan struct{})}
+	s.write = func(data []byte) error {
+		_, err := conn.Write(data)
+		return err
+	}
+
+	go func() {
+		defer conn.Close()
+		_, err := io.Copy(writer, conn)
+		s.finish(-1, err)
+		writer.Close()
+	}()
+	return s
+}
+
+// newExecTerminal 运行本地 adb 进程的会话，stdout 与 stderr 合并输出
+func newExecTerminal(adbPath string, args []string) (*TerminalSession, error) {
+	cmd := exec.Command(adbPath, args...)
+	cmd.Env = append(os.Environ(), "TERM="+DefaultTerm)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	cmd.WaitDelay = 2 * time.Second
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	s := &TerminalSession{output: reader, done: make(chan struct{})}
+	s.write = func(data []byte) error {
+		_, err := stdin.Write(data)
+		return err
+	}
+	s.kill = cmd.Process.Kill
+
+	go func() {
+		err := cmd.Wait()
+		var exitErr *exec.ExitError
+		switch {
+		case err == nil:
+			s.finish(0, nil)
+		case errors.As(err, &exitErr):
+			s.finish(exitErr.ExitCode(), nil)
+		default:
+			s.finish(-1, err)
+		}
+		writer.Close()
+	}()
+	return s, nil
+}
+
+// setEncoding 设置终端输入输出的编码
+func (s *TerminalSession) setEncoding(enc Encoding) {
+	s.enc = enc
+	s.reader = enc.NewReader(s.output)
+}
+
+// finish 记录会话结果，只有第一次调用有效
+func (s *TerminalSession) finish(code int, err error) {
+	s.closeOnce.Do(func() {
+		if s.closed.Load() {
+			code, err = -1, nil // 主动关闭，断开连接导致的错误不算失败
+		}
+		s.exitCode, s.err = code, err
+		close(s.done)
+	})
+}
+
+// Read 读取终端输出，会话结束后返回 io.EOF
+func (s *TerminalSession) Read(p []byte) (int, error) {
+	if s.reader == nil {
+		return s.output.Read(p)
+	}
+	return s.reader.Read(p)
+}
+
+// Write 写入键盘输入
+func (s *TerminalSession) Write(p []byte) (int, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.write(s.enc.Encode(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// CanResize 会话是否支持调整终端大小（设备支持 shell_v2 时才支持）
+func (s *TerminalSession) CanResize() bool {
+	return s.resize != nil
+}
+
+// Resize 通知设备终端的新大小，不支持时忽略
+func (s *TerminalSession) Resize(cols, rows int) error {
+	if s.resize == nil || cols <= 0 || rows <= 0 {
+		return nil
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.resize(cols, rows)
+}
+
+// Close 结束会话
+func (s *TerminalSession) Close() error {
+	s.closed.Store(true)
+	err := s.kill()
+	s.output.Close() // 没有人读取输出时，转发输出的协程也能结束
+	return err
+}
+
+// Done 会话结束时关闭
+func (s *TerminalSession) Done() <-chan struct{} {
+	return s.done
+}
+
+// Wait 等待会话结束，返回 shell 的退出码；由 Close 结束或得不到退出码时为 -1
+func (s *TerminalSession) Wait() (int, error) {
+	<-s.done
+	return s.exitCode, s.err
+}
@@ -0,0 +1,415 @@
+package adb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TransferProgress 文件传输进度
+type TransferProgress struct {
+	Path        string        // 正在传输的远程文件
+	Transferred int64         // 已传输的字节数，续传时包含之前已下载的部分
+	Total       int64         // 文件总大小
+	Resumed     int64         // 续传的起点，未续传时为 0
+	BytesPerSec float64       // 本次传输的平均速度
+	Elapsed     time.Duration // 本次传输已用时间
+	Done        bool          // 传输已完成
+}
+
+// Percent 已完成的百分比
+func (p TransferProgress) Percent() float64 {
+	if p.Total <= 0 {
+		if p.Done {
+			return 100
+		}
+		return 0
+	}
+	return float64(p.Transferred) * 100 / float64(p.Total)
+}
+
+// ProgressFunc 传输进度回调，在传输所在的协程中调用，不应长时间阻塞
+type ProgressFunc func(progress TransferProgress)
+
+// progressInterval 两次进度回调的最小间隔
+const progressInterval = 200 * time.Millisecond
+
+// partSuffix 下载中的临时文件后缀，中断后保留，下次拉取同一文件时从断点继续
+const partSuffix = ".adbpart"
+
+// transferMeter 统计传输字节数并按间隔回调进度
+type transferMeter struct {
+	callback ProgressFunc
+	progress TransferProgress
+	start    time.Time
+	last     time.Time
+	size32   bool // 总大小只有低 32 位，收到的字节数超过它时改为未知
+}
+
+func newTransferMeter(remotePath string, total, resumed int64, callback ProgressFunc) *transferMeter {
+	m := &transferMeter{
+		callback: callback,
+		progress: TransferProgress{Path: remotePath, Transferred: resumed, Total: total, Resumed: resumed},
+		start:    time.Now(),
+	}
+	m.report()
+	return m
+}
+
+func (m *transferMeter) add(n int) {
+	m.progress.Transferred += int64(n)
+	if m.size32 && m.progress.Total > 0 && m.progress.Transferred > m.progress.Total {
+		m.progress.Total = 0
+	}
+	if time.Since(m.last) >= progressInterval {
+		m.report()
+	}
+}
+
+func (m *transferMeter) report() {
+	m.last = time.Now()
+	m.progress.Elapsed = m.last.Sub(m.start)
+	if seconds := m.progress.Elapsed.Seconds(); seconds > 0 {
+		m.progress.BytesPerSec = float64(m.progress.Transferred-m.progress.Resumed) / seconds
+	}
+	if m.callback != nil {
+		m.callback(m.progress)
+	}
+}
+
+func (m *transferMeter) finish() {
+	m.progress.Done = true
+	m.report()
+}
+
+func (m *transferMeter) transferred() int64 {
+	return m.progress.Transferred
+}
+
+func (m *transferMeter) reader(r io.Reader) io.Reader {
+	return &meterReader{r: r, meter: m}
+}
+
+func (m *transferMeter) writer(w io.Writer) io.Writer {
+	return &meterWriter{w: w, meter: m}
+}
+
+type meterReader struct {
+	r     io.Reader
+	meter *transferMeter
+}
+
+func (r *meterReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.meter.add(n)
+	return n, err
+}
+
+type meterWriter struct {
+	w     io.Writer
+	meter *transferMeter
+}
+
+func (w *meterWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.meter.add(n)
+	return n, err
+}
+
+// syncPushFile 通过 sync 服务推送单个文件，保留本地文件的权限与修改时间
+func syncPushFile(ctx context.Context, open serviceOpener, localPath, remotePath string, progress ProgressFunc) error {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return errUnsupported
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	conn, err := openSync(ctx, open, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// 目标为目录时使用本地文件名
+	if strings.HasSuffix(remotePath, "/") {
+		remotePath += filepath.Base(localPath)
+	} else if stat, err := conn.Stat(remotePath); err == nil && stat.IsDir() {
+		remotePath = path.Join(remotePath, filepath.Base(localPath))
+	}
+
+	meter := newTransferMeter(remotePath, info.Size(), 0, progress)
+	if err := conn.Send(remotePath, info.Mode(), info.ModTime(), meter.reader(file)); err != nil {
+		return err
+	}
+	meter.finish()
+	return nil
+}
+
+// syncPullFile 通过 sync 服务拉取单个文件，保留远程文件的权限与修改时间
+// 先写入 .adbpart 临时文件，完成后再改名；中断时保留已下载的部分，远程文件未变化时下次从断点继续
+// 设备不支持 stat_v2 时远程文件大小只有低 32 位，4 GiB 以上的文件只能按低 32 位核对收到的字节数
+func syncPullFile(ctx context.Context, log *slog.Logger, open serviceOpener, features []string, remotePath, localPath string, progress ProgressFunc) error {
+	conn, err := openSync(ctx, open, features)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	stat, err := conn.Stat(remotePath)
+	if err != nil {
+		return err
+	}
+	if !stat.Exists() {
+		return fmt.Errorf("remote object '%s' does not exist", remotePath)
+	}
+	if stat.IsDir() {
+		return errUnsupported
+	}
+
+	if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+		localPath = filepath.Join(localPath, path.Base(remotePath))
+	}
+
+	total := int64(stat.Size)
+	partPath := localPath + partSuffix
+	offset := resumeOffset(partPath, stat)
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_WRONLY | os.O_APPEND
+		log.Info("从断点继续拉取", "path", remotePath, "offset", offset)
+	}
+	file, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return err
+	}
+
+	meter := newTransferMeter(remotePath, total, offset, progress)
+	meter.size32 = stat.size32
+	if offset > 0 {
+		err = pullRange(ctx, open, remotePath, offset, meter.writer(file))
+		if err == nil && !stat.sizeMatches(meter.transferred()) {
+			// 设备不支持 exec 服务或 tail 时读不到数据，丢弃已下载的部分重新拉取
+			log.Warn("断点续传失败，重新拉取", "path", remotePath)
+			if err = file.Truncate(0); err == nil {
+				meter = newTransferMeter(remotePath, total, 0, progress)
+				meter.size32 = stat.size32
+				err = conn.Recv(remotePath, meter.writer(file))
+			}
+		}
+	} else {
+		err = conn.Recv(remotePath, meter.writer(file))
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil && !stat.sizeMatches(meter.transferred()) {
+		os.Remove(partPath)
+		return fmt.Errorf("文件大小不一致: 应为 %d 字节，实际收到 %d 字节", total, meter.transferred())
+	}
+	if err != nil {
+		if meter.transferred() > 0 {
+			// 以远程文件的修改时间标记断点对应的文件版本
+			os.Chtimes(partPath, stat.ModTime, stat.ModTime)
+		} else {
+			os.Remove(partPath)
+		}
+		return err
+	}
+
+	if err := os.Rename(partPath, localPath); err != nil {
+		return err
+	}
+	// 保证本机用户仍可读写（设备上的文件可能属于其他用户）
+	os.Chmod(localPath, stat.Mode.Perm()|0600)
+	os.Chtimes(localPath, stat.ModTime, stat.ModTime)
+	meter.finish()
+	return nil
+}
+
+// resumeOffset 返回可以继续拉取的位置，临时文件不存在或远程文件已变化时为 0
+// Size 只有低 32 位时小于真实大小，临时文件不小于它时重新拉取
+func resumeOffset(partPath string, stat SyncStat) int64 {
+	total := int64(stat.Size)
+	info, err := os.Stat(partPath)
+	if err != nil || info.Size() == 0 || info.Size() >= total {
+		return 0
+	}
+	if info.ModTime().Unix() != stat.ModTime.Unix() {
+		return 0
+	}
+	return info.Size()
+}
+
+// pullRange 通过 exec 服务读取远程文件 offset 之后的内容，sync 协议本身不支持从中间开始读取
+// exec 服务的 stderr 与 stdout 混在一起，错误信息需丢弃以免写入文件
+func pullRange(ctx context.Context, open serviceOpener, remotePath string, offset int64, w io.Writer) error {
+	command := ShellJoin("tail", "-c", "+"+strconv.FormatInt(offset+1, 10), remotePath) + " 2>/dev/null"
+	conn, err := open(ctx, "exec:"+command)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = io.Copy(w, conn)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// syncFeatures 设备声明的特性，用于选择 sync 请求的版本；无法查询时按不支持新版请求的旧设备处理
+// 在 transfer 持有并发许可期间调用，因此直接询问执行器，不经过 m.run
+func (m *ADBManager) syncFeatures(ctx context.Context, serial string) []string {
+	m.featureLock.Lock()
+	features, ok := m.features[serial]
+	m.featureLock.Unlock()
+	if ok {
+		return features
+	}
+	if lister, ok := m.runner.(featureLister); ok {
+		features, _ = lister.deviceFeatures(ctx, serial)
+	}
+	return features
+}
+
+// serviceOpener 返回打开指定设备服务的函数，执行器不支持时打开服务返回 errUnsupported
+func (m *ADBManager) serviceOpener(serial string) serviceOpener {
+	return func(ctx context.Context, service string) (io.ReadWriteCloser, error) {
+		if opener, ok := m.runner.(ServiceOpener); ok {
+			return opener.OpenService(ctx, serial, service)
+		}
+		return nil, errUnsupported
+	}
+}
+
+// transfer 通过设备服务执行 op，失败时返回 *CommandError，args 为等价的 adb 命令行参数
+// fallback 为 true 时，执行器无法直接打开设备服务（例如 adb server 未运行）则改为执行 adb 命令行
+func (m *ADBManager) transfer(ctx context.Context, serial string, args []string, fallback bool, op func(open serviceOpener) error) error {
+	args = deviceArgs(serial, args...)
+	release, err := m.acquire(ctx, args)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	err = op(m.serviceOpener(serial))
+	release()
+	if fallback && (errors.Is(err, errUnsupported) || isServerUnavailable(err)) {
+		_, err = m.run(ctx, args...)
+		return err
+	}
+	if err != nil {
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			err = ctxErr
+		} else {
+			err = newCommandError(args, []byte(errorOutput(err)), err)
+		}
+	}
+	m.audit(args, start, auditExitCode(err), err)
+	return err
+}
+
+// StatPath 通过 sync 服务获取远程文件状态，文件不存在时 Exists 为 false
+func (m *ADBManager) StatPath(serial, remotePath string) (SyncStat, error) {
+	return m.StatPathContext(context.Background(), serial, remotePath)
+}
+
+// StatPathContext 可通过 ctx 取消的 StatPath
+func (m *ADBManager) StatPathContext(ctx context.Context, serial, remotePath string) (SyncStat, error) {
+	var stat SyncStat
+	err := m.transfer(ctx, serial, []string{"stat", remotePath}, false, func(open serviceOpener) error {
+		conn, err := openSync(ctx, open, m.syncFeatures(ctx, serial))
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		stat, err = conn.Stat(remotePath)
+		return err
+	})
+	return stat, err
+}
+
+// ListDirectory 通过 sync 服务列出远程目录
+func (m *ADBManager) ListDirectory(serial, remotePath string) ([]SyncDirEntry, error) {
+	return m.ListDirectoryContext(context.Background(), serial, remotePath)
+}
+
+// ListDirectoryContext 可通过 ctx 取消的 ListDirectory
+func (m *ADBManager) ListDirectoryContext(ctx context.Context, serial, remotePath string) ([]SyncDirEntry, error) {
+	var entries []SyncDirEntry
+	err := m.transfer(ctx, serial, []string{"ls", remotePath}, false, func(open serviceOpener) error {
+		conn, err := openSync(ctx, open, m.syncFeatures(ctx, serial))
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		entries, err = conn.List(remotePath)
+		return err
+	})
+	return entries, err
+}
+
+// PushFileWithProgress 推送文件到设备，传输过程中通过 progress 报告进度（可为 nil）
+func (m *ADBManager) PushFileWithProgress(serial, localPath, remotePath string, progress ProgressFunc) error {
+	return m.PushFileWithProgressContext(context.Background(), serial, localPath, remotePath, progress)
+}
+
+// PushFileWithProgressContext 可通过 ctx 取消的 PushFileWithProgress
+// 无法使用 sync 协议时（adb server 未运行、推送目录）回退到 adb push，此时只在完成时报告一次进度
+func (m *ADBManager) PushFileWithProgressContext(ctx context.Context, serial, localPath, remotePath string, progress ProgressFunc) error {
+	synced := false
+	err := m.transfer(ctx, serial, []string{"push", localPath, remotePath}, true, func(open serviceOpener) error {
+		err := syncPushFile(ctx, open, localPath, remotePath, progress)
+		synced = err == nil
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("推送文件失败: %w", err)
+	}
+	if !synced && progress != nil {
+		progress(TransferProgress{Path: remotePath, Done: true})
+	}
+	return nil
+}
+
+// PullFileWithProgress 从设备拉取文件，传输过程中通过 progress 报告进度（可为 nil）
+// 上次拉取中断留下的 .adbpart 临时文件会被继续使用，只传输剩余部分
+func (m *ADBManager) PullFileWithProgress(serial, remotePath, localPath string, progress ProgressFunc) error {
+	return m.PullFileWithProgressContext(context.Background(), serial, remotePath, localPath, progress)
+}
+
+// PullFileWithProgressContext 可通过 ctx 取消的 PullFileWithProgress
+// 无法使用 sync 协议时（adb server 未运行、拉取目录）回退到 adb pull，此时只在完成时报告一次进度
+func (m *ADBManager) PullFileWithProgressContext(ctx context.Context, serial, remotePath, localPath string, progress ProgressFunc) error {
+	synced := false
+	err := m.transfer(ctx, serial, []string{"pull", remotePath, localPath}, true, func(open serviceOpener) error {
+		err := syncPullFile(ctx, m.log.With("device", serial), open, m.syncFeatures(ctx, serial), remotePath, localPath, progress)
+		synced = err == nil
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("拉取文件失败: %w", err)
+	}
+	if !synced && progress != nil {
+		progress(TransferProgress{Path: remotePath, Done: true})
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package adb
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPullFileResume(t *testing.T) {
+	srv, err := NewFakeServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.AddDevice("emulator-5554", "device", "")
+
+	data := bytes.Repeat([]byte("0123456789abcdef\r\n"), 8000)
+	modTime := time.Unix(1704163200, 0)
+	srv.SetFile("emulator-5554", "/sdcard/backup.bin", FakeFile{Data: data, Mode: 0644, ModTime: modTime})
+
+	// 上次中断留下的临时文件，修改时间与远程文件一致时从断点继续
+	localPath := filepath.Join(t.TempDir(), "backup.bin")
+	if err := os.WriteFile(localPath+partSuffix, data[:50000], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(localPath+partSuffix, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	m := NewADBManagerWithRunner(NewServerRunner(srv.Addr(), nil))
+	m.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	var last TransferProgress
+	err = m.PullFileWithProgress("emulator-5554", "/sdcard/backup.bin", localPath, func(p TransferProgress) { last = p })
+	if err != nil {
+		t.Fatalf("PullFileWithProgress: %v", err)
+	}
+	got, err := os.ReadFile(localPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("pulled %d bytes, want %d bytes identical to the remote file", len(got), len(data))
+	}
+	if !last.Done {
+		t.Errorf("last progress = %+v, want Done", last)
+	}
+	if _, err := os.Stat(localPath + partSuffix); !os.IsNotExist(err) {
+		t.Errorf("temporary file not removed: %v", err)
+	}
+
+	// 日志写入 SetLogger 设置的 logger，并带有设备序列号
+	output := logs.String()
+	for _, want := range []string{"从断点继续拉取", "component=adb", "device=emulator-5554", "offset=50000"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("log output missing %q:\n%s", want, output)
+		}
+	}
+}
+
+func TestPullFileResumeLargeFile(t *testing.T) {
+	srv, err := NewFakeServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.AddDevice("emulator-5554", "device", "")
+
+	// 超过 4 GiB 的文件，旧版 STAT 的 32 位大小只剩 100
+	const size = 4<<30 + 100
+	modTime := time.Unix(1704163200, 0)
+	srv.SetFile("emulator-5554", "/sdcard/big.img", FakeFile{Data: []byte("HEAD"), Size: size, ModTime: modTime})
+
+	// 以稀疏文件模拟只差最后 1000 字节的临时文件
+	localPath := filepath.Join(t.TempDir(), "big.img")
+	part, err := os.Create(localPath + partSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := part.Truncate(size - 1000); err != nil {
+		t.Fatal(err)
+	}
+	part.Close()
+	if err := os.Chtimes(localPath+partSuffix, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	m := newTestManager(NewServerRunner(srv.Addr(), nil))
+	var last TransferProgress
+	err = m.PullFileWithProgress("emulator-5554", "/sdcard/big.img", localPath, func(p TransferProgress) { last = p })
+	if err != nil {
+		t.Fatalf("PullFileWithProgress: %v", err)
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != size {
+		t.Errorf("pulled %d bytes, want %d", info.Size(), size)
+	}
+	want := TransferProgress{Path: "/sdcard/big.img", Transferred: size, Total: size, Resumed: size - 1000, Done: true}
+	if last.Path != want.Path || last.Transferred != want.Transferred || last.Total != want.Total || last.Resumed != want.Resumed || !last.Done {
+		t.Errorf("last progress = %+v, want %+v", last, want)
+	}
+
+	stat, err := m.StatPath("emulator-5554", "/sdcard/big.img")
+	if err != nil || stat.Size != size {
+		t.Errorf("StatPath = %+v, %v, want size %d", stat, err, size)
+	}
+	entries, err := m.ListDirectory("emulator-5554", "/sdcard")
+	if err != nil || len(entries) != 1 || entries[0].Size != size {
+		t.Errorf("ListDirectory = %+v, %v, want size %d", entries, err, size)
+	}
+}
+
+func TestSyncStatLegacySize(t *testing.T) {
+	srv, err := NewFakeServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.AddDevice("emulator-5554", "device", "")
+	srv.SetFeatures("emulator-5554", "shell_v2,cmd")
+	srv.SetFile("emulator-5554", "/sdcard/big.img", FakeFile{Size: 4<<30 + 100, Mode: 0644, ModTime: time.Unix(1704163200, 0)})
+
+	// 不支持 stat_v2 的设备只返回低 32 位，收到的字节数按低 32 位核对
+	m := newTestManager(NewServerRunner(srv.Addr(), nil))
+	stat, err := m.StatPath("emulator-5554", "/sdcard/big.img")
+	if err != nil {
+		t.Fatalf("StatPath: %v", err)
+	}
+	if stat.Size != 100 || !stat.size32 {
+		t.Fatalf("StatPath = %+v, want 32-bit size 100", stat)
+	}
+	for n, want := range map[int64]bool{4<<30 + 100: true, 100: true, 4 << 30: false, 4<<30 + 99: false} {
+		if got := stat.sizeMatches(n); got != want {
+			t.Errorf("sizeMatches(%d) = %v, want %v", n, got, want)
+		}
+	}
+
+	// 总大小被截断时，收到的字节数超过它后不再报告总大小
+	meter := newTransferMeter("/sdcard/big.img", int64(stat.Size), 0, nil)
+	meter.size32 = true
+	meter.add(101)
+	if meter.progress.Total != 0 {
+		t.Errorf("Total = %d after exceeding the 32-bit size, want 0 (unknown)", meter.progress.Total)
+	}
+}
@@ -0,0 +1,287 @@
+package adb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeviceEventType 设备事件类型
+type DeviceEventType int
+
+const (
+	DeviceConnected    DeviceEventType = iota // 新设备出现且可用
+	DeviceDisconnected                        // 设备从 adb server 中消失
+	DeviceStateChanged                        // 设备状态变化，例如 device -> offline
+	DeviceUnauthorized                        // 设备出现或变为未授权状态，需要在设备上确认调试授权
+)
+
+func (t DeviceEventType) String() string {
+	switch t {
+	case DeviceConnected:
+		return "已连接"
+	case DeviceDisconnected:
+		return "已断开"
+	case DeviceStateChanged:
+		return "状态变化"
+	case DeviceUnauthorized:
+		return "未授权"
+	}
+	return fmt.Sprintf("DeviceEventType(%d)", int(t))
+}
+
+// DeviceEvent 设备事件
+type DeviceEvent struct {
+	Type     DeviceEventType
+	Device   Device // 事件发生后的设备信息，断开时为最后一次看到的信息，状态为 offline
+	OldState string // 变化前的状态，新出现的设备为空
+}
+
+// DeviceWatcher 通过 track-devices 长连接跟踪设备变化，不再需要轮询 adb devices
+// adb server 重启或连接中断后自动重连，重连后通过与上次的设备列表对比补发期间的变化
+// 不经过 adb server 的直连设备在 adbd 连接建立或断开时同样产生事件
+type DeviceWatcher struct {
+	RetryInterval    time.Duration // 首次重连的等待时间，之后每次加倍
+	MaxRetryInterval time.Duration // 重连等待时间上限
+
+	adbMgr *ADBManager
+
+	mu          sync.Mutex
+	subscribers map[int]func(DeviceEvent)
+	nextID      int
+	devices     map[string]Device // 最近一次推送的设备列表
+	cancel      context.CancelFunc
+	done        chan struct{}
+
+	updateMu   sync.Mutex // 保证两个来源的更新按顺序产生事件
+	serverList string     // adb server 最近一次推送的设备列表
+}
+
+// NewDeviceWatcher 创建设备监视器，调用 Start 后开始跟踪
+func NewDeviceWatcher(adbMgr *ADBManager) *DeviceWatcher {
+	return &DeviceWatcher{
+		RetryInterval:    time.Second,
+		MaxRetryInterval: 10 * time.Second,
+		adbMgr:           adbMgr,
+		subscribers:      make(map[int]func(DeviceEvent)),
+		devices:          make(map[string]Device),
+	}
+}
+
+// Subscribe 订阅设备事件，返回取消订阅的函数
+// 回调在监视器的协程中按顺序调用，不应长时间阻塞
+func (w *DeviceWatcher) Subscribe(callback func(event DeviceEvent)) func() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	id := w.nextID
+	w.nextID++
+	w.subscribers[id] = callback
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.subscribers, id)
+	}
+}
+
+// Start 开始跟踪设备，已在运行时不做任何事
+func (w *DeviceWatcher) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.done = make(chan struct{})
+	go w.run(ctx, w.done)
+}
+
+// Stop 停止跟踪并等待后台协程退出
+func (w *DeviceWatcher) Stop() {
+	w.mu.Lock()
+	cancel, done := w.cancel, w.done
+	w.cancel, w.done = nil, nil
+	w.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// Devices 返回最近一次推送的设备列表
+func (w *DeviceWatcher) Devices() []Device {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	devices := make([]Device, 0, len(w.devices))
+	for _, dev := range w.devices {
+		devices = append(devices, dev)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Serial < devices[j].Serial })
+	return devices
+}
+
+// run 保持 track-devices 连接，断开后按退避间隔重连
+func (w *DeviceWatcher) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	directDone := make(chan struct{})
+	go func() {
+		defer close(directDone)
+		w.watchDirect(ctx)
+	}()
+	defer func() { <-directDone }()
+
+	long := true
+	retry := w.RetryInterval
+	for {
+		received, err := w.track(ctx, long)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// 旧版 adb server 不支持 track-devices-l，改用不带型号的版本
+		var serverErr *ServerError
+		if long && received == 0 && errors.As(err, &serverErr) {
+			w.adbMgr.log.Info("adb server 不支持 track-devices-l，改用 track-devices")
+			long = false
+			continue
+		}
+
+		if received > 0 {
+			retry = w.RetryInterval
+		}
+		w.adbMgr.log.Warn("设备跟踪连接中断", "error", err, "retry", retry)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+		retry = min(retry*2, w.MaxRetryInterval)
+	}
+}
+
+// track 建立一次 track-devices 连接并持续处理推送，返回收到的设备列表次数
+func (w *DeviceWatcher) track(ctx context.Context, long bool) (int, error) {
+	args := []string{"track-devices"}
+	if long {
+		args = append(args, "-l")
+	}
+
+	proc, err := w.adbMgr.runner.Start(ctx, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer proc.Wait()
+	defer proc.Kill()
+
+	go io.Copy(io.Discard, proc.Stderr())
+
+	received := 0
+	for {
+		list, err := readHexString(proc.Stdout())
+		if err != nil {
+			return received, err
+		}
+		received++
+		w.update(list)
+	}
+}
+
+// watchDirect 直连设备变化时重新生成设备列表，adb server 不可用时直连设备的事件也不受影响
+func (w *DeviceWatcher) watchDirect(ctx context.Context) {
+	changes, stop := w.adbMgr.router.watchDirect()
+	defer stop()
+
+	for {
+		w.updateMu.Lock()
+		w.publishChanges()
+		w.updateMu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-changes:
+		}
+	}
+}
+
+// update 处理 adb server 推送的设备列表
+func (w *DeviceWatcher) update(list string) {
+	w.updateMu.Lock()
+	defer w.updateMu.Unlock()
+
+	w.serverList = list
+	w.publishChanges()
+}
+
+// publishChanges 合并 adb server 与直连设备的列表，与上次对比后更新 ADBManager 的缓存并通知订阅者
+// 调用方需持有 updateMu
+func (w *DeviceWatcher) publishChanges() {
+	current := make(map[string]Device)
+	for _, line := range strings.Split(w.serverList, "\n") {
+		if device, ok := parseDeviceLine(strings.TrimSpace(line)); ok {
+			current[device.Serial] = *device
+		}
+	}
+	for _, device := range w.adbMgr.router.directDevices() {
+		current[device.Serial] = device
+	}
+
+	w.mu.Lock()
+	previous := w.devices
+	w.devices = current
+	w.mu.Unlock()
+
+	events := make([]DeviceEvent, 0)
+	for serial, dev := range current {
+		old, existed := previous[serial]
+		switch {
+		case existed && old.Status == dev.Status:
+			continue
+		case dev.Status == "unauthorized":
+			events = append(events, DeviceEvent{Type: DeviceUnauthorized, Device: dev, OldState: old.Status})
+		case !existed:
+			events = append(events, DeviceEvent{Type: DeviceConnected, Device: dev})
+		default:
+			events = append(events, DeviceEvent{Type: DeviceStateChanged, Device: dev, OldState: old.Status})
+		}
+	}
+	for serial, dev := range previous {
+		if _, found := current[serial]; !found {
+			oldState := dev.Status
+			dev.Status = "offline"
+			events = append(events, DeviceEvent{Type: DeviceDisconnected, Device: dev, OldState: oldState})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Device.Serial < events[j].Device.Serial })
+
+	for _, event := range events {
+		w.adbMgr.log.Info("设备事件", "device", event.Device.Serial, "event", event.Type, "old_state", event.OldState, "state", event.Device.Status)
+		w.adbMgr.applyDeviceEvent(event)
+		w.publish(event)
+	}
+}
+
+// publish 将事件交给所有订阅者
+func (w *DeviceWatcher) publish(event DeviceEvent) {
+	w.mu.Lock()
+	callbacks := make([]func(DeviceEvent), 0, len(w.subscribers))
+	for _, callback := range w.subscribers {
+		callbacks = append(callbacks, callback)
+	}
+	w.mu.Unlock()
+
+	for _, callback := range callbacks {
+		callback(event)
+	}
+}
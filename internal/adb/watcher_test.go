@@ -0,0 +1,71 @@
+package adb
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeviceWatcherDirectDevice(t *testing.T) {
+	srv, err := NewFakeServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.AddDevice("emulator-5554", "device", "product:sdk model:Pixel_7 device:emu64xa")
+
+	key, err := GenerateKey(filepath.Join(t.TempDir(), "adbkey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	adbd, err := NewFakeAdbd(testBanner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer adbd.Close()
+	adbd.Authorize(&key.PublicKey)
+
+	m := newTestManager(NewServerRunner(srv.Addr(), nil))
+	w := NewDeviceWatcher(m)
+	events := make(chan DeviceEvent, 16)
+	w.Subscribe(func(event DeviceEvent) { events <- event })
+	w.Start()
+	defer w.Stop()
+
+	next := func() DeviceEvent {
+		t.Helper()
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a device event")
+			return DeviceEvent{}
+		}
+	}
+	if event := next(); event.Type != DeviceConnected || event.Device.Serial != "emulator-5554" {
+		t.Fatalf("first event = %+v, want emulator-5554 connected", event)
+	}
+
+	// 直连设备不经过 adb server，连接建立后同样产生事件
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := m.ConnectDirectContext(ctx, adbd.Addr(), key); err != nil {
+		t.Fatalf("ConnectDirect: %v", err)
+	}
+	event := next()
+	if event.Type != DeviceConnected || event.Device.Serial != adbd.Addr() || event.Device.Model != "Box" {
+		t.Fatalf("event = %+v, want %s connected", event, adbd.Addr())
+	}
+
+	// adbd 连接断开后产生断开事件，adb server 的设备不受影响
+	adbd.Close()
+	event = next()
+	if event.Type != DeviceDisconnected || event.Device.Serial != adbd.Addr() {
+		t.Fatalf("event = %+v, want %s disconnected", event, adbd.Addr())
+	}
+	devices := w.Devices()
+	if len(devices) != 1 || devices[0].Serial != "emulator-5554" {
+		t.Errorf("devices = %+v, want only emulator-5554", devices)
+	}
+}
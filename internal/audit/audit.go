@@ -0,0 +1,410 @@
+package audit
+
+import (
+	"adbmanager/internal/adb"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config 审计配置，保存在 JSON 文件中
+type Config struct {
+	Operator string `json:"operator"` // 操作者名称，为空时使用当前系统用户名
+	Path     string `json:"path"`     // 审计日志文件，为空时使用 DefaultPath
+}
+
+// DefaultConfigPath 返回审计配置文件的默认路径
+func DefaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "audit.json"
+	}
+	return filepath.Join(dir, "adbmanager", "audit.json")
+}
+
+// DefaultPath 返回审计日志文件的默认路径
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "audit.jsonl"
+	}
+	return filepath.Join(dir, "adbmanager", "audit.jsonl")
+}
+
+// LoadConfig 读取审计配置，文件不存在时返回空配置
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("解析 %s 失败: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// SaveConfig 保存审计配置
+func SaveConfig(path string, cfg Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建配置目录失败: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("保存审计配置失败: %v", err)
+	}
+	return nil
+}
+
+// systemUser 当前系统用户名，取不到时为空
+func systemUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// Record 一条审计记录，每行一个 JSON 对象
+// Hash 为去掉 Hash 字段后的 JSON 的 SHA-256，其中包含上一条记录的哈希，修改或删除中间任一条记录都会使之后的校验失败
+type Record struct {
+	Seq            int64     `json:"seq"` // 从 1 开始连续编号
+	Time           time.Time `json:"time"`
+	Operator       string    `json:"operator"`        // 配置的操作者名称
+	User           string    `json:"user"`            // 运行程序的系统用户
+	Host           string    `json:"host"`            // 运行程序的主机名
+	Device         string    `json:"device"`          // 设备序列号或 IP:PORT，不针对设备的命令为空
+	HardwareSerial string    `json:"hardware_serial"` // 设备的 ro.serialno，未识别时为空
+	Model          string    `json:"model"`
+	Argv           []string  `json:"argv"` // adb 参数（不含 adb 本身）
+	ExitCode       int       `json:"exit_code"`
+	DurationMS     int64     `json:"duration_ms"`
+	Error          string    `json:"error"`
+	PrevHash       string    `json:"prev_hash"` // 第一条记录为空
+	Hash           string    `json:"hash"`
+}
+
+// digest 计算记录的哈希
+func (r Record) digest() string {
+	r.Hash = ""
+	data, _ := json.Marshal(r)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Command 以 shell 转义拼接的 adb 命令行
+func (r Record) Command() string {
+	return "adb " + adb.ShellJoin(r.Argv...)
+}
+
+// String 审计查看器中的显示文字
+func (r Record) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#%d %s %s", r.Seq, r.Time.Local().Format("2006-01-02 15:04:05"), r.Operator)
+	if r.User != "" && r.User != r.Operator {
+		fmt.Fprintf(&b, "(%s)", r.User)
+	}
+	if r.Device != "" {
+		fmt.Fprintf(&b, " %s", r.Device)
+		if r.HardwareSerial != "" && r.HardwareSerial != r.Device {
+			fmt.Fprintf(&b, "[%s]", r.HardwareSerial)
+		}
+	}
+	fmt.Fprintf(&b, " 退出码=%d %dms  %s", r.ExitCode, r.DurationMS, r.Command())
+	if r.Error != "" {
+		b.WriteString("  错误: " + r.Error)
+	}
+	return b.String()
+}
+
+// Log 只追加的审计日志，实现 adb.Auditor
+type Log struct {
+	path string
+	user string
+	host string
+	log  *slog.Logger
+
+	mu          sync.Mutex
+	file        *os.File
+	operator    string
+	seq         int64
+	lastHash    string
+	subscribers map[int]func(Record)
+	nextID      int
+	writeErr    error
+}
+
+// Open 打开审计日志并从最后一条记录继续哈希链，文件不存在时创建
+// operator 为空时使用当前系统用户名
+func Open(path, operator string, logger *slog.Logger) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建审计日志目录失败: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("打开审计日志失败: %v", err)
+	}
+
+	l := &Log{
+		path:        path,
+		user:        systemUser(),
+		file:        file,
+		log:         logger.With("component", "audit"),
+		subscribers: make(map[int]func(Record)),
+	}
+	l.host, _ = os.Hostname()
+	l.operator = l.user
+	if operator != "" {
+		l.operator = operator
+	}
+	if err := l.resume(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// resume 从最后一条能解析的记录继续序号与哈希链
+// 最后一行不完整（例如写入时程序崩溃）时补上换行，使新记录另起一行，校验时该行会被报告
+func (l *Log) resume() error {
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(l.file)
+	var last []byte
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			last = line
+			var record Record
+			if json.Unmarshal(line, &record) == nil {
+				l.seq, l.lastHash = record.Seq, record.Hash
+			} else {
+				l.log.Warn("审计日志中有无法解析的行", "path", l.path, "seq", l.seq)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("读取审计日志失败: %v", err)
+		}
+	}
+
+	if last != nil && last[len(last)-1] != '\n' {
+		if _, err := l.file.Write([]byte("\n")); err != nil {
+			return fmt.Errorf("写入审计日志失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// Path 审计日志文件路径
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Operator 当前的操作者名称
+func (l *Log) Operator() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.operator
+}
+
+// SetOperator 修改操作者名称，之后的记录使用新名称；为空时使用当前系统用户名
+func (l *Log) SetOperator(operator string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.operator = l.user
+	if operator = strings.TrimSpace(operator); operator != "" {
+		l.operator = operator
+	}
+}
+
+// Record 追加一条记录，写入失败只在日志中报告一次
+func (l *Log) Record(event adb.AuditEvent) {
+	argv := make([]string, len(event.Argv))
+	for i, arg := range event.Argv {
+		argv[i] = validUTF8(arg)
+	}
+
+	l.mu.Lock()
+	record := Record{
+		Seq:            l.seq + 1,
+		Time:           event.Time.UTC(),
+		Operator:       validUTF8(l.operator),
+		User:           validUTF8(l.user),
+		Host:           validUTF8(l.host),
+		Device:         validUTF8(event.Serial),
+		HardwareSerial: validUTF8(event.HardwareSerial),
+		Model:          validUTF8(event.Model),
+		Argv:           argv,
+		ExitCode:       event.ExitCode,
+		DurationMS:     event.Duration.Milliseconds(),
+		Error:          validUTF8(event.Error),
+		PrevHash:       l.lastHash,
+	}
+	record.Hash = record.digest()
+
+	line, err := json.Marshal(record)
+	if err == nil {
+		_, err = l.file.Write(append(line, '\n'))
+	}
+	if err != nil {
+		if l.writeErr == nil {
+			l.writeErr = err
+			l.log.Error("写入审计日志失败", "path", l.path, "error", err)
+		}
+		l.mu.Unlock()
+		return
+	}
+	l.seq, l.lastHash = record.Seq, record.Hash
+	callbacks := make([]func(Record), 0, len(l.subscribers))
+	for _, callback := range l.subscribers {
+		callbacks = append(callbacks, callback)
+	}
+	l.mu.Unlock()
+
+	for _, callback := range callbacks {
+		callback(record)
+	}
+}
+
+// validUTF8 替换无效的 UTF-8 字节，保证记录重新编码后哈希不变
+func validUTF8(s string) string {
+	return strings.ToValidUTF8(s, "\uFFFD")
+}
+
+// Subscribe 订阅新的记录，返回取消订阅的函数
+// 回调在执行 adb 操作的协程中调用，不应长时间阻塞
+func (l *Log) Subscribe(callback func(record Record)) func() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	id := l.nextID
+	l.nextID++
+	l.subscribers[id] = callback
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.subscribers, id)
+	}
+}
+
+// Close 关闭审计日志文件
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// ReadFile 读取审计日志中的全部记录，跳过无法解析的行
+func ReadFile(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records := make([]Record, 0)
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		var record Record
+		if len(bytes.TrimSpace(line)) > 0 && json.Unmarshal(line, &record) == nil {
+			records = append(records, record)
+		}
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+	}
+}
+
+// VerifyResult 审计日志的校验结果
+type VerifyResult struct {
+	Records  int    // 校验通过的记录数
+	LastHash string // 最后一条通过校验的记录的哈希
+	Line     int    // 第一处问题所在的行号（从 1 开始），没有问题时为 0
+	Problem  string // 问题描述
+}
+
+// OK 哈希链是否完整
+func (r VerifyResult) OK() bool {
+	return r.Line == 0
+}
+
+// Verify 校验审计日志的哈希链，发现第一处问题即停止，error 只表示文件无法读取
+// 删除末尾的记录不会破坏哈希链，需要时可另行保存 LastHash，下次校验时比对
+func Verify(path string) (VerifyResult, error) {
+	var result VerifyResult
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for lineNo := 1; ; lineNo++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return result, err
+		}
+		if len(line) == 0 && err == io.EOF {
+			return result, nil
+		}
+
+		if problem := result.check(line); problem != "" {
+			result.Line, result.Problem = lineNo, problem
+			return result, nil
+		}
+		if err == io.EOF {
+			return result, nil
+		}
+	}
+}
+
+// check 校验一行是否为哈希链上的下一条记录，通过时更新结果并返回空字符串
+func (r *VerifyResult) check(line []byte) string {
+	var record Record
+	if err := json.Unmarshal(line, &record); err != nil {
+		return fmt.Sprintf("无法解析: %v", err)
+	}
+	if want := int64(r.Records + 1); record.Seq != want {
+		return fmt.Sprintf("序号为 %d，应为 %d（记录被删除或插入）", record.Seq, want)
+	}
+	if record.PrevHash != r.LastHash {
+		return fmt.Sprintf("第 %d 条记录的上一条哈希不匹配（之前的记录被修改或删除）", record.Seq)
+	}
+	if record.digest() != record.Hash {
+		return fmt.Sprintf("第 %d 条记录的哈希不匹配（记录内容被修改）", record.Seq)
+	}
+	r.Records++
+	r.LastHash = record.Hash
+	return ""
+}
@@ -0,0 +1,187 @@
+package audit
+
+import (
+	"adbmanager/internal/adb"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestLog 写入 n 条记录，返回日志路径与各行内容
+func writeTestLog(t *testing.T, n int) (string, [][]byte) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := Open(path, "alice", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		l.Record(adb.AuditEvent{
+			Time:     start.Add(time.Duration(i) * time.Second),
+			Serial:   "emulator-5554",
+			Model:    "Pixel 7",
+			Argv:     []string{"-s", "emulator-5554", "shell", "echo", string(rune('a' + i))},
+			Duration: 120 * time.Millisecond,
+		})
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, bytes.SplitAfter(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+}
+
+// writeLines 用 lines 覆盖日志文件
+func writeLines(t *testing.T, path string, lines [][]byte) {
+	t.Helper()
+	if err := os.WriteFile(path, bytes.Join(lines, nil), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// parseLine 解析一行记录
+func parseLine(t *testing.T, line []byte) Record {
+	t.Helper()
+	var record Record
+	if err := json.Unmarshal(line, &record); err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+// marshalLine 将记录编码为一行
+func marshalLine(t *testing.T, record Record) []byte {
+	t.Helper()
+	line, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(line, '\n')
+}
+
+func TestVerifyClean(t *testing.T) {
+	path, lines := writeTestLog(t, 5)
+	if len(lines) != 5 {
+		t.Fatalf("log has %d lines, want 5", len(lines))
+	}
+
+	result, err := Verify(path)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	last := parseLine(t, lines[4])
+	if !result.OK() || result.Records != 5 || result.LastHash != last.Hash {
+		t.Fatalf("Verify = %+v, want 5 records ending with %s", result, last.Hash)
+	}
+
+	records, err := ReadFile(path)
+	if err != nil || len(records) != 5 {
+		t.Fatalf("ReadFile = %d records, %v", len(records), err)
+	}
+	if first := records[0]; first.Seq != 1 || first.PrevHash != "" || first.Operator != "alice" {
+		t.Errorf("first record = %+v", first)
+	}
+
+	// 重新打开后从最后一条记录继续哈希链
+	l, err := Open(path, "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Record(adb.AuditEvent{Time: time.Now(), Argv: []string{"devices"}})
+	l.Close()
+	result, err = Verify(path)
+	if err != nil || !result.OK() || result.Records != 6 {
+		t.Fatalf("Verify after reopen = %+v, %v, want 6 records", result, err)
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(t *testing.T, lines [][]byte) [][]byte
+		line    int
+		problem string
+	}{
+		{
+			name: "修改字段",
+			tamper: func(t *testing.T, lines [][]byte) [][]byte {
+				record := parseLine(t, lines[2])
+				record.ExitCode = 1
+				lines[2] = marshalLine(t, record)
+				return lines
+			},
+			line:    3,
+			problem: "记录内容被修改",
+		},
+		{
+			name: "修改字段并重新计算哈希",
+			tamper: func(t *testing.T, lines [][]byte) [][]byte {
+				record := parseLine(t, lines[2])
+				record.Argv = []string{"-s", "emulator-5554", "shell", "true"}
+				record.Hash = record.digest()
+				lines[2] = marshalLine(t, record)
+				return lines
+			},
+			line:    4,
+			problem: "之前的记录被修改或删除",
+		},
+		{
+			name: "删除中间一行",
+			tamper: func(t *testing.T, lines [][]byte) [][]byte {
+				return append(lines[:2:2], lines[3:]...)
+			},
+			line:    3,
+			problem: "序号为 4，应为 3",
+		},
+		{
+			name: "插入伪造的记录",
+			tamper: func(t *testing.T, lines [][]byte) [][]byte {
+				// 伪造的记录本身能接上哈希链，但之后原有的记录序号对不上
+				prev := parseLine(t, lines[1])
+				forged := Record{Seq: 3, Time: prev.Time, Operator: "mallory", Argv: []string{"devices"}, PrevHash: prev.Hash}
+				forged.Hash = forged.digest()
+				return append(lines[:2:2], append([][]byte{marshalLine(t, forged)}, lines[2:]...)...)
+			},
+			line:    4,
+			problem: "序号为 3，应为 4",
+		},
+		{
+			name: "最后一行不完整",
+			tamper: func(t *testing.T, lines [][]byte) [][]byte {
+				lines[4] = lines[4][:len(lines[4])/2]
+				return lines
+			},
+			line:    5,
+			problem: "无法解析",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, lines := writeTestLog(t, 5)
+			writeLines(t, path, tt.tamper(t, lines))
+
+			result, err := Verify(path)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if result.OK() || result.Line != tt.line || !strings.Contains(result.Problem, tt.problem) {
+				t.Fatalf("Verify = %+v, want problem %q at line %d", result, tt.problem, tt.line)
+			}
+			if result.Records != tt.line-1 {
+				t.Errorf("Records = %d, want %d verified before the problem", result.Records, tt.line-1)
+			}
+		})
+	}
+}
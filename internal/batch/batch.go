@@ -3,28 +3,77 @@ package batch
 import (
 	"adbmanager/internal/adb"
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 )
 
-// BatchManager 批量操作管理器
+// offlineRetries 设备离线时的最大重试次数
+const offlineRetries = 2
+
+// BatchManager 批量操作管理器，目标列表（IP:PORT）保存在文本文件中
 type BatchManager struct {
 	adbMgr  *adb.ADBManager
+	log     *slog.Logger
+	path    string
 	targets []string
 	mu      sync.Mutex
 }
 
-// NewBatchManager 创建批量操作管理器
-func NewBatchManager(adbMgr *adb.ADBManager) *BatchManager {
-	return &BatchManager{
+// DefaultTargetsPath 返回目标列表文件的默认路径
+func DefaultTargetsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "targets.txt"
+	}
+	return filepath.Join(dir, "adbmanager", "targets.txt")
+}
+
+// NewBatchManager 创建批量操作管理器并读取 path 中已保存的目标，日志写入 adbMgr.Logger()
+func NewBatchManager(adbMgr *adb.ADBManager, path string) *BatchManager {
+	bm := &BatchManager{
 		adbMgr:  adbMgr,
+		log:     adbMgr.Logger().With("component", "batch"),
+		path:    path,
 		targets: make([]string, 0),
 	}
+	if _, err := os.Stat(path); err == nil {
+		if err := bm.ImportTargetsFromFile(path); err != nil {
+			bm.log.Warn("读取目标列表失败", "path", path, "error", err)
+		}
+	}
+	return bm
+}
+
+// save 将目标列表写入文件，调用方需持有锁；保存失败只记录日志，不影响内存中的目标列表
+func (bm *BatchManager) save() {
+	if err := os.MkdirAll(filepath.Dir(bm.path), 0755); err != nil {
+		bm.log.Warn("创建配置目录失败", "error", err)
+		return
+	}
+	if err := bm.writeTargets(bm.path); err != nil {
+		bm.log.Warn("保存目标列表失败", "path", bm.path, "error", err)
+	}
+}
+
+// addTarget 添加目标并去重，调用方需持有锁
+func (bm *BatchManager) addTarget(target string) bool {
+	for _, t := range bm.targets {
+		if t == target {
+			return false
+		}
+	}
+	bm.targets = append(bm.targets, target)
+	return true
 }
 
-// ImportTargetsFromFile 从文件导入目标
+// ImportTargetsFromFile 从文件导入目标，已有的目标不会重复添加
 func (bm *BatchManager) ImportTargetsFromFile(filePath string) error {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -35,6 +84,7 @@ func (bm *BatchManager) ImportTargetsFromFile(filePath string) error {
 	bm.mu.Lock()
 	defer bm.mu.Unlock()
 
+	added := false
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
@@ -46,8 +96,8 @@ func (bm *BatchManager) ImportTargetsFromFile(filePath string) error {
 		// 验证 IP:PORT 格式
 		if strings.Contains(line, ":") {
 			parts := strings.Split(line, ":")
-			if len(parts) == 2 {
-				bm.targets = append(bm.targets, line)
+			if len(parts) == 2 && bm.addTarget(line) {
+				added = true
 			}
 		}
 	}
@@ -56,6 +106,9 @@ func (bm *BatchManager) ImportTargetsFromFile(filePath string) error {
 		return fmt.Errorf("读取文件失败: %v", err)
 	}
 
+	if added && filePath != bm.path {
+		bm.save()
+	}
 	return nil
 }
 
@@ -64,14 +117,9 @@ func (bm *BatchManager) AddTarget(target string) {
 	bm.mu.Lock()
 	defer bm.mu.Unlock()
 
-	// 检查是否已存在
-	for _, t := range bm.targets {
-		if t == target {
-			return
-		}
+	if bm.addTarget(target) {
+		bm.save()
 	}
-
-	bm.targets = append(bm.targets, target)
 }
 
 // RemoveTarget 移除目标
@@ -82,6 +130,7 @@ func (bm *BatchManager) RemoveTarget(target string) {
 	for i, t := range bm.targets {
 		if t == target {
 			bm.targets = append(bm.targets[:i], bm.targets[i+1:]...)
+			bm.save()
 			return
 		}
 	}
@@ -103,6 +152,25 @@ func (bm *BatchManager) ClearTargets() {
 	defer bm.mu.Unlock()
 
 	bm.targets = make([]string, 0)
+	bm.save()
+}
+
+// retryOffline 执行 op，设备离线时先尝试重连再重试
+// 其他错误（未授权、设备不存在等）直接返回，重试没有意义
+func (bm *BatchManager) retryOffline(ctx context.Context, device string, op func() error) error {
+	err := op()
+	for attempt := 1; attempt <= offlineRetries && errors.Is(err, adb.ErrOffline); attempt++ {
+		bm.log.Warn("设备离线，重试", "device", device, "attempt", attempt)
+		bm.adbMgr.ReconnectContext(ctx, device)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * time.Second):
+		}
+		err = op()
+	}
+	return err
 }
 
 // BatchConnectResult 批量连接结果
@@ -114,6 +182,11 @@ type BatchConnectResult struct {
 
 // BatchConnect 批量连接设备
 func (bm *BatchManager) BatchConnect(callback func(result BatchConnectResult)) {
+	bm.BatchConnectContext(context.Background(), callback)
+}
+
+// BatchConnectContext 可通过 ctx 取消的 BatchConnect，取消后未完成的设备返回取消错误
+func (bm *BatchManager) BatchConnectContext(ctx context.Context, callback func(result BatchConnectResult)) {
 	targets := bm.GetTargets()
 
 	var wg sync.WaitGroup
@@ -137,7 +210,7 @@ func (bm *BatchManager) BatchConnect(callback func(result BatchConnectResult)) {
 		go func(t string) {
 			defer wg.Done()
 
-			err := bm.adbMgr.Connect(t)
+			err := bm.adbMgr.ConnectContext(ctx, t)
 			resultChan <- BatchConnectResult{
 				Target:  t,
 				Success: err == nil,
@@ -152,6 +225,40 @@ func (bm *BatchManager) BatchConnect(callback func(result BatchConnectResult)) {
 	callbackWg.Wait()
 }
 
+// SwitchToTCPResult USB 设备切换为无线连接的结果
+type SwitchToTCPResult struct {
+	Device  string // USB 序列号
+	Address string // 切换后的 IP:PORT，失败时为空
+	Error   error
+}
+
+// BatchSwitchToTCP 将多台 USB 设备切换为无线连接，成功的地址加入目标列表
+func (bm *BatchManager) BatchSwitchToTCP(devices []string, port int, callback func(result SwitchToTCPResult)) {
+	bm.BatchSwitchToTCPContext(context.Background(), devices, port, callback)
+}
+
+// BatchSwitchToTCPContext 可通过 ctx 取消的 BatchSwitchToTCP，取消后未完成的设备返回取消错误
+func (bm *BatchManager) BatchSwitchToTCPContext(ctx context.Context, devices []string, port int, callback func(result SwitchToTCPResult)) {
+	var wg sync.WaitGroup
+
+	for _, device := range devices {
+		wg.Add(1)
+		go func(dev string) {
+			defer wg.Done()
+
+			address, err := bm.adbMgr.SwitchToTCPContext(ctx, dev, port)
+			if err == nil {
+				bm.AddTarget(address)
+			}
+			if callback != nil {
+				callback(SwitchToTCPResult{Device: dev, Address: address, Error: err})
+			}
+		}(device)
+	}
+
+	wg.Wait()
+}
+
 // CommandResult 命令执行结果
 type CommandResult struct {
 	Device string
@@ -161,11 +268,19 @@ type CommandResult struct {
 
 // BatchExecuteCommand 批量执行命令
 func (bm *BatchManager) BatchExecuteCommand(devices []string, command string, callback func(result CommandResult)) {
+	bm.BatchExecuteCommandContext(context.Background(), devices, command, callback)
+}
+
+// BatchExecuteCommandContext 可通过 ctx 取消的 BatchExecuteCommand，取消后未完成的设备返回取消错误
+func (bm *BatchManager) BatchExecuteCommandContext(ctx context.Context, devices []string, command string, callback func(result CommandResult)) {
 	var wg sync.WaitGroup
 	resultChan := make(chan CommandResult, len(devices))
 
 	// 启动协程处理回调
+	var callbackWg sync.WaitGroup
+	callbackWg.Add(1)
 	go func() {
+		defer callbackWg.Done()
 		for result := range resultChan {
 			if callback != nil {
 				callback(result)
@@ -179,7 +294,12 @@ func (bm *BatchManager) BatchExecuteCommand(devices []string, command string, ca
 		go func(dev string) {
 			defer wg.Done()
 
-			output, err := bm.adbMgr.ExecuteCommand(dev, command)
+			var output string
+			err := bm.retryOffline(ctx, dev, func() error {
+				var err error
+				output, err = bm.adbMgr.ExecuteCommandContext(ctx, dev, command)
+				return err
+			})
 			resultChan <- CommandResult{
 				Device: dev,
 				Output: output,
@@ -190,10 +310,17 @@ func (bm *BatchManager) BatchExecuteCommand(devices []string, command string, ca
 
 	wg.Wait()
 	close(resultChan)
+	// 等待回调处理完成
+	callbackWg.Wait()
 }
 
 // BatchInstallApp 批量安装应用
 func (bm *BatchManager) BatchInstallApp(devices []string, apkPath string, callback func(device string, err error)) {
+	bm.BatchInstallAppContext(context.Background(), devices, apkPath, callback)
+}
+
+// BatchInstallAppContext 可通过 ctx 取消的 BatchInstallApp，取消后未完成的设备返回取消错误
+func (bm *BatchManager) BatchInstallAppContext(ctx context.Context, devices []string, apkPath string, callback func(device string, err error)) {
 	var wg sync.WaitGroup
 
 	for _, device := range devices {
@@ -201,7 +328,9 @@ func (bm *BatchManager) BatchInstallApp(devices []string, apkPath string, callba
 		go func(dev string) {
 			defer wg.Done()
 
-			err := bm.adbMgr.InstallApp(dev, apkPath)
+			err := bm.retryOffline(ctx, dev, func() error {
+				return bm.adbMgr.InstallAppContext(ctx, dev, apkPath)
+			})
 			if callback != nil {
 				callback(dev, err)
 			}
@@ -213,6 +342,11 @@ func (bm *BatchManager) BatchInstallApp(devices []string, apkPath string, callba
 
 // BatchUninstallApp 批量卸载应用
 func (bm *BatchManager) BatchUninstallApp(devices []string, packageName string, callback func(device string, err error)) {
+	bm.BatchUninstallAppContext(context.Background(), devices, packageName, callback)
+}
+
+// BatchUninstallAppContext 可通过 ctx 取消的 BatchUninstallApp，取消后未完成的设备返回取消错误
+func (bm *BatchManager) BatchUninstallAppContext(ctx context.Context, devices []string, packageName string, callback func(device string, err error)) {
 	var wg sync.WaitGroup
 
 	for _, device := range devices {
@@ -220,7 +354,9 @@ func (bm *BatchManager) BatchUninstallApp(devices []string, packageName string,
 		go func(dev string) {
 			defer wg.Done()
 
-			err := bm.adbMgr.UninstallApp(dev, packageName)
+			err := bm.retryOffline(ctx, dev, func() error {
+				return bm.adbMgr.UninstallAppContext(ctx, dev, packageName)
+			})
 			if callback != nil {
 				callback(dev, err)
 			}
@@ -232,6 +368,21 @@ func (bm *BatchManager) BatchUninstallApp(devices []string, packageName string,
 
 // BatchPushFile 批量推送文件
 func (bm *BatchManager) BatchPushFile(devices []string, localPath, remotePath string, callback func(device string, err error)) {
+	bm.BatchPushFileContext(context.Background(), devices, localPath, remotePath, callback)
+}
+
+// BatchPushFileContext 可通过 ctx 取消的 BatchPushFile，取消后未完成的设备返回取消错误
+func (bm *BatchManager) BatchPushFileContext(ctx context.Context, devices []string, localPath, remotePath string, callback func(device string, err error)) {
+	bm.BatchPushFileWithProgressContext(ctx, devices, localPath, remotePath, nil, callback)
+}
+
+// BatchPushFileWithProgress 批量推送文件，progress 报告每台设备的传输进度（可为 nil）
+func (bm *BatchManager) BatchPushFileWithProgress(devices []string, localPath, remotePath string, progress func(device string, progress adb.TransferProgress), callback func(device string, err error)) {
+	bm.BatchPushFileWithProgressContext(context.Background(), devices, localPath, remotePath, progress, callback)
+}
+
+// BatchPushFileWithProgressContext 可通过 ctx 取消的 BatchPushFileWithProgress
+func (bm *BatchManager) BatchPushFileWithProgressContext(ctx context.Context, devices []string, localPath, remotePath string, progress func(device string, progress adb.TransferProgress), callback func(device string, err error)) {
 	var wg sync.WaitGroup
 
 	for _, device := range devices {
@@ -239,7 +390,13 @@ func (bm *BatchManager) BatchPushFile(devices []string, localPath, remotePath st
 		go func(dev string) {
 			defer wg.Done()
 
-			err := bm.adbMgr.PushFile(dev, localPath, remotePath)
+			var onProgress adb.ProgressFunc
+			if progress != nil {
+				onProgress = func(p adb.TransferProgress) { progress(dev, p) }
+			}
+			err := bm.retryOffline(ctx, dev, func() error {
+				return bm.adbMgr.PushFileWithProgressContext(ctx, dev, localPath, remotePath, onProgress)
+			})
 			if callback != nil {
 				callback(dev, err)
 			}
@@ -249,8 +406,82 @@ func (bm *BatchManager) BatchPushFile(devices []string, localPath, remotePath st
 	wg.Wait()
 }
 
+// MirrorPlanResult 单台设备的镜像计划
+type MirrorPlanResult struct {
+	Device string
+	Plan   *adb.MirrorPlan
+	Error  error
+}
+
+// BatchPlanMirror 为每台设备生成目录镜像计划（dry-run），不做任何修改
+// 从设备拉取时每台设备使用 LocalDir 下以序列号命名的子目录，避免互相覆盖
+func (bm *BatchManager) BatchPlanMirror(devices []string, opts adb.MirrorOptions, callback func(result MirrorPlanResult)) {
+	bm.BatchPlanMirrorContext(context.Background(), devices, opts, callback)
+}
+
+// BatchPlanMirrorContext 可通过 ctx 取消的 BatchPlanMirror
+func (bm *BatchManager) BatchPlanMirrorContext(ctx context.Context, devices []string, opts adb.MirrorOptions, callback func(result MirrorPlanResult)) {
+	var wg sync.WaitGroup
+
+	for _, device := range devices {
+		wg.Add(1)
+		go func(dev string) {
+			defer wg.Done()
+
+			devOpts := opts
+			if opts.Direction == adb.MirrorPull {
+				devOpts.LocalDir = filepath.Join(opts.LocalDir, strings.ReplaceAll(dev, ":", "_"))
+			}
+			var plan *adb.MirrorPlan
+			err := bm.retryOffline(ctx, dev, func() error {
+				var err error
+				plan, err = bm.adbMgr.PlanMirrorContext(ctx, dev, devOpts)
+				return err
+			})
+			if callback != nil {
+				callback(MirrorPlanResult{Device: dev, Plan: plan, Error: err})
+			}
+		}(device)
+	}
+
+	wg.Wait()
+}
+
+// BatchApplyMirror 在各设备上执行 BatchPlanMirror 得到的计划，progress 报告每台设备的进度（可为 nil）
+func (bm *BatchManager) BatchApplyMirror(plans map[string]*adb.MirrorPlan, progress func(device string, progress adb.MirrorProgress), callback func(device string, err error)) {
+	bm.BatchApplyMirrorContext(context.Background(), plans, progress, callback)
+}
+
+// BatchApplyMirrorContext 可通过 ctx 取消的 BatchApplyMirror，取消后未完成的设备返回取消错误
+func (bm *BatchManager) BatchApplyMirrorContext(ctx context.Context, plans map[string]*adb.MirrorPlan, progress func(device string, progress adb.MirrorProgress), callback func(device string, err error)) {
+	var wg sync.WaitGroup
+
+	for device, plan := range plans {
+		wg.Add(1)
+		go func(dev string, plan *adb.MirrorPlan) {
+			defer wg.Done()
+
+			var onProgress adb.MirrorProgressFunc
+			if progress != nil {
+				onProgress = func(p adb.MirrorProgress) { progress(dev, p) }
+			}
+			err := bm.adbMgr.ApplyMirrorContext(ctx, dev, plan, onProgress)
+			if callback != nil {
+				callback(dev, err)
+			}
+		}(device, plan)
+	}
+
+	wg.Wait()
+}
+
 // BatchScreenshot 批量截屏
 func (bm *BatchManager) BatchScreenshot(devices []string, outputDir string, callback func(device, filepath string, err error)) {
+	bm.BatchScreenshotContext(context.Background(), devices, outputDir, callback)
+}
+
+// BatchScreenshotContext 可通过 ctx 取消的 BatchScreenshot，取消后未完成的设备返回取消错误
+func (bm *BatchManager) BatchScreenshotContext(ctx context.Context, devices []string, outputDir string, callback func(device, filepath string, err error)) {
 	var wg sync.WaitGroup
 
 	for _, device := range devices {
@@ -260,7 +491,9 @@ func (bm *BatchManager) BatchScreenshot(devices []string, outputDir string, call
 
 			// 创建唯一的文件名
 			filename := fmt.Sprintf("%s/%s_screenshot.png", outputDir, strings.ReplaceAll(dev, ":", "_"))
-			err := bm.adbMgr.Screenshot(dev, filename)
+			err := bm.retryOffline(ctx, dev, func() error {
+				return bm.adbMgr.ScreenshotContext(ctx, dev, filename)
+			})
 
 			if callback != nil {
 				callback(dev, filename, err)
@@ -275,7 +508,11 @@ func (bm *BatchManager) BatchScreenshot(devices []string, outputDir string, call
 func (bm *BatchManager) ExportTargetsToFile(filePath string) error {
 	bm.mu.Lock()
 	defer bm.mu.Unlock()
+	return bm.writeTargets(filePath)
+}
 
+// writeTargets 将目标逐行写入文件，调用方需持有锁
+func (bm *BatchManager) writeTargets(filePath string) error {
 	file, err := os.Create(filePath)
 	if err != nil {
 		return fmt.Errorf("创建文件失败: %v", err)
@@ -0,0 +1,120 @@
+package batch
+
+import (
+	"adbmanager/internal/adb"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// newTestBatchManager 使用脚本化执行器创建批量操作管理器，目标列表保存在临时目录
+func newTestBatchManager(t *testing.T, runner adb.CommandRunner) (*BatchManager, string) {
+	t.Helper()
+	adbMgr := adb.NewADBManagerWithRunner(runner)
+	adbMgr.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	path := filepath.Join(t.TempDir(), "targets.txt")
+	return NewBatchManager(adbMgr, path), path
+}
+
+func TestBatchExecuteCommand(t *testing.T) {
+	runner := adb.NewScriptedRunner().
+		On("-s emulator-5554 shell getprop ro.product.model", adb.ScriptedResponse{Stdout: "Pixel 7\n"}).
+		On("-s 0123456789ABCDEF shell getprop ro.product.model",
+			adb.ScriptedResponse{Stderr: "error: device unauthorized.\nThis adb server's $ADB_VENDOR_KEYS is not set\n", ExitCode: 1}).
+		// 第一次离线，重连后成功
+		On("-s 192.168.1.20:5555 shell getprop ro.product.model",
+			adb.ScriptedResponse{Stderr: "error: device offline\n", ExitCode: 1},
+			adb.ScriptedResponse{Stdout: "Box\n"}).
+		On("-s 192.168.1.20:5555 reconnect", adb.ScriptedResponse{Stdout: "reconnecting 192.168.1.20:5555 [device]\n"})
+	bm, _ := newTestBatchManager(t, runner)
+
+	var mu sync.Mutex
+	results := make(map[string]CommandResult)
+	devices := []string{"emulator-5554", "0123456789ABCDEF", "192.168.1.20:5555"}
+	bm.BatchExecuteCommand(devices, "getprop ro.product.model", func(result CommandResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[result.Device] = result
+	})
+
+	if len(results) != len(devices) {
+		t.Fatalf("results = %+v, want one per device", results)
+	}
+	if r := results["emulator-5554"]; r.Error != nil || r.Output != "Pixel 7\n" {
+		t.Errorf("emulator-5554 = %+v", r)
+	}
+	if r := results["0123456789ABCDEF"]; !errors.Is(r.Error, adb.ErrUnauthorized) {
+		t.Errorf("0123456789ABCDEF error = %v, want ErrUnauthorized", r.Error)
+	}
+	if r := results["192.168.1.20:5555"]; r.Error != nil || r.Output != "Box\n" {
+		t.Errorf("192.168.1.20:5555 = %+v, want success after reconnect", r)
+	}
+
+	// 未授权不重试，离线的设备先重连再重试
+	count := make(map[string]int)
+	for _, call := range runner.Calls() {
+		count[call[1]+" "+call[2]]++
+	}
+	want := map[string]int{
+		"emulator-5554 shell":         1,
+		"0123456789ABCDEF shell":      1,
+		"192.168.1.20:5555 shell":     2,
+		"192.168.1.20:5555 reconnect": 1,
+	}
+	if !reflect.DeepEqual(count, want) {
+		t.Errorf("calls = %v, want %v", count, want)
+	}
+}
+
+func TestBatchConnect(t *testing.T) {
+	runner := adb.NewScriptedRunner().
+		On("connect 192.168.1.20:5555", adb.ScriptedResponse{Stdout: "connected to 192.168.1.20:5555\n"}).
+		On("connect 192.168.1.21:5555", adb.ScriptedResponse{Stdout: "failed to connect to '192.168.1.21:5555': Connection refused\n"})
+	bm, _ := newTestBatchManager(t, runner)
+	bm.AddTarget("192.168.1.20:5555")
+	bm.AddTarget("192.168.1.21:5555")
+
+	var mu sync.Mutex
+	success := make(map[string]bool)
+	bm.BatchConnect(func(result BatchConnectResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		success[result.Target] = result.Success
+	})
+	want := map[string]bool{"192.168.1.20:5555": true, "192.168.1.21:5555": false}
+	if !reflect.DeepEqual(success, want) {
+		t.Fatalf("success = %v, want %v", success, want)
+	}
+}
+
+func TestTargetsPersisted(t *testing.T) {
+	bm, path := newTestBatchManager(t, adb.NewScriptedRunner())
+	bm.AddTarget("192.168.1.20:5555")
+	bm.AddTarget("192.168.1.20:5555")
+	bm.AddTarget("192.168.1.21:5555")
+	bm.RemoveTarget("192.168.1.21:5555")
+
+	imported := filepath.Join(t.TempDir(), "import.txt")
+	content := "# 机房 A\n192.168.1.30:5555\n\nnot-a-target\n192.168.1.20:5555\n"
+	if err := os.WriteFile(imported, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := bm.ImportTargetsFromFile(imported); err != nil {
+		t.Fatalf("ImportTargetsFromFile: %v", err)
+	}
+
+	// 重新打开时读取保存的目标
+	reopened := NewBatchManager(bm.adbMgr, path)
+	got := reopened.GetTargets()
+	sort.Strings(got)
+	want := []string{"192.168.1.20:5555", "192.168.1.30:5555"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("targets = %q, want %q", got, want)
+	}
+}
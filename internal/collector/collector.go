@@ -2,19 +2,23 @@ package collector
 
 import (
 	"adbmanager/internal/adb"
+	"context"
 	"fmt"
+	"log/slog"
 	"strings"
 )
 
 // Collector 信息采集器
 type Collector struct {
 	adbMgr *adb.ADBManager
+	log    *slog.Logger
 }
 
-// NewCollector 创建信息采集器
+// NewCollector 创建信息采集器，日志写入 adbMgr.Logger()
 func NewCollector(adbMgr *adb.ADBManager) *Collector {
 	return &Collector{
 		adbMgr: adbMgr,
+		log:    adbMgr.Logger().With("component", "collector"),
 	}
 }
 
@@ -27,8 +31,13 @@ type ContactInfo struct {
 
 // GetContacts 获取联系人信息
 func (c *Collector) GetContacts(serial string) ([]ContactInfo, error) {
+	return c.GetContactsContext(context.Background(), serial)
+}
+
+// GetContactsContext 可通过 ctx 取消的 GetContacts
+func (c *Collector) GetContactsContext(ctx context.Context, serial string) ([]ContactInfo, error) {
 	// 需要读取联系人权限
-	output, err := c.adbMgr.ExecuteCommand(serial,
+	output, err := c.adbMgr.ExecuteCommandContext(ctx, serial,
 		"content query --uri content://contacts/phones --projection display_name:number")
 	if err != nil {
 		return nil, fmt.Errorf("获取联系人失败: %v", err)
@@ -60,6 +69,7 @@ func (c *Collector) GetContacts(serial string) ([]ContactInfo, error) {
 		}
 	}
 
+	c.log.Info("已采集联系人", "device", serial, "count", len(contacts))
 	return contacts, nil
 }
 
@@ -73,8 +83,13 @@ type SMSInfo struct {
 
 // GetSMS 获取短信信息
 func (c *Collector) GetSMS(serial string, limit int) ([]SMSInfo, error) {
+	return c.GetSMSContext(context.Background(), serial, limit)
+}
+
+// GetSMSContext 可通过 ctx 取消的 GetSMS
+func (c *Collector) GetSMSContext(ctx context.Context, serial string, limit int) ([]SMSInfo, error) {
 	cmd := fmt.Sprintf("content query --uri content://sms --projection address:body:date:type --sort \"date DESC\" --limit %d", limit)
-	output, err := c.adbMgr.ExecuteCommand(serial, cmd)
+	output, err := c.adbMgr.ExecuteCommandContext(ctx, serial, cmd)
 	if err != nil {
 		return nil, fmt.Errorf("获取短信失败: %v", err)
 	}
@@ -108,6 +123,7 @@ func (c *Collector) GetSMS(serial string, limit int) ([]SMSInfo, error) {
 		}
 	}
 
+	c.log.Info("已采集短信", "device", serial, "count", len(smsList))
 	return smsList, nil
 }
 
@@ -122,8 +138,13 @@ type LocationInfo struct {
 
 // GetLocation 获取位置信息
 func (c *Collector) GetLocation(serial string) (*LocationInfo, error) {
+	return c.GetLocationContext(context.Background(), serial)
+}
+
+// GetLocationContext 可通过 ctx 取消的 GetLocation
+func (c *Collector) GetLocationContext(ctx context.Context, serial string) (*LocationInfo, error) {
 	// 尝试获取最后已知位置
-	output, err := c.adbMgr.ExecuteCommand(serial,
+	output, err := c.adbMgr.ExecuteCommandContext(ctx, serial,
 		"dumpsys location | grep -A 10 'Last Known Locations'")
 	if err != nil {
 		return nil, fmt.Errorf("获取位置信息失败: %v", err)
@@ -149,6 +170,7 @@ func (c *Collector) GetLocation(serial string) (*LocationInfo, error) {
 		}
 	}
 
+	c.log.Info("已采集位置信息", "device", serial)
 	return location, nil
 }
 
@@ -162,19 +184,25 @@ type WiFiInfo struct {
 
 // GetWiFiInfo 获取 WiFi 信息
 func (c *Collector) GetWiFiInfo(serial string) ([]WiFiInfo, error) {
+	return c.GetWiFiInfoContext(context.Background(), serial)
+}
+
+// GetWiFiInfoContext 可通过 ctx 取消的 GetWiFiInfo
+func (c *Collector) GetWiFiInfoContext(ctx context.Context, serial string) ([]WiFiInfo, error) {
 	wifiList := make([]WiFiInfo, 0)
 
 	// 获取当前连接的 WiFi
-	currentSSID, _ := c.adbMgr.ExecuteCommand(serial,
+	currentSSID, _ := c.adbMgr.ExecuteCommandContext(ctx, serial,
 		"dumpsys wifi | grep 'mWifiInfo' | awk '{print $4}'")
 	currentSSID = strings.TrimSpace(currentSSID)
 
 	// 获取已保存的 WiFi 配置（需要 root 权限）
-	output, err := c.adbMgr.ExecuteCommand(serial,
+	output, err := c.adbMgr.ExecuteCommandContext(ctx, serial,
 		"cat /data/misc/wifi/wpa_supplicant.conf")
 	if err != nil {
 		// 如果没有 root 权限，尝试其他方法
-		output, err = c.adbMgr.ExecuteCommand(serial, "dumpsys wifi")
+		c.log.Debug("读取 wpa_supplicant.conf 失败，改用 dumpsys wifi", "device", serial, "error", err)
+		output, err = c.adbMgr.ExecuteCommandContext(ctx, serial, "dumpsys wifi")
 		if err != nil {
 			return nil, fmt.Errorf("获取 WiFi 信息失败: %v", err)
 		}
@@ -202,12 +230,18 @@ func (c *Collector) GetWiFiInfo(serial string) ([]WiFiInfo, error) {
 		}
 	}
 
+	c.log.Info("已采集 WiFi 信息", "device", serial, "count", len(wifiList))
 	return wifiList, nil
 }
 
 // GetInstalledApps 获取已安装应用列表
 func (c *Collector) GetInstalledApps(serial string) ([]string, error) {
-	return c.adbMgr.ListPackages(serial)
+	return c.GetInstalledAppsContext(context.Background(), serial)
+}
+
+// GetInstalledAppsContext 可通过 ctx 取消的 GetInstalledApps
+func (c *Collector) GetInstalledAppsContext(ctx context.Context, serial string) ([]string, error) {
+	return c.adbMgr.ListPackagesContext(ctx, serial)
 }
 
 // AppPermission 应用权限信息
@@ -218,8 +252,13 @@ type AppPermission struct {
 
 // GetAppPermissions 获取应用权限
 func (c *Collector) GetAppPermissions(serial, packageName string) ([]AppPermission, error) {
-	output, err := c.adbMgr.ExecuteCommand(serial,
-		fmt.Sprintf("dumpsys package %s | grep permission", packageName))
+	return c.GetAppPermissionsContext(context.Background(), serial, packageName)
+}
+
+// GetAppPermissionsContext 可通过 ctx 取消的 GetAppPermissions
+func (c *Collector) GetAppPermissionsContext(ctx context.Context, serial, packageName string) ([]AppPermission, error) {
+	output, err := c.adbMgr.ExecuteCommandContext(ctx, serial,
+		adb.ShellJoin("dumpsys", "package", packageName)+" | grep permission")
 	if err != nil {
 		return nil, fmt.Errorf("获取应用权限失败: %v", err)
 	}
@@ -245,12 +284,18 @@ func (c *Collector) GetAppPermissions(serial, packageName string) ([]AppPermissi
 		}
 	}
 
+	c.log.Info("已采集应用权限", "device", serial, "package", packageName, "count", len(permissions))
 	return permissions, nil
 }
 
 // GetBatteryInfo 获取电池信息
 func (c *Collector) GetBatteryInfo(serial string) (map[string]string, error) {
-	output, err := c.adbMgr.ExecuteCommand(serial, "dumpsys battery")
+	return c.GetBatteryInfoContext(context.Background(), serial)
+}
+
+// GetBatteryInfoContext 可通过 ctx 取消的 GetBatteryInfo
+func (c *Collector) GetBatteryInfoContext(ctx context.Context, serial string) (map[string]string, error) {
+	output, err := c.adbMgr.ExecuteCommandContext(ctx, serial, "dumpsys battery")
 	if err != nil {
 		return nil, fmt.Errorf("获取电池信息失败: %v", err)
 	}
@@ -273,7 +318,12 @@ func (c *Collector) GetBatteryInfo(serial string) (map[string]string, error) {
 
 // GetSystemProperties 获取系统属性
 func (c *Collector) GetSystemProperties(serial string) (map[string]string, error) {
-	output, err := c.adbMgr.ExecuteCommand(serial, "getprop")
+	return c.GetSystemPropertiesContext(context.Background(), serial)
+}
+
+// GetSystemPropertiesContext 可通过 ctx 取消的 GetSystemProperties
+func (c *Collector) GetSystemPropertiesContext(ctx context.Context, serial string) (map[string]string, error) {
+	output, err := c.adbMgr.ExecuteCommandContext(ctx, serial, "getprop")
 	if err != nil {
 		return nil, fmt.Errorf("获取系统属性失败: %v", err)
 	}
@@ -0,0 +1,483 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxEntries 内存中为日志查看器保留的最近日志条数
+const maxEntries = 5000
+
+// 日志记录中有特殊含义的属性
+const (
+	KeyComponent = "component" // 产生日志的模块：adb、batch、scanner、collector、ui 等
+	KeyDevice    = "device"    // 设备序列号，带有该属性的日志同时写入设备单独的日志文件
+)
+
+// Format 日志文件的格式
+type Format string
+
+const (
+	FormatText Format = "text" // key=value 文本
+	FormatJSON Format = "json" // 每行一个 JSON 对象
+)
+
+// Config 日志配置，保存在 JSON 文件中
+type Config struct {
+	Level      string `json:"level"`       // debug / info / warn / error
+	Format     Format `json:"format"`      // text / json
+	Dir        string `json:"dir"`         // 日志目录，为空时使用 DefaultLogDir
+	MaxSizeMB  int    `json:"max_size_mb"` // 单个日志文件的大小上限，超过后轮转，0 表示不轮转
+	MaxBackups int    `json:"max_backups"` // 轮转后保留的旧文件个数
+	PerDevice  bool   `json:"per_device"`  // 带有设备序列号的日志是否另外写入 devices/<序列号>.log
+	Console    bool   `json:"console"`     // 是否同时输出到标准错误
+}
+
+// DefaultConfigPath 返回日志配置文件的默认路径
+func DefaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "logging.json"
+	}
+	return filepath.Join(dir, "adbmanager", "logging.json")
+}
+
+// DefaultLogDir 返回日志目录的默认路径
+func DefaultLogDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "logs"
+	}
+	return filepath.Join(dir, "adbmanager", "logs")
+}
+
+// DefaultConfig 默认配置：info 级别文本日志，单个文件 10 MB，保留 5 个旧文件，按设备分文件
+func DefaultConfig() Config {
+	return Config{
+		Level:      "info",
+		Format:     FormatText,
+		MaxSizeMB:  10,
+		MaxBackups: 5,
+		PerDevice:  true,
+	}
+}
+
+// LoadConfig 读取日志配置，文件不存在时返回默认配置
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return DefaultConfig(), fmt.Errorf("解析 %s 失败: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// SaveConfig 保存日志配置
+func SaveConfig(path string, cfg Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建配置目录失败: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("保存日志配置失败: %v", err)
+	}
+	return nil
+}
+
+// ParseLevel 解析日志级别，大小写不敏感
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return slog.LevelInfo, fmt.Errorf("未知的日志级别: %q", s)
+	}
+	return level, nil
+}
+
+// Entry 一条日志，供日志查看器显示与过滤
+type Entry struct {
+	Time      time.Time
+	Level     slog.Level
+	Component string
+	Device    string
+	Message   string
+	Attrs     string // 其余属性，key=value 以空格分隔
+}
+
+// String 日志查看器中的显示文字
+func (e Entry) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %-5s", e.Time.Format("15:04:05.000"), e.Level)
+	if e.Component != "" {
+		fmt.Fprintf(&b, " [%s]", e.Component)
+	}
+	if e.Device != "" {
+		fmt.Fprintf(&b, " %s:", e.Device)
+	}
+	b.WriteString(" " + e.Message)
+	if e.Attrs != "" {
+		b.WriteString("  " + e.Attrs)
+	}
+	return b.String()
+}
+
+// Manager 日志管理器：写入带轮转的日志文件与按设备的日志文件，并在内存中保留最近的日志供查看器使用
+type Manager struct {
+	cfg    Config
+	dir    string
+	level  *slog.LevelVar
+	logger *slog.Logger
+
+	mu          sync.Mutex
+	main        *rotatingFile
+	devices     map[string]*rotatingFile
+	entries     []Entry
+	subscribers map[int]func(Entry)
+	nextID      int
+	writeErr    error
+}
+
+// New 按配置创建日志管理器，日志目录不可写时返回错误
+func New(cfg Config) (*Manager, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Format != FormatJSON {
+		cfg.Format = FormatText
+	}
+	dir := cfg.Dir
+	if dir == "" {
+		dir = DefaultLogDir()
+	}
+
+	main, err := openRotating(filepath.Join(dir, "adbmanager.log"), cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manager{
+		cfg:         cfg,
+		dir:         dir,
+		level:       new(slog.LevelVar),
+		main:        main,
+		devices:     make(map[string]*rotatingFile),
+		entries:     make([]Entry, 0, maxEntries),
+		subscribers: make(map[int]func(Entry)),
+	}
+	m.level.Set(level)
+	m.logger = slog.New(&handler{m: m})
+	return m, nil
+}
+
+// Logger 返回写入本管理器的 logger
+func (m *Manager) Logger() *slog.Logger {
+	return m.logger
+}
+
+// Dir 日志目录
+func (m *Manager) Dir() string {
+	return m.dir
+}
+
+// Level 当前的日志级别
+func (m *Manager) Level() slog.Level {
+	return m.level.Level()
+}
+
+// SetLevel 修改日志级别，立即生效
+func (m *Manager) SetLevel(level slog.Level) {
+	m.level.Set(level)
+}
+
+// Close 关闭所有日志文件
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	errs := []error{m.main.Close()}
+	for _, file := range m.devices {
+		errs = append(errs, file.Close())
+	}
+	m.devices = make(map[string]*rotatingFile)
+	return errors.Join(errs...)
+}
+
+// Entries 返回内存中保留的日志，按时间顺序
+func (m *Manager) Entries() []Entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Entry(nil), m.entries...)
+}
+
+// Devices 返回内存中的日志涉及的设备，按序列号排序
+func (m *Manager) Devices() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[string]bool)
+	devices := make([]string, 0)
+	for _, entry := range m.entries {
+		if entry.Device != "" && !seen[entry.Device] {
+			seen[entry.Device] = true
+			devices = append(devices, entry.Device)
+		}
+	}
+	sort.Strings(devices)
+	return devices
+}
+
+// Subscribe 订阅新的日志，返回取消订阅的函数
+// 回调在写日志的协程中调用，不能再写日志，也不应长时间阻塞
+func (m *Manager) Subscribe(callback func(entry Entry)) func() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.nextID
+	m.nextID++
+	m.subscribers[id] = callback
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.subscribers, id)
+	}
+}
+
+// write 写入格式化后的日志行并保存条目，日志文件写入失败只在标准错误上报告一次
+func (m *Manager) write(entry Entry, line []byte) {
+	m.mu.Lock()
+	err := m.main.write(line)
+	if entry.Device != "" && m.cfg.PerDevice {
+		err = errors.Join(err, m.writeDevice(entry.Device, line))
+	}
+	if err != nil && m.writeErr == nil {
+		m.writeErr = err
+		fmt.Fprintf(os.Stderr, "写入日志失败: %v\n", err)
+	}
+	if m.cfg.Console {
+		os.Stderr.Write(line)
+	}
+
+	if len(m.entries) == maxEntries {
+		copy(m.entries, m.entries[1:])
+		m.entries = m.entries[:maxEntries-1]
+	}
+	m.entries = append(m.entries, entry)
+	callbacks := make([]func(Entry), 0, len(m.subscribers))
+	for _, callback := range m.subscribers {
+		callbacks = append(callbacks, callback)
+	}
+	m.mu.Unlock()
+
+	for _, callback := range callbacks {
+		callback(entry)
+	}
+}
+
+// writeDevice 写入设备单独的日志文件，调用方需持有锁
+func (m *Manager) writeDevice(device string, line []byte) error {
+	file, exists := m.devices[device]
+	if !exists {
+		var err error
+		file, err = openRotating(filepath.Join(m.dir, "devices", deviceFileName(device)), m.cfg)
+		if err != nil {
+			return err
+		}
+		m.devices[device] = file
+	}
+	return file.write(line)
+}
+
+// deviceFileName 设备日志的文件名，序列号中的 IP:PORT 等字符替换为下划线
+func deviceFileName(device string) string {
+	name := strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '/', '\\', '*', '?', '"', '<', '>', '|', ' ':
+			return '_'
+		}
+		return r
+	}, device)
+	return name + ".log"
+}
+
+// handler 将日志记录格式化为文本或 JSON 后交给 Manager 写入
+type handler struct {
+	m      *Manager
+	attrs  []slog.Attr                       // WithAttrs 添加的属性，分组内的属性名带有分组前缀
+	chain  []func(slog.Handler) slog.Handler // 依次重放 WithAttrs、WithGroup
+	prefix string                            // 当前分组的前缀，例如 "g."；分组内的属性不视为 component 与 device
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.m.level.Level()
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	var buf bytes.Buffer
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var format slog.Handler
+	if h.m.cfg.Format == FormatJSON {
+		format = slog.NewJSONHandler(&buf, opts)
+	} else {
+		format = slog.NewTextHandler(&buf, opts)
+	}
+	for _, apply := range h.chain {
+		format = apply(format)
+	}
+	if err := format.Handle(ctx, r); err != nil {
+		return err
+	}
+
+	entry := Entry{Time: r.Time, Level: r.Level, Message: r.Message}
+	var extra []string
+	collect := func(a slog.Attr) {
+		value := a.Value.Resolve().String()
+		switch a.Key {
+		case KeyComponent:
+			entry.Component = value
+		case KeyDevice:
+			entry.Device = value
+		default:
+			extra = append(extra, a.Key+"="+value)
+		}
+	}
+	for _, a := range h.attrs {
+		collect(a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		collect(slog.Attr{Key: h.prefix + a.Key, Value: a.Value})
+		return true
+	})
+	entry.Attrs = strings.Join(extra, " ")
+
+	h.m.write(entry, buf.Bytes())
+	return nil
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := *h
+	next.chain = append(append([]func(slog.Handler) slog.Handler(nil), h.chain...), func(format slog.Handler) slog.Handler {
+		return format.WithAttrs(attrs)
+	})
+	next.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, a := range attrs {
+		next.attrs = append(next.attrs, slog.Attr{Key: h.prefix + a.Key, Value: a.Value})
+	}
+	return &next
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	next := *h
+	next.chain = append(append([]func(slog.Handler) slog.Handler(nil), h.chain...), func(format slog.Handler) slog.Handler {
+		return format.WithGroup(name)
+	})
+	next.prefix = h.prefix + name + "."
+	return &next
+}
+
+// rotatingFile 超过大小上限后轮转的日志文件：path → path.1 → path.2 ...
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// openRotating 以追加方式打开日志文件
+func openRotating(path string, cfg Config) (*rotatingFile, error) {
+	f := &rotatingFile{
+		path:       path,
+		maxSize:    int64(cfg.MaxSizeMB) << 20,
+		maxBackups: cfg.MaxBackups,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return fmt.Errorf("创建日志目录失败: %v", err)
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开日志文件失败: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("打开日志文件失败: %v", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// write 写入一行，写入后超过大小上限时先轮转
+func (f *rotatingFile) write(line []byte) error {
+	if f.file == nil {
+		return fmt.Errorf("日志文件已关闭: %s", f.path)
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(line)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := f.file.Write(line)
+	f.size += int64(n)
+	return err
+}
+
+// rotate 关闭当前文件并依次重命名旧文件，超出 maxBackups 的最旧文件被删除
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	if f.maxBackups <= 0 {
+		os.Remove(f.path)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxBackups))
+		for i := f.maxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+		}
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			return fmt.Errorf("轮转日志文件失败: %v", err)
+		}
+	}
+	return f.open()
+}
+
+// Close 关闭文件
+func (f *rotatingFile) Close() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,167 @@
+package profile
+
+import (
+	"adbmanager/internal/adb"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// detectTimeout 设备连接后检测命令包装方式的超时时间
+const detectTimeout = 30 * time.Second
+
+// Store 各设备的命令包装配置，以 ro.serialno 为键保存在 JSON 文件中
+// 同一台设备换 IP 或改用 USB 连接后仍使用同一份配置
+type Store struct {
+	adbMgr *adb.ADBManager
+	log    *slog.Logger
+	path   string
+
+	mu       sync.Mutex
+	profiles map[string]adb.WrapperProfile
+}
+
+// DefaultProfilePath 返回配置文件的默认路径
+func DefaultProfilePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "profiles.json"
+	}
+	return filepath.Join(dir, "adbmanager", "profiles.json")
+}
+
+// NewStore 创建配置存储并读取 path 中已保存的配置
+func NewStore(adbMgr *adb.ADBManager, path string) *Store {
+	s := &Store{
+		adbMgr:   adbMgr,
+		log:      adbMgr.Logger().With("component", "profile"),
+		path:     path,
+		profiles: make(map[string]adb.WrapperProfile),
+	}
+	if err := s.Load(); err != nil {
+		s.log.Warn("读取命令包装配置失败", "path", path, "error", err)
+	}
+	return s
+}
+
+// Load 从文件读取配置，文件不存在时配置为空
+func (s *Store) Load() error {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	profiles := make(map[string]adb.WrapperProfile)
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return fmt.Errorf("解析 %s 失败: %v", s.path, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profiles = profiles
+	return nil
+}
+
+// save 将配置写入文件，调用方需持有锁
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.profiles, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("创建配置目录失败: %v", err)
+	}
+	if err := os.WriteFile(s.path, data, 0644); err != nil {
+		return fmt.Errorf("保存命令包装配置失败: %v", err)
+	}
+	return nil
+}
+
+// identity 设备的标识，优先使用 ro.serialno，读取失败时使用序列号
+func (s *Store) identity(ctx context.Context, serial string) string {
+	if hardwareSerial, err := s.adbMgr.ResolveIdentityContext(ctx, serial); err == nil && hardwareSerial != "" {
+		return hardwareSerial
+	}
+	return serial
+}
+
+// put 保存设备的配置并应用到 ADB 管理器
+func (s *Store) put(ctx context.Context, serial string, profile adb.WrapperProfile) error {
+	s.adbMgr.SetWrapperProfile(serial, profile)
+
+	id := s.identity(ctx, serial)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profiles[id] = profile
+	return s.save()
+}
+
+// Set 手动指定设备的命令包装配置并保存
+func (s *Store) Set(serial string, profile adb.WrapperProfile) error {
+	return s.SetContext(context.Background(), serial, profile)
+}
+
+// SetContext 可通过 ctx 取消的 Set
+func (s *Store) SetContext(ctx context.Context, serial string, profile adb.WrapperProfile) error {
+	profile.AutoDetected = false
+	return s.put(ctx, serial, profile)
+}
+
+// Detect 重新检测设备的命令包装方式，覆盖已保存的配置
+func (s *Store) Detect(serial string) (adb.WrapperProfile, error) {
+	return s.DetectContext(context.Background(), serial)
+}
+
+// DetectContext 可通过 ctx 取消的 Detect
+func (s *Store) DetectContext(ctx context.Context, serial string) (adb.WrapperProfile, error) {
+	profile, err := s.adbMgr.DetectWrapperProfileContext(ctx, serial)
+	if err != nil {
+		return profile, err
+	}
+	return profile, s.put(ctx, serial, profile)
+}
+
+// Apply 将已保存的配置应用到设备，没有保存过配置的设备先自动检测
+func (s *Store) Apply(serial string) (adb.WrapperProfile, error) {
+	return s.ApplyContext(context.Background(), serial)
+}
+
+// ApplyContext 可通过 ctx 取消的 Apply
+func (s *Store) ApplyContext(ctx context.Context, serial string) (adb.WrapperProfile, error) {
+	id := s.identity(ctx, serial)
+	s.mu.Lock()
+	profile, ok := s.profiles[id]
+	s.mu.Unlock()
+
+	if !ok {
+		return s.DetectContext(ctx, serial)
+	}
+	s.adbMgr.SetWrapperProfile(serial, profile)
+	return profile, nil
+}
+
+// Watch 订阅设备事件，设备连接或恢复在线时应用该设备的配置，返回取消订阅的函数
+func (s *Store) Watch(watcher *adb.DeviceWatcher) func() {
+	return watcher.Subscribe(func(event adb.DeviceEvent) {
+		if event.Device.Status != "device" || event.Type == adb.DeviceDisconnected {
+			return
+		}
+		serial := event.Device.Serial
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), detectTimeout)
+			defer cancel()
+			if _, err := s.ApplyContext(ctx, serial); err != nil {
+				s.log.Warn("应用命令包装配置失败", "device", serial, "error", err)
+			}
+		}()
+	})
+}
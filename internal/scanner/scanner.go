@@ -2,7 +2,9 @@ package scanner
 
 import (
 	"adbmanager/internal/adb"
+	"context"
 	"fmt"
+	"log/slog"
 	"regexp"
 	"strings"
 )
@@ -10,12 +12,14 @@ import (
 // Scanner 敏感信息扫描器
 type Scanner struct {
 	adbMgr *adb.ADBManager
+	log    *slog.Logger
 }
 
-// NewScanner 创建扫描器
+// NewScanner 创建扫描器，日志写入 adbMgr.Logger()
 func NewScanner(adbMgr *adb.ADBManager) *Scanner {
 	return &Scanner{
 		adbMgr: adbMgr,
+		log:    adbMgr.Logger().With("component", "scanner"),
 	}
 }
 
@@ -43,6 +47,11 @@ var sensitivePatterns = map[string]*regexp.Regexp{
 
 // ScanConfigFiles 扫描配置文件中的敏感信息
 func (s *Scanner) ScanConfigFiles(serial string) ([]SensitiveInfo, error) {
+	return s.ScanConfigFilesContext(context.Background(), serial)
+}
+
+// ScanConfigFilesContext 可通过 ctx 取消的 ScanConfigFiles
+func (s *Scanner) ScanConfigFilesContext(ctx context.Context, serial string) ([]SensitiveInfo, error) {
 	results := make([]SensitiveInfo, 0)
 
 	// 常见配置文件路径
@@ -57,28 +66,38 @@ func (s *Scanner) ScanConfigFiles(serial string) ([]SensitiveInfo, error) {
 		"/sdcard/*.conf",
 	}
 
+	s.log.Info("扫描配置文件", "device", serial)
 	for _, pattern := range configPaths {
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
 		// 查找匹配的文件
-		files, err := s.findFiles(serial, pattern)
+		files, err := s.findFiles(ctx, serial, pattern)
 		if err != nil {
+			s.log.Debug("查找文件失败", "device", serial, "pattern", pattern, "error", err)
 			continue
 		}
 
 		// 扫描每个文件
 		for _, file := range files {
-			fileResults, err := s.scanFile(serial, file)
+			if ctx.Err() != nil {
+				return results, ctx.Err()
+			}
+			fileResults, err := s.scanFile(ctx, serial, file)
 			if err != nil {
+				s.log.Debug("读取文件失败", "device", serial, "path", file, "error", err)
 				continue
 			}
 			results = append(results, fileResults...)
 		}
 	}
 
+	s.log.Info("扫描完成", "device", serial, "found", len(results))
 	return results, nil
 }
 
 // findFiles 查找文件
-func (s *Scanner) findFiles(serial, pattern string) ([]string, error) {
+func (s *Scanner) findFiles(ctx context.Context, serial, pattern string) ([]string, error) {
 	// 提取目录和文件模式
 	lastSlash := strings.LastIndex(pattern, "/")
 	if lastSlash == -1 {
@@ -89,14 +108,15 @@ func (s *Scanner) findFiles(serial, pattern string) ([]string, error) {
 	filePattern := pattern[lastSlash+1:]
 
 	// 使用 find 命令查找文件
-	cmd := fmt.Sprintf("find %s -name '%s' 2>/dev/null", dir, filePattern)
-	output, err := s.adbMgr.ExecuteCommand(serial, cmd)
+	// 部分目录无权限时 find 以非零状态退出，但已找到的文件仍然有效
+	cmd := adb.ShellJoin("find", dir, "-name", filePattern) + " 2>/dev/null"
+	result, err := s.adbMgr.ExecuteShellContext(ctx, serial, cmd)
 	if err != nil {
 		return nil, err
 	}
 
 	files := make([]string, 0)
-	lines := strings.Split(output, "\n")
+	lines := strings.Split(result.Stdout, "\n")
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
 		if line != "" && !strings.Contains(line, "Permission denied") {
@@ -108,15 +128,18 @@ func (s *Scanner) findFiles(serial, pattern string) ([]string, error) {
 }
 
 // scanFile 扫描单个文件
-func (s *Scanner) scanFile(serial, filePath string) ([]SensitiveInfo, error) {
+func (s *Scanner) scanFile(ctx context.Context, serial, filePath string) ([]SensitiveInfo, error) {
 	// 读取文件内容
-	content, err := s.adbMgr.ExecuteCommand(serial, fmt.Sprintf("cat %s", filePath))
+	result, err := s.adbMgr.ExecuteArgsContext(ctx, serial, "cat", filePath)
 	if err != nil {
 		return nil, err
 	}
+	if result.ExitCode != 0 && result.Stdout == "" {
+		return nil, fmt.Errorf("读取文件失败: %s", strings.TrimSpace(result.Stderr))
+	}
 
 	results := make([]SensitiveInfo, 0)
-	lines := strings.Split(content, "\n")
+	lines := strings.Split(result.Stdout, "\n")
 
 	// 对每一行应用所有匹配模式
 	for lineNum, line := range lines {
@@ -145,21 +168,30 @@ func (s *Scanner) scanFile(serial, filePath string) ([]SensitiveInfo, error) {
 
 // ScanSharedPreferences 扫描 SharedPreferences
 func (s *Scanner) ScanSharedPreferences(serial, packageName string) ([]SensitiveInfo, error) {
+	return s.ScanSharedPreferencesContext(context.Background(), serial, packageName)
+}
+
+// ScanSharedPreferencesContext 可通过 ctx 取消的 ScanSharedPreferences
+func (s *Scanner) ScanSharedPreferencesContext(ctx context.Context, serial, packageName string) ([]SensitiveInfo, error) {
 	results := make([]SensitiveInfo, 0)
 
 	// SharedPreferences 路径
 	prefsPath := fmt.Sprintf("/data/data/%s/shared_prefs/", packageName)
 
 	// 列出所有 XML 文件
-	files, err := s.findFiles(serial, prefsPath+"*.xml")
+	files, err := s.findFiles(ctx, serial, prefsPath+"*.xml")
 	if err != nil {
 		return nil, err
 	}
 
 	// 扫描每个文件
 	for _, file := range files {
-		fileResults, err := s.scanFile(serial, file)
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+		fileResults, err := s.scanFile(ctx, serial, file)
 		if err != nil {
+			s.log.Debug("读取文件失败", "device", serial, "path", file, "error", err)
 			continue
 		}
 		results = append(results, fileResults...)
@@ -170,10 +202,15 @@ func (s *Scanner) ScanSharedPreferences(serial, packageName string) ([]Sensitive
 
 // ScanDatabases 扫描数据库
 func (s *Scanner) ScanDatabases(serial, packageName string) ([]string, error) {
+	return s.ScanDatabasesContext(context.Background(), serial, packageName)
+}
+
+// ScanDatabasesContext 可通过 ctx 取消的 ScanDatabases
+func (s *Scanner) ScanDatabasesContext(ctx context.Context, serial, packageName string) ([]string, error) {
 	dbPath := fmt.Sprintf("/data/data/%s/databases/", packageName)
 
 	// 列出所有数据库文件
-	files, err := s.findFiles(serial, dbPath+"*.db")
+	files, err := s.findFiles(ctx, serial, dbPath+"*.db")
 	if err != nil {
 		return nil, err
 	}
@@ -183,11 +220,21 @@ func (s *Scanner) ScanDatabases(serial, packageName string) ([]string, error) {
 
 // ExportDatabase 导出数据库
 func (s *Scanner) ExportDatabase(serial, dbPath, localPath string) error {
-	return s.adbMgr.PullFile(serial, dbPath, localPath)
+	return s.ExportDatabaseContext(context.Background(), serial, dbPath, localPath)
+}
+
+// ExportDatabaseContext 可通过 ctx 取消的 ExportDatabase
+func (s *Scanner) ExportDatabaseContext(ctx context.Context, serial, dbPath, localPath string) error {
+	return s.adbMgr.PullFileContext(ctx, serial, dbPath, localPath)
 }
 
 // ScanLogFiles 扫描日志文件
 func (s *Scanner) ScanLogFiles(serial string) ([]SensitiveInfo, error) {
+	return s.ScanLogFilesContext(context.Background(), serial)
+}
+
+// ScanLogFilesContext 可通过 ctx 取消的 ScanLogFiles
+func (s *Scanner) ScanLogFilesContext(ctx context.Context, serial string) ([]SensitiveInfo, error) {
 	results := make([]SensitiveInfo, 0)
 
 	// 常见日志文件路径
@@ -197,43 +244,67 @@ func (s *Scanner) ScanLogFiles(serial string) ([]SensitiveInfo, error) {
 		"/data/local/tmp/*.log",
 	}
 
+	s.log.Info("扫描日志文件", "device", serial)
 	for _, pattern := range logPaths {
-		files, err := s.findFiles(serial, pattern)
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+		files, err := s.findFiles(ctx, serial, pattern)
 		if err != nil {
+			s.log.Debug("查找文件失败", "device", serial, "pattern", pattern, "error", err)
 			continue
 		}
 
 		for _, file := range files {
-			fileResults, err := s.scanFile(serial, file)
+			if ctx.Err() != nil {
+				return results, ctx.Err()
+			}
+			fileResults, err := s.scanFile(ctx, serial, file)
 			if err != nil {
+				s.log.Debug("读取文件失败", "device", serial, "path", file, "error", err)
 				continue
 			}
 			results = append(results, fileResults...)
 		}
 	}
 
+	s.log.Info("扫描完成", "device", serial, "found", len(results))
 	return results, nil
 }
 
 // GetInstalledCertificates 获取已安装的证书
 func (s *Scanner) GetInstalledCertificates(serial string) (string, error) {
-	return s.adbMgr.ExecuteCommand(serial, "ls -la /system/etc/security/cacerts/")
+	return s.GetInstalledCertificatesContext(context.Background(), serial)
+}
+
+// GetInstalledCertificatesContext 可通过 ctx 取消的 GetInstalledCertificates
+func (s *Scanner) GetInstalledCertificatesContext(ctx context.Context, serial string) (string, error) {
+	return s.adbMgr.ExecuteCommandContext(ctx, serial, "ls -la /system/etc/security/cacerts/")
 }
 
 // CheckRootStatus 检查设备是否已 root
 func (s *Scanner) CheckRootStatus(serial string) (bool, error) {
-	output, err := s.adbMgr.ExecuteCommand(serial, "su -c 'id'")
+	return s.CheckRootStatusContext(context.Background(), serial)
+}
+
+// CheckRootStatusContext 可通过 ctx 取消的 CheckRootStatus
+func (s *Scanner) CheckRootStatusContext(ctx context.Context, serial string) (bool, error) {
+	method, err := s.adbMgr.DetectRootMethodContext(ctx, serial)
 	if err != nil {
-		return false, nil
+		return false, err
 	}
-
-	return strings.Contains(output, "uid=0"), nil
+	return method.HasRoot(), nil
 }
 
 // GetAppDataSize 获取应用数据大小
 func (s *Scanner) GetAppDataSize(serial, packageName string) (map[string]string, error) {
-	output, err := s.adbMgr.ExecuteCommand(serial,
-		fmt.Sprintf("dumpsys package %s | grep -A 5 'dataDir'", packageName))
+	return s.GetAppDataSizeContext(context.Background(), serial, packageName)
+}
+
+// GetAppDataSizeContext 可通过 ctx 取消的 GetAppDataSize
+func (s *Scanner) GetAppDataSizeContext(ctx context.Context, serial, packageName string) (map[string]string, error) {
+	output, err := s.adbMgr.ExecuteCommandContext(ctx, serial,
+		adb.ShellJoin("dumpsys", "package", packageName)+" | grep -A 5 'dataDir'")
 	if err != nil {
 		return nil, err
 	}
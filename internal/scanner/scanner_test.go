@@ -0,0 +1,123 @@
+package scanner
+
+import (
+	"adbmanager/internal/adb"
+	"io"
+	"log/slog"
+	"sort"
+	"testing"
+)
+
+// newTestScanner 创建连接到模拟 adb server 的扫描器
+func newTestScanner(t *testing.T) (*Scanner, *adb.FakeServer) {
+	t.Helper()
+	srv, err := adb.NewFakeServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	srv.AddDevice("emulator-5554", "device", "product:sdk model:Pixel_7 device:emu64xa")
+
+	adbMgr := adb.NewADBManagerWithRunner(adb.NewServerRunner(srv.Addr(), nil))
+	adbMgr.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	return NewScanner(adbMgr), srv
+}
+
+// findCommand 与 findFiles 发出的 find 命令一致
+func findCommand(dir, name string) string {
+	return adb.ShellJoin("find", dir, "-name", name) + " 2>/dev/null"
+}
+
+func TestScanSharedPreferences(t *testing.T) {
+	s, srv := newTestScanner(t)
+	const prefs = "/data/data/com.example.app/shared_prefs"
+	srv.SetShellResult("emulator-5554", findCommand(prefs, "*.xml"), adb.ShellResult{
+		Stdout:   prefs + "/login.xml\n" + prefs + "/my settings.xml\nfind: '/data/data/x': Permission denied\n",
+		ExitCode: 1,
+	})
+	srv.SetShell("emulator-5554", adb.ShellJoin("cat", prefs+"/login.xml"),
+		"<?xml version='1.0' encoding='utf-8' standalone='yes' ?>\n"+
+			"<map>\n"+
+			"    <string name=\"email\">alice@example.com</string>\n"+
+			"    <!-- password=hunter2 -->\n"+
+			"</map>\n")
+	srv.SetShell("emulator-5554", adb.ShellJoin("cat", prefs+"/my settings.xml"),
+		"<map><string name=\"endpoint\">https://api.example.com/v1</string></map>\n")
+
+	results, err := s.ScanSharedPreferences("emulator-5554", "com.example.app")
+	if err != nil {
+		t.Fatalf("ScanSharedPreferences: %v", err)
+	}
+
+	got := make([]string, 0, len(results))
+	for _, r := range results {
+		got = append(got, r.Type+" "+r.Value+" "+r.FilePath+" "+r.Line)
+	}
+	sort.Strings(got)
+	want := []string{
+		"email alice@example.com " + prefs + "/login.xml Line 3",
+		"password hunter2 " + prefs + "/login.xml Line 4",
+		"url https://api.example.com/v1 " + prefs + "/my settings.xml Line 1",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("results =\n%q\nwant\n%q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("result %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestScanDatabasesUnreadableFile(t *testing.T) {
+	s, srv := newTestScanner(t)
+	const dbs = "/data/data/com.example.app/databases"
+	srv.SetShell("emulator-5554", findCommand(dbs, "*.db"), dbs+"/app.db\n"+dbs+"/cache.db\n")
+
+	files, err := s.ScanDatabases("emulator-5554", "com.example.app")
+	if err != nil {
+		t.Fatalf("ScanDatabases: %v", err)
+	}
+	if len(files) != 2 || files[0] != dbs+"/app.db" || files[1] != dbs+"/cache.db" {
+		t.Fatalf("files = %q", files)
+	}
+
+	// 读取失败的文件被跳过，不影响其他文件
+	srv.SetShell("emulator-5554", findCommand("/data/data/com.example.app/shared_prefs", "*.xml"),
+		"/data/data/com.example.app/shared_prefs/a.xml\n")
+	srv.SetShellResult("emulator-5554", adb.ShellJoin("cat", "/data/data/com.example.app/shared_prefs/a.xml"),
+		adb.ShellResult{Stderr: "cat: a.xml: Permission denied\n", ExitCode: 1})
+	results, err := s.ScanSharedPreferences("emulator-5554", "com.example.app")
+	if err != nil || len(results) != 0 {
+		t.Fatalf("results = %+v, err = %v", results, err)
+	}
+}
+
+func TestCheckRootStatus(t *testing.T) {
+	s, srv := newTestScanner(t)
+	// adbd 未以 root 运行，Magisk 的 su -c 可用
+	srv.SetShell("emulator-5554", adb.RootAdbd.Command("id"), "uid=2000(shell) gid=2000(shell)\n")
+	srv.SetShell("emulator-5554", adb.RootMagisk.Command("id"), "uid=0(root) gid=0(root) context=u:r:magisk:s0\n")
+
+	rooted, err := s.CheckRootStatus("emulator-5554")
+	if err != nil {
+		t.Fatalf("CheckRootStatus: %v", err)
+	}
+	if !rooted {
+		t.Fatal("CheckRootStatus = false, want true")
+	}
+}
+
+func TestCheckRootStatusNoRoot(t *testing.T) {
+	s, srv := newTestScanner(t)
+	srv.SetShell("emulator-5554", adb.RootAdbd.Command("id"), "uid=2000(shell) gid=2000(shell)\n")
+
+	// 其余方式的 su 不存在（模拟设备返回 not found）
+	rooted, err := s.CheckRootStatus("emulator-5554")
+	if err != nil {
+		t.Fatalf("CheckRootStatus: %v", err)
+	}
+	if rooted {
+		t.Fatal("CheckRootStatus = true, want false")
+	}
+}
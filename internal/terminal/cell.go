@@ -0,0 +1,135 @@
+package terminal
+
+import "image/color"
+
+// Color 单元格颜色：默认色、256 色调色板索引或 24 位 RGB
+type Color uint32
+
+const (
+	// DefaultColor 终端的默认前景或背景色，由界面决定
+	DefaultColor Color = 0
+
+	colorIndexed Color = 1 << 24
+	colorRGB     Color = 2 << 24
+	colorKind    Color = 0xff << 24
+)
+
+// IndexedColor 256 色调色板中的颜色，0-15 为 ANSI 颜色
+func IndexedColor(index uint8) Color {
+	return colorIndexed | Color(index)
+}
+
+// RGBColor 24 位真彩色
+func RGBColor(r, g, b uint8) Color {
+	return colorRGB | Color(r)<<16 | Color(g)<<8 | Color(b)
+}
+
+// Indexed 返回调色板索引，不是调色板颜色时 ok 为 false
+func (c Color) Indexed() (index uint8, ok bool) {
+	return uint8(c), c&colorKind == colorIndexed
+}
+
+// RGBA 转换为具体颜色，默认色返回 fallback
+func (c Color) RGBA(fallback color.RGBA) color.RGBA {
+	switch c & colorKind {
+	case colorIndexed:
+		return PaletteColor(uint8(c))
+	case colorRGB:
+		return color.RGBA{R: uint8(c >> 16), G: uint8(c >> 8), B: uint8(c), A: 0xff}
+	}
+	return fallback
+}
+
+// ansiColors 16 种 ANSI 颜色，适合深色背景
+var ansiColors = [16]color.RGBA{
+	{0x00, 0x00, 0x00, 0xff}, {0xcd, 0x31, 0x31, 0xff}, {0x0d, 0xbc, 0x79, 0xff}, {0xe5, 0xe5, 0x10, 0xff},
+	{0x24, 0x72, 0xc8, 0xff}, {0xbc, 0x3f, 0xbc, 0xff}, {0x11, 0xa8, 0xcd, 0xff}, {0xe5, 0xe5, 0xe5, 0xff},
+	{0x66, 0x66, 0x66, 0xff}, {0xf1, 0x4c, 0x4c, 0xff}, {0x23, 0xd1, 0x8b, 0xff}, {0xf5, 0xf5, 0x43, 0xff},
+	{0x3b, 0x8e, 0xea, 0xff}, {0xd6, 0x70, 0xd6, 0xff}, {0x29, 0xb8, 0xdb, 0xff}, {0xff, 0xff, 0xff, 0xff},
+}
+
+// PaletteColor xterm 256 色调色板：16 种 ANSI 颜色、6x6x6 色立方与 24 级灰度
+func PaletteColor(index uint8) color.RGBA {
+	switch {
+	case index < 16:
+		return ansiColors[index]
+	case index < 232:
+		levels := [6]uint8{0x00, 0x5f, 0x87, 0xaf, 0xd7, 0xff}
+		i := index - 16
+		return color.RGBA{R: levels[i/36], G: levels[i/6%6], B: levels[i%6], A: 0xff}
+	default:
+		gray := 8 + (index-232)*10
+		return color.RGBA{R: gray, G: gray, B: gray, A: 0xff}
+	}
+}
+
+// Attr 字符属性
+type Attr uint8
+
+const (
+	AttrBold Attr = 1 << iota
+	AttrDim
+	AttrItalic
+	AttrUnderline
+	AttrBlink
+	AttrReverse
+	AttrHidden
+	AttrStrike
+)
+
+// Cell 屏幕上的一个字符位置
+// 宽字符（中文等）占两个位置，第二个位置的 Rune 为 0
+type Cell struct {
+	Rune rune
+	FG   Color
+	BG   Color
+	Attr Attr
+}
+
+// Line 一行单元格
+type Line struct {
+	Cells   []Cell
+	Wrapped bool // 该行写满后自动折到下一行，复制时与下一行相连
+}
+
+// pen 写入字符时使用的颜色与属性
+type pen struct {
+	fg   Color
+	bg   Color
+	attr Attr
+}
+
+// blank 以当前背景色填充的空白单元格
+func (p pen) blank() Cell {
+	return Cell{Rune: ' ', BG: p.bg}
+}
+
+// newLine 创建 cols 个空白单元格的行
+func newLine(cols int, p pen) *Line {
+	line := &Line{Cells: make([]Cell, cols)}
+	blank := p.blank()
+	for i := range line.Cells {
+		line.Cells[i] = blank
+	}
+	return line
+}
+
+// clone 复制一行，供界面在锁外使用
+func (l *Line) clone() Line {
+	return Line{Cells: append([]Cell(nil), l.Cells...), Wrapped: l.Wrapped}
+}
+
+// resize 调整行宽，新增部分为空白
+func (l *Line) resize(cols int) {
+	if len(l.Cells) >= cols {
+		l.Cells = l.Cells[:cols]
+		// 截断处落在宽字符中间时清除残留的一半
+		if cols > 0 && l.Cells[cols-1].Rune != 0 && runeWidth(l.Cells[cols-1].Rune) == 2 {
+			l.Cells[cols-1].Rune = ' '
+		}
+		return
+	}
+	for len(l.Cells) < cols {
+		l.Cells = append(l.Cells, Cell{Rune: ' '})
+	}
+}
@@ -0,0 +1,553 @@
+package terminal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 控制序列解析器的状态
+const (
+	stateGround = iota
+	stateEscape
+	stateEscapeIntermediate
+	stateCSI
+	stateOSC
+	stateOSCEscape
+	stateString // DCS / SOS / PM / APC，内容被忽略
+	stateStringEscape
+)
+
+// maxOSCLength OSC 字符串的长度上限
+const maxOSCLength = 4096
+
+// maxCSIParams CSI 参数个数与每个参数的子参数个数上限，超出的部分被忽略，避免只有分隔符的序列无限占用内存
+const maxCSIParams = 32
+
+// parser 控制序列解析状态
+type parser struct {
+	state         int
+	params        [][]int // 每个参数及其以冒号分隔的子参数，省略的值为 -1
+	dropped       bool    // 参数个数已达上限，之后的值被忽略
+	value         int
+	hasValue      bool
+	private       rune // CSI 后的 ? > = < 标记
+	intermediates []rune
+	osc           []rune
+}
+
+// feed 处理一个字符，调用方需持有锁
+func (s *Screen) feed(r rune) {
+	p := &s.parser
+
+	// CAN、SUB 中止任何序列；ESC 开始新的序列（字符串中的 ESC 可能是 ST 的开头）
+	switch r {
+	case 0x18, 0x1a:
+		p.state = stateGround
+		return
+	case 0x1b:
+		switch p.state {
+		case stateOSC:
+			p.state = stateOSCEscape
+		case stateString:
+			p.state = stateStringEscape
+		default:
+			p.state = stateEscape
+			p.intermediates = p.intermediates[:0]
+		}
+		return
+	}
+
+	switch p.state {
+	case stateGround:
+		if r < 0x20 || r == 0x7f {
+			s.control(r)
+			return
+		}
+		s.put(r)
+
+	case stateEscape:
+		if r < 0x20 {
+			s.control(r)
+			return
+		}
+		s.escape(r)
+
+	case stateEscapeIntermediate:
+		switch {
+		case r < 0x20:
+			s.control(r)
+		case r < 0x30:
+			p.intermediates = append(p.intermediates, r)
+		default:
+			s.escapeIntermediate(r)
+			p.state = stateGround
+		}
+
+	case stateCSI:
+		switch {
+		case r < 0x20:
+			s.control(r)
+		case r >= '0' && r <= '9':
+			p.value = min(p.value*10+int(r-'0'), 65535)
+			p.hasValue = true
+		case r == ';':
+			p.endValue()
+			if len(p.params) < maxCSIParams {
+				p.params = append(p.params, nil)
+			} else {
+				p.dropped = true
+			}
+		case r == ':':
+			p.endValue()
+		case r >= '<' && r <= '?':
+			if len(p.params) == 1 && len(p.params[0]) == 0 && !p.hasValue {
+				p.private = r
+			}
+		case r >= 0x20 && r < 0x30:
+			p.intermediates = append(p.intermediates, r)
+		case r >= 0x40 && r <= 0x7e:
+			p.endValue()
+			s.csi(r)
+			p.state = stateGround
+		}
+
+	case stateOSC:
+		switch {
+		case r == 0x07:
+			s.osc()
+			p.state = stateGround
+		case len(p.osc) < maxOSCLength:
+			p.osc = append(p.osc, r)
+		}
+
+	case stateOSCEscape:
+		// ESC \ 结束 OSC，其他字符视为新的转义序列
+		s.osc()
+		p.state = stateGround
+		if r != '\\' {
+			p.state = stateEscape
+			p.intermediates = p.intermediates[:0]
+			s.feed(r)
+		}
+
+	case stateString:
+		if r == 0x07 {
+			p.state = stateGround
+		}
+
+	case stateStringEscape:
+		p.state = stateGround
+		if r != '\\' {
+			p.state = stateEscape
+			p.intermediates = p.intermediates[:0]
+			s.feed(r)
+		}
+	}
+}
+
+// endValue 结束当前参数值，调用方需持有锁
+func (p *parser) endValue() {
+	value := -1
+	if p.hasValue {
+		value = p.value
+	}
+	p.value, p.hasValue = 0, false
+	last := len(p.params) - 1
+	if p.dropped || len(p.params[last]) >= maxCSIParams {
+		return
+	}
+	p.params[last] = append(p.params[last], value)
+}
+
+// param 第 i 个参数，省略或为 0 时返回 def
+func (p *parser) param(i, def int) int {
+	if i < len(p.params) && len(p.params[i]) > 0 && p.params[i][0] > 0 {
+		return p.params[i][0]
+	}
+	return def
+}
+
+// control 执行 C0 控制字符，调用方需持有锁
+func (s *Screen) control(r rune) {
+	switch r {
+	case '\b':
+		if s.cur.x > 0 {
+			s.cur.x--
+		}
+		s.cur.wrapPending = false
+	case '\t':
+		s.tab(1)
+	case '\n', '\v', '\f':
+		if s.newline {
+			s.cur.x = 0
+		}
+		s.lineFeed()
+	case '\r':
+		s.cur.x = 0
+		s.cur.wrapPending = false
+	case 0x0e: // SO
+		s.cur.shift = 1
+	case 0x0f: // SI
+		s.cur.shift = 0
+	}
+}
+
+// escape 执行 ESC 开头的序列，调用方需持有锁
+func (s *Screen) escape(r rune) {
+	p := &s.parser
+	p.state = stateGround
+	switch r {
+	case '[':
+		p.state = stateCSI
+		p.params = [][]int{nil}
+		p.dropped = false
+		p.value, p.hasValue = 0, false
+		p.private = 0
+		p.intermediates = p.intermediates[:0]
+	case ']':
+		p.state = stateOSC
+		p.osc = p.osc[:0]
+	case 'P', 'X', '^', '_':
+		p.state = stateString
+	case '7':
+		s.saved = s.cur
+	case '8':
+		s.restoreCursor(s.saved)
+	case 'D':
+		s.lineFeed()
+	case 'E':
+		s.cur.x = 0
+		s.lineFeed()
+	case 'H':
+		s.tabs[s.cur.x] = true
+	case 'M':
+		s.reverseIndex()
+	case 'c':
+		s.reset()
+	case '=', '>':
+		// 数字键盘模式，键盘输入不区分
+	default:
+		if r >= 0x20 && r < 0x30 {
+			p.intermediates = append(p.intermediates, r)
+			p.state = stateEscapeIntermediate
+		}
+	}
+}
+
+// escapeIntermediate 执行带中间字符的 ESC 序列（字符集选择、DECALN），调用方需持有锁
+func (s *Screen) escapeIntermediate(r rune) {
+	switch s.parser.intermediates[0] {
+	case '(':
+		s.cur.graphics[0] = r == '0'
+	case ')':
+		s.cur.graphics[1] = r == '0'
+	case '#':
+		if r == '8' {
+			// DECALN：以 E 填满屏幕
+			for _, line := range s.lines {
+				for x := range line.Cells {
+					line.Cells[x] = Cell{Rune: 'E'}
+				}
+			}
+			s.moveTo(0, 0)
+		}
+	}
+}
+
+// csi 执行 CSI 序列，调用方需持有锁
+func (s *Screen) csi(final rune) {
+	p := &s.parser
+	n := p.param(0, 1)
+
+	switch {
+	case p.private == '?':
+		if final == 'h' || final == 'l' {
+			for _, param := range p.params {
+				if len(param) > 0 {
+					s.setPrivateMode(param[0], final == 'h')
+				}
+			}
+		}
+		return
+	case p.private == '>':
+		if final == 'c' {
+			s.respond("\x1b[>0;10;1c") // 次设备属性
+		}
+		return
+	case p.private != 0:
+		return
+	case len(p.intermediates) > 0:
+		if p.intermediates[0] == '!' && final == 'p' {
+			s.softReset()
+		}
+		return // 光标形状（SP q）等不影响内容
+	}
+
+	switch final {
+	case '@':
+		s.insertChars(n)
+	case 'A':
+		s.moveVertical(-n)
+	case 'B', 'e':
+		s.moveVertical(n)
+	case 'C', 'a':
+		s.moveTo(s.cur.x+n, s.cur.y)
+	case 'D':
+		s.moveTo(s.cur.x-n, s.cur.y)
+	case 'E':
+		s.moveVertical(n)
+		s.cur.x = 0
+	case 'F':
+		s.moveVertical(-n)
+		s.cur.x = 0
+	case 'G', '`':
+		s.moveTo(n-1, s.cur.y)
+	case 'H', 'f':
+		s.setPosition(p.param(0, 1), p.param(1, 1))
+	case 'I':
+		s.tab(n)
+	case 'J':
+		s.eraseDisplay(p.param(0, 0))
+	case 'K':
+		s.eraseLine(p.param(0, 0))
+	case 'L':
+		s.insertLines(n)
+	case 'M':
+		s.deleteLines(n)
+	case 'P':
+		s.deleteChars(n)
+	case 'S':
+		s.scrollUp(n)
+	case 'T':
+		s.scrollDown(n)
+	case 'X':
+		s.fill(s.lines[s.cur.y], s.cur.x, s.cur.x+n)
+		s.cur.wrapPending = false
+	case 'Z':
+		s.tab(-n)
+	case 'b':
+		if s.lastRune != 0 {
+			for i := 0; i < min(n, s.cols*s.rows); i++ {
+				s.put(s.lastRune)
+			}
+		}
+	case 'c':
+		if p.param(0, 0) == 0 {
+			s.respond("\x1b[?62;22c") // VT220，支持 ANSI 颜色
+		}
+	case 'd':
+		s.setPosition(n, s.cur.x+1)
+	case 'g':
+		switch p.param(0, 0) {
+		case 0:
+			s.tabs[s.cur.x] = false
+		case 3:
+			clear(s.tabs)
+		}
+	case 'h', 'l':
+		for _, param := range p.params {
+			if len(param) == 0 {
+				continue
+			}
+			switch param[0] {
+			case 4:
+				s.insert = final == 'h'
+			case 20:
+				s.newline = final == 'h'
+			}
+		}
+	case 'm':
+		s.sgr()
+	case 'n':
+		switch p.param(0, 0) {
+		case 5:
+			s.respond("\x1b[0n")
+		case 6:
+			row := s.cur.y + 1
+			if s.cur.origin {
+				row -= s.top
+			}
+			s.respond(fmt.Sprintf("\x1b[%d;%dR", row, s.cur.x+1))
+		}
+	case 'r':
+		s.setScrollRegion(p.param(0, 1), p.param(1, s.rows))
+	case 's':
+		s.saved = s.cur
+	case 't':
+		if p.param(0, 0) == 18 {
+			s.respond(fmt.Sprintf("\x1b[8;%d;%dt", s.rows, s.cols))
+		}
+	case 'u':
+		s.restoreCursor(s.saved)
+	}
+}
+
+// setPrivateMode DECSET / DECRST，调用方需持有锁
+func (s *Screen) setPrivateMode(mode int, on bool) {
+	switch mode {
+	case 1:
+		s.appCursor = on
+	case 6:
+		s.cur.origin = on
+		s.setPosition(1, 1)
+	case 7:
+		s.autowrap = on
+		if !on {
+			s.cur.wrapPending = false
+		}
+	case 25:
+		s.cursorVisible = on
+	case 47, 1047:
+		if on {
+			s.enterAlt()
+		} else {
+			s.exitAlt()
+		}
+	case 1048:
+		if on {
+			s.saved = s.cur
+		} else {
+			s.restoreCursor(s.saved)
+		}
+	case 1049:
+		if on {
+			s.enterAlt()
+		} else if s.alt {
+			s.exitAlt()
+			s.restoreCursor(s.altSaved)
+		}
+	case 2004:
+		s.bracketedPaste = on
+	}
+}
+
+// sgr 设置字符属性与颜色，调用方需持有锁
+func (s *Screen) sgr() {
+	params := s.parser.params
+	cur := &s.cur.pen
+	for i := 0; i < len(params); i++ {
+		param := params[i]
+		code := 0
+		if len(param) > 0 && param[0] > 0 {
+			code = param[0]
+		}
+
+		switch {
+		case code == 0:
+			*cur = pen{}
+		case code == 1:
+			cur.attr |= AttrBold
+		case code == 2:
+			cur.attr |= AttrDim
+		case code == 3:
+			cur.attr |= AttrItalic
+		case code == 4:
+			if len(param) > 1 && param[1] == 0 {
+				cur.attr &^= AttrUnderline
+			} else {
+				cur.attr |= AttrUnderline
+			}
+		case code == 5 || code == 6:
+			cur.attr |= AttrBlink
+		case code == 7:
+			cur.attr |= AttrReverse
+		case code == 8:
+			cur.attr |= AttrHidden
+		case code == 9:
+			cur.attr |= AttrStrike
+		case code == 21:
+			cur.attr |= AttrUnderline
+		case code == 22:
+			cur.attr &^= AttrBold | AttrDim
+		case code == 23:
+			cur.attr &^= AttrItalic
+		case code == 24:
+			cur.attr &^= AttrUnderline
+		case code == 25:
+			cur.attr &^= AttrBlink
+		case code == 27:
+			cur.attr &^= AttrReverse
+		case code == 28:
+			cur.attr &^= AttrHidden
+		case code == 29:
+			cur.attr &^= AttrStrike
+		case code >= 30 && code <= 37:
+			cur.fg = IndexedColor(uint8(code - 30))
+		case code == 38:
+			var c Color
+			c, i = extendedColor(params, i)
+			if c != DefaultColor {
+				cur.fg = c
+			}
+		case code == 39:
+			cur.fg = DefaultColor
+		case code >= 40 && code <= 47:
+			cur.bg = IndexedColor(uint8(code - 40))
+		case code == 48:
+			var c Color
+			c, i = extendedColor(params, i)
+			if c != DefaultColor {
+				cur.bg = c
+			}
+		case code == 49:
+			cur.bg = DefaultColor
+		case code >= 90 && code <= 97:
+			cur.fg = IndexedColor(uint8(code - 90 + 8))
+		case code >= 100 && code <= 107:
+			cur.bg = IndexedColor(uint8(code - 100 + 8))
+		}
+	}
+}
+
+// extendedColor 解析 38 / 48 之后的 256 色（5;n）或真彩色（2;r;g;b）参数，
+// 兼容以冒号分隔的写法（38:5:n、38:2::r:g:b），返回颜色与最后使用的参数下标
+func extendedColor(params [][]int, i int) (Color, int) {
+	value := func(v int) uint8 {
+		return uint8(min(max(v, 0), 255))
+	}
+
+	if sub := params[i]; len(sub) > 1 {
+		switch {
+		case sub[1] == 5 && len(sub) >= 3:
+			return IndexedColor(value(sub[2])), i
+		case sub[1] == 2 && len(sub) >= 6:
+			return RGBColor(value(sub[3]), value(sub[4]), value(sub[5])), i
+		case sub[1] == 2 && len(sub) == 5:
+			return RGBColor(value(sub[2]), value(sub[3]), value(sub[4])), i
+		}
+		return DefaultColor, i
+	}
+
+	next := func(j int) int {
+		if j < len(params) && len(params[j]) > 0 {
+			return params[j][0]
+		}
+		return -1
+	}
+	switch next(i + 1) {
+	case 5:
+		if i+2 < len(params) {
+			return IndexedColor(value(next(i + 2))), i + 2
+		}
+	case 2:
+		if i+4 < len(params) {
+			return RGBColor(value(next(i+2)), value(next(i+3)), value(next(i+4))), i + 4
+		}
+	}
+	return DefaultColor, len(params)
+}
+
+// osc 执行 OSC 序列，目前只处理窗口标题，调用方需持有锁
+func (s *Screen) osc() {
+	command, text, ok := strings.Cut(string(s.parser.osc), ";")
+	if !ok {
+		return
+	}
+	if code, err := strconv.Atoi(command); err == nil && (code == 0 || code == 2) {
+		if runes := []rune(text); len(runes) > 256 {
+			text = string(runes[:256])
+		}
+		s.title = text
+	}
+}
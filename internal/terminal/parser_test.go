@@ -0,0 +1,101 @@
+package terminal
+
+import (
+	"strings"
+	"testing"
+)
+
+// newScreen 创建屏幕并写入 input
+func newScreen(cols, rows int, input string) *Screen {
+	s := New(cols, rows)
+	s.Write([]byte(input))
+	return s
+}
+
+// cellAt 返回可见屏幕上的单元格
+func cellAt(s *Screen, x, y int) Cell {
+	return s.Snapshot(0).Lines[y].Cells[x]
+}
+
+func TestSGRColors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  Cell
+	}{
+		{"默认", "X", Cell{Rune: 'X'}},
+		{"基本色与属性", "\x1b[1;31;42mX", Cell{Rune: 'X', FG: IndexedColor(1), BG: IndexedColor(2), Attr: AttrBold}},
+		{"高亮色", "\x1b[91;103mX", Cell{Rune: 'X', FG: IndexedColor(9), BG: IndexedColor(11)}},
+		{"256 色", "\x1b[38;5;196;48;5;21mX", Cell{Rune: 'X', FG: IndexedColor(196), BG: IndexedColor(21)}},
+		{"256 色冒号写法", "\x1b[38:5:196mX", Cell{Rune: 'X', FG: IndexedColor(196)}},
+		{"真彩色", "\x1b[38;2;10;20;30;48;2;40;50;60mX", Cell{Rune: 'X', FG: RGBColor(10, 20, 30), BG: RGBColor(40, 50, 60)}},
+		{"真彩色冒号写法", "\x1b[38:2::10:20:30mX", Cell{Rune: 'X', FG: RGBColor(10, 20, 30)}},
+		{"真彩色省略色彩空间", "\x1b[38:2:10:20:30mX", Cell{Rune: 'X', FG: RGBColor(10, 20, 30)}},
+		{"真彩色超出范围", "\x1b[38;2;300;;0mX", Cell{Rune: 'X', FG: RGBColor(255, 0, 0)}},
+		{"真彩色之后的参数", "\x1b[38;2;1;2;3;4mX", Cell{Rune: 'X', FG: RGBColor(1, 2, 3), Attr: AttrUnderline}},
+		{"不完整的 256 色", "\x1b[31m\x1b[38;5mX", Cell{Rune: 'X', FG: IndexedColor(1)}},
+		{"恢复默认色", "\x1b[31;42m\x1b[39;49mX", Cell{Rune: 'X'}},
+		{"清除属性", "\x1b[1;2;3;4;7;9m\x1b[22;23;24;27;29mX", Cell{Rune: 'X'}},
+		{"4:0 取消下划线", "\x1b[4m\x1b[4:0mX", Cell{Rune: 'X'}},
+		{"全部重置", "\x1b[1;38;5;100m\x1b[mX", Cell{Rune: 'X'}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cellAt(newScreen(10, 2, tt.input), 0, 0); got != tt.want {
+				t.Errorf("cell = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSIParamLimit(t *testing.T) {
+	s := New(10, 2)
+
+	// 只有分隔符的序列不会让参数无限增长
+	s.Write([]byte("\x1b[" + strings.Repeat(";", 100000)))
+	if n := len(s.parser.params); n > maxCSIParams {
+		t.Fatalf("%d params buffered, want at most %d", n, maxCSIParams)
+	}
+	s.Write([]byte("1" + strings.Repeat(":", 100000) + "m"))
+	for i, param := range s.parser.params {
+		if len(param) > maxCSIParams {
+			t.Fatalf("param %d has %d sub-parameters, want at most %d", i, len(param), maxCSIParams)
+		}
+	}
+
+	// 之后的序列照常解析；上限以内的参数生效，超出的被忽略
+	s.Write([]byte("\x1b[" + strings.Repeat("0;", maxCSIParams-1) + "1mA"))
+	s.Write([]byte("\x1b[0;" + strings.Repeat("0;", maxCSIParams-1) + "1mB"))
+	if a := cellAt(s, 0, 0); a.Rune != 'A' || a.Attr != AttrBold {
+		t.Errorf("cell A = %+v, want bold", a)
+	}
+	if b := cellAt(s, 1, 0); b.Rune != 'B' || b.Attr != 0 {
+		t.Errorf("cell B = %+v, want the parameter past the limit ignored", b)
+	}
+}
+
+func TestParserControlSequences(t *testing.T) {
+	var replies []string
+	s := New(20, 5)
+	s.SetReplyHandler(func(data []byte) { replies = append(replies, string(data)) })
+
+	// 被 CAN 中止的序列与 DCS 的内容都不显示
+	s.Write([]byte("\x1b]0;adb shell\x07\x1b[3;5H\x1b[6n\x1b[31\x18A\x1bPignored\x1b\\B"))
+	s.Write([]byte("\x1b]2;标题\x1b\\\x1b[?2004h\x1b[?1h"))
+
+	if s.Title() != "标题" {
+		t.Errorf("title = %q", s.Title())
+	}
+	if !s.BracketedPaste() || !s.AppCursorKeys() {
+		t.Error("private modes were not set")
+	}
+	if len(replies) != 1 || replies[0] != "\x1b[3;5R" {
+		t.Errorf("replies = %q, want a cursor position report", replies)
+	}
+	if got := s.Text(Pos{Line: 2}, Pos{Line: 2, Col: 19}); got != "    AB" {
+		t.Errorf("row = %q, want %q", got, "    AB")
+	}
+	if a := cellAt(s, 4, 2); a.FG != DefaultColor {
+		t.Errorf("aborted SGR changed the colour: %+v", a)
+	}
+}
@@ -0,0 +1,667 @@
+package terminal
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// DefaultScrollback 默认保留的回滚行数
+const DefaultScrollback = 5000
+
+// Pos 缓冲区中的位置，Line 为绝对行号：回滚区的行从 0 开始编号，其后依次是屏幕上的各行
+type Pos struct {
+	Line int
+	Col  int
+}
+
+// cursor 光标位置与写入状态，DECSC 保存与 DECRC 恢复的就是这些
+type cursor struct {
+	x, y        int
+	pen         pen
+	wrapPending bool    // 已写到最后一列，下一个字符先折行
+	origin      bool    // DECOM：行号相对于滚动区域
+	graphics    [2]bool // G0 / G1 是否为 DEC 特殊图形字符集
+	shift       int     // 当前使用 G0 还是 G1
+}
+
+// Snapshot 供界面绘制的屏幕内容
+type Snapshot struct {
+	Lines   []Line // 可见的各行，回滚区中的行宽度可能与当前列数不同
+	Top     int    // 第一行的绝对行号
+	CursorX int
+	CursorY int // 光标所在的可见行，光标隐藏或不在可见范围内时为 -1
+}
+
+// Screen VT100 / xterm 兼容的终端屏幕：解析程序输出的控制序列，维护屏幕内容与回滚区
+// 可在任意协程中调用，Write 与界面绘制可以同时进行
+type Screen struct {
+	mu         sync.Mutex
+	cols, rows int
+	lines      []*Line // 当前显示的屏幕（主屏或备用屏）
+	mainLines  []*Line // 使用备用屏时保存的主屏
+	alt        bool
+	scrollback []*Line
+	maxScroll  int
+
+	cur      cursor
+	saved    cursor // DECSC 保存的光标
+	altSaved cursor // 切换到备用屏前的光标
+	top      int    // 滚动区域的第一行
+	bottom   int    // 滚动区域的最后一行
+	tabs     []bool
+
+	autowrap       bool
+	insert         bool // IRM：插入而不是覆盖
+	newline        bool // LNM：换行时同时回车
+	appCursor      bool // DECCKM：方向键发送 SS3 序列
+	cursorVisible  bool
+	bracketedPaste bool
+	title          string
+	lastRune       rune // REP 重复的字符
+
+	parser  parser
+	partial []byte // 上次写入末尾不完整的 UTF-8 字节
+
+	reply   func(data []byte)
+	replies [][]byte // 待发送给程序的应答（光标位置报告等）
+}
+
+// New 创建 cols 列 rows 行的终端屏幕
+func New(cols, rows int) *Screen {
+	cols, rows = max(cols, 1), max(rows, 1)
+	s := &Screen{cols: cols, rows: rows, maxScroll: DefaultScrollback}
+	s.lines = make([]*Line, rows)
+	for i := range s.lines {
+		s.lines[i] = newLine(cols, pen{})
+	}
+	s.reset()
+	return s
+}
+
+// SetReplyHandler 设置应答的接收方，终端需要回答程序的查询（光标位置、设备属性）时调用，
+// 通常写回到会话的输入
+func (s *Screen) SetReplyHandler(reply func(data []byte)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reply = reply
+}
+
+// Write 处理程序的输出，按 UTF-8 解码，跨两次写入的多字节字符会拼接起来
+func (s *Screen) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	data := p
+	if len(s.partial) > 0 {
+		data = append(s.partial, p...)
+		s.partial = nil
+	}
+	for i := 0; i < len(data); {
+		if data[i] < utf8.RuneSelf {
+			s.feed(rune(data[i]))
+			i++
+			continue
+		}
+		if !utf8.FullRune(data[i:]) {
+			s.partial = append([]byte(nil), data[i:]...)
+			break
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		s.feed(r)
+		i += size
+	}
+	replies, reply := s.replies, s.reply
+	s.replies = nil
+	s.mu.Unlock()
+
+	// 在锁外应答，写回会话可能阻塞
+	if reply != nil {
+		for _, data := range replies {
+			reply(data)
+		}
+	}
+	return len(p), nil
+}
+
+// Resize 调整屏幕大小，内容不重新折行
+// 主屏变矮时光标以上的行移入回滚区，变高时从回滚区取回
+func (s *Screen) Resize(cols, rows int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cols, rows = max(cols, 1), max(rows, 1)
+	if cols == s.cols && rows == s.rows {
+		return
+	}
+	if s.alt {
+		s.lines = resizeLines(s.lines, cols, rows)
+		s.mainLines = s.resizeMain(s.mainLines, &s.altSaved.y, cols, rows)
+	} else {
+		s.lines = s.resizeMain(s.lines, &s.cur.y, cols, rows)
+	}
+	s.cols, s.rows = cols, rows
+	s.top, s.bottom = 0, rows-1
+	s.resetTabs()
+	s.cur.x = min(s.cur.x, cols-1)
+	s.cur.y = min(s.cur.y, rows-1)
+	s.cur.wrapPending = false
+}
+
+// resizeMain 调整主屏的大小，y 为主屏光标所在行
+func (s *Screen) resizeMain(lines []*Line, y *int, cols, rows int) []*Line {
+	for _, line := range lines {
+		line.resize(cols)
+	}
+	if rows < len(lines) {
+		// 光标以上的行移入回滚区，保持光标可见
+		if excess := *y - (rows - 1); excess > 0 {
+			for _, line := range lines[:excess] {
+				s.pushScrollback(line)
+			}
+			lines = lines[excess:]
+			*y -= excess
+		}
+		if len(lines) > rows {
+			lines = lines[:rows]
+		}
+	}
+	for len(lines) < rows {
+		n := len(s.scrollback)
+		if n == 0 {
+			lines = append(lines, newLine(cols, pen{}))
+			continue
+		}
+		line := s.scrollback[n-1]
+		s.scrollback = s.scrollback[:n-1]
+		line.resize(cols)
+		lines = append([]*Line{line}, lines...)
+		*y++
+	}
+	return lines
+}
+
+// resizeLines 调整备用屏的大小，多出的行从底部去掉
+func resizeLines(lines []*Line, cols, rows int) []*Line {
+	if len(lines) > rows {
+		lines = lines[:rows]
+	}
+	for _, line := range lines {
+		line.resize(cols)
+	}
+	for len(lines) < rows {
+		lines = append(lines, newLine(cols, pen{}))
+	}
+	return lines
+}
+
+// Size 返回列数与行数
+func (s *Screen) Size() (cols, rows int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cols, s.rows
+}
+
+// ScrollbackLen 回滚区的行数，使用备用屏（vim、top 等全屏程序）时为 0
+func (s *Screen) ScrollbackLen() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.alt {
+		return 0
+	}
+	return len(s.scrollback)
+}
+
+// Title 程序通过 OSC 0 / 2 设置的标题
+func (s *Screen) Title() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.title
+}
+
+// AppCursorKeys 方向键是否应发送应用模式（SS3）序列
+func (s *Screen) AppCursorKeys() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.appCursor
+}
+
+// BracketedPaste 程序是否要求粘贴的内容以 ESC [200~ 与 ESC [201~ 包围
+func (s *Screen) BracketedPaste() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bracketedPaste
+}
+
+// Snapshot 返回可见内容，scroll 为向上翻看回滚区的行数，0 表示显示当前屏幕
+func (s *Screen) Snapshot(scroll int) Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := len(s.scrollback)
+	if s.alt {
+		scroll = 0
+	}
+	scroll = min(max(scroll, 0), history)
+
+	snap := Snapshot{Lines: make([]Line, s.rows), Top: history - scroll, CursorX: s.cur.x, CursorY: -1}
+	for i := range snap.Lines {
+		snap.Lines[i] = s.lineAt(snap.Top + i).clone()
+	}
+	if s.cursorVisible && s.cur.y+scroll < s.rows {
+		snap.CursorY = s.cur.y + scroll
+	}
+	return snap
+}
+
+// lineAt 返回绝对行号对应的行，调用方需持有锁
+func (s *Screen) lineAt(abs int) *Line {
+	if abs < len(s.scrollback) {
+		return s.scrollback[abs]
+	}
+	return s.lines[abs-len(s.scrollback)]
+}
+
+// Text 返回 from 与 to 之间（含两端）的文字，自动折行的行首尾相连，其余行以换行分隔
+func (s *Screen) Text(from, to Pos) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if to.Line < from.Line || (to.Line == from.Line && to.Col < from.Col) {
+		from, to = to, from
+	}
+	total := len(s.scrollback) + len(s.lines)
+	from.Line = max(from.Line, 0)
+	to.Line = min(to.Line, total-1)
+
+	var b strings.Builder
+	for abs := from.Line; abs <= to.Line; abs++ {
+		line := s.lineAt(abs)
+		start, end := 0, len(line.Cells)-1
+		if abs == from.Line {
+			start = from.Col
+		}
+		if abs == to.Line {
+			end = min(end, to.Col)
+		}
+
+		var text strings.Builder
+		for col := max(start, 0); col <= end; col++ {
+			if r := line.Cells[col].Rune; r != 0 {
+				text.WriteRune(r)
+			}
+		}
+		if abs < to.Line && line.Wrapped {
+			b.WriteString(text.String())
+			continue
+		}
+		b.WriteString(strings.TrimRight(text.String(), " "))
+		if abs < to.Line {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// AllText 回滚区与屏幕上的全部文字
+func (s *Screen) AllText() string {
+	s.mu.Lock()
+	last := Pos{Line: len(s.scrollback) + len(s.lines) - 1, Col: s.cols - 1}
+	s.mu.Unlock()
+	return strings.TrimRight(s.Text(Pos{}, last), "\n")
+}
+
+// reset 恢复初始状态（RIS），保留回滚区，调用方需持有锁
+func (s *Screen) reset() {
+	if s.alt {
+		s.exitAlt()
+	}
+	s.cur = cursor{}
+	s.saved = cursor{}
+	s.top, s.bottom = 0, s.rows-1
+	s.autowrap = true
+	s.insert = false
+	s.newline = false
+	s.appCursor = false
+	s.cursorVisible = true
+	s.bracketedPaste = false
+	s.parser = parser{}
+	s.resetTabs()
+	s.eraseDisplay(2)
+}
+
+// softReset DECSTR：恢复模式与光标属性，不清除屏幕，调用方需持有锁
+func (s *Screen) softReset() {
+	s.cur.pen = pen{}
+	s.cur.origin = false
+	s.cur.graphics = [2]bool{}
+	s.cur.shift = 0
+	s.saved = cursor{}
+	s.top, s.bottom = 0, s.rows-1
+	s.autowrap = true
+	s.insert = false
+	s.appCursor = false
+	s.cursorVisible = true
+}
+
+// resetTabs 每 8 列一个制表位，调用方需持有锁
+func (s *Screen) resetTabs() {
+	s.tabs = make([]bool, s.cols)
+	for i := 8; i < s.cols; i += 8 {
+		s.tabs[i] = true
+	}
+}
+
+// pushScrollback 将滚出屏幕的行加入回滚区，超出上限时成批丢弃最早的行，调用方需持有锁
+func (s *Screen) pushScrollback(line *Line) {
+	s.scrollback = append(s.scrollback, line)
+	if len(s.scrollback) > s.maxScroll+s.maxScroll/4 {
+		n := copy(s.scrollback, s.scrollback[len(s.scrollback)-s.maxScroll:])
+		clear(s.scrollback[n:])
+		s.scrollback = s.scrollback[:n]
+	}
+}
+
+// put 在光标处写入一个字符，调用方需持有锁
+func (s *Screen) put(r rune) {
+	if s.cur.graphics[s.cur.shift] {
+		if g, ok := decSpecialGraphics[r]; ok {
+			r = g
+		}
+	}
+	width := runeWidth(r)
+	if width == 0 || width > s.cols {
+		return // 单元格无法叠加组合字符，忽略
+	}
+
+	if s.cur.wrapPending {
+		s.cur.wrapPending = false
+		if s.autowrap {
+			s.wrap()
+		}
+	}
+	if width == 2 && s.cur.x == s.cols-1 {
+		// 行尾放不下宽字符
+		if !s.autowrap {
+			s.cur.x--
+		} else {
+			s.lines[s.cur.y].Cells[s.cur.x] = s.cur.pen.blank()
+			s.wrap()
+		}
+	}
+
+	line := s.lines[s.cur.y]
+	x := s.cur.x
+	if s.insert {
+		copy(line.Cells[x+width:], line.Cells[x:])
+	}
+	s.clearWide(line, x)
+	if width == 2 {
+		s.clearWide(line, x+1)
+	}
+
+	cell := Cell{Rune: r, FG: s.cur.pen.fg, BG: s.cur.pen.bg, Attr: s.cur.pen.attr}
+	line.Cells[x] = cell
+	if width == 2 {
+		cell.Rune = 0
+		line.Cells[x+1] = cell
+	}
+	s.lastRune = r
+
+	s.cur.x += width
+	if s.cur.x >= s.cols {
+		s.cur.x = s.cols - 1
+		s.cur.wrapPending = true
+	}
+}
+
+// wrap 自动折行到下一行行首，调用方需持有锁
+func (s *Screen) wrap() {
+	s.lines[s.cur.y].Wrapped = true
+	s.cur.x = 0
+	s.lineFeed()
+}
+
+// clearWide 覆盖 x 处的字符前，清除被拆开的宽字符残留的另一半，调用方需持有锁
+func (s *Screen) clearWide(line *Line, x int) {
+	if x >= len(line.Cells) {
+		return
+	}
+	if line.Cells[x].Rune == 0 && x > 0 {
+		line.Cells[x-1].Rune = ' '
+	}
+	if x+1 < len(line.Cells) && line.Cells[x+1].Rune == 0 {
+		line.Cells[x+1].Rune = ' '
+	}
+}
+
+// lineFeed 下移一行，位于滚动区域底部时向上滚动，调用方需持有锁
+func (s *Screen) lineFeed() {
+	s.cur.wrapPending = false
+	switch {
+	case s.cur.y == s.bottom:
+		s.scrollUp(1)
+	case s.cur.y < s.rows-1:
+		s.cur.y++
+	}
+}
+
+// reverseIndex 上移一行，位于滚动区域顶部时向下滚动，调用方需持有锁
+func (s *Screen) reverseIndex() {
+	s.cur.wrapPending = false
+	switch {
+	case s.cur.y == s.top:
+		s.scrollDown(1)
+	case s.cur.y > 0:
+		s.cur.y--
+	}
+}
+
+// scrollUp 滚动区域内容上移 n 行，主屏从顶部滚出的行进入回滚区，调用方需持有锁
+func (s *Screen) scrollUp(n int) {
+	n = min(n, s.bottom-s.top+1)
+	for i := 0; i < n; i++ {
+		if s.top == 0 && !s.alt {
+			s.pushScrollback(s.lines[s.top])
+		}
+		copy(s.lines[s.top:s.bottom], s.lines[s.top+1:s.bottom+1])
+		s.lines[s.bottom] = newLine(s.cols, s.cur.pen)
+	}
+}
+
+// scrollDown 滚动区域内容下移 n 行，调用方需持有锁
+func (s *Screen) scrollDown(n int) {
+	n = min(n, s.bottom-s.top+1)
+	for i := 0; i < n; i++ {
+		copy(s.lines[s.top+1:s.bottom+1], s.lines[s.top:s.bottom])
+		s.lines[s.top] = newLine(s.cols, s.cur.pen)
+	}
+}
+
+// moveTo 移动光标，超出屏幕时取边界，调用方需持有锁
+func (s *Screen) moveTo(x, y int) {
+	s.cur.x = min(max(x, 0), s.cols-1)
+	s.cur.y = min(max(y, 0), s.rows-1)
+	s.cur.wrapPending = false
+}
+
+// setPosition CUP：移动到第 row 行第 col 列（从 1 开始），原点模式下行号相对于滚动区域，调用方需持有锁
+func (s *Screen) setPosition(row, col int) {
+	if s.cur.origin {
+		s.moveTo(col-1, min(s.top+row-1, s.bottom))
+		return
+	}
+	s.moveTo(col-1, row-1)
+}
+
+// moveVertical 上下移动光标，从滚动区域内出发时不越过区域边界，调用方需持有锁
+func (s *Screen) moveVertical(n int) {
+	y := s.cur.y + n
+	if s.cur.y >= s.top && s.cur.y <= s.bottom {
+		y = min(max(y, s.top), s.bottom)
+	}
+	s.moveTo(s.cur.x, y)
+}
+
+// tab 移动到后面第 n 个制表位（n 为负时向前），调用方需持有锁
+func (s *Screen) tab(n int) {
+	x := s.cur.x
+	for ; n > 0 && x < s.cols-1; n-- {
+		for x++; x < s.cols-1 && !s.tabs[x]; x++ {
+		}
+	}
+	for ; n < 0 && x > 0; n++ {
+		for x--; x > 0 && !s.tabs[x]; x-- {
+		}
+	}
+	s.cur.x = x
+	s.cur.wrapPending = false
+}
+
+// eraseLine EL：0 清除到行尾，1 清除到行首，2 清除整行，调用方需持有锁
+func (s *Screen) eraseLine(mode int) {
+	line := s.lines[s.cur.y]
+	start, end := 0, s.cols
+	switch mode {
+	case 0:
+		start = s.cur.x
+		line.Wrapped = false
+	case 1:
+		end = s.cur.x + 1
+	case 2:
+		line.Wrapped = false
+	default:
+		return
+	}
+	s.fill(line, start, end)
+	s.cur.wrapPending = false
+}
+
+// eraseDisplay ED：0 清除到屏幕末尾，1 清除到屏幕开头，2 清除整屏，3 清除回滚区，调用方需持有锁
+func (s *Screen) eraseDisplay(mode int) {
+	switch mode {
+	case 0:
+		s.eraseLine(0)
+		for y := s.cur.y + 1; y < s.rows; y++ {
+			s.lines[y] = newLine(s.cols, s.cur.pen)
+		}
+	case 1:
+		for y := 0; y < s.cur.y; y++ {
+			s.lines[y] = newLine(s.cols, s.cur.pen)
+		}
+		s.eraseLine(1)
+	case 2:
+		for y := range s.lines {
+			s.lines[y] = newLine(s.cols, s.cur.pen)
+		}
+	case 3:
+		s.scrollback = nil
+	}
+}
+
+// fill 以空白填充 [start, end) 列，调用方需持有锁
+func (s *Screen) fill(line *Line, start, end int) {
+	end = min(end, len(line.Cells))
+	if start > 0 && start < end {
+		s.clearWide(line, start)
+	}
+	if end > 0 && end < len(line.Cells) {
+		s.clearWide(line, end-1)
+	}
+	blank := s.cur.pen.blank()
+	for x := max(start, 0); x < end; x++ {
+		line.Cells[x] = blank
+	}
+}
+
+// insertChars ICH：在光标处插入 n 个空白，右侧内容右移，调用方需持有锁
+func (s *Screen) insertChars(n int) {
+	line := s.lines[s.cur.y]
+	x := s.cur.x
+	n = min(n, s.cols-x)
+	copy(line.Cells[x+n:], line.Cells[x:s.cols-n])
+	s.fill(line, x, x+n)
+	s.cur.wrapPending = false
+}
+
+// deleteChars DCH：删除光标处的 n 个字符，右侧内容左移，调用方需持有锁
+func (s *Screen) deleteChars(n int) {
+	line := s.lines[s.cur.y]
+	x := s.cur.x
+	n = min(n, s.cols-x)
+	copy(line.Cells[x:], line.Cells[x+n:])
+	s.fill(line, s.cols-n, s.cols)
+	s.cur.wrapPending = false
+}
+
+// insertLines IL：在光标所在行插入 n 个空行，仅在滚动区域内有效，调用方需持有锁
+func (s *Screen) insertLines(n int) {
+	if s.cur.y < s.top || s.cur.y > s.bottom {
+		return
+	}
+	n = min(n, s.bottom-s.cur.y+1)
+	for i := 0; i < n; i++ {
+		copy(s.lines[s.cur.y+1:s.bottom+1], s.lines[s.cur.y:s.bottom])
+		s.lines[s.cur.y] = newLine(s.cols, s.cur.pen)
+	}
+	s.cur.x = 0
+	s.cur.wrapPending = false
+}
+
+// deleteLines DL：删除光标所在行起的 n 行，仅在滚动区域内有效，调用方需持有锁
+func (s *Screen) deleteLines(n int) {
+	if s.cur.y < s.top || s.cur.y > s.bottom {
+		return
+	}
+	n = min(n, s.bottom-s.cur.y+1)
+	for i := 0; i < n; i++ {
+		copy(s.lines[s.cur.y:s.bottom], s.lines[s.cur.y+1:s.bottom+1])
+		s.lines[s.bottom] = newLine(s.cols, s.cur.pen)
+	}
+	s.cur.x = 0
+	s.cur.wrapPending = false
+}
+
+// setScrollRegion DECSTBM：设置滚动区域（行号从 1 开始）并将光标移到原点，调用方需持有锁
+func (s *Screen) setScrollRegion(top, bottom int) {
+	top, bottom = top-1, min(bottom, s.rows)-1
+	if top >= bottom {
+		return
+	}
+	s.top, s.bottom = top, bottom
+	s.setPosition(1, 1)
+}
+
+// enterAlt 切换到清空的备用屏，调用方需持有锁
+func (s *Screen) enterAlt() {
+	if s.alt {
+		return
+	}
+	s.altSaved = s.cur
+	s.mainLines = s.lines
+	s.lines = make([]*Line, s.rows)
+	for i := range s.lines {
+		s.lines[i] = newLine(s.cols, pen{})
+	}
+	s.alt = true
+}
+
+// exitAlt 回到主屏，调用方需持有锁
+func (s *Screen) exitAlt() {
+	if !s.alt {
+		return
+	}
+	s.lines = s.mainLines
+	s.mainLines = nil
+	s.alt = false
+}
+
+// restoreCursor 恢复保存的光标，屏幕大小可能已改变，调用方需持有锁
+func (s *Screen) restoreCursor(saved cursor) {
+	s.cur = saved
+	s.cur.x = min(s.cur.x, s.cols-1)
+	s.cur.y = min(s.cur.y, s.rows-1)
+}
+
+// respond 记录发给程序的应答，在 Write 返回前发送，调用方需持有锁
+func (s *Screen) respond(data string) {
+	s.replies = append(s.replies, []byte(data))
+}
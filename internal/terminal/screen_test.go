@@ -0,0 +1,191 @@
+package terminal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// screenRows 返回可见屏幕上各行的文字，去掉行尾空白
+func screenRows(s *Screen) []string {
+	snap := s.Snapshot(0)
+	rows := make([]string, len(snap.Lines))
+	for y, line := range snap.Lines {
+		var b strings.Builder
+		for _, cell := range line.Cells {
+			if cell.Rune != 0 {
+				b.WriteRune(cell.Rune)
+			}
+		}
+		rows[y] = strings.TrimRight(b.String(), " ")
+	}
+	return rows
+}
+
+// expectCursor 检查光标所在的可见位置
+func expectCursor(t *testing.T, s *Screen, x, y int) {
+	t.Helper()
+	if snap := s.Snapshot(0); snap.CursorX != x || snap.CursorY != y {
+		t.Errorf("cursor = (%d, %d), want (%d, %d)", snap.CursorX, snap.CursorY, x, y)
+	}
+}
+
+func TestCursorPosition(t *testing.T) {
+	s := newScreen(10, 5, "\x1b[3;4HX")
+	if got := screenRows(s)[2]; got != "   X" {
+		t.Errorf("row 2 = %q", got)
+	}
+	expectCursor(t, s, 4, 2)
+
+	s.Write([]byte("\x1b[H"))
+	expectCursor(t, s, 0, 0)
+	s.Write([]byte("\x1b[99;99H"))
+	expectCursor(t, s, 9, 4)
+	s.Write([]byte("\x1b[2;3f\x1b[2A\x1b[5C"))
+	expectCursor(t, s, 7, 0)
+}
+
+func TestEditing(t *testing.T) {
+	const text = "abcde\r\nfghij\r\nklmno\x1b[2;2H"
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"ED 0", "\x1b[J", []string{"abcde", "f", ""}},
+		{"ED 1", "\x1b[1J", []string{"", "  hij", "klmno"}},
+		{"ED 2", "\x1b[2J", []string{"", "", ""}},
+		{"EL 0", "\x1b[K", []string{"abcde", "f", "klmno"}},
+		{"EL 1", "\x1b[1K", []string{"abcde", "  hij", "klmno"}},
+		{"EL 2", "\x1b[2K", []string{"abcde", "", "klmno"}},
+		{"ICH", "\x1b[2@", []string{"abcde", "f  gh", "klmno"}},
+		{"ICH 超出行宽", "\x1b[9@", []string{"abcde", "f", "klmno"}},
+		{"DCH", "\x1b[P", []string{"abcde", "fhij", "klmno"}},
+		{"DCH 2", "\x1b[2P", []string{"abcde", "fij", "klmno"}},
+		{"ECH", "\x1b[2X", []string{"abcde", "f  ij", "klmno"}},
+		{"IL", "\x1b[L", []string{"abcde", "", "fghij"}},
+		{"DL", "\x1b[M", []string{"abcde", "klmno", ""}},
+		{"插入模式", "\x1b[4hXY\x1b[4l", []string{"abcde", "fXYgh", "klmno"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newScreen(5, 3, text+tt.input)
+			if got := screenRows(s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rows = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrollRegion(t *testing.T) {
+	s := newScreen(5, 5, "1\r\n2\r\n3\r\n4\r\n5\x1b[2;4r")
+	expectCursor(t, s, 0, 0)
+
+	// 在区域底部换行只滚动区域内的行，滚出的行不进入回滚区
+	s.Write([]byte("\x1b[4;1H\n"))
+	if want := []string{"1", "3", "4", "", "5"}; !reflect.DeepEqual(screenRows(s), want) {
+		t.Errorf("rows after LF = %q, want %q", screenRows(s), want)
+	}
+	if s.ScrollbackLen() != 0 {
+		t.Errorf("scrollback = %d, want 0", s.ScrollbackLen())
+	}
+
+	// 在区域顶部反向换行向下滚动
+	s.Write([]byte("\x1b[2;1H\x1bM"))
+	if want := []string{"1", "", "3", "4", "5"}; !reflect.DeepEqual(screenRows(s), want) {
+		t.Errorf("rows after RI = %q, want %q", screenRows(s), want)
+	}
+
+	// 原点模式下行号相对于区域
+	s.Write([]byte("\x1b[?6h\x1b[1;1HX\x1b[9;1HY\x1b[?6l"))
+	if want := []string{"1", "X", "3", "Y", "5"}; !reflect.DeepEqual(screenRows(s), want) {
+		t.Errorf("rows in origin mode = %q, want %q", screenRows(s), want)
+	}
+
+	// 无效的区域被忽略，恢复整屏后从顶部滚出的行进入回滚区
+	s.Write([]byte("\x1b[4;2r\x1b[r\x1b[5;1H\n"))
+	if want := []string{"X", "3", "Y", "5", ""}; !reflect.DeepEqual(screenRows(s), want) {
+		t.Errorf("rows after full-screen LF = %q, want %q", screenRows(s), want)
+	}
+	if s.ScrollbackLen() != 1 || s.AllText() != "1\nX\n3\nY\n5" {
+		t.Errorf("scrollback = %d, text = %q", s.ScrollbackLen(), s.AllText())
+	}
+}
+
+func TestAltScreen(t *testing.T) {
+	s := newScreen(10, 3, "1\r\n2\r\n3\r\n4\r\nmain")
+	if s.ScrollbackLen() != 2 {
+		t.Fatalf("scrollback = %d, want 2", s.ScrollbackLen())
+	}
+
+	// 备用屏是空的，全屏程序的输出不进入回滚区
+	s.Write([]byte("\x1b[?1049h"))
+	if want := []string{"", "", ""}; !reflect.DeepEqual(screenRows(s), want) {
+		t.Errorf("alt rows = %q", screenRows(s))
+	}
+	s.Write([]byte("\x1b[Hvim\r\n\n\n\n\n"))
+	if s.ScrollbackLen() != 0 || s.Snapshot(5).Top != 2 {
+		t.Errorf("alt screen scrollback = %d, top = %d", s.ScrollbackLen(), s.Snapshot(5).Top)
+	}
+
+	// 回到主屏后内容、回滚区与光标恢复原状
+	s.Write([]byte("\x1b[?1049l"))
+	if want := []string{"3", "4", "main"}; !reflect.DeepEqual(screenRows(s), want) {
+		t.Errorf("main rows = %q, want %q", screenRows(s), want)
+	}
+	if s.ScrollbackLen() != 2 {
+		t.Errorf("scrollback = %d, want 2", s.ScrollbackLen())
+	}
+	expectCursor(t, s, 4, 2)
+}
+
+func TestResizeScrollback(t *testing.T) {
+	s := newScreen(10, 4, "1\r\n2\r\n3\r\n4")
+
+	// 变矮时光标以上的行移入回滚区
+	s.Resize(10, 2)
+	if want := []string{"3", "4"}; !reflect.DeepEqual(screenRows(s), want) {
+		t.Errorf("rows = %q, want %q", screenRows(s), want)
+	}
+	if s.ScrollbackLen() != 2 {
+		t.Errorf("scrollback = %d, want 2", s.ScrollbackLen())
+	}
+	expectCursor(t, s, 1, 1)
+
+	// 变高时从回滚区取回
+	s.Resize(10, 5)
+	if want := []string{"1", "2", "3", "4", ""}; !reflect.DeepEqual(screenRows(s), want) {
+		t.Errorf("rows = %q, want %q", screenRows(s), want)
+	}
+	if s.ScrollbackLen() != 0 {
+		t.Errorf("scrollback = %d, want 0", s.ScrollbackLen())
+	}
+	expectCursor(t, s, 1, 3)
+
+	// 光标下方的行在变矮时直接去掉
+	s.Write([]byte("\x1b[2;1H"))
+	s.Resize(10, 2)
+	if want := []string{"1", "2"}; !reflect.DeepEqual(screenRows(s), want) || s.ScrollbackLen() != 0 {
+		t.Errorf("rows = %q, scrollback = %d", screenRows(s), s.ScrollbackLen())
+	}
+}
+
+func TestResizeColumns(t *testing.T) {
+	s := newScreen(6, 2, "ab中文")
+
+	// 截断处落在宽字符中间时清除残留的一半，光标不超出新的列数
+	s.Resize(5, 2)
+	if got := screenRows(s)[0]; got != "ab中" {
+		t.Errorf("row = %q, want %q", got, "ab中")
+	}
+	expectCursor(t, s, 4, 0)
+	if cols, rows := s.Size(); cols != 5 || rows != 2 {
+		t.Errorf("size = %dx%d", cols, rows)
+	}
+
+	s.Resize(8, 2)
+	s.Write([]byte("\x1b[1;7HX"))
+	if got := screenRows(s)[0]; got != "ab中  X" {
+		t.Errorf("row after widening = %q", got)
+	}
+}
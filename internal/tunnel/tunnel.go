@@ -0,0 +1,204 @@
+package tunnel
+
+import (
+	"adbmanager/internal/adb"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// applyTimeout 设备重连后自动应用预设的超时时间
+const applyTimeout = 30 * time.Second
+
+// Preset 端口转发预设，设备连接后自动应用
+type Preset struct {
+	Name    string `json:"name"`
+	Device  string `json:"device,omitempty"` // 序列号或 ro.serialno，为空时应用到所有设备
+	Reverse bool   `json:"reverse,omitempty"`
+	Local   string `json:"local"`  // 正向转发为本机端，反向转发为设备端
+	Remote  string `json:"remote"` // 正向转发为设备端，反向转发为本机端
+	Enabled bool   `json:"enabled"`
+}
+
+// Rule 预设对应的转发规则
+func (p Preset) Rule() adb.ForwardRule {
+	return adb.ForwardRule{Local: p.Local, Remote: p.Remote, Reverse: p.Reverse}
+}
+
+// Manager 端口转发预设管理器，预设保存在 JSON 文件中
+type Manager struct {
+	adbMgr *adb.ADBManager
+	log    *slog.Logger
+	path   string
+
+	mu      sync.Mutex
+	presets []Preset
+}
+
+// DefaultPresetPath 返回预设文件的默认路径
+func DefaultPresetPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "tunnels.json"
+	}
+	return filepath.Join(dir, "adbmanager", "tunnels.json")
+}
+
+// NewManager 创建预设管理器并读取 path 中已保存的预设
+func NewManager(adbMgr *adb.ADBManager, path string) *Manager {
+	m := &Manager{
+		adbMgr:  adbMgr,
+		log:     adbMgr.Logger().With("component", "tunnel"),
+		path:    path,
+		presets: make([]Preset, 0),
+	}
+	if err := m.Load(); err != nil {
+		m.log.Warn("读取端口转发预设失败", "path", path, "error", err)
+	}
+	return m
+}
+
+// Load 从文件读取预设，文件不存在时预设为空
+func (m *Manager) Load() error {
+	data, err := os.ReadFile(m.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	presets := make([]Preset, 0)
+	if err := json.Unmarshal(data, &presets); err != nil {
+		return fmt.Errorf("解析 %s 失败: %v", m.path, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.presets = presets
+	return nil
+}
+
+// save 将预设写入文件，调用方需持有锁
+func (m *Manager) save() error {
+	data, err := json.MarshalIndent(m.presets, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return fmt.Errorf("创建配置目录失败: %v", err)
+	}
+	if err := os.WriteFile(m.path, data, 0644); err != nil {
+		return fmt.Errorf("保存端口转发预设失败: %v", err)
+	}
+	return nil
+}
+
+// Presets 返回所有预设
+func (m *Manager) Presets() []Preset {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Preset(nil), m.presets...)
+}
+
+// AddPreset 添加预设并保存
+func (m *Manager) AddPreset(preset Preset) error {
+	if err := adb.ValidateForwardSpec(preset.Local); err != nil {
+		return err
+	}
+	if err := adb.ValidateForwardSpec(preset.Remote); err != nil {
+		return err
+	}
+	if preset.Name == "" {
+		preset.Name = preset.Rule().String()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.presets = append(m.presets, preset)
+	return m.save()
+}
+
+// RemovePreset 删除第 index 个预设并保存
+func (m *Manager) RemovePreset(index int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if index < 0 || index >= len(m.presets) {
+		return fmt.Errorf("预设不存在: %d", index)
+	}
+	m.presets = append(m.presets[:index], m.presets[index+1:]...)
+	return m.save()
+}
+
+// SetEnabled 启用或停用第 index 个预设并保存
+func (m *Manager) SetEnabled(index int, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if index < 0 || index >= len(m.presets) {
+		return fmt.Errorf("预设不存在: %d", index)
+	}
+	m.presets[index].Enabled = enabled
+	return m.save()
+}
+
+// Apply 在设备上应用所有匹配的已启用预设，个别预设失败不影响其余预设
+func (m *Manager) Apply(serial string) error {
+	return m.ApplyContext(context.Background(), serial)
+}
+
+// ApplyContext 可通过 ctx 取消的 Apply
+func (m *Manager) ApplyContext(ctx context.Context, serial string) error {
+	var errs []error
+	for _, preset := range m.Presets() {
+		if !preset.Enabled || !m.matches(ctx, preset, serial) {
+			continue
+		}
+
+		var err error
+		if preset.Reverse {
+			_, err = m.adbMgr.ReverseContext(ctx, serial, preset.Local, preset.Remote, false)
+		} else {
+			_, err = m.adbMgr.ForwardContext(ctx, serial, preset.Local, preset.Remote, false)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", preset.Name, err))
+			continue
+		}
+		m.log.Info("已应用端口转发预设", "device", serial, "preset", preset.Name, "rule", preset.Rule())
+	}
+	return errors.Join(errs...)
+}
+
+// matches 预设是否适用于该设备，预设中的设备可以是序列号或 ro.serialno（网络连接的序列号会随 IP 变化）
+func (m *Manager) matches(ctx context.Context, preset Preset, serial string) bool {
+	if preset.Device == "" || preset.Device == serial {
+		return true
+	}
+	hardwareSerial, err := m.adbMgr.ResolveIdentityContext(ctx, serial)
+	return err == nil && hardwareSerial == preset.Device
+}
+
+// Watch 订阅设备事件，设备连接或恢复在线时自动应用预设，返回取消订阅的函数
+func (m *Manager) Watch(watcher *adb.DeviceWatcher) func() {
+	return watcher.Subscribe(func(event adb.DeviceEvent) {
+		if event.Device.Status != "device" || event.Type == adb.DeviceDisconnected {
+			return
+		}
+		serial := event.Device.Serial
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), applyTimeout)
+			defer cancel()
+			if err := m.ApplyContext(ctx, serial); err != nil {
+				m.log.Warn("自动应用端口转发预设失败", "device", serial, "error", err)
+			}
+		}()
+	})
+}
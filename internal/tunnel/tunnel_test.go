@@ -0,0 +1,129 @@
+package tunnel
+
+import (
+	"adbmanager/internal/adb"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+const testSerial = "192.168.1.5:5555"
+
+// newTestManager 创建连接模拟 adb server 的预设管理器
+func newTestManager(t *testing.T, srv *adb.FakeServer) (*Manager, *adb.ADBManager) {
+	t.Helper()
+	adbMgr := adb.NewADBManagerWithRunner(adb.NewServerRunner(srv.Addr(), nil))
+	adbMgr.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	return NewManager(adbMgr, filepath.Join(t.TempDir(), "tunnels.json")), adbMgr
+}
+
+// waitFor 等待条件成立
+func waitFor(t *testing.T, what string, ok func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !ok() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPresetsPersist(t *testing.T) {
+	srv, err := adb.NewFakeServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	m, adbMgr := newTestManager(t, srv)
+
+	if err := m.AddPreset(Preset{Local: "tcp:9222", Remote: "chrome"}); err == nil {
+		t.Error("AddPreset accepted an invalid spec")
+	}
+	if err := m.AddPreset(Preset{Local: "tcp:9222", Remote: "localabstract:chrome_devtools_remote", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddPreset(Preset{Name: "Metro", Reverse: true, Local: "tcp:8081", Remote: "tcp:8081"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetEnabled(1, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RemovePreset(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RemovePreset(5); err == nil {
+		t.Error("RemovePreset accepted an index out of range")
+	}
+
+	want := []Preset{{Name: "Metro", Reverse: true, Local: "tcp:8081", Remote: "tcp:8081", Enabled: true}}
+	reloaded := NewManager(adbMgr, m.path)
+	if got := reloaded.Presets(); !reflect.DeepEqual(got, want) {
+		t.Errorf("reloaded presets = %+v, want %+v", got, want)
+	}
+}
+
+func TestWatchAppliesPresets(t *testing.T) {
+	srv, err := adb.NewFakeServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.AddDevice(testSerial, "device", "product:sdk model:Pixel_7 device:panther")
+	srv.SetShell(testSerial, adb.ShellJoin("getprop", "ro.serialno"), "HW123\n")
+
+	m, adbMgr := newTestManager(t, srv)
+	presets := []Preset{
+		{Name: "按序列号", Device: testSerial, Local: "tcp:8700", Remote: "jdwp:1234", Enabled: true},
+		{Name: "按 ro.serialno", Device: "HW123", Local: "tcp:9222", Remote: "localabstract:chrome_devtools_remote", Enabled: true},
+		{Name: "Metro", Reverse: true, Local: "tcp:8081", Remote: "tcp:8081", Enabled: true},
+		{Name: "已停用", Local: "tcp:5005", Remote: "jdwp:1", Enabled: false},
+		{Name: "其他设备", Device: "OTHER", Local: "tcp:7000", Remote: "tcp:7000", Enabled: true},
+	}
+	for _, preset := range presets {
+		if err := m.AddPreset(preset); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	watcher := adb.NewDeviceWatcher(adbMgr)
+	defer m.Watch(watcher)()
+	watcher.Start()
+	defer watcher.Stop()
+
+	// 设备上线后应用匹配的已启用预设，预设中的设备可以是 ro.serialno
+	forwardsTo := func(locals ...string) func() bool {
+		return func() bool {
+			var got []string
+			for _, rule := range srv.Forwards() {
+				got = append(got, rule.Local)
+			}
+			slices.Sort(got)
+			return slices.Equal(got, locals)
+		}
+	}
+	reversed := func() bool {
+		rules, err := adbMgr.ListReverses(testSerial)
+		return err == nil && len(rules) == 1 && rules[0].Local == "tcp:8081"
+	}
+	waitFor(t, "forwards after connecting", forwardsTo("tcp:8700", "tcp:9222"))
+	waitFor(t, "reverse after connecting", reversed)
+
+	// 断开后转发随连接消失，重新连接（DeviceConnected）后再次应用
+	if err := adbMgr.RemoveAllForwards(testSerial); err != nil {
+		t.Fatal(err)
+	}
+	srv.RemoveDevice(testSerial)
+	waitFor(t, "device to disconnect", func() bool { return len(watcher.Devices()) == 0 })
+	srv.AddDevice(testSerial, "device", "product:sdk model:Pixel_7 device:panther")
+	waitFor(t, "forward after reconnecting", func() bool {
+		return slices.ContainsFunc(srv.Forwards(), func(rule adb.ForwardRule) bool {
+			return rule.Serial == testSerial && rule.Local == "tcp:8700" && rule.Remote == "jdwp:1234"
+		})
+	})
+	waitFor(t, "reverse after reconnecting", reversed)
+}
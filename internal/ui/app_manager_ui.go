@@ -3,6 +3,7 @@ package ui
 import (
 	"adbmanager/internal/adb"
 	"fmt"
+	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -176,8 +177,7 @@ func (a *AppManagerUI) Build() fyne.CanvasObject {
 			return
 		}
 
-		output, err := a.adbMgr.ExecuteCommand(device,
-			fmt.Sprintf("dumpsys package %s", packageName))
+		output, err := a.adbMgr.ExecuteCommand(device, adb.ShellJoin("dumpsys", "package", packageName))
 		if err != nil {
 			showError(a.window, "获取应用信息失败", err)
 			return
@@ -217,8 +217,10 @@ func (a *AppManagerUI) Build() fyne.CanvasObject {
 					return
 				}
 
-				_, err := a.adbMgr.ExecuteCommand(device,
-					fmt.Sprintf("pm clear %s", packageName))
+				result, err := a.adbMgr.ExecuteArgs(device, "pm", "clear", packageName)
+				if err == nil && result.ExitCode != 0 {
+					err = fmt.Errorf("%s", strings.TrimSpace(result.Stdout+result.Stderr))
+				}
 				if err != nil {
 					showError(a.window, "清除数据失败", err)
 					return
@@ -0,0 +1,233 @@
+package ui
+
+import (
+	"adbmanager/internal/audit"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// auditViewLimit 审计查看器最多显示的条数（最近的记录）
+const auditViewLimit = 5000
+
+// AuditUI 审计日志查看器，可按设备与关键字过滤，并校验哈希链
+type AuditUI struct {
+	window fyne.Window
+	audit  *audit.Log
+
+	mu      sync.Mutex
+	all     []audit.Record // 文件中最近的记录
+	records []audit.Record // 过滤后的记录
+	device  string         // 为空时显示所有设备
+	keyword string
+	pending bool // 是否已安排刷新
+
+	list         *widget.List
+	deviceSelect *widget.Select
+}
+
+// NewAuditUI 创建审计日志查看器
+func NewAuditUI(window fyne.Window, auditLog *audit.Log) *AuditUI {
+	return &AuditUI{
+		window:  window,
+		audit:   auditLog,
+		all:     make([]audit.Record, 0),
+		records: make([]audit.Record, 0),
+	}
+}
+
+// Build 构建审计日志查看器
+func (a *AuditUI) Build() fyne.CanvasObject {
+	a.list = widget.NewList(
+		func() int {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			return len(a.records)
+		},
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("记录")
+			label.TextStyle = fyne.TextStyle{Monospace: true}
+			return label
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			if id < len(a.records) {
+				obj.(*widget.Label).SetText(a.records[id].String())
+			}
+		},
+	)
+
+	a.deviceSelect = widget.NewSelect([]string{allDevices}, func(selected string) {
+		a.mu.Lock()
+		a.device = selected
+		if selected == allDevices {
+			a.device = ""
+		}
+		a.filter()
+		a.mu.Unlock()
+		a.refresh()
+	})
+	a.deviceSelect.SetSelected(allDevices)
+
+	keywordEntry := widget.NewEntry()
+	keywordEntry.SetPlaceHolder("关键字（命令、操作者、错误）")
+	keywordEntry.OnChanged = func(keyword string) {
+		a.mu.Lock()
+		a.keyword = strings.TrimSpace(keyword)
+		a.filter()
+		a.mu.Unlock()
+		a.refresh()
+	}
+
+	// 操作者名称写入之后的每条记录，修改后保存到 audit.json
+	operatorEntry := widget.NewEntry()
+	operatorEntry.SetText(a.audit.Operator())
+	saveOperatorBtn := widget.NewButton("设置操作者", func() {
+		a.audit.SetOperator(operatorEntry.Text)
+		operatorEntry.SetText(a.audit.Operator())
+		cfg, err := audit.LoadConfig(audit.DefaultConfigPath())
+		if err == nil {
+			cfg.Operator = strings.TrimSpace(operatorEntry.Text)
+			err = audit.SaveConfig(audit.DefaultConfigPath(), cfg)
+		}
+		if err != nil {
+			showError(a.window, "保存操作者失败", err)
+		}
+	})
+
+	reloadBtn := widget.NewButton("重新读取", a.reload)
+	verifyBtn := widget.NewButton("校验", a.verify)
+
+	a.audit.Subscribe(a.onRecord)
+	a.reload()
+
+	filters := container.NewHBox(
+		widget.NewLabel("设备:"), a.deviceSelect,
+		reloadBtn, verifyBtn,
+	)
+	operatorRow := container.NewBorder(nil, nil, widget.NewLabel("操作者:"), saveOperatorBtn, operatorEntry)
+	return container.NewBorder(
+		container.NewVBox(
+			operatorRow,
+			container.NewBorder(nil, nil, filters, nil, keywordEntry),
+			widget.NewLabel("审计日志: "+a.audit.Path()),
+			widget.NewSeparator(),
+		),
+		nil, nil, nil,
+		a.list,
+	)
+}
+
+// matches 记录是否符合当前过滤条件，调用方需持有锁
+func (a *AuditUI) matches(record audit.Record) bool {
+	if a.device != "" && record.Device != a.device && record.HardwareSerial != a.device {
+		return false
+	}
+	if a.keyword != "" && !strings.Contains(record.String(), a.keyword) {
+		return false
+	}
+	return true
+}
+
+// filter 按过滤条件重新筛选，调用方需持有锁
+func (a *AuditUI) filter() {
+	records := make([]audit.Record, 0, len(a.all))
+	for _, record := range a.all {
+		if a.matches(record) {
+			records = append(records, record)
+		}
+	}
+	a.records = records
+}
+
+// reload 重新读取审计日志文件
+func (a *AuditUI) reload() {
+	all, err := audit.ReadFile(a.audit.Path())
+	if err != nil {
+		showError(a.window, "读取审计日志失败", err)
+	}
+	if len(all) > auditViewLimit {
+		all = all[len(all)-auditViewLimit:]
+	}
+
+	a.mu.Lock()
+	a.all = all
+	a.filter()
+	a.mu.Unlock()
+
+	a.updateDevices()
+	a.refresh()
+}
+
+// onRecord 新记录写入，在执行 adb 操作的协程中调用
+func (a *AuditUI) onRecord(record audit.Record) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.all = append(a.all, record)
+	if len(a.all) > auditViewLimit {
+		a.all = append([]audit.Record(nil), a.all[len(a.all)-auditViewLimit:]...)
+	}
+	if a.matches(record) {
+		a.records = append(a.records, record)
+	}
+	if !a.pending {
+		a.pending = true
+		time.AfterFunc(logRefreshDelay, func() {
+			a.mu.Lock()
+			a.pending = false
+			a.filter() // 超出条数上限时丢弃的旧记录也要从过滤结果中去掉
+			a.mu.Unlock()
+			a.updateDevices()
+			a.refresh()
+		})
+	}
+}
+
+// updateDevices 以记录中出现过的设备更新设备选项
+func (a *AuditUI) updateDevices() {
+	a.mu.Lock()
+	seen := make(map[string]bool)
+	devices := make([]string, 0)
+	for _, record := range a.all {
+		if record.Device != "" && !seen[record.Device] {
+			seen[record.Device] = true
+			devices = append(devices, record.Device)
+		}
+	}
+	a.mu.Unlock()
+
+	sort.Strings(devices)
+	a.deviceSelect.Options = append([]string{allDevices}, devices...)
+	a.deviceSelect.Refresh()
+}
+
+// refresh 重绘列表并滚动到最新的记录
+func (a *AuditUI) refresh() {
+	a.list.Refresh()
+	a.list.ScrollToBottom()
+}
+
+// verify 校验整个审计日志文件的哈希链
+func (a *AuditUI) verify() {
+	result, err := audit.Verify(a.audit.Path())
+	if err != nil {
+		showError(a.window, "读取审计日志失败", err)
+		return
+	}
+	if !result.OK() {
+		showError(a.window, "审计日志校验失败",
+			fmt.Errorf("前 %d 条记录完整，第 %d 行: %s", result.Records, result.Line, result.Problem))
+		return
+	}
+	showInfo(a.window, "审计日志校验通过", fmt.Sprintf(
+		"共 %d 条记录，哈希链完整\n最后一条记录的哈希（可另行保存，用于发现末尾记录被删除）:\n%s",
+		result.Records, result.LastHash))
+}
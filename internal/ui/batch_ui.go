@@ -3,7 +3,13 @@ package ui
 import (
 	"adbmanager/internal/adb"
 	"adbmanager/internal/batch"
+	"adbmanager/internal/profile"
+	"context"
 	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -16,6 +22,7 @@ type BatchUI struct {
 	window          fyne.Window
 	batchMgr        *batch.BatchManager
 	adbMgr          *adb.ADBManager
+	profiles        *profile.Store
 	selectedDevices []string
 
 	deviceCheckboxes map[string]*widget.Check
@@ -23,11 +30,12 @@ type BatchUI struct {
 }
 
 // NewBatchUI 创建批量操作界面
-func NewBatchUI(window fyne.Window, batchMgr *batch.BatchManager, adbMgr *adb.ADBManager, selectedDevices []string) *BatchUI {
+func NewBatchUI(window fyne.Window, batchMgr *batch.BatchManager, adbMgr *adb.ADBManager, profiles *profile.Store, selectedDevices []string) *BatchUI {
 	return &BatchUI{
 		window:           window,
 		batchMgr:         batchMgr,
 		adbMgr:           adbMgr,
+		profiles:         profiles,
 		selectedDevices:  selectedDevices,
 		deviceCheckboxes: make(map[string]*widget.Check),
 		devices:          make([]adb.Device, 0),
@@ -88,16 +96,25 @@ func (b *BatchUI) buildOperationPanel() fyne.CanvasObject {
 	resultText.Wrapping = fyne.TextWrapWord
 	resultText.TextStyle = fyne.TextStyle{Monospace: true}
 
-	// Busybox 开关
-	busyboxCheck := widget.NewCheck("启用 Busybox 模式", func(checked bool) {
-		b.adbMgr.SetBusyboxEnabled(checked)
-		if checked {
-			resultText.SetText("✅ Busybox 模式已启用 - 命令执行时将自动加入 'busybox' 前缀\n")
-		} else {
-			resultText.SetText("❌ Busybox 模式已禁用\n")
-		}
+	// 选中设备的命令包装配置，批量执行时每台设备按各自的配置包装命令
+	profileBtn := widget.NewButton("命令包装配置", func() {
+		selectedDevs := b.getSelectedDevices()
+		showProfileDialog(b.window, b.adbMgr, b.profiles, selectedDevs, func() {
+			var lines strings.Builder
+			for _, device := range selectedDevs {
+				lines.WriteString(device + ": " + b.adbMgr.WrapperProfile(device).String() + "\n")
+			}
+			resultText.SetText(lines.String())
+		})
+	})
+
+	// 所有 adb 操作共用的并发上限，批量操作的设备较多时在此排队
+	concurrencyBtn := widget.NewButton("并发上限", func() {
+		showConcurrencyDialog(b.window, b.adbMgr)
 	})
-	busyboxCheck.Checked = b.adbMgr.IsBusyboxEnabled()
+
+	// 取消进行中的批量操作
+	cancelBtn := newCancelButton()
 
 	// 批量执行命令
 	commandEntry := widget.NewEntry()
@@ -118,15 +135,19 @@ func (b *BatchUI) buildOperationPanel() fyne.CanvasObject {
 
 		resultText.SetText(fmt.Sprintf("正在 %d 台设备上执行命令...\n\n", len(selectedDevs)))
 
-		b.batchMgr.BatchExecuteCommand(selectedDevs, command, func(result batch.CommandResult) {
-			output := resultText.Text
-			output += fmt.Sprintf("========== %s ==========\n", result.Device)
-			if result.Error != nil {
-				output += fmt.Sprintf("错误: %s\n", result.Error.Error())
-			}
-			output += result.Output + "\n\n"
-			resultText.SetText(output)
-		})
+		ctx, done := cancelBtn.Start()
+		go func() {
+			defer done()
+			b.batchMgr.BatchExecuteCommandContext(ctx, selectedDevs, command, func(result batch.CommandResult) {
+				output := resultText.Text
+				output += fmt.Sprintf("========== %s ==========\n", result.Device)
+				if result.Error != nil {
+					output += fmt.Sprintf("错误: %s\n", result.Error.Error())
+				}
+				output += result.Output + "\n\n"
+				resultText.SetText(output)
+			})
+		}()
 	})
 
 	// 批量安装APK
@@ -146,17 +167,21 @@ func (b *BatchUI) buildOperationPanel() fyne.CanvasObject {
 			apkPath := uc.URI().Path()
 			resultText.SetText(fmt.Sprintf("正在 %d 台设备上安装应用...\n\n", len(selectedDevs)))
 
-			b.batchMgr.BatchInstallApp(selectedDevs, apkPath, func(device string, err error) {
-				output := resultText.Text
-				if err != nil {
-					output += fmt.Sprintf("✗ %s: 安装失败 - %s\n", device, err.Error())
-				} else {
-					output += fmt.Sprintf("✓ %s: 安装成功\n", device)
-				}
-				resultText.SetText(output)
-			})
+			ctx, done := cancelBtn.Start()
+			go func() {
+				defer done()
+				b.batchMgr.BatchInstallAppContext(ctx, selectedDevs, apkPath, func(device string, err error) {
+					output := resultText.Text
+					if err != nil {
+						output += fmt.Sprintf("✗ %s: 安装失败 - %s\n", device, err.Error())
+					} else {
+						output += fmt.Sprintf("✓ %s: 安装成功\n", device)
+					}
+					resultText.SetText(output)
+				})
 
-			resultText.SetText(resultText.Text + "\n批量安装完成！")
+				resultText.SetText(resultText.Text + "\n批量安装完成！")
+			}()
 		}, b.window)
 	})
 
@@ -181,18 +206,155 @@ func (b *BatchUI) buildOperationPanel() fyne.CanvasObject {
 				packageName := pkgEntry.Text
 				resultText.SetText(fmt.Sprintf("正在 %d 台设备上卸载应用...\n\n", len(selectedDevs)))
 
-				b.batchMgr.BatchUninstallApp(selectedDevs, packageName, func(device string, err error) {
-					output := resultText.Text
-					if err != nil {
-						output += fmt.Sprintf("✗ %s: 卸载失败 - %s\n", device, err.Error())
-					} else {
-						output += fmt.Sprintf("✓ %s: 卸载成功\n", device)
-					}
-					resultText.SetText(output)
-				})
+				ctx, done := cancelBtn.Start()
+				go func() {
+					defer done()
+					b.batchMgr.BatchUninstallAppContext(ctx, selectedDevs, packageName, func(device string, err error) {
+						output := resultText.Text
+						if err != nil {
+							output += fmt.Sprintf("✗ %s: 卸载失败 - %s\n", device, err.Error())
+						} else {
+							output += fmt.Sprintf("✓ %s: 卸载成功\n", device)
+						}
+						resultText.SetText(output)
+					})
+
+					resultText.SetText(resultText.Text + "\n批量卸载完成！")
+				}()
+			}, b.window)
+	})
+
+	// 批量推送文件，每台设备一个进度条
+	pushBtn := widget.NewButton("批量推送文件", func() {
+		selectedDevs := b.getSelectedDevices()
+		if len(selectedDevs) == 0 {
+			showError(b.window, "错误", fmt.Errorf("请先选择设备"))
+			return
+		}
+
+		dialog.ShowFileOpen(func(uc fyne.URIReadCloser, err error) {
+			if err != nil || uc == nil {
+				return
+			}
+			localPath := uc.URI().Path()
+			uc.Close()
+
+			remoteEntry := widget.NewEntry()
+			remoteEntry.SetText("/sdcard/")
+
+			dialog.ShowCustomConfirm("推送到设备目录", "确定", "取消", remoteEntry, func(confirmed bool) {
+				if !confirmed || remoteEntry.Text == "" {
+					return
+				}
 
-				resultText.SetText(resultText.Text + "\n批量卸载完成！")
+				remotePath := remoteEntry.Text
+				resultText.SetText(fmt.Sprintf("正在向 %d 台设备推送 %s ...\n\n", len(selectedDevs), filepath.Base(localPath)))
+
+				ctx, done := cancelBtn.Start()
+				progress := newTransferDialog(b.window, "批量推送 "+filepath.Base(localPath), selectedDevs, cancelBtn.Cancel)
+				progress.Show()
+				go func() {
+					defer done()
+					b.batchMgr.BatchPushFileWithProgressContext(ctx, selectedDevs, localPath, remotePath, progress.Update, func(device string, err error) {
+						progress.Finish(device, err)
+						output := resultText.Text
+						if err != nil {
+							output += fmt.Sprintf("✗ %s: 推送失败 - %s\n", device, err.Error())
+						} else {
+							output += fmt.Sprintf("✓ %s: 推送成功\n", device)
+						}
+						resultText.SetText(output)
+					})
+
+					progress.Done()
+					resultText.SetText(resultText.Text + "\n批量推送完成！")
+				}()
 			}, b.window)
+		}, b.window)
+	})
+
+	// 批量目录镜像，先为每台设备生成计划并确认
+	mirrorBtn := widget.NewButton("批量目录镜像", func() {
+		selectedDevs := b.getSelectedDevices()
+		if len(selectedDevs) == 0 {
+			showError(b.window, "错误", fmt.Errorf("请先选择设备"))
+			return
+		}
+
+		showMirrorDialog(b.window, "批量目录镜像（从设备拉取时按序列号分目录保存）", "/sdcard/", func(opts adb.MirrorOptions) {
+			var mu sync.Mutex
+			plans := make(map[string]*adb.MirrorPlan)
+			var report strings.Builder
+			runCancellable(b.window, "批量目录镜像", fmt.Sprintf("正在对比 %d 台设备的目录...", len(selectedDevs)), func(ctx context.Context) error {
+				results := make([]batch.MirrorPlanResult, 0, len(selectedDevs))
+				b.batchMgr.BatchPlanMirrorContext(ctx, selectedDevs, opts, func(result batch.MirrorPlanResult) {
+					mu.Lock()
+					defer mu.Unlock()
+					results = append(results, result)
+				})
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return ctxErr
+				}
+
+				sort.Slice(results, func(i, j int) bool { return results[i].Device < results[j].Device })
+				for _, result := range results {
+					fmt.Fprintf(&report, "=== %s ===\n", result.Device)
+					if result.Error != nil {
+						fmt.Fprintf(&report, "✗ 对比失败: %s\n\n", result.Error.Error())
+						continue
+					}
+					report.WriteString(formatMirrorPlan(result.Plan) + "\n")
+					if len(result.Plan.Actions) > 0 {
+						plans[result.Device] = result.Plan
+					}
+				}
+				return nil
+			}, func(err error) {
+				if err != nil {
+					showError(b.window, "对比目录失败", err)
+					return
+				}
+				resultText.SetText(report.String())
+				if len(plans) == 0 {
+					showInfo(b.window, "批量目录镜像", "所有设备均无需同步")
+					return
+				}
+
+				showMirrorPlan(b.window, report.String(), func() {
+					devices := make([]string, 0, len(plans))
+					for device := range plans {
+						devices = append(devices, device)
+					}
+					sort.Strings(devices)
+					resultText.SetText(fmt.Sprintf("正在 %d 台设备上执行目录镜像...\n\n", len(devices)))
+
+					ctx, done := cancelBtn.Start()
+					progress := newTransferDialog(b.window, "批量目录镜像", devices, cancelBtn.Cancel)
+					progress.Show()
+					go func() {
+						defer done()
+						b.batchMgr.BatchApplyMirrorContext(ctx, plans, func(device string, p adb.MirrorProgress) {
+							value, text := formatMirrorProgress(p)
+							progress.SetStatus(device, value, text)
+						}, func(device string, err error) {
+							progress.Finish(device, err)
+							mu.Lock()
+							defer mu.Unlock()
+							output := resultText.Text
+							if err != nil {
+								output += fmt.Sprintf("✗ %s: 同步失败 - %s\n", device, err.Error())
+							} else {
+								output += fmt.Sprintf("✓ %s: 同步完成（%s）\n", device, plans[device].Summary())
+							}
+							resultText.SetText(output)
+						})
+
+						progress.Done()
+						resultText.SetText(resultText.Text + "\n批量目录镜像完成！")
+					}()
+				})
+			})
+		})
 	})
 
 	// 批量截屏
@@ -211,18 +373,62 @@ func (b *BatchUI) buildOperationPanel() fyne.CanvasObject {
 			outputDir := dir.Path()
 			resultText.SetText(fmt.Sprintf("正在 %d 台设备上截屏...\n\n", len(selectedDevs)))
 
-			b.batchMgr.BatchScreenshot(selectedDevs, outputDir, func(device, filepath string, err error) {
+			ctx, done := cancelBtn.Start()
+			go func() {
+				defer done()
+				b.batchMgr.BatchScreenshotContext(ctx, selectedDevs, outputDir, func(device, filepath string, err error) {
+					output := resultText.Text
+					if err != nil {
+						output += fmt.Sprintf("✗ %s: 截屏失败 - %s\n", device, err.Error())
+					} else {
+						output += fmt.Sprintf("✓ %s: 已保存到 %s\n", device, filepath)
+					}
+					resultText.SetText(output)
+				})
+
+				resultText.SetText(resultText.Text + "\n批量截屏完成！")
+			}()
+		}, b.window)
+	})
+
+	// 批量切换为无线连接
+	switchTCPBtn := widget.NewButton("批量切换无线", func() {
+		selectedDevs := b.getSelectedDevices()
+		usbDevs := make([]string, 0, len(selectedDevs))
+		for _, dev := range b.devices {
+			if dev.Transport == adb.TransportUSB && b.deviceCheckboxes[dev.Serial].Checked {
+				usbDevs = append(usbDevs, dev.Serial)
+			}
+		}
+		if len(usbDevs) == 0 {
+			showError(b.window, "错误", fmt.Errorf("请先选择 USB 设备"))
+			return
+		}
+
+		output := fmt.Sprintf("正在将 %d 台 USB 设备切换为无线连接（端口 %d）...\n", len(usbDevs), adb.DefaultTCPPort)
+		if skipped := len(selectedDevs) - len(usbDevs); skipped > 0 {
+			output += fmt.Sprintf("已跳过 %d 台非 USB 设备\n", skipped)
+		}
+		resultText.SetText(output + "\n")
+
+		ctx, done := cancelBtn.Start()
+		go func() {
+			defer done()
+			var mu sync.Mutex
+			b.batchMgr.BatchSwitchToTCPContext(ctx, usbDevs, adb.DefaultTCPPort, func(result batch.SwitchToTCPResult) {
+				mu.Lock()
+				defer mu.Unlock()
 				output := resultText.Text
-				if err != nil {
-					output += fmt.Sprintf("✗ %s: 截屏失败 - %s\n", device, err.Error())
+				if result.Error != nil {
+					output += fmt.Sprintf("✗ %s: 切换失败 - %s\n", result.Device, result.Error.Error())
 				} else {
-					output += fmt.Sprintf("✓ %s: 已保存到 %s\n", device, filepath)
+					output += fmt.Sprintf("✓ %s: 已切换为 %s，可以拔掉 USB 线\n", result.Device, result.Address)
 				}
 				resultText.SetText(output)
 			})
 
-			resultText.SetText(resultText.Text + "\n批量截屏完成！")
-		}, b.window)
+			resultText.SetText(resultText.Text + "\n批量切换无线完成！地址已保存到目标列表")
+		}()
 	})
 
 	// 清空结果
@@ -235,14 +441,17 @@ func (b *BatchUI) buildOperationPanel() fyne.CanvasObject {
 
 	// 右侧面板布局
 	cmdBox := container.NewVBox(
-		busyboxCheck,
+		container.NewHBox(profileBtn, concurrencyBtn),
 		container.NewBorder(nil, nil, nil, execBtn, commandEntry),
 	)
 
-	buttonBox := container.NewGridWithColumns(3,
+	buttonBox := container.NewGridWithColumns(4,
 		installBtn,
 		uninstallBtn,
+		pushBtn,
 		screenshotBtn,
+		mirrorBtn,
+		switchTCPBtn,
 	)
 
 	rightPanel := container.NewBorder(
@@ -250,7 +459,8 @@ func (b *BatchUI) buildOperationPanel() fyne.CanvasObject {
 			widget.NewLabel("批量操作:"),
 			cmdBox,
 			buttonBox,
-			clearResultBtn,
+			container.NewGridWithColumns(2, cancelBtn.button, clearResultBtn),
+			newQueueStatsLabel(b.adbMgr),
 			widget.NewSeparator(),
 		),
 		nil, nil, nil,
@@ -271,9 +481,9 @@ func (b *BatchUI) refreshDeviceList(container *fyne.Container) {
 		return
 	}
 
-	// 只保留在线设备
+	// 只保留在线设备，同一台物理设备的多个连接只保留一个，避免重复执行
 	onlineDevices := make([]adb.Device, 0)
-	for _, dev := range devices {
+	for _, dev := range adb.MergeDevices(devices) {
 		if dev.Status == "device" {
 			onlineDevices = append(onlineDevices, dev)
 		}
@@ -0,0 +1,92 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// cancelButton 长时间操作的"取消"按钮
+// 每次 Start 开始一个新操作（并取消上一个），操作进行中按钮可用，点击后取消对应的 ctx
+type cancelButton struct {
+	button *widget.Button
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	seq    int
+}
+
+// newCancelButton 创建取消按钮，初始为不可用状态
+func newCancelButton() *cancelButton {
+	c := &cancelButton{}
+	c.button = widget.NewButton("取消", c.Cancel)
+	c.button.Disable()
+	return c
+}
+
+// Start 开始一个可取消的操作，操作结束后必须调用返回的 done
+func (c *cancelButton) Start() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c.mu.Lock()
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.cancel = cancel
+	c.seq++
+	seq := c.seq
+	c.mu.Unlock()
+
+	c.button.Enable()
+	return ctx, func() {
+		cancel()
+
+		c.mu.Lock()
+		current := c.seq == seq
+		if current {
+			c.cancel = nil
+		}
+		c.mu.Unlock()
+
+		if current {
+			c.button.Disable()
+		}
+	}
+}
+
+// Cancel 取消进行中的操作
+func (c *cancelButton) Cancel() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
+// runCancellable 在后台执行 run，期间显示带"取消"按钮的进度对话框
+// 用户取消时提示已取消，否则将 run 的结果交给 done
+func runCancellable(w fyne.Window, title, message string, run func(ctx context.Context) error, done func(err error)) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	progress := dialog.NewCustom(title, "取消",
+		container.NewVBox(widget.NewLabel(message), widget.NewProgressBarInfinite()), w)
+	progress.SetOnClosed(cancel)
+	progress.Show()
+
+	go func() {
+		err := run(ctx)
+		progress.Hide()
+
+		if errors.Is(err, context.Canceled) {
+			showInfo(w, "提示", "操作已取消")
+			return
+		}
+		done(err)
+	}()
+}
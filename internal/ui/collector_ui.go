@@ -187,6 +187,9 @@ func (c *CollectorUI) Build() fyne.CanvasObject {
 		resultText.SetText("")
 	})
 
+	// 取消全部采集
+	cancelBtn := newCancelButton()
+
 	// 全部采集
 	collectAllBtn := widget.NewButton("全部采集", func() {
 		device := c.getDevice()
@@ -195,53 +198,64 @@ func (c *CollectorUI) Build() fyne.CanvasObject {
 			return
 		}
 
-		result := "========== 设备信息全面采集 ==========\n\n"
+		resultText.SetText("正在采集...\n")
 
-		// 联系人
-		if contacts, err := c.collector.GetContacts(device); err == nil {
-			result += fmt.Sprintf("✓ 联系人: %d 条\n", len(contacts))
-		} else {
-			result += "✗ 联系人: 采集失败\n"
-		}
+		ctx, done := cancelBtn.Start()
+		go func() {
+			defer done()
 
-		// 短信
-		if smsList, err := c.collector.GetSMS(device, 100); err == nil {
-			result += fmt.Sprintf("✓ 短信: %d 条\n", len(smsList))
-		} else {
-			result += "✗ 短信: 采集失败\n"
-		}
+			result := "========== 设备信息全面采集 ==========\n\n"
 
-		// 位置
-		if _, err := c.collector.GetLocation(device); err == nil {
-			result += "✓ 位置信息: 已采集\n"
-		} else {
-			result += "✗ 位置信息: 采集失败\n"
-		}
+			// 联系人
+			if contacts, err := c.collector.GetContactsContext(ctx, device); err == nil {
+				result += fmt.Sprintf("✓ 联系人: %d 条\n", len(contacts))
+			} else {
+				result += "✗ 联系人: 采集失败\n"
+			}
 
-		// WiFi
-		if wifiList, err := c.collector.GetWiFiInfo(device); err == nil {
-			result += fmt.Sprintf("✓ WiFi: %d 个网络\n", len(wifiList))
-		} else {
-			result += "✗ WiFi: 采集失败\n"
-		}
+			// 短信
+			if smsList, err := c.collector.GetSMSContext(ctx, device, 100); err == nil {
+				result += fmt.Sprintf("✓ 短信: %d 条\n", len(smsList))
+			} else {
+				result += "✗ 短信: 采集失败\n"
+			}
 
-		// 应用
-		if apps, err := c.collector.GetInstalledApps(device); err == nil {
-			result += fmt.Sprintf("✓ 已安装应用: %d 个\n", len(apps))
-		} else {
-			result += "✗ 已安装应用: 采集失败\n"
-		}
+			// 位置
+			if _, err := c.collector.GetLocationContext(ctx, device); err == nil {
+				result += "✓ 位置信息: 已采集\n"
+			} else {
+				result += "✗ 位置信息: 采集失败\n"
+			}
 
-		// 电池
-		if _, err := c.collector.GetBatteryInfo(device); err == nil {
-			result += "✓ 电池信息: 已采集\n"
-		} else {
-			result += "✗ 电池信息: 采集失败\n"
-		}
+			// WiFi
+			if wifiList, err := c.collector.GetWiFiInfoContext(ctx, device); err == nil {
+				result += fmt.Sprintf("✓ WiFi: %d 个网络\n", len(wifiList))
+			} else {
+				result += "✗ WiFi: 采集失败\n"
+			}
 
-		result += "\n采集完成！可以点击各个按钮查看详细信息。"
+			// 应用
+			if apps, err := c.collector.GetInstalledAppsContext(ctx, device); err == nil {
+				result += fmt.Sprintf("✓ 已安装应用: %d 个\n", len(apps))
+			} else {
+				result += "✗ 已安装应用: 采集失败\n"
+			}
 
-		resultText.SetText(result)
+			// 电池
+			if _, err := c.collector.GetBatteryInfoContext(ctx, device); err == nil {
+				result += "✓ 电池信息: 已采集\n"
+			} else {
+				result += "✗ 电池信息: 采集失败\n"
+			}
+
+			if ctx.Err() != nil {
+				resultText.SetText(result + "\n采集已取消")
+				return
+			}
+			result += "\n采集完成！可以点击各个按钮查看详细信息。"
+
+			resultText.SetText(result)
+		}()
 	})
 
 	// 布局
@@ -257,8 +271,9 @@ func (c *CollectorUI) Build() fyne.CanvasObject {
 		propsBtn,
 	)
 
-	buttonBox3 := container.NewGridWithColumns(2,
+	buttonBox3 := container.NewGridWithColumns(3,
 		collectAllBtn,
+		cancelBtn.button,
 		clearBtn,
 	)
 
@@ -0,0 +1,94 @@
+package ui
+
+import (
+	"adbmanager/internal/adb"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// queueStatsInterval 并发统计的刷新间隔
+const queueStatsInterval = time.Second
+
+// showConcurrencyDialog 编辑 adb 操作的并发上限，保存到 concurrency.json 并立即生效
+func showConcurrencyDialog(w fyne.Window, adbMgr *adb.ADBManager) {
+	limits := adbMgr.ConcurrencyLimits()
+	globalEntry := widget.NewEntry()
+	globalEntry.SetText(strconv.Itoa(limits.Global))
+	perDeviceEntry := widget.NewEntry()
+	perDeviceEntry.SetText(strconv.Itoa(limits.PerDevice))
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("总并发", globalEntry),
+		widget.NewFormItem("单设备并发", perDeviceEntry),
+	}
+	items[0].HintText = "同时执行的 adb 操作总数，0 表示不限制"
+	items[1].HintText = "同一设备上同时执行的 adb 操作数，0 表示不限制"
+
+	dialog.ShowForm("并发上限", "保存", "取消", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		global, err := strconv.Atoi(strings.TrimSpace(globalEntry.Text))
+		if err != nil || global < 0 {
+			showError(w, "错误", fmt.Errorf("总并发无效: %s", globalEntry.Text))
+			return
+		}
+		perDevice, err := strconv.Atoi(strings.TrimSpace(perDeviceEntry.Text))
+		if err != nil || perDevice < 0 {
+			showError(w, "错误", fmt.Errorf("单设备并发无效: %s", perDeviceEntry.Text))
+			return
+		}
+
+		limits := adb.ConcurrencyLimits{Global: global, PerDevice: perDevice}
+		adbMgr.SetConcurrencyLimits(limits)
+		if err := adb.SaveConcurrencyLimits(adb.DefaultConcurrencyPath(), limits); err != nil {
+			showError(w, "保存并发上限失败", err)
+		}
+	}, w)
+}
+
+// newQueueStatsLabel 定时刷新的并发统计
+func newQueueStatsLabel(adbMgr *adb.ADBManager) *widget.Label {
+	label := widget.NewLabel(formatQueueStats(adbMgr.QueueStats()))
+	go func() {
+		ticker := time.NewTicker(queueStatsInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			label.SetText(formatQueueStats(adbMgr.QueueStats()))
+		}
+	}()
+	return label
+}
+
+// formatQueueStats 并发统计的显示文字
+func formatQueueStats(stats adb.QueueStats) string {
+	limit := func(n int) string {
+		if n <= 0 {
+			return "不限"
+		}
+		return strconv.Itoa(n)
+	}
+
+	text := fmt.Sprintf("adb 并发: 执行中 %d/%s，排队 %d（峰值 %d），平均等待 %v",
+		stats.Running, limit(stats.Limits.Global), stats.Queued, stats.PeakQueued,
+		stats.AvgWait().Round(time.Millisecond))
+
+	// 排队最多的设备
+	busiest := ""
+	most := 0
+	for _, serial := range stats.SortedDevices() {
+		if dev := stats.Devices[serial]; dev.Queued > most && serial != "" {
+			busiest, most = serial, dev.Queued
+		}
+	}
+	if busiest != "" {
+		text += fmt.Sprintf("，排队最多: %s（%d，单设备上限 %s）", busiest, most, limit(stats.Limits.PerDevice))
+	}
+	return text
+}
@@ -286,6 +286,23 @@ func (d *DeviceInfoUI) Build() fyne.CanvasObject {
 		infoText.SetText(result)
 	})
 
+	// 设备能力（重新探测）
+	capsBtn := widget.NewButton("设备能力", func() {
+		device := d.getDevice()
+		if device == "" {
+			showError(d.window, "错误", fmt.Errorf("请先选择设备"))
+			return
+		}
+
+		caps, err := d.adbMgr.ProbeCapabilities(device)
+		if err != nil {
+			showError(d.window, "探测设备能力失败", err)
+			return
+		}
+
+		infoText.SetText("========== 设备能力 ==========\n" + caps.String())
+	})
+
 	// 清空按钮
 	clearBtn := widget.NewButton("清空", func() {
 		infoText.SetText("")
@@ -312,8 +329,8 @@ func (d *DeviceInfoUI) Build() fyne.CanvasObject {
 
 	buttonBox4 := container.NewGridWithColumns(3,
 		startupBtn,
+		capsBtn,
 		clearBtn,
-		widget.NewLabel(""), // 占位符
 	)
 
 	return container.NewBorder(
@@ -2,9 +2,10 @@ package ui
 
 import (
 	"adbmanager/internal/adb"
+	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
-	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -128,7 +129,7 @@ func (f *FileManagerUI) Build() fyne.CanvasObject {
 		func(id widget.ListItemID, obj fyne.CanvasObject) {
 			defer func() {
 				if r := recover(); r != nil {
-					fmt.Printf("[PANIC] 更新列表项 %d 时发生错误: %v\n", id, r)
+					f.adbMgr.Logger().Error("更新文件列表项时发生 panic", "component", "ui", "item", id, "panic", r)
 				}
 			}()
 
@@ -169,18 +170,33 @@ func (f *FileManagerUI) Build() fyne.CanvasObject {
 			}
 
 			// 设置图标
-			if strings.HasPrefix(file.Permissions, "d") {
+			switch {
+			case file.IsDir:
 				icon.SetText("📁")
-			} else {
+			case file.IsSymlink:
+				icon.SetText("🔗")
+			default:
 				icon.SetText("📄")
 			}
 
-			// 设置文件名
-			name.SetText(file.Name)
+			// 设置文件名，符号链接显示指向的路径
+			if file.IsSymlink && file.LinkTarget != "" {
+				name.SetText(file.Name + " -> " + file.LinkTarget)
+			} else {
+				name.SetText(file.Name)
+			}
 
 			// 设置日期、大小、权限
-			date.SetText(file.Date)
-			size.SetText(file.Size)
+			if file.ModTime.IsZero() {
+				date.SetText("")
+			} else {
+				date.SetText(file.ModTime.Format("2006-01-02 15:04"))
+			}
+			if file.IsDir {
+				size.SetText("-")
+			} else {
+				size.SetText(formatBytes(file.Size))
+			}
 			perm.SetText(file.Permissions)
 
 			// 编辑按钮
@@ -199,7 +215,7 @@ func (f *FileManagerUI) Build() fyne.CanvasObject {
 			}
 
 			// 上传按钮（只对目录显示）
-			if strings.HasPrefix(file.Permissions, "d") {
+			if file.IsDir {
 				uploadBtn.Show()
 				uploadBtn.OnTapped = func() {
 					f.uploadToDirectory(int(id))
@@ -216,13 +232,9 @@ func (f *FileManagerUI) Build() fyne.CanvasObject {
 			return
 		}
 		file := f.files[id]
-		// 如果是目录
-		if strings.HasPrefix(file.Permissions, "d") {
-			if file.Name == ".." {
-				f.currentPath = filepath.Dir(f.currentPath)
-			} else if file.Name != "." {
-				f.currentPath = filepath.Join(f.currentPath, file.Name)
-			}
+		// 如果是目录，或指向目录的符号链接
+		if file.IsDir || (file.IsSymlink && f.isDirectory(filepath.Join(f.currentPath, file.Name))) {
+			f.currentPath = filepath.Join(f.currentPath, file.Name)
 			f.pathEntry.SetText(f.currentPath)
 			f.refreshFileList()
 		}
@@ -245,10 +257,15 @@ func (f *FileManagerUI) Build() fyne.CanvasObject {
 		}
 	})
 
+	mirrorBtn := widget.NewButton("🔁 目录镜像", func() {
+		f.mirrorDirectory()
+	})
+
 	actionBar := container.NewHBox(
 		uploadBtn,
 		downloadBtn,
 		chmodBtn,
+		mirrorBtn,
 	)
 
 	// 整体布局
@@ -301,6 +318,67 @@ func (f *FileManagerUI) refreshFileList() {
 	}
 }
 
+// isDirectory 判断设备上的路径是否为目录（会跟随符号链接）
+func (f *FileManagerUI) isDirectory(path string) bool {
+	result, err := f.adbMgr.ExecuteArgs(f.getDevice(), "test", "-d", path)
+	return err == nil && result.ExitCode == 0
+}
+
+// mirrorDirectory 在本地目录与设备目录之间镜像，先对比并展示计划，确认后只传输有差异的文件
+func (f *FileManagerUI) mirrorDirectory() {
+	device := f.getDevice()
+	if device == "" {
+		showError(f.window, "错误", fmt.Errorf("请先选择设备"))
+		return
+	}
+
+	showMirrorDialog(f.window, "目录镜像", f.currentPath, func(opts adb.MirrorOptions) {
+		var plan *adb.MirrorPlan
+		runCancellable(f.window, "目录镜像", "正在对比两端目录...", func(ctx context.Context) error {
+			var err error
+			plan, err = f.adbMgr.PlanMirrorContext(ctx, device, opts)
+			return err
+		}, func(err error) {
+			if err != nil {
+				showError(f.window, "对比目录失败", err)
+				return
+			}
+			if len(plan.Actions) == 0 {
+				showInfo(f.window, "目录镜像", "两端目录已一致，无需同步\n\n"+plan.Summary())
+				return
+			}
+			showMirrorPlan(f.window, formatMirrorPlan(plan), func() {
+				f.applyMirror(device, plan)
+			})
+		})
+	})
+}
+
+// applyMirror 执行镜像计划，期间显示进度条与"取消"按钮
+func (f *FileManagerUI) applyMirror(device string, plan *adb.MirrorPlan) {
+	ctx, cancel := context.WithCancel(context.Background())
+	progress := newTransferDialog(f.window, "目录镜像", []string{device}, cancel)
+	progress.Show()
+
+	go func() {
+		err := f.adbMgr.ApplyMirrorContext(ctx, device, plan, func(p adb.MirrorProgress) {
+			value, text := formatMirrorProgress(p)
+			progress.SetStatus(device, value, text)
+		})
+		progress.Hide()
+		f.refreshFileList()
+
+		switch {
+		case errors.Is(err, context.Canceled):
+			showInfo(f.window, "提示", "操作已取消")
+		case err != nil:
+			showError(f.window, "部分文件同步失败", err)
+		default:
+			showInfo(f.window, "成功", "目录镜像完成\n\n"+plan.Summary())
+		}
+	}()
+}
+
 // uploadFile 上传文件
 func (f *FileManagerUI) uploadFile() {
 	dialog.ShowFileOpen(func(uc fyne.URIReadCloser, err error) {
@@ -318,14 +396,17 @@ func (f *FileManagerUI) uploadFile() {
 		localPath := uc.URI().Path()
 		remotePath := filepath.Join(f.currentPath, filepath.Base(localPath))
 
-		err = f.adbMgr.PushFile(device, localPath, remotePath)
-		if err != nil {
-			showError(f.window, "上传失败", err)
-			return
-		}
+		runTransfer(f.window, "正在上传 "+filepath.Base(localPath), device, func(ctx context.Context, progress adb.ProgressFunc) error {
+			return f.adbMgr.PushFileWithProgressContext(ctx, device, localPath, remotePath, progress)
+		}, func(err error) {
+			if err != nil {
+				showError(f.window, "上传失败", err)
+				return
+			}
 
-		showInfo(f.window, "成功", "文件上传成功")
-		f.refreshFileList()
+			showInfo(f.window, "成功", "文件上传成功")
+			f.refreshFileList()
+		})
 	}, f.window)
 }
 
@@ -353,13 +434,16 @@ func (f *FileManagerUI) downloadFile(id int) {
 
 		localPath := uc.URI().Path()
 
-		err = f.adbMgr.PullFile(device, remotePath, localPath)
-		if err != nil {
-			showError(f.window, "下载失败", err)
-			return
-		}
+		runTransfer(f.window, "正在下载 "+file.Name, device, func(ctx context.Context, progress adb.ProgressFunc) error {
+			return f.adbMgr.PullFileWithProgressContext(ctx, device, remotePath, localPath, progress)
+		}, func(err error) {
+			if err != nil {
+				showError(f.window, "下载失败", err)
+				return
+			}
 
-		showInfo(f.window, "成功", fmt.Sprintf("文件已下载到:\n%s", localPath))
+			showInfo(f.window, "成功", fmt.Sprintf("文件已下载到:\n%s", localPath))
+		})
 	}, f.window)
 }
 
@@ -492,7 +576,7 @@ func (f *FileManagerUI) uploadToDirectory(id int) {
 
 	file := f.files[id]
 	// 确保是目录
-	if !strings.HasPrefix(file.Permissions, "d") {
+	if !file.IsDir {
 		return
 	}
 
@@ -515,13 +599,16 @@ func (f *FileManagerUI) uploadToDirectory(id int) {
 		fileName := filepath.Base(localPath)
 		remotePath := filepath.Join(targetDir, fileName)
 
-		err = f.adbMgr.PushFile(device, localPath, remotePath)
-		if err != nil {
-			showError(f.window, "上传失败", err)
-			return
-		}
+		runTransfer(f.window, "正在上传 "+fileName, device, func(ctx context.Context, progress adb.ProgressFunc) error {
+			return f.adbMgr.PushFileWithProgressContext(ctx, device, localPath, remotePath, progress)
+		}, func(err error) {
+			if err != nil {
+				showError(f.window, "上传失败", err)
+				return
+			}
 
-		showInfo(f.window, "成功", fmt.Sprintf("文件已上传到:\n%s", remotePath))
-		f.refreshFileList()
+			showInfo(f.window, "成功", fmt.Sprintf("文件已上传到:\n%s", remotePath))
+			f.refreshFileList()
+		})
 	}, f.window)
 }
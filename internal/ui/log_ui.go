@@ -0,0 +1,232 @@
+package ui
+
+import (
+	"adbmanager/internal/logging"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// logViewLimit 日志查看器最多显示的条数
+const logViewLimit = 5000
+
+// logRefreshDelay 新日志到达后合并刷新的间隔，避免日志密集时频繁重绘
+const logRefreshDelay = 200 * time.Millisecond
+
+// allDevices 设备过滤中表示不过滤的选项
+const allDevices = "全部设备"
+
+// logLevels 级别选项，由低到高
+var logLevels = []string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+// LogUI 日志查看器，可按设备、级别与关键字过滤
+type LogUI struct {
+	window fyne.Window
+	logs   *logging.Manager
+
+	mu       sync.Mutex
+	entries  []logging.Entry // 过滤后的日志
+	device   string          // 为空时显示所有设备
+	minLevel slog.Level
+	keyword  string
+	devices  map[string]bool // 设备选项中已有的设备
+	pending  bool            // 是否已安排刷新
+
+	list         *widget.List
+	deviceSelect *widget.Select
+	follow       *widget.Check
+}
+
+// NewLogUI 创建日志查看器
+func NewLogUI(window fyne.Window, logs *logging.Manager) *LogUI {
+	return &LogUI{
+		window:   window,
+		logs:     logs,
+		entries:  make([]logging.Entry, 0),
+		minLevel: slog.LevelDebug,
+		devices:  make(map[string]bool),
+	}
+}
+
+// Build 构建日志查看器
+func (l *LogUI) Build() fyne.CanvasObject {
+	l.list = widget.NewList(
+		func() int {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			return len(l.entries)
+		},
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("日志")
+			label.TextStyle = fyne.TextStyle{Monospace: true}
+			return label
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if id < len(l.entries) {
+				obj.(*widget.Label).SetText(l.entries[id].String())
+			}
+		},
+	)
+
+	l.follow = widget.NewCheck("自动滚动", nil)
+	l.follow.SetChecked(true)
+
+	l.deviceSelect = widget.NewSelect([]string{allDevices}, func(selected string) {
+		l.mu.Lock()
+		l.device = selected
+		if selected == allDevices {
+			l.device = ""
+		}
+		l.mu.Unlock()
+		l.reload()
+	})
+	l.deviceSelect.SetSelected(allDevices)
+
+	levelSelect := widget.NewSelect(logLevels, func(selected string) {
+		level, _ := logging.ParseLevel(selected)
+		l.mu.Lock()
+		l.minLevel = level
+		l.mu.Unlock()
+		l.reload()
+	})
+	levelSelect.SetSelected("DEBUG")
+
+	keywordEntry := widget.NewEntry()
+	keywordEntry.SetPlaceHolder("关键字")
+	keywordEntry.OnChanged = func(keyword string) {
+		l.mu.Lock()
+		l.keyword = strings.TrimSpace(keyword)
+		l.mu.Unlock()
+		l.reload()
+	}
+
+	// 记录级别决定写入日志文件的内容，修改后保存到 logging.json
+	recordSelect := widget.NewSelect(logLevels, func(selected string) {
+		level, err := logging.ParseLevel(selected)
+		if err != nil || level == l.logs.Level() {
+			return
+		}
+		l.logs.SetLevel(level)
+		cfg, err := logging.LoadConfig(logging.DefaultConfigPath())
+		if err == nil {
+			cfg.Level = strings.ToLower(selected)
+			err = logging.SaveConfig(logging.DefaultConfigPath(), cfg)
+		}
+		if err != nil {
+			showError(l.window, "保存日志级别失败", err)
+		}
+	})
+	recordSelect.SetSelected(l.logs.Level().String())
+
+	l.logs.Subscribe(l.onEntry)
+	l.reload()
+
+	filters := container.NewHBox(
+		widget.NewLabel("设备:"), l.deviceSelect,
+		widget.NewLabel("级别:"), levelSelect,
+		widget.NewLabel("记录级别:"), recordSelect,
+		l.follow,
+	)
+	return container.NewBorder(
+		container.NewVBox(
+			container.NewBorder(nil, nil, filters, nil, keywordEntry),
+			widget.NewLabel("日志目录: "+l.logs.Dir()),
+			widget.NewSeparator(),
+		),
+		nil, nil, nil,
+		l.list,
+	)
+}
+
+// matches 日志是否符合当前过滤条件，调用方需持有锁
+func (l *LogUI) matches(entry logging.Entry) bool {
+	if entry.Level < l.minLevel {
+		return false
+	}
+	if l.device != "" && entry.Device != l.device {
+		return false
+	}
+	if l.keyword != "" && !strings.Contains(entry.String(), l.keyword) {
+		return false
+	}
+	return true
+}
+
+// reload 按过滤条件重新筛选内存中的全部日志
+func (l *LogUI) reload() {
+	if l.list == nil {
+		return // Build 尚未完成
+	}
+	all := l.logs.Entries()
+
+	l.mu.Lock()
+	entries := make([]logging.Entry, 0, len(all))
+	for _, entry := range all {
+		if l.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	l.entries = entries
+	l.mu.Unlock()
+
+	l.updateDevices(l.logs.Devices())
+	l.refresh()
+}
+
+// onEntry 新日志到达，在写日志的协程中调用，不能写日志
+func (l *LogUI) onEntry(entry logging.Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	matched := l.matches(entry)
+	if matched {
+		l.entries = append(l.entries, entry)
+		if len(l.entries) > logViewLimit {
+			l.entries = append([]logging.Entry(nil), l.entries[len(l.entries)-logViewLimit:]...)
+		}
+	}
+	newDevice := entry.Device != "" && !l.devices[entry.Device]
+	if (matched || newDevice) && !l.pending {
+		l.pending = true
+		time.AfterFunc(logRefreshDelay, func() {
+			l.mu.Lock()
+			l.pending = false
+			l.mu.Unlock()
+			l.updateDevices(l.logs.Devices())
+			l.refresh()
+		})
+	}
+}
+
+// updateDevices 将新出现的设备加入设备选项
+func (l *LogUI) updateDevices(devices []string) {
+	l.mu.Lock()
+	changed := false
+	for _, device := range devices {
+		if !l.devices[device] {
+			l.devices[device] = true
+			changed = true
+		}
+	}
+	l.mu.Unlock()
+
+	if changed {
+		l.deviceSelect.Options = append([]string{allDevices}, devices...)
+		l.deviceSelect.Refresh()
+	}
+}
+
+// refresh 重绘列表，勾选自动滚动时滚动到最新的日志
+func (l *LogUI) refresh() {
+	l.list.Refresh()
+	if l.follow.Checked {
+		l.list.ScrollToBottom()
+	}
+}
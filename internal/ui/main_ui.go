@@ -2,11 +2,23 @@ package ui
 
 import (
 	"adbmanager/internal/adb"
+	"adbmanager/internal/audit"
 	"adbmanager/internal/batch"
 	"adbmanager/internal/collector"
+	"adbmanager/internal/logging"
+	"adbmanager/internal/profile"
 	"adbmanager/internal/scanner"
+	"adbmanager/internal/tunnel"
+	"context"
+	"crypto/rsa"
+	"errors"
 	"fmt"
 	"image/color"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -20,9 +32,18 @@ import (
 type MainUI struct {
 	window    fyne.Window
 	adbMgr    *adb.ADBManager
+	watcher   *adb.DeviceWatcher
+	reconnect *adb.Supervisor
 	batchMgr  *batch.BatchManager
 	collector *collector.Collector
 	scanner   *scanner.Scanner
+	tunnels   *tunnel.Manager
+	profiles  *profile.Store
+	logs      *logging.Manager // 日志文件不可用时为 nil，日志写入 slog.Default()
+	log       *slog.Logger
+	audit     *audit.Log           // 审计日志不可用时为 nil，不记录设备操作
+	recorder  *adb.RecordingRunner // 设置了 ADBMANAGER_RECORD 时录制 adb 调用，否则为 nil
+	recordTo  string
 
 	selectedDevices []string
 
@@ -33,21 +54,113 @@ type MainUI struct {
 
 // NewMainUI 创建主界面
 func NewMainUI(window fyne.Window) *MainUI {
-	adbMgr := adb.NewADBManager()
-	batchMgr := batch.NewBatchManager(adbMgr)
+	// 日志要在其他模块创建前设置好，各模块创建时从 adbMgr 取得 logger
+	logs := openLogs()
+	logger := slog.Default()
+	if logs != nil {
+		logger = logs.Logger()
+		slog.SetDefault(logger)
+	}
+	adbMgr, recorder, recordTo := newADBManager()
+	adbMgr.SetLogger(logger)
+	limits, err := adb.LoadConcurrencyLimits(adb.DefaultConcurrencyPath())
+	if err != nil {
+		logger.Warn("读取并发上限失败，使用默认上限", "error", err)
+	}
+	adbMgr.SetConcurrencyLimits(limits)
+	auditLog := openAudit(logger)
+	if auditLog != nil {
+		adbMgr.SetAuditor(auditLog)
+	}
+	batchMgr := batch.NewBatchManager(adbMgr, batch.DefaultTargetsPath())
 	collector := collector.NewCollector(adbMgr)
 	scanner := scanner.NewScanner(adbMgr)
 
 	return &MainUI{
 		window:          window,
 		adbMgr:          adbMgr,
+		watcher:         adb.NewDeviceWatcher(adbMgr),
+		reconnect:       adb.NewSupervisor(adbMgr),
 		batchMgr:        batchMgr,
 		collector:       collector,
 		scanner:         scanner,
+		tunnels:         tunnel.NewManager(adbMgr, tunnel.DefaultPresetPath()),
+		profiles:        profile.NewStore(adbMgr, profile.DefaultProfilePath()),
+		logs:            logs,
+		log:             logger.With("component", "ui"),
+		audit:           auditLog,
+		recorder:        recorder,
+		recordTo:        recordTo,
 		selectedDevices: make([]string, 0),
 	}
 }
 
+// recordEnv 设置后录制所有 adb 调用，退出时写入该文件，可用 adb.LoadScriptedRunner 回放
+const recordEnv = "ADBMANAGER_RECORD"
+
+// newADBManager 创建 ADB 管理器，设置了 recordEnv 时返回录制执行器与录制文件路径
+func newADBManager() (*adb.ADBManager, *adb.RecordingRunner, string) {
+	path := os.Getenv(recordEnv)
+	if path == "" {
+		return adb.NewADBManager(), nil, ""
+	}
+	recorder := adb.NewRecordingRunner(adb.NewServerRunner(adb.DefaultServerAddr, adb.NewExecRunner("adb")))
+	return adb.NewADBManagerWithRunner(recorder), recorder, path
+}
+
+// saveRecording 将录制的 adb 调用写入 recordTo
+func (m *MainUI) saveRecording() {
+	file, err := os.Create(m.recordTo)
+	if err != nil {
+		m.log.Error("保存录制文件失败", "path", m.recordTo, "error", err)
+		return
+	}
+	defer file.Close()
+	if err := m.recorder.Save(file); err != nil {
+		m.log.Error("保存录制文件失败", "path", m.recordTo, "error", err)
+		return
+	}
+	m.log.Info("已保存录制文件", "path", m.recordTo)
+}
+
+// openLogs 按 logging.json 打开日志文件，日志目录不可写时改用临时目录，仍失败时返回 nil
+func openLogs() *logging.Manager {
+	cfg, err := logging.LoadConfig(logging.DefaultConfigPath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取日志配置失败，使用默认配置: %v\n", err)
+	}
+	logs, err := logging.New(cfg)
+	if err == nil {
+		return logs
+	}
+	fmt.Fprintf(os.Stderr, "打开日志失败: %v\n", err)
+
+	cfg.Dir = filepath.Join(os.TempDir(), "adbmanager-logs")
+	if logs, err = logging.New(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "打开日志失败: %v\n", err)
+		return nil
+	}
+	return logs
+}
+
+// openAudit 按 audit.json 打开审计日志，失败时返回 nil
+func openAudit(logger *slog.Logger) *audit.Log {
+	cfg, err := audit.LoadConfig(audit.DefaultConfigPath())
+	if err != nil {
+		logger.Warn("读取审计配置失败，使用默认配置", "error", err)
+	}
+	path := cfg.Path
+	if path == "" {
+		path = audit.DefaultPath()
+	}
+	auditLog, err := audit.Open(path, cfg.Operator, logger)
+	if err != nil {
+		logger.Error("打开审计日志失败，设备操作将不被记录", "path", path, "error", err)
+		return nil
+	}
+	return auditLog
+}
+
 // Build 构建主界面
 func (m *MainUI) Build() fyne.CanvasObject {
 	// 创建各个功能标签页
@@ -57,8 +170,11 @@ func (m *MainUI) Build() fyne.CanvasObject {
 	infoTab := m.buildInfoTab()
 	collectorTab := m.buildCollectorTab()
 	appTab := m.buildAppTab()
+	tunnelTab := m.buildTunnelTab()
 	scannerTab := m.buildScannerTab()
 	batchTab := m.buildBatchTab()
+	logTab := m.buildLogTab()
+	auditTab := m.buildAuditTab()
 
 	// 创建标签页容器
 	m.tabContainer = container.NewAppTabs(
@@ -68,23 +184,70 @@ func (m *MainUI) Build() fyne.CanvasObject {
 		container.NewTabItem("设备信息", infoTab),
 		container.NewTabItem("信息采集", collectorTab),
 		container.NewTabItem("应用管理", appTab),
+		container.NewTabItem("端口转发", tunnelTab),
 		container.NewTabItem("敏感信息", scannerTab),
 		container.NewTabItem("批量操作", batchTab),
+		container.NewTabItem("日志", logTab),
+		container.NewTabItem("审计", auditTab),
 	)
 
+	// 设备列表由 track-devices 推送的事件驱动刷新，设备连接后自动应用命令包装配置与端口转发预设，无线设备断开后自动重连
+	m.reconnect.Watch(m.watcher)
+	m.profiles.Watch(m.watcher)
+	m.tunnels.Watch(m.watcher)
+	m.watcher.Start()
+	m.window.SetOnClosed(func() {
+		m.watcher.Stop()
+		m.reconnect.Stop()
+		if m.recorder != nil {
+			m.saveRecording()
+		}
+		if m.audit != nil {
+			m.audit.Close()
+		}
+		if m.logs != nil {
+			m.logs.Close()
+		}
+	})
+
 	return m.tabContainer
 }
 
 // buildDeviceTab 构建设备管理标签页
 func (m *MainUI) buildDeviceTab() fyne.CanvasObject {
-	// 设备列表
-	devices := make([]adb.Device, 0)
-	deviceStrings := make([]string, 0)
+	// 设备列表：设备与显示文本放在同一个切片中整体替换
+	// showDevices 会在设备监视器、重连统计等后台协程中调用，与列表的读取需要加锁
+	type deviceRow struct {
+		device adb.Device
+		text   string
+	}
+	var rowsLock sync.Mutex
+	rows := make([]deviceRow, 0)
+	rowCount := func() int {
+		rowsLock.Lock()
+		defer rowsLock.Unlock()
+		return len(rows)
+	}
+	rowAt := func(id int) (deviceRow, bool) {
+		rowsLock.Lock()
+		defer rowsLock.Unlock()
+		if id < 0 || id >= len(rows) {
+			return deviceRow{}, false
+		}
+		return rows[id], true
+	}
+	shownDevices := func() []adb.Device {
+		rowsLock.Lock()
+		defer rowsLock.Unlock()
+		devs := make([]adb.Device, len(rows))
+		for i, row := range rows {
+			devs[i] = row.device
+		}
+		return devs
+	}
 
 	m.deviceList = widget.NewList(
-		func() int {
-			return len(deviceStrings)
-		},
+		rowCount,
 		func() fyne.CanvasObject {
 			// 创建带状态指示器的列表项
 			statusCircle := canvas.NewCircle(color.NRGBA{R: 0, G: 255, B: 0, A: 255})
@@ -108,30 +271,37 @@ func (m *MainUI) buildDeviceTab() fyne.CanvasObject {
 			shellBtn := widget.NewButton("💻 Shell", nil)
 			shellBtn.Importance = widget.LowImportance
 
+			// 切换为无线连接按钮（仅 USB 设备）
+			wirelessBtn := widget.NewButton("📶 切换无线", nil)
+			wirelessBtn.Importance = widget.LowImportance
+
 			return container.NewHBox(
 				widget.NewCheck("", nil),
 				statusCircle,
 				widget.NewLabel("设备信息"),
 				statusContainer,
 				shellBtn,
+				wirelessBtn,
 			)
 		},
 		func(id widget.ListItemID, obj fyne.CanvasObject) {
-			if id < len(deviceStrings) {
+			if row, ok := rowAt(id); ok {
+				dev := row.device
 				box := obj.(*fyne.Container)
 				check := box.Objects[0].(*widget.Check)
 				statusCircle := box.Objects[1].(*canvas.Circle)
 				label := box.Objects[2].(*widget.Label)
 				statusContainer := box.Objects[3].(*fyne.Container)
 				shellBtn := box.Objects[4].(*widget.Button)
+				wirelessBtn := box.Objects[5].(*widget.Button)
 				statusBg := statusContainer.Objects[0].(*canvas.Rectangle)
 				statusLabelContainer := statusContainer.Objects[1].(*fyne.Container)
 				statusLabel := statusLabelContainer.Objects[0].(*widget.Label)
 
-				label.SetText(deviceStrings[id])
+				label.SetText(row.text)
 
 				// 恢复勾选状态（修复滚动bug）
-				serial := devices[id].Serial
+				serial := dev.Serial
 				isSelected := false
 				for _, s := range m.selectedDevices {
 					if s == serial {
@@ -153,64 +323,120 @@ func (m *MainUI) buildDeviceTab() fyne.CanvasObject {
 				}
 
 				// 根据设备状态设置颜色和文本
-				isOnline := devices[id].Status == "device"
+				isOnline := dev.Status == "device"
 				if isOnline {
 					statusCircle.FillColor = color.NRGBA{R: 76, G: 175, B: 80, A: 255} // Material 绿
 					statusBg.FillColor = color.NRGBA{R: 76, G: 175, B: 80, A: 200}     // 半透明绿
 					statusLabel.SetText("在线")
 					shellBtn.Show()
-				} else if devices[id].Status == "offline" {
+				} else if dev.Status == "offline" {
 					statusCircle.FillColor = color.NRGBA{R: 244, G: 67, B: 54, A: 255} // Material 红
 					statusBg.FillColor = color.NRGBA{R: 244, G: 67, B: 54, A: 200}     // 半透明红
 					statusLabel.SetText("离线")
 					shellBtn.Hide()
+				} else if dev.Status == "unauthorized" {
+					statusCircle.FillColor = color.NRGBA{R: 255, G: 152, B: 0, A: 255} // Material 橙
+					statusBg.FillColor = color.NRGBA{R: 255, G: 152, B: 0, A: 200}     // 半透明橙
+					statusLabel.SetText("待授权")
+					shellBtn.Hide()
 				} else {
 					statusCircle.FillColor = color.NRGBA{R: 255, G: 152, B: 0, A: 255} // Material 橙
 					statusBg.FillColor = color.NRGBA{R: 255, G: 152, B: 0, A: 200}     // 半透明橙
-					statusLabel.SetText(devices[id].Status)
+					statusLabel.SetText(dev.Status)
 					shellBtn.Hide()
 				}
+				if isOnline && dev.Transport == adb.TransportUSB {
+					wirelessBtn.Show()
+				} else {
+					wirelessBtn.Hide()
+				}
 				statusCircle.Refresh()
 				statusBg.Refresh()
 
 				// Shell 按钮点击事件
 				shellBtn.OnTapped = func() {
-					m.openShellWindow(devices[id].Serial, devices[id].Model)
+					m.openShellWindow(dev.Serial, dev.Model)
+				}
+
+				// 切换无线按钮点击事件
+				wirelessBtn.OnTapped = func() {
+					m.switchToTCP(serial)
 				}
 			}
 		},
 	)
 
+	// 显示设备列表
+	showDevices := func(devs []adb.Device) {
+		// 同一台物理设备的多个连接合并为一项
+		devs = adb.MergeDevices(devs)
+
+		// 更新设备列表（包括清空为0的情况）
+		newRows := make([]deviceRow, len(devs))
+		for i, dev := range devs {
+			text := dev.Serial + " [" + dev.Transport.String() + "] - " + dev.Status + " - " + dev.Model
+			if stats, ok := m.reconnect.StatsFor(dev.Serial); ok {
+				text += " - " + formatConnStats(stats)
+			}
+			// 只显示已探测过的 root 方式，刷新列表不在设备上执行命令
+			if dev.Root != adb.RootUnknown {
+				text += " - " + dev.Root.String()
+			}
+			if len(dev.Aliases) > 0 {
+				text += "（另有连接: " + strings.Join(dev.Aliases, ", ") + "）"
+			}
+			newRows[i] = deviceRow{device: dev, text: text}
+		}
+		rowsLock.Lock()
+		rows = newRows
+		rowsLock.Unlock()
+
+		m.log.Debug("刷新设备列表", "count", len(devs))
+
+		m.deviceList.Refresh()
+	}
+
+	// 后台读取新上线设备的 ro.serialno，识别后合并重复的连接；同时探测一次设备能力
+	resolveIdentities := func() {
+		go func() {
+			if err := m.adbMgr.ResolveIdentities(); err != nil {
+				m.log.Warn("识别设备失败", "error", err)
+			}
+			showDevices(m.adbMgr.CachedDevices())
+			if err := m.adbMgr.ProbeAllCapabilities(); err != nil {
+				m.log.Warn("探测设备能力失败", "error", err)
+			}
+		}()
+	}
+
 	// 刷新设备列表
 	refreshDevices := func() {
 		devs, err := m.adbMgr.ListDevices()
 		if err != nil {
 			// 如果获取设备列表失败，不更新UI，保持之前的设备列表
-			fmt.Printf("[UI] 获取设备列表失败: %v\n", err)
-			fmt.Printf("[UI] 保持之前的 %d 台设备\n", len(devices))
+			m.log.Warn("获取设备列表失败，保持之前的设备列表", "error", err, "kept", rowCount())
 			showError(m.window, "获取设备列表失败（保持上次结果）", err)
 			return
 		}
+		showDevices(devs)
+		resolveIdentities()
+	}
 
-		// 更新设备列表（包括清空为0的情况）
-		devices = devs
-		deviceStrings = make([]string, len(devs))
-		for i, dev := range devs {
-			deviceStrings[i] = dev.Serial + " - " + dev.Status + " - " + dev.Model
-		}
+	// 无线设备重连状态变化时刷新
+	m.reconnect.Subscribe(func(adb.ConnStats) {
+		showDevices(m.adbMgr.CachedDevices())
+	})
 
-		// 调试信息
-		fmt.Printf("刷新设备列表: 共 %d 台设备\n", len(devs))
-		for _, dev := range devs {
-			fmt.Printf("  - %s [%s]\n", dev.Serial, dev.Status)
+	// 设备插拔、状态变化时由监视器推送事件，直接使用已更新的缓存刷新
+	m.watcher.Subscribe(func(event adb.DeviceEvent) {
+		showDevices(m.adbMgr.CachedDevices())
+		if event.Device.Status == "device" {
+			resolveIdentities()
+		}
+		if event.Type == adb.DeviceUnauthorized {
+			showInfo(m.window, "需要授权", "设备 "+event.Device.Serial+" 未授权\n\n"+
+				"请解锁设备，在「允许 USB 调试吗？」对话框中点击「允许」")
 		}
-
-		m.deviceList.Refresh()
-	}
-
-	// 按钮
-	refreshBtn := widget.NewButton("刷新设备列表", func() {
-		refreshDevices()
 	})
 
 	// 诊断按钮
@@ -228,6 +454,9 @@ func (m *MainUI) buildDeviceTab() fyne.CanvasObject {
 	ipEntry := widget.NewEntry()
 	ipEntry.SetPlaceHolder("输入 IP:PORT (例如: 192.168.1.100:5555)")
 
+	// 直连模式：不经过 adb server，使用本机 RSA 密钥直接与设备 adbd 握手
+	directCheck := widget.NewCheck("直连 adbd", nil)
+
 	connectBtn := widget.NewButton("连接", func() {
 		address := ipEntry.Text
 		if address == "" {
@@ -235,14 +464,31 @@ func (m *MainUI) buildDeviceTab() fyne.CanvasObject {
 			return
 		}
 
-		err := m.adbMgr.Connect(address)
-		if err != nil {
-			showError(m.window, "连接失败", err)
-			return
+		message := "正在连接 " + address
+		connect := func(ctx context.Context) error {
+			return m.adbMgr.ConnectContext(ctx, address)
+		}
+		if directCheck.Checked {
+			key, err := adb.LoadOrCreateKey(adb.DefaultKeyPath())
+			if err != nil {
+				showError(m.window, "读取 RSA 密钥失败", err)
+				return
+			}
+			// 等待设备上确认授权最长 60 秒，在后台进行以免界面卡住
+			message = "正在直连 " + address + "\n首次直连时请在设备上确认 USB 调试授权"
+			connect = func(ctx context.Context) error {
+				return m.adbMgr.ConnectDirectContext(ctx, address, key)
+			}
 		}
 
-		showInfo(m.window, "连接成功", "已成功连接到设备: "+address)
-		refreshDevices()
+		runCancellable(m.window, "连接设备", message, connect, func(err error) {
+			if err != nil {
+				showError(m.window, "连接失败", err)
+				return
+			}
+			showInfo(m.window, "连接成功", "已成功连接到设备: "+address)
+			refreshDevices()
+		})
 	})
 
 	disconnectBtn := widget.NewButton("断开选中设备", func() {
@@ -275,7 +521,7 @@ func (m *MainUI) buildDeviceTab() fyne.CanvasObject {
 				return
 			}
 
-			// 批量连接导入的设备
+			// 导入的地址与之前保存的地址一起保留在目标列表中，批量连接列表中的所有设备
 			targets := m.batchMgr.GetTargets()
 			if len(targets) == 0 {
 				showInfo(m.window, "提示", "文件中没有有效的设备地址")
@@ -330,12 +576,14 @@ func (m *MainUI) buildDeviceTab() fyne.CanvasObject {
 					resultEntry.SetText(resultEntry.Text + "\n✓ 设备列表已刷新")
 				})
 			}
-
-			// 清空目标列表
-			m.batchMgr.ClearTargets()
 		}, m.window)
 	})
 
+	// RSA 密钥管理按钮
+	keyBtn := widget.NewButton("RSA 密钥", func() {
+		m.showKeyDialog()
+	})
+
 	// 检查设备状态按钮
 	checkStatusBtn := widget.NewButton("检查设备状态", func() {
 		refreshDevices()
@@ -345,6 +593,7 @@ func (m *MainUI) buildDeviceTab() fyne.CanvasObject {
 		offlineCount := 0
 		otherCount := 0
 
+		devices := shownDevices()
 		for _, dev := range devices {
 			if dev.Status == "device" {
 				onlineCount++
@@ -371,7 +620,7 @@ func (m *MainUI) buildDeviceTab() fyne.CanvasObject {
 
 		// 统计离线设备
 		offlineDevices := make([]string, 0)
-		for _, dev := range devices {
+		for _, dev := range shownDevices() {
 			if dev.Status == "offline" {
 				offlineDevices = append(offlineDevices, dev.Serial)
 			}
@@ -409,6 +658,14 @@ func (m *MainUI) buildDeviceTab() fyne.CanvasObject {
 			}, m.window)
 	})
 
+	// 无线调试配对（Android 11+）
+	pairBtn := widget.NewButton("无线调试配对", func() {
+		m.showPairingDialog(refreshDevices)
+	})
+	qrPairBtn := widget.NewButton("二维码配对", func() {
+		m.showQRPairingDialog(refreshDevices)
+	})
+
 	// 初始加载
 	refreshDevices()
 
@@ -417,17 +674,17 @@ func (m *MainUI) buildDeviceTab() fyne.CanvasObject {
 		nil,
 		nil,
 		widget.NewLabel("无线连接:"),
-		connectBtn,
+		container.NewHBox(directCheck, connectBtn, pairBtn, qrPairBtn),
 		ipEntry,
 	)
 
 	buttonBox := container.NewGridWithColumns(6,
-		refreshBtn,
 		disconnectBtn,
 		importBtn,
 		checkStatusBtn,
 		removeOfflineBtn,
 		diagnoseBtn,
+		keyBtn,
 	)
 
 	return container.NewBorder(
@@ -443,29 +700,99 @@ func (m *MainUI) buildDeviceTab() fyne.CanvasObject {
 	)
 }
 
+// showKeyDialog 显示直连 adbd 使用的 RSA 密钥，可重新生成
+func (m *MainUI) showKeyDialog() {
+	keyPath := adb.DefaultKeyPath()
+
+	pubEntry := widget.NewMultiLineEntry()
+	pubEntry.Wrapping = fyne.TextWrapBreak
+	pubEntry.TextStyle = fyne.TextStyle{Monospace: true}
+
+	loadKey := func(key *rsa.PrivateKey) {
+		pub, err := adb.AndroidPublicKey(&key.PublicKey, "")
+		if err != nil {
+			pubEntry.SetText(err.Error())
+			return
+		}
+		pubEntry.SetText(pub)
+	}
+
+	key, err := adb.LoadOrCreateKey(keyPath)
+	if err != nil {
+		showError(m.window, "读取 RSA 密钥失败", err)
+		return
+	}
+	loadKey(key)
+
+	regenerateBtn := widget.NewButton("重新生成", func() {
+		dialog.ShowConfirm("重新生成密钥",
+			"重新生成后，所有设备（包括 adb 命令行）都需要重新授权，确定继续吗？",
+			func(confirmed bool) {
+				if !confirmed {
+					return
+				}
+				key, err := adb.GenerateKey(keyPath)
+				if err != nil {
+					showError(m.window, "生成密钥失败", err)
+					return
+				}
+				loadKey(key)
+			}, m.window)
+	})
+
+	content := container.NewBorder(
+		widget.NewLabel("私钥路径: "+keyPath),
+		regenerateBtn,
+		nil,
+		nil,
+		container.NewScroll(pubEntry),
+	)
+
+	keyDialog := dialog.NewCustom("RSA 密钥", "关闭", content, m.window)
+	keyDialog.Resize(fyne.NewSize(600, 300))
+	keyDialog.Show()
+}
+
+// switchToTCP 将 USB 设备切换为无线连接，成功后地址保存到目标列表，设备列表由监视器事件刷新
+func (m *MainUI) switchToTCP(serial string) {
+	var address string
+	runCancellable(m.window, "切换无线", fmt.Sprintf("正在将 %s 切换为无线连接（端口 %d）...", serial, adb.DefaultTCPPort), func(ctx context.Context) error {
+		var err error
+		address, err = m.adbMgr.SwitchToTCPContext(ctx, serial, adb.DefaultTCPPort)
+		return err
+	}, func(err error) {
+		if err != nil {
+			showError(m.window, "切换无线失败", err)
+			return
+		}
+		m.batchMgr.AddTarget(address)
+		showInfo(m.window, "切换成功", fmt.Sprintf("%s 已通过 %s 无线连接，可以拔掉 USB 线\n地址已保存到目标列表", serial, address))
+	})
+}
+
 // buildShellTab 构建命令执行标签页
 func (m *MainUI) buildShellTab() fyne.CanvasObject {
 	// 命令输入
 	commandEntry := widget.NewEntry()
 	commandEntry.SetPlaceHolder("输入 ADB Shell 命令")
 
-	// 输出显示 - 使用 Label 而不是 Entry，提高可读性
-	outputText := widget.NewMultiLineEntry()
-	outputText.Wrapping = fyne.TextWrapWord
-	// 设置等宽字体和黑色文字
-	outputText.TextStyle = fyne.TextStyle{Monospace: true}
-
-	// Busybox 开关
-	busyboxCheck := widget.NewCheck("启用 Busybox 模式", func(checked bool) {
-		m.adbMgr.SetBusyboxEnabled(checked)
-		if checked {
-			outputText.SetText("✅ Busybox 模式已启用 - 命令执行时将自动加入 'busybox' 前缀\n")
-		} else {
-			outputText.SetText("❌ Busybox 模式已禁用\n")
+	// 输出逐行实时显示，top、logcat、ping 等命令在取消前持续输出
+	output := newStreamView()
+
+	// 当前设备的命令包装配置（busybox、toybox、厂商 shell）与输出编码
+	profileBtn := widget.NewButton("命令包装配置", func() {
+		device := m.getSelectedDevice()
+		if device == "" {
+			showError(m.window, "错误", fmt.Errorf("请先选择设备"))
+			return
 		}
+		showProfileDialog(m.window, m.adbMgr, m.profiles, []string{device}, func() {
+			output.AppendText("命令包装: " + m.adbMgr.WrapperProfile(device).String())
+		})
 	})
-	// 设置初始状态（不会触发回调）
-	busyboxCheck.Checked = m.adbMgr.IsBusyboxEnabled()
+
+	// 取消正在执行的命令（终止 adb 进程）
+	cancelBtn := newCancelButton()
 
 	// 执行命令的通用函数
 	executeCommand := func(command string) {
@@ -474,25 +801,43 @@ func (m *MainUI) buildShellTab() fyne.CanvasObject {
 		}
 
 		if len(m.selectedDevices) == 0 {
-			showError(m.window, "错误", nil)
-			outputText.SetText("请先选择设备")
+			showError(m.window, "错误", fmt.Errorf("请先选择设备"))
 			return
 		}
 
-		outputText.SetText("正在执行命令...\n")
-
-		// 在选中的设备上执行命令
-		for _, device := range m.selectedDevices {
-			output, err := m.adbMgr.ExecuteCommand(device, command)
-
-			result := "\n========== " + device + " ==========\n"
-			if err != nil {
-				result += "错误: " + err.Error() + "\n"
+		devices := append([]string(nil), m.selectedDevices...)
+		output.Clear()
+		output.AppendText("$ " + command)
+
+		// 各设备同时执行，多台设备时每行以序列号开头
+		ctx, done := cancelBtn.Start()
+		var wg sync.WaitGroup
+		for _, device := range devices {
+			prefix := ""
+			if len(devices) > 1 {
+				prefix = "[" + device + "] "
 			}
-			result += output + "\n"
-
-			outputText.SetText(outputText.Text + result)
+			wg.Add(1)
+			go func(device string) {
+				defer wg.Done()
+				code, err := m.adbMgr.StreamCommandContext(ctx, device, command, func(line adb.StreamLine) {
+					line.Text = prefix + line.Text
+					output.Append(line)
+				})
+				switch {
+				case errors.Is(err, context.Canceled):
+					output.AppendText(prefix + "命令已取消")
+				case err != nil:
+					output.Append(adb.StreamLine{Text: prefix + "错误: " + err.Error(), Stderr: true})
+				case code != 0:
+					output.AppendText(fmt.Sprintf("%s退出码 %d", prefix, code))
+				}
+			}(device)
 		}
+		go func() {
+			wg.Wait()
+			done()
+		}()
 	}
 
 	// 执行按钮
@@ -500,9 +845,7 @@ func (m *MainUI) buildShellTab() fyne.CanvasObject {
 		executeCommand(commandEntry.Text)
 	})
 
-	clearBtn := widget.NewButton("清空输出", func() {
-		outputText.SetText("")
-	})
+	clearBtn := widget.NewButton("清空输出", output.Clear)
 
 	// 快捷命令
 	quickCommands := []struct {
@@ -534,15 +877,15 @@ func (m *MainUI) buildShellTab() fyne.CanvasObject {
 		container.NewGridWithColumns(3, quickBtns...),
 	)
 
-	// 控制面板：busybox开关 + 输入框 + 执行按钮
+	// 控制面板：命令包装配置 + 输入框 + 执行按钮
 	controlPanel := container.NewVBox(
-		busyboxCheck,
+		container.NewHBox(profileBtn),
 		widget.NewSeparator(),
 		container.NewBorder(
 			nil,
 			nil,
 			nil,
-			container.NewHBox(executeBtn, clearBtn),
+			container.NewHBox(executeBtn, cancelBtn.button, clearBtn),
 			commandEntry,
 		),
 	)
@@ -556,7 +899,7 @@ func (m *MainUI) buildShellTab() fyne.CanvasObject {
 		nil,
 		nil,
 		nil,
-		container.NewScroll(outputText),
+		output.Build(m.window),
 	)
 }
 
@@ -580,14 +923,35 @@ func (m *MainUI) buildAppTab() fyne.CanvasObject {
 	return NewAppManagerUI(m.window, m.adbMgr, m.getSelectedDevice).Build()
 }
 
+// buildTunnelTab 构建端口转发标签页
+func (m *MainUI) buildTunnelTab() fyne.CanvasObject {
+	return NewTunnelUI(m.window, m.adbMgr, m.tunnels, m.getSelectedDevice).Build()
+}
+
 // buildScannerTab 构建敏感信息扫描标签页
 func (m *MainUI) buildScannerTab() fyne.CanvasObject {
 	return NewScannerUI(m.window, m.scanner, m.adbMgr, m.getSelectedDevice).Build()
 }
 
+// buildLogTab 构建日志标签页
+func (m *MainUI) buildLogTab() fyne.CanvasObject {
+	if m.logs == nil {
+		return widget.NewLabel("日志文件不可用，日志仅输出到标准错误")
+	}
+	return NewLogUI(m.window, m.logs).Build()
+}
+
+// buildAuditTab 构建审计日志标签页
+func (m *MainUI) buildAuditTab() fyne.CanvasObject {
+	if m.audit == nil {
+		return widget.NewLabel("审计日志不可用，设备操作未被记录，详见日志")
+	}
+	return NewAuditUI(m.window, m.audit).Build()
+}
+
 // buildBatchTab 构建批量操作标签页
 func (m *MainUI) buildBatchTab() fyne.CanvasObject {
-	return NewBatchUI(m.window, m.batchMgr, m.adbMgr, m.selectedDevices).Build()
+	return NewBatchUI(m.window, m.batchMgr, m.adbMgr, m.profiles, m.selectedDevices).Build()
 }
 
 // 辅助方法
@@ -616,154 +980,52 @@ func (m *MainUI) getSelectedDevice() string {
 	return ""
 }
 
-// openShellWindow 打开交互式 Shell 窗口
-func (m *MainUI) openShellWindow(serial, model string) {
-	// 创建新窗口
-	shellWindow := fyne.CurrentApp().NewWindow(fmt.Sprintf("ADB Shell - %s (%s)", model, serial))
-	shellWindow.Resize(fyne.NewSize(800, 600))
-
-	// 命令历史
-	commandHistory := make([]string, 0)
-	_ = commandHistory // 预留用于未来增加上下箭头浏览历史功能
-
-	// 输出显示区
-	outputText := widget.NewMultiLineEntry()
-	outputText.Wrapping = fyne.TextWrapWord
-	outputText.TextStyle = fyne.TextStyle{Monospace: true}
-	// 不禁用，但设置为只读样式确保文本清晰可见
-
-	// 添加欢迎信息
-	outputText.SetText(fmt.Sprintf("已连接到设备: %s\n", serial))
-	outputText.SetText(outputText.Text + fmt.Sprintf("型号: %s\n", model))
-	outputText.SetText(outputText.Text + "\n输入 'exit' 退出 Shell\n")
-	outputText.SetText(outputText.Text + "========================================\n\n")
-
-	// 命令输入框
-	cmdEntry := widget.NewEntry()
-	cmdEntry.SetPlaceHolder("输入命令...")
-
-	// 执行命令的函数
-	executeCommand := func(command string) {
-		if command == "" {
-			return
-		}
-
-		// 处理 exit 命令
-		if command == "exit" {
-			shellWindow.Close()
-			return
-		}
-
-		// 处理 clear 命令
-		if command == "clear" || command == "cls" {
-			outputText.SetText("")
-			cmdEntry.SetText("")
-			return
-		}
-
-		// 添加到历史
-		commandHistory = append(commandHistory, command)
-
-		// 显示命令
-		outputText.SetText(outputText.Text + fmt.Sprintf("$ %s\n", command))
-
-		// 执行命令
-		result, err := m.adbMgr.ExecuteCommand(serial, command)
-		if err != nil {
-			outputText.SetText(outputText.Text + fmt.Sprintf("错误: %v\n\n", err))
-		} else {
-			outputText.SetText(outputText.Text + result + "\n\n")
-		}
-
-		// 滚动到底部
-		outputText.CursorRow = len(outputText.Text)
-
-		// 清空输入框
-		cmdEntry.SetText("")
-	}
-
-	// 执行按钮
-	execBtn := widget.NewButton("执行", func() {
-		executeCommand(cmdEntry.Text)
-	})
-	execBtn.Importance = widget.HighImportance
-
-	// 回车键执行命令
-	cmdEntry.OnSubmitted = func(text string) {
-		executeCommand(text)
-	}
-
-	// 快捷命令按钮
-	quickBtn1 := widget.NewButton("📁 ls -la", func() {
-		cmdEntry.SetText("ls -la")
-		executeCommand("ls -la")
-	})
-	quickBtn1.Importance = widget.LowImportance
-
-	quickBtn2 := widget.NewButton("📊 top -n 1", func() {
-		cmdEntry.SetText("top -n 1")
-		executeCommand("top -n 1")
-	})
-	quickBtn2.Importance = widget.LowImportance
-
-	quickBtn3 := widget.NewButton("🔍 ps -A", func() {
-		cmdEntry.SetText("ps -A")
-		executeCommand("ps -A")
-	})
-	quickBtn3.Importance = widget.LowImportance
-
-	quickBtn4 := widget.NewButton("⚡ su", func() {
-		cmdEntry.SetText("su")
-		executeCommand("su")
-	})
-	quickBtn4.Importance = widget.WarningImportance
-
-	clearBtn := widget.NewButton("🧹 清屏", func() {
-		outputText.SetText("")
-	})
-
-	// 布局
-	quickBtnBox := container.NewHBox(
-		quickBtn1, quickBtn2, quickBtn3, quickBtn4, clearBtn,
-	)
-
-	inputBox := container.NewBorder(
-		nil, nil, nil, execBtn,
-		cmdEntry,
-	)
-
-	content := container.NewBorder(
-		container.NewVBox(
-			widget.NewLabel("💻 交互式 Shell 终端"),
-			widget.NewSeparator(),
-			quickBtnBox,
-			widget.NewSeparator(),
-		),
-		container.NewVBox(
-			widget.NewSeparator(),
-			inputBox,
-		),
-		nil, nil,
-		container.NewScroll(outputText),
-	)
-
-	shellWindow.SetContent(content)
-	shellWindow.Show()
-
-	// 聚焦到输入框
-	shellWindow.Canvas().Focus(cmdEntry)
-}
-
 // 通用对话框函数
 func showError(w fyne.Window, title string, err error) {
 	message := title
 	if err != nil {
 		message += ": " + err.Error()
 	}
+
+	// 针对可由用户处理的错误给出操作提示
+	switch {
+	case errors.Is(err, adb.ErrUnauthorized):
+		dialog.NewInformation("需要授权", message+
+			"\n\n请解锁设备，在「允许 USB 调试吗？」对话框中点击「允许」"+
+			"\n（可勾选「一律允许使用这台计算机进行调试」），授权后设备列表会自动更新", w).Show()
+		return
+	case errors.Is(err, adb.ErrServerVersionMismatch):
+		message += "\n\n本机存在多个版本的 adb，请执行 adb kill-server 后重试，" +
+			"或统一使用同一版本的 platform-tools"
+	case errors.Is(err, adb.ErrOffline):
+		message += "\n\n设备处于离线状态，请检查连接，必要时重新插拔或重新连接"
+	case errors.Is(err, adb.ErrDeviceNotFound):
+		message += "\n\n设备已断开，请重新连接后再试"
+	}
+
 	dlg := dialog.NewInformation("错误", message, w)
 	dlg.Show()
 }
 
+// formatConnStats 无线设备的连接状态与统计
+func formatConnStats(stats adb.ConnStats) string {
+	switch stats.State {
+	case adb.ConnConnected:
+		text := "在线 " + stats.Uptime().Round(time.Second).String()
+		if stats.Flaps > 0 {
+			text += fmt.Sprintf("，已断开 %d 次", stats.Flaps)
+		}
+		return text
+	case adb.ConnWaiting:
+		text := fmt.Sprintf("等待重连（%s 后）", time.Until(stats.NextRetry).Round(time.Second))
+		if stats.LastError != nil {
+			text += "，上次失败: " + stats.LastError.Error()
+		}
+		return text
+	}
+	return fmt.Sprintf("重连中（第 %d 次）", stats.Attempts)
+}
+
 func showInfo(w fyne.Window, title, message string) {
 	dlg := dialog.NewInformation(title, message, w)
 	dlg.Show()
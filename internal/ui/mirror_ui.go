@@ -0,0 +1,148 @@
+package ui
+
+import (
+	"adbmanager/internal/adb"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// mirrorPlanLines 计划对话框中最多列出的操作数
+const mirrorPlanLines = 300
+
+// showMirrorDialog 显示目录镜像选项对话框，确定后以填写的选项调用 onPreview
+func showMirrorDialog(w fyne.Window, title, remoteDir string, onPreview func(opts adb.MirrorOptions)) {
+	directions := []string{adb.MirrorPush.String(), adb.MirrorPull.String()}
+	direction := widget.NewRadioGroup(directions, nil)
+	direction.Horizontal = true
+	direction.SetSelected(directions[0])
+
+	localEntry := widget.NewEntry()
+	localEntry.SetPlaceHolder("本地目录")
+	browseBtn := widget.NewButton("浏览...", func() {
+		dialog.ShowFolderOpen(func(dir fyne.ListableURI, err error) {
+			if err == nil && dir != nil {
+				localEntry.SetText(dir.Path())
+			}
+		}, w)
+	})
+
+	remoteEntry := widget.NewEntry()
+	remoteEntry.SetText(remoteDir)
+
+	checksumCheck := widget.NewCheck("大小相同时比较 md5（较慢，适合修改时间不可靠的目录，例如刚从 git 检出）", nil)
+	deleteCheck := widget.NewCheck("删除目标端多余的文件", nil)
+
+	excludeEntry := widget.NewEntry()
+	excludeEntry.SetText(".git")
+	excludeEntry.SetPlaceHolder("以逗号分隔，支持通配符，例如 .git, *.tmp")
+
+	form := widget.NewForm(
+		widget.NewFormItem("方向", direction),
+		widget.NewFormItem("本地目录", container.NewBorder(nil, nil, nil, browseBtn, localEntry)),
+		widget.NewFormItem("设备目录", remoteEntry),
+		widget.NewFormItem("排除", excludeEntry),
+	)
+	content := container.NewVBox(form, checksumCheck, deleteCheck,
+		widget.NewLabel("确定后先对比两端目录并列出将要执行的操作，确认后才会传输"))
+
+	dlg := dialog.NewCustomConfirm(title, "预览", "取消", content, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		opts := adb.MirrorOptions{
+			Direction: adb.MirrorPush,
+			LocalDir:  strings.TrimSpace(localEntry.Text),
+			RemoteDir: strings.TrimSpace(remoteEntry.Text),
+			Checksum:  checksumCheck.Checked,
+			Delete:    deleteCheck.Checked,
+		}
+		if direction.Selected == adb.MirrorPull.String() {
+			opts.Direction = adb.MirrorPull
+		}
+		for _, pattern := range strings.Split(excludeEntry.Text, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				opts.Exclude = append(opts.Exclude, pattern)
+			}
+		}
+		if opts.LocalDir == "" || opts.RemoteDir == "" {
+			showError(w, "错误", fmt.Errorf("请填写本地目录与设备目录"))
+			return
+		}
+		onPreview(opts)
+	}, w)
+	dlg.Resize(fyne.NewSize(640, 360))
+	dlg.Show()
+}
+
+// showMirrorPlan 显示镜像计划（dry-run），用户确认后调用 onApply
+func showMirrorPlan(w fyne.Window, text string, onApply func()) {
+	label := widget.NewLabel(text)
+	label.TextStyle = fyne.TextStyle{Monospace: true}
+
+	dlg := dialog.NewCustomConfirm("镜像计划", "执行", "取消", container.NewVScroll(label), func(confirmed bool) {
+		if confirmed {
+			onApply()
+		}
+	}, w)
+	dlg.Resize(fyne.NewSize(680, 500))
+	dlg.Show()
+}
+
+// formatMirrorPlan 格式化镜像计划，列出每项操作
+func formatMirrorPlan(plan *adb.MirrorPlan) string {
+	opts := plan.Options
+	var b strings.Builder
+	if opts.Direction == adb.MirrorPull {
+		fmt.Fprintf(&b, "%s: %s -> %s\n", opts.Direction, opts.RemoteDir, opts.LocalDir)
+	} else {
+		fmt.Fprintf(&b, "%s: %s -> %s\n", opts.Direction, opts.LocalDir, opts.RemoteDir)
+	}
+	fmt.Fprintf(&b, "%s，共需传输 %s\n", plan.Summary(), formatBytes(plan.TransferBytes()))
+
+	if len(plan.Actions) > 0 {
+		b.WriteString("\n")
+	}
+	for i, action := range plan.Actions {
+		if i == mirrorPlanLines {
+			fmt.Fprintf(&b, "...（其余 %d 项未列出）\n", len(plan.Actions)-i)
+			break
+		}
+		switch action.Type {
+		case adb.MirrorCopy:
+			fmt.Fprintf(&b, "[%s] %s  %s\n", action.Type, action.Path, formatBytes(action.Size))
+		case adb.MirrorUpdate:
+			fmt.Fprintf(&b, "[%s] %s  %s（%s）\n", action.Type, action.Path, formatBytes(action.Size), action.Reason)
+		default:
+			fmt.Fprintf(&b, "[%s] %s\n", action.Type, action.Path)
+		}
+	}
+
+	if len(plan.Conflicts) > 0 {
+		b.WriteString("\n两端类型不同（一端是文件、另一端是目录），将跳过:\n")
+		for _, conflict := range plan.Conflicts {
+			b.WriteString("  " + conflict + "\n")
+		}
+	}
+	return b.String()
+}
+
+// formatMirrorProgress 返回镜像执行的进度（0~1）与状态文字
+func formatMirrorProgress(p adb.MirrorProgress) (float64, string) {
+	value := 0.0
+	switch {
+	case p.BytesTotal > 0:
+		value = float64(p.BytesDone) / float64(p.BytesTotal)
+	case p.Total > 0:
+		value = float64(p.Completed) / float64(p.Total)
+	}
+	if p.Completed == p.Total {
+		return value, fmt.Sprintf("%d/%d 已完成  %s", p.Completed, p.Total, formatBytes(p.BytesDone))
+	}
+	return value, fmt.Sprintf("%d/%d %s %s  %s / %s", p.Completed+1, p.Total, p.Action.Type, p.Action.Path,
+		formatBytes(p.BytesDone), formatBytes(p.BytesTotal))
+}
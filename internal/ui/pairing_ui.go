@@ -0,0 +1,183 @@
+package ui
+
+import (
+	"adbmanager/internal/adb"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// qrSize 配对二维码的边长（像素）
+const qrSize = 280
+
+// showPairingDialog 列出通过 mDNS 发现的无线调试设备，使用配对码配对或直接连接，成功后调用 onConnected
+func (m *MainUI) showPairingDialog(onConnected func()) {
+	services := make([]adb.MdnsService, 0)
+	selected := -1
+
+	addrEntry := widget.NewEntry()
+	addrEntry.SetPlaceHolder("配对地址 IP:PORT（设备「使用配对码配对设备」界面显示）")
+	codeEntry := widget.NewEntry()
+	codeEntry.SetPlaceHolder("六位配对码")
+
+	serviceList := widget.NewList(
+		func() int {
+			return len(services)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("mDNS 服务")
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id < len(services) {
+				obj.(*widget.Label).SetText(formatMdnsService(services[id]))
+			}
+		},
+	)
+	serviceList.OnSelected = func(id widget.ListItemID) {
+		selected = id
+		if id < len(services) && services[id].IsPairing() {
+			addrEntry.SetText(services[id].Address)
+		}
+	}
+
+	statusLabel := widget.NewLabel("")
+	refresh := func() {
+		go func() {
+			found, err := m.adbMgr.Discover()
+			if err != nil {
+				statusLabel.SetText(err.Error())
+				return
+			}
+			services = found
+			selected = -1
+			serviceList.UnselectAll()
+			serviceList.Refresh()
+			statusLabel.SetText(fmt.Sprintf("发现 %d 个服务", len(found)))
+		}()
+	}
+
+	refreshBtn := widget.NewButton("🔄 刷新", refresh)
+
+	connectBtn := widget.NewButton("连接选中设备", func() {
+		if selected < 0 || selected >= len(services) || !services[selected].IsConnect() {
+			showError(m.window, "错误", fmt.Errorf("请选择一个连接服务（%s），已配对的设备开启无线调试后会出现在列表中", adb.MdnsTLSConnect))
+			return
+		}
+		address := services[selected].Address
+		runCancellable(m.window, "连接", "正在连接 "+address+"...", func(ctx context.Context) error {
+			return m.adbMgr.ConnectContext(ctx, address)
+		}, func(err error) {
+			if err != nil {
+				showError(m.window, "连接失败", err)
+				return
+			}
+			showInfo(m.window, "连接成功", "已成功连接到设备: "+address)
+			onConnected()
+		})
+	})
+
+	pairBtn := widget.NewButton("配对并连接", func() {
+		address, code := strings.TrimSpace(addrEntry.Text), strings.TrimSpace(codeEntry.Text)
+		var serial string
+		runCancellable(m.window, "配对", "正在与 "+address+" 配对，配对后等待设备广播连接端口...", func(ctx context.Context) error {
+			var err error
+			serial, err = m.adbMgr.PairAndConnectContext(ctx, address, code)
+			return err
+		}, func(err error) {
+			if err != nil {
+				showError(m.window, "配对失败", err)
+				return
+			}
+			showInfo(m.window, "连接成功", "已配对并连接到设备: "+serial)
+			onConnected()
+		})
+	})
+
+	form := widget.NewForm(
+		widget.NewFormItem("配对地址", addrEntry),
+		widget.NewFormItem("配对码", codeEntry),
+	)
+	content := container.NewBorder(
+		container.NewVBox(
+			widget.NewLabel("在设备的「开发者选项 → 无线调试」中开启无线调试，选择「使用配对码配对设备」后会出现配对服务"),
+			container.NewBorder(nil, nil, nil, refreshBtn, statusLabel),
+		),
+		container.NewVBox(
+			connectBtn,
+			widget.NewSeparator(),
+			form,
+			pairBtn,
+		),
+		nil, nil,
+		serviceList,
+	)
+
+	dlg := dialog.NewCustom("无线调试配对", "关闭", content, m.window)
+	dlg.Resize(fyne.NewSize(640, 520))
+	dlg.Show()
+	refresh()
+}
+
+// showQRPairingDialog 显示配对二维码，手机扫码后自动配对并连接，成功后调用 onConnected
+func (m *MainUI) showQRPairingDialog(onConnected func()) {
+	qr, err := adb.NewQRPairing()
+	if err != nil {
+		showError(m.window, "生成二维码失败", err)
+		return
+	}
+	png, err := qrcode.New(qr.Payload(), qrcode.Medium)
+	if err != nil {
+		showError(m.window, "生成二维码失败", err)
+		return
+	}
+
+	image := canvas.NewImageFromImage(png.Image(qrSize))
+	image.FillMode = canvas.ImageFillOriginal
+	image.ScaleMode = canvas.ImageScalePixels
+
+	ctx, cancel := context.WithCancel(context.Background())
+	dlg := dialog.NewCustom("二维码配对", "取消", container.NewVBox(
+		widget.NewLabel("在设备的「开发者选项 → 无线调试」中选择「使用二维码配对设备」，扫描下方二维码"),
+		container.NewCenter(image),
+		widget.NewLabel("电脑与手机需在同一局域网，扫码后将自动配对并连接"),
+		widget.NewProgressBarInfinite(),
+	), m.window)
+	dlg.SetOnClosed(cancel)
+	dlg.Show()
+
+	go func() {
+		serial, err := m.adbMgr.WaitQRPairingContext(ctx, qr)
+		dlg.Hide()
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if err != nil {
+			showError(m.window, "二维码配对失败", err)
+			return
+		}
+		showInfo(m.window, "连接成功", "已配对并连接到设备: "+serial)
+		onConnected()
+	}()
+}
+
+// formatMdnsService mDNS 服务的显示文字
+func formatMdnsService(service adb.MdnsService) string {
+	kind := "其他"
+	switch {
+	case service.IsPairing():
+		kind = "等待配对"
+	case service.Type == adb.MdnsTLSConnect:
+		kind = "已配对，可连接"
+	case service.IsConnect():
+		kind = "adb over TCP"
+	}
+	return fmt.Sprintf("[%s] %s  %s", kind, service.Address, service.Name)
+}
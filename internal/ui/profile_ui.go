@@ -0,0 +1,121 @@
+package ui
+
+import (
+	"adbmanager/internal/adb"
+	"adbmanager/internal/profile"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// wrapperKinds 命令包装方式选项，顺序与 adb.WrapperKind 一致
+var wrapperKinds = []adb.WrapperKind{adb.WrapperNone, adb.WrapperBusybox, adb.WrapperToybox, adb.WrapperShell}
+
+// outputEncodings 输出编码选项，顺序与 adb.Encoding 一致
+var outputEncodings = []adb.Encoding{adb.EncodingAuto, adb.EncodingUTF8, adb.EncodingGBK, adb.EncodingShiftJIS, adb.EncodingLatin1, adb.EncodingRaw}
+
+// showProfileDialog 编辑多台设备的命令包装配置与输出编码，保存后调用 onSaved（可为 nil）
+func showProfileDialog(w fyne.Window, adbMgr *adb.ADBManager, store *profile.Store, devices []string, onSaved func()) {
+	if len(devices) == 0 {
+		showError(w, "错误", fmt.Errorf("请先选择设备"))
+		return
+	}
+
+	options := make([]string, 0, len(wrapperKinds))
+	for _, kind := range wrapperKinds {
+		options = append(options, kind.String())
+	}
+	encodingOptions := make([]string, 0, len(outputEncodings))
+	for _, enc := range outputEncodings {
+		encodingOptions = append(encodingOptions, enc.String())
+	}
+
+	type row struct {
+		kind     *widget.Select
+		path     *widget.Entry
+		encoding *widget.Select
+	}
+	rows := make(map[string]row, len(devices))
+	form := widget.NewForm()
+
+	for _, device := range devices {
+		device := device
+		r := row{kind: widget.NewSelect(options, nil), path: widget.NewEntry(), encoding: widget.NewSelect(encodingOptions, nil)}
+		r.path.SetPlaceHolder("路径，busybox / toybox 可留空")
+		setRow := func(p adb.WrapperProfile) {
+			r.kind.SetSelected(p.Kind.String())
+			r.path.SetText(p.Path)
+			r.encoding.SetSelected(p.Encoding.String())
+		}
+		setRow(adbMgr.WrapperProfile(device))
+
+		var detectBtn *widget.Button
+		detectBtn = widget.NewButton("自动检测", func() {
+			detectBtn.Disable()
+			go func() {
+				defer detectBtn.Enable()
+				p, err := store.Detect(device)
+				if err != nil {
+					showError(w, "检测失败", err)
+					return
+				}
+				setRow(p)
+			}()
+		})
+
+		rows[device] = r
+		form.Append(device, container.NewBorder(nil, nil, r.kind, container.NewHBox(r.encoding, detectBtn), r.path))
+	}
+
+	content := container.NewVBox(
+		widget.NewLabel("命令执行、批量执行时按设备的配置包装命令；设备连接时会自动检测，配置按 ro.serialno 保存"),
+		widget.NewLabel("输出编码：自动识别 UTF-8、GBK、Shift-JIS 与 Latin-1，识别错误时可手动指定；原始字节不做转换"),
+		form,
+	)
+	dlg := dialog.NewCustomConfirm("命令包装配置", "保存", "取消", container.NewVScroll(content), func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		var failed []string
+		for _, device := range devices {
+			r := rows[device]
+			p := adb.WrapperProfile{Path: strings.TrimSpace(r.path.Text)}
+			for _, kind := range wrapperKinds {
+				if kind.String() == r.kind.Selected {
+					p.Kind = kind
+				}
+			}
+			for _, enc := range outputEncodings {
+				if enc.String() == r.encoding.Selected {
+					p.Encoding = enc
+				}
+			}
+			if p.Kind == adb.WrapperShell && p.Path == "" {
+				failed = append(failed, device+": 厂商 shell 需要填写路径")
+				continue
+			}
+			if p.Kind == adb.WrapperNone {
+				p.Path = ""
+			}
+			if err := store.Set(device, p); err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", device, err))
+			}
+		}
+		if len(failed) > 0 {
+			showError(w, "保存失败", fmt.Errorf("%s", strings.Join(failed, "\n")))
+		}
+		if onSaved != nil {
+			onSaved()
+		}
+	}, w)
+	height := 190 + float32(len(devices))*44
+	if height > 560 {
+		height = 560
+	}
+	dlg.Resize(fyne.NewSize(760, height))
+	dlg.Show()
+}
@@ -0,0 +1,145 @@
+package ui
+
+import (
+	"adbmanager/internal/adb"
+	"context"
+	"errors"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// transferDialog 显示每台设备文件传输进度的对话框
+// 传输进行中按钮为"取消"，关闭对话框即取消传输；全部结束后按钮变为"关闭"
+type transferDialog struct {
+	dialog *dialog.CustomDialog
+	rows   map[string]*transferRow
+}
+
+// transferRow 单台设备的进度条与状态
+type transferRow struct {
+	bar    *widget.ProgressBar
+	status *widget.Label
+}
+
+// newTransferDialog 为每台设备创建一行进度条，关闭对话框时调用 cancel
+func newTransferDialog(w fyne.Window, title string, devices []string, cancel context.CancelFunc) *transferDialog {
+	t := &transferDialog{rows: make(map[string]*transferRow)}
+
+	box := container.NewVBox()
+	for _, device := range devices {
+		row := &transferRow{
+			bar:    widget.NewProgressBar(),
+			status: widget.NewLabel("等待中..."),
+		}
+		t.rows[device] = row
+
+		name := widget.NewLabel(device)
+		name.TextStyle = fyne.TextStyle{Bold: true}
+		box.Add(container.NewBorder(nil, nil, name, nil, row.bar))
+		box.Add(row.status)
+	}
+
+	t.dialog = dialog.NewCustom(title, "取消", container.NewVScroll(box), w)
+	t.dialog.SetOnClosed(cancel)
+	t.dialog.Resize(fyne.NewSize(560, float32(min(140+len(devices)*80, 520))))
+	return t
+}
+
+// Show 显示对话框
+func (t *transferDialog) Show() {
+	t.dialog.Show()
+}
+
+// Hide 关闭对话框
+func (t *transferDialog) Hide() {
+	t.dialog.Hide()
+}
+
+// Update 更新设备的传输进度
+func (t *transferDialog) Update(device string, progress adb.TransferProgress) {
+	t.SetStatus(device, progress.Percent()/100, formatProgress(progress))
+}
+
+// SetStatus 设置设备的进度（0~1）与状态文字
+func (t *transferDialog) SetStatus(device string, value float64, text string) {
+	row, ok := t.rows[device]
+	if !ok {
+		return
+	}
+	row.bar.SetValue(value)
+	row.status.SetText(text)
+}
+
+// Finish 标记设备传输结束
+func (t *transferDialog) Finish(device string, err error) {
+	row, ok := t.rows[device]
+	if !ok {
+		return
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		row.status.SetText("已取消")
+	case err != nil:
+		row.status.SetText("✗ 失败: " + err.Error())
+	default:
+		row.bar.SetValue(1)
+		row.status.SetText("✓ 完成")
+	}
+}
+
+// Done 全部设备结束后调用，按钮改为"关闭"
+func (t *transferDialog) Done() {
+	t.dialog.SetDismissText("关闭")
+}
+
+// runTransfer 在后台执行单台设备的文件传输，期间显示进度条与"取消"按钮
+// 用户取消时提示已取消，否则将结果交给 done
+func runTransfer(w fyne.Window, title, device string, run func(ctx context.Context, progress adb.ProgressFunc) error, done func(err error)) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	progress := newTransferDialog(w, title, []string{device}, cancel)
+	progress.Show()
+
+	go func() {
+		err := run(ctx, func(p adb.TransferProgress) {
+			progress.Update(device, p)
+		})
+		progress.Hide()
+
+		if errors.Is(err, context.Canceled) {
+			showInfo(w, "提示", "操作已取消")
+			return
+		}
+		done(err)
+	}()
+}
+
+// formatProgress 格式化传输进度，例如 "12.5 MB / 100.0 MB  12.5%  3.2 MB/s"
+func formatProgress(p adb.TransferProgress) string {
+	text := fmt.Sprintf("%s / %s  %.1f%%  %s/s",
+		formatBytes(p.Transferred), formatBytes(p.Total), p.Percent(), formatBytes(int64(p.BytesPerSec)))
+	if p.Resumed > 0 {
+		text += "  （从 " + formatBytes(p.Resumed) + " 处续传）"
+	}
+	return text
+}
+
+// formatBytes 以合适的单位显示字节数
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value := float64(n) / unit
+	for _, suffix := range []string{"KB", "MB", "GB"} {
+		if value < unit {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+		value /= unit
+	}
+	return fmt.Sprintf("%.1f TB", value)
+}
@@ -36,6 +36,9 @@ func (s *ScannerUI) Build() fyne.CanvasObject {
 	resultText.Wrapping = fyne.TextWrapWord
 	resultText.TextStyle = fyne.TextStyle{Monospace: true}
 
+	// 取消进行中的扫描
+	cancelBtn := newCancelButton()
+
 	// 扫描配置文件
 	scanConfigBtn := widget.NewButton("扫描配置文件", func() {
 		device := s.getDevice()
@@ -46,27 +49,36 @@ func (s *ScannerUI) Build() fyne.CanvasObject {
 
 		resultText.SetText("正在扫描配置文件...\n")
 
-		results, err := s.scanner.ScanConfigFiles(device)
-		if err != nil {
-			showError(s.window, "扫描失败", err)
-			return
-		}
-
-		result := "========== 配置文件扫描结果 ==========\n"
-		result += fmt.Sprintf("发现敏感信息: %d 条\n\n", len(results))
-
-		for i, info := range results {
-			result += fmt.Sprintf("%d. 类型: %s\n", i+1, info.Type)
-			result += fmt.Sprintf("   文件: %s\n", info.FilePath)
-			result += fmt.Sprintf("   位置: %s\n", info.Line)
-			result += fmt.Sprintf("   内容: %s\n\n", info.Value)
-		}
-
-		if len(results) == 0 {
-			result += "未发现敏感信息或无权限访问配置文件\n"
-		}
-
-		resultText.SetText(result)
+		ctx, done := cancelBtn.Start()
+		go func() {
+			defer done()
+
+			results, err := s.scanner.ScanConfigFilesContext(ctx, device)
+			if ctx.Err() != nil {
+				resultText.SetText("扫描已取消\n")
+				return
+			}
+			if err != nil {
+				showError(s.window, "扫描失败", err)
+				return
+			}
+
+			result := "========== 配置文件扫描结果 ==========\n"
+			result += fmt.Sprintf("发现敏感信息: %d 条\n\n", len(results))
+
+			for i, info := range results {
+				result += fmt.Sprintf("%d. 类型: %s\n", i+1, info.Type)
+				result += fmt.Sprintf("   文件: %s\n", info.FilePath)
+				result += fmt.Sprintf("   位置: %s\n", info.Line)
+				result += fmt.Sprintf("   内容: %s\n\n", info.Value)
+			}
+
+			if len(results) == 0 {
+				result += "未发现敏感信息或无权限访问配置文件\n"
+			}
+
+			resultText.SetText(result)
+		}()
 	})
 
 	// 扫描 SharedPreferences
@@ -95,26 +107,35 @@ func (s *ScannerUI) Build() fyne.CanvasObject {
 
 				resultText.SetText(fmt.Sprintf("正在扫描 %s 的 SharedPreferences...\n", packageName))
 
-				results, err := s.scanner.ScanSharedPreferences(device, packageName)
-				if err != nil {
-					showError(s.window, "扫描失败", err)
-					return
-				}
-
-				result := fmt.Sprintf("========== %s SharedPreferences 扫描结果 ==========\n", packageName)
-				result += fmt.Sprintf("发现敏感信息: %d 条\n\n", len(results))
-
-				for i, info := range results {
-					result += fmt.Sprintf("%d. 类型: %s\n", i+1, info.Type)
-					result += fmt.Sprintf("   文件: %s\n", info.FilePath)
-					result += fmt.Sprintf("   内容: %s\n\n", info.Value)
-				}
-
-				if len(results) == 0 {
-					result += "未发现敏感信息\n"
-				}
-
-				resultText.SetText(result)
+				ctx, done := cancelBtn.Start()
+				go func() {
+					defer done()
+
+					results, err := s.scanner.ScanSharedPreferencesContext(ctx, device, packageName)
+					if ctx.Err() != nil {
+						resultText.SetText("扫描已取消\n")
+						return
+					}
+					if err != nil {
+						showError(s.window, "扫描失败", err)
+						return
+					}
+
+					result := fmt.Sprintf("========== %s SharedPreferences 扫描结果 ==========\n", packageName)
+					result += fmt.Sprintf("发现敏感信息: %d 条\n\n", len(results))
+
+					for i, info := range results {
+						result += fmt.Sprintf("%d. 类型: %s\n", i+1, info.Type)
+						result += fmt.Sprintf("   文件: %s\n", info.FilePath)
+						result += fmt.Sprintf("   内容: %s\n\n", info.Value)
+					}
+
+					if len(results) == 0 {
+						result += "未发现敏感信息\n"
+					}
+
+					resultText.SetText(result)
+				}()
 			}, s.window)
 	})
 
@@ -175,27 +196,36 @@ func (s *ScannerUI) Build() fyne.CanvasObject {
 
 		resultText.SetText("正在扫描日志文件...\n")
 
-		results, err := s.scanner.ScanLogFiles(device)
-		if err != nil {
-			showError(s.window, "扫描失败", err)
-			return
-		}
-
-		result := "========== 日志文件扫描结果 ==========\n"
-		result += fmt.Sprintf("发现敏感信息: %d 条\n\n", len(results))
-
-		for i, info := range results {
-			result += fmt.Sprintf("%d. 类型: %s\n", i+1, info.Type)
-			result += fmt.Sprintf("   文件: %s\n", info.FilePath)
-			result += fmt.Sprintf("   位置: %s\n", info.Line)
-			result += fmt.Sprintf("   内容: %s\n\n", info.Value)
-		}
-
-		if len(results) == 0 {
-			result += "未发现敏感信息\n"
-		}
-
-		resultText.SetText(result)
+		ctx, done := cancelBtn.Start()
+		go func() {
+			defer done()
+
+			results, err := s.scanner.ScanLogFilesContext(ctx, device)
+			if ctx.Err() != nil {
+				resultText.SetText("扫描已取消\n")
+				return
+			}
+			if err != nil {
+				showError(s.window, "扫描失败", err)
+				return
+			}
+
+			result := "========== 日志文件扫描结果 ==========\n"
+			result += fmt.Sprintf("发现敏感信息: %d 条\n\n", len(results))
+
+			for i, info := range results {
+				result += fmt.Sprintf("%d. 类型: %s\n", i+1, info.Type)
+				result += fmt.Sprintf("   文件: %s\n", info.FilePath)
+				result += fmt.Sprintf("   位置: %s\n", info.Line)
+				result += fmt.Sprintf("   内容: %s\n\n", info.Value)
+			}
+
+			if len(results) == 0 {
+				result += "未发现敏感信息\n"
+			}
+
+			resultText.SetText(result)
+		}()
 	})
 
 	// 提升 Root 权限按钮
@@ -230,15 +260,16 @@ func (s *ScannerUI) Build() fyne.CanvasObject {
 			return
 		}
 
-		isRooted, err := s.scanner.CheckRootStatus(device)
+		// 重新探测，只执行 id，不会重启 adbd
+		method, err := s.adbMgr.ProbeRootMethod(device)
 		if err != nil {
 			showError(s.window, "检查失败", err)
 			return
 		}
 
 		result := "========== Root 状态检查 ==========\n"
-		if isRooted {
-			result += "✓ 设备已 Root\n"
+		if method.HasRoot() {
+			result += "✓ 设备已 Root（" + method.String() + "）\n"
 			result += "警告: Root 设备存在安全风险\n"
 		} else {
 			result += "✗ 设备未 Root\n"
@@ -325,8 +356,9 @@ func (s *ScannerUI) Build() fyne.CanvasObject {
 		certBtn,
 	)
 
-	buttonBox3 := container.NewGridWithColumns(2,
+	buttonBox3 := container.NewGridWithColumns(3,
 		appDataBtn,
+		cancelBtn.button,
 		clearBtn,
 	)
 
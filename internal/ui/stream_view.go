@@ -0,0 +1,124 @@
+package ui
+
+import (
+	"adbmanager/internal/adb"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// streamViewLimit 流式输出最多保留的行数，超出时丢弃最早的行
+const streamViewLimit = 5000
+
+// streamView 显示命令实时输出的列表，stderr 以红色显示
+// Append 可在任意协程中调用，新行到达后合并刷新，top、logcat 等输出密集的命令也不会频繁重绘
+type streamView struct {
+	mu      sync.Mutex
+	lines   []adb.StreamLine
+	pending bool // 是否已安排刷新
+
+	list   *widget.List
+	follow *widget.Check
+}
+
+// newStreamView 创建流式输出视图
+func newStreamView() *streamView {
+	v := &streamView{lines: make([]adb.StreamLine, 0)}
+	v.list = widget.NewList(
+		func() int {
+			v.mu.Lock()
+			defer v.mu.Unlock()
+			return len(v.lines)
+		},
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("输出")
+			label.TextStyle = fyne.TextStyle{Monospace: true}
+			return label
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			v.mu.Lock()
+			var line adb.StreamLine
+			if id < len(v.lines) {
+				line = v.lines[id]
+			}
+			v.mu.Unlock()
+
+			label := obj.(*widget.Label)
+			label.Importance = widget.MediumImportance
+			if line.Stderr {
+				label.Importance = widget.DangerImportance
+			}
+			label.SetText(line.Text)
+		},
+	)
+	v.follow = widget.NewCheck("自动滚动", nil)
+	v.follow.SetChecked(true)
+	return v
+}
+
+// Append 追加一行输出
+func (v *streamView) Append(line adb.StreamLine) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.lines = append(v.lines, line)
+	if len(v.lines) > streamViewLimit {
+		v.lines = append([]adb.StreamLine(nil), v.lines[len(v.lines)-streamViewLimit:]...)
+	}
+	if !v.pending {
+		v.pending = true
+		time.AfterFunc(logRefreshDelay, func() {
+			v.mu.Lock()
+			v.pending = false
+			v.mu.Unlock()
+			v.refresh()
+		})
+	}
+}
+
+// AppendText 追加提示信息（可包含多行）
+func (v *streamView) AppendText(text string) {
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		v.Append(adb.StreamLine{Text: line})
+	}
+}
+
+// Clear 清空输出
+func (v *streamView) Clear() {
+	v.mu.Lock()
+	v.lines = v.lines[:0]
+	v.mu.Unlock()
+	v.refresh()
+}
+
+// Text 全部输出，用于复制
+func (v *streamView) Text() string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var b strings.Builder
+	for _, line := range v.lines {
+		b.WriteString(line.Text + "\n")
+	}
+	return b.String()
+}
+
+// refresh 重绘列表，勾选自动滚动时滚动到最新的输出
+func (v *streamView) refresh() {
+	v.list.Refresh()
+	if v.follow.Checked {
+		v.list.ScrollToBottom()
+	}
+}
+
+// Build 输出列表与其下方的自动滚动、复制按钮
+func (v *streamView) Build(w fyne.Window) fyne.CanvasObject {
+	copyBtn := widget.NewButton("复制全部", func() {
+		w.Clipboard().SetContent(v.Text())
+	})
+	return container.NewBorder(nil, container.NewHBox(v.follow, copyBtn), nil, nil, v.list)
+}
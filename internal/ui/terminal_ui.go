@@ -0,0 +1,790 @@
+package ui
+
+import (
+	"adbmanager/internal/adb"
+	"adbmanager/internal/terminal"
+	"fmt"
+	"image/color"
+	"math"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// terminalRefreshDelay 终端输出到达后合并重绘的间隔
+const terminalRefreshDelay = 30 * time.Millisecond
+
+// 会话打开前的默认大小
+const (
+	terminalDefaultCols = 80
+	terminalDefaultRows = 24
+)
+
+// 终端配色
+var (
+	terminalForeground = color.RGBA{R: 0xd4, G: 0xd4, B: 0xd4, A: 0xff}
+	terminalBackground = color.RGBA{R: 0x1e, G: 0x1e, B: 0x1e, A: 0xff}
+	terminalSelection  = color.RGBA{R: 0x26, G: 0x4f, B: 0x78, A: 0xff}
+	terminalCursorIdle = color.RGBA{R: 0x60, G: 0x60, B: 0x60, A: 0xff} // 未获得焦点时的光标
+)
+
+// terminalWidget 显示终端屏幕，把键盘输入与粘贴的内容发给会话
+// 支持鼠标拖动选择、滚轮与 Shift+PageUp/PageDown 翻看回滚区、右键菜单复制粘贴
+type terminalWidget struct {
+	widget.BaseWidget
+
+	screen *terminal.Screen
+	grid   *widget.TextGrid
+	window fyne.Window
+
+	mu        sync.Mutex
+	send      func(data []byte)    // 会话打开前为 nil，输入被丢弃
+	onResize  func(cols, rows int) // 终端大小改变时调用
+	cols      int
+	rows      int
+	scroll    int // 向上翻看回滚区的行数
+	pending   bool
+	focused   bool
+	shift     bool
+	selecting bool
+	selected  bool
+	selStart  terminal.Pos
+	selEnd    terminal.Pos
+	styles    map[[2]color.RGBA]*widget.CustomTextGridStyle
+}
+
+// newTerminalWidget 创建终端控件
+func newTerminalWidget(window fyne.Window) *terminalWidget {
+	t := &terminalWidget{
+		screen: terminal.New(terminalDefaultCols, terminalDefaultRows),
+		grid:   widget.NewTextGrid(),
+		window: window,
+		cols:   terminalDefaultCols,
+		rows:   terminalDefaultRows,
+		styles: make(map[[2]color.RGBA]*widget.CustomTextGridStyle),
+	}
+	t.ExtendBaseWidget(t)
+	return t
+}
+
+// Attach 连接到会话：输入写入 send，大小改变时调用 onResize，并立即以当前大小调用一次
+func (t *terminalWidget) Attach(send func(data []byte), onResize func(cols, rows int)) {
+	t.mu.Lock()
+	t.send, t.onResize = send, onResize
+	cols, rows := t.cols, t.rows
+	t.mu.Unlock()
+
+	t.screen.SetReplyHandler(send)
+	onResize(cols, rows)
+}
+
+// TermSize 当前的列数与行数
+func (t *terminalWidget) TermSize() (cols, rows int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cols, t.rows
+}
+
+// Output 写入会话的输出并安排重绘
+func (t *terminalWidget) Output(data []byte) {
+	t.screen.Write(data)
+	t.scheduleDraw()
+}
+
+// CreateRenderer 实现 fyne.Widget
+func (t *terminalWidget) CreateRenderer() fyne.WidgetRenderer {
+	bg := canvas.NewRectangle(terminalBackground)
+	t.draw()
+	return &terminalRenderer{t: t, bg: bg, objects: []fyne.CanvasObject{bg, t.grid}}
+}
+
+// terminalCellSize 单个字符的大小，与 TextGrid 的计算方式一致
+func terminalCellSize() fyne.Size {
+	size := fyne.MeasureText("M", theme.TextSize(), fyne.TextStyle{Monospace: true})
+	return fyne.NewSize(float32(math.Round(float64(size.Width))), float32(math.Round(float64(size.Height))))
+}
+
+// fit 按控件大小计算行列数，改变时调整屏幕并通知会话
+func (t *terminalWidget) fit(size fyne.Size) {
+	cell := terminalCellSize()
+	cols, rows := int(size.Width/cell.Width), int(size.Height/cell.Height)
+	if cols <= 0 || rows <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	if cols == t.cols && rows == t.rows {
+		t.mu.Unlock()
+		return
+	}
+	t.cols, t.rows = cols, rows
+	onResize := t.onResize
+	t.mu.Unlock()
+
+	t.screen.Resize(cols, rows)
+	if onResize != nil {
+		go onResize(cols, rows)
+	}
+	t.scheduleDraw()
+}
+
+// scheduleDraw 合并短时间内的多次输出后重绘
+func (t *terminalWidget) scheduleDraw() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pending {
+		return
+	}
+	t.pending = true
+	time.AfterFunc(terminalRefreshDelay, func() {
+		t.mu.Lock()
+		t.pending = false
+		t.mu.Unlock()
+		t.draw()
+	})
+}
+
+// draw 将屏幕内容转换为 TextGrid 的行
+func (t *terminalWidget) draw() {
+	t.mu.Lock()
+	snap := t.screen.Snapshot(t.scroll)
+	cols, _ := t.screen.Size()
+	rows := make([]widget.TextGridRow, len(snap.Lines))
+	for y, line := range snap.Lines {
+		cells := make([]widget.TextGridCell, cols)
+		for x := range cells {
+			cell := terminal.Cell{Rune: ' '}
+			if x < len(line.Cells) {
+				cell = line.Cells[x]
+			}
+			fg, bg := terminalColors(cell)
+			if t.selected && t.inSelection(terminal.Pos{Line: snap.Top + y, Col: x}) {
+				bg = terminalSelection
+			}
+			if y == snap.CursorY && x == snap.CursorX {
+				if t.focused {
+					fg, bg = bg, fg
+				} else {
+					bg = terminalCursorIdle
+				}
+			}
+			r := cell.Rune
+			if cell.Attr&terminal.AttrHidden != 0 {
+				r = ' '
+			}
+			cells[x] = widget.TextGridCell{Rune: r, Style: t.style(fg, bg)}
+		}
+		rows[y] = widget.TextGridRow{Cells: cells}
+	}
+	t.grid.Rows = rows
+	t.mu.Unlock()
+
+	t.grid.Refresh()
+}
+
+// terminalColors 单元格的前景与背景色，粗体的基本颜色显示为亮色
+func terminalColors(cell terminal.Cell) (color.RGBA, color.RGBA) {
+	fgColor := cell.FG
+	if index, ok := fgColor.Indexed(); ok && index < 8 && cell.Attr&terminal.AttrBold != 0 {
+		fgColor = terminal.IndexedColor(index + 8)
+	}
+	fg := fgColor.RGBA(terminalForeground)
+	bg := cell.BG.RGBA(terminalBackground)
+	if cell.Attr&terminal.AttrReverse != 0 {
+		fg, bg = bg, fg
+	}
+	if cell.Attr&terminal.AttrDim != 0 {
+		fg = color.RGBA{R: uint8((int(fg.R) + int(bg.R)) / 2), G: uint8((int(fg.G) + int(bg.G)) / 2), B: uint8((int(fg.B) + int(bg.B)) / 2), A: 0xff}
+	}
+	return fg, bg
+}
+
+// style 返回颜色组合对应的样式，调用方需持有锁
+func (t *terminalWidget) style(fg, bg color.RGBA) widget.TextGridStyle {
+	key := [2]color.RGBA{fg, bg}
+	if style, ok := t.styles[key]; ok {
+		return style
+	}
+	if len(t.styles) > 4096 {
+		// 真彩色程序可能用到大量颜色，避免缓存无限增长
+		t.styles = make(map[[2]color.RGBA]*widget.CustomTextGridStyle)
+	}
+	style := &widget.CustomTextGridStyle{FGColor: fg, BGColor: bg}
+	t.styles[key] = style
+	return style
+}
+
+// inSelection 位置是否在选择范围内，调用方需持有锁
+func (t *terminalWidget) inSelection(pos terminal.Pos) bool {
+	start, end := t.selStart, t.selEnd
+	if before(end, start) {
+		start, end = end, start
+	}
+	return !before(pos, start) && !before(end, pos)
+}
+
+// before a 是否在 b 之前
+func before(a, b terminal.Pos) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Col < b.Col)
+}
+
+// posAt 控件中的坐标对应的缓冲区位置
+func (t *terminalWidget) posAt(p fyne.Position) terminal.Pos {
+	cell := terminalCellSize()
+	history := t.screen.ScrollbackLen()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	row := min(max(int(p.Y/cell.Height), 0), t.rows-1)
+	col := min(max(int(p.X/cell.Width), 0), t.cols-1)
+	return terminal.Pos{Line: history - min(t.scroll, history) + row, Col: col}
+}
+
+// input 发送键盘输入，并回到回滚区底部
+func (t *terminalWidget) input(data []byte) {
+	t.mu.Lock()
+	send := t.send
+	scrolled := t.scroll != 0
+	t.scroll = 0
+	t.mu.Unlock()
+
+	if scrolled {
+		t.scheduleDraw()
+	}
+	if send != nil && len(data) > 0 {
+		send(data)
+	}
+}
+
+// scrollBy 翻看回滚区，lines 为正时向上
+func (t *terminalWidget) scrollBy(lines int) {
+	history := t.screen.ScrollbackLen()
+	t.mu.Lock()
+	t.scroll = min(max(t.scroll+lines, 0), history)
+	t.mu.Unlock()
+	t.scheduleDraw()
+}
+
+// Copy 复制选中的文字并取消选择，没有选择时返回 false
+func (t *terminalWidget) Copy() bool {
+	t.mu.Lock()
+	selected, start, end := t.selected, t.selStart, t.selEnd
+	t.selected = false
+	t.mu.Unlock()
+	if !selected {
+		return false
+	}
+	t.window.Clipboard().SetContent(t.screen.Text(start, end))
+	t.scheduleDraw()
+	return true
+}
+
+// CopyAll 复制回滚区与屏幕上的全部文字
+func (t *terminalWidget) CopyAll() {
+	t.window.Clipboard().SetContent(t.screen.AllText())
+}
+
+// Paste 粘贴剪贴板内容，换行按回车键发送，程序开启括号粘贴模式时加上标记
+func (t *terminalWidget) Paste() {
+	text := t.window.Clipboard().Content()
+	if text == "" {
+		return
+	}
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\r"), "\n", "\r")
+	if t.screen.BracketedPaste() {
+		text = "\x1b[200~" + text + "\x1b[201~"
+	}
+	t.input([]byte(text))
+}
+
+// Tapped 获得焦点并取消选择
+func (t *terminalWidget) Tapped(*fyne.PointEvent) {
+	t.window.Canvas().Focus(t)
+	t.mu.Lock()
+	t.selected = false
+	t.mu.Unlock()
+	t.scheduleDraw()
+}
+
+// TappedSecondary 右键菜单
+func (t *terminalWidget) TappedSecondary(e *fyne.PointEvent) {
+	menu := fyne.NewMenu("",
+		fyne.NewMenuItem("复制", func() { t.Copy() }),
+		fyne.NewMenuItem("粘贴", t.Paste),
+		fyne.NewMenuItem("复制全部", t.CopyAll),
+	)
+	widget.ShowPopUpMenuAtPosition(menu, t.window.Canvas(), e.AbsolutePosition)
+}
+
+// Scrolled 鼠标滚轮翻看回滚区
+func (t *terminalWidget) Scrolled(e *fyne.ScrollEvent) {
+	lines := int(math.Round(float64(e.Scrolled.DY / terminalCellSize().Height)))
+	if lines == 0 && e.Scrolled.DY != 0 {
+		lines = 1
+		if e.Scrolled.DY < 0 {
+			lines = -1
+		}
+	}
+	t.scrollBy(lines)
+}
+
+// Dragged 拖动选择文字
+func (t *terminalWidget) Dragged(e *fyne.DragEvent) {
+	end := t.posAt(e.Position)
+	var start terminal.Pos
+	t.mu.Lock()
+	selecting := t.selecting
+	t.mu.Unlock()
+	if !selecting {
+		start = t.posAt(e.Position.Subtract(e.Dragged))
+	}
+
+	t.mu.Lock()
+	if !t.selecting {
+		t.selecting, t.selected = true, true
+		t.selStart = start
+	}
+	t.selEnd = end
+	t.mu.Unlock()
+	t.scheduleDraw()
+}
+
+// DragEnd 结束选择
+func (t *terminalWidget) DragEnd() {
+	t.mu.Lock()
+	t.selecting = false
+	t.mu.Unlock()
+}
+
+// FocusGained 实现 fyne.Focusable
+func (t *terminalWidget) FocusGained() {
+	t.mu.Lock()
+	t.focused = true
+	t.mu.Unlock()
+	t.scheduleDraw()
+}
+
+// FocusLost 实现 fyne.Focusable
+func (t *terminalWidget) FocusLost() {
+	t.mu.Lock()
+	t.focused = false
+	t.shift = false
+	t.mu.Unlock()
+	t.scheduleDraw()
+}
+
+// AcceptsTab Tab 键发给终端而不是切换焦点
+func (t *terminalWidget) AcceptsTab() bool {
+	return true
+}
+
+// TypedRune 输入字符
+func (t *terminalWidget) TypedRune(r rune) {
+	t.input([]byte(string(r)))
+}
+
+// KeyDown 记录 Shift 的状态，用于 Shift+PageUp/PageDown 与 Shift+Tab
+func (t *terminalWidget) KeyDown(e *fyne.KeyEvent) {
+	if e.Name == desktop.KeyShiftLeft || e.Name == desktop.KeyShiftRight {
+		t.mu.Lock()
+		t.shift = true
+		t.mu.Unlock()
+	}
+}
+
+// KeyUp 实现 desktop.Keyable
+func (t *terminalWidget) KeyUp(e *fyne.KeyEvent) {
+	if e.Name == desktop.KeyShiftLeft || e.Name == desktop.KeyShiftRight {
+		t.mu.Lock()
+		t.shift = false
+		t.mu.Unlock()
+	}
+}
+
+// TypedKey 功能键转换为 xterm 的按键序列
+func (t *terminalWidget) TypedKey(e *fyne.KeyEvent) {
+	t.mu.Lock()
+	shift, rows := t.shift, t.rows
+	t.mu.Unlock()
+
+	switch {
+	case shift && e.Name == fyne.KeyPageUp:
+		t.scrollBy(rows / 2)
+		return
+	case shift && e.Name == fyne.KeyPageDown:
+		t.scrollBy(-rows / 2)
+		return
+	case shift && e.Name == fyne.KeyTab:
+		t.input([]byte("\x1b[Z"))
+		return
+	}
+	if seq := keySequence(e.Name, t.screen.AppCursorKeys()); seq != "" {
+		t.input([]byte(seq))
+	}
+}
+
+// TypedShortcut 组合键：Ctrl+字母发送控制字符，Alt+字母发送 ESC 前缀，Ctrl+Shift+C / V 复制粘贴
+// Ctrl+C 在有选择时复制，否则发送中断
+func (t *terminalWidget) TypedShortcut(shortcut fyne.Shortcut) {
+	darwin := runtime.GOOS == "darwin" // macOS 上的标准快捷键使用 Cmd，不与控制字符冲突
+	switch s := shortcut.(type) {
+	case *fyne.ShortcutCopy:
+		if !t.Copy() && !darwin {
+			t.input([]byte{0x03})
+		}
+	case *fyne.ShortcutPaste:
+		t.Paste()
+	case *fyne.ShortcutCut:
+		if !darwin {
+			t.input([]byte{0x18})
+		}
+	case *fyne.ShortcutSelectAll:
+		if !darwin {
+			t.input([]byte{0x01})
+		}
+	case *desktop.CustomShortcut:
+		t.customShortcut(s)
+	}
+}
+
+// customShortcut 处理带 Ctrl、Alt 的按键
+func (t *terminalWidget) customShortcut(s *desktop.CustomShortcut) {
+	const ctrlShift = fyne.KeyModifierControl | fyne.KeyModifierShift
+	switch {
+	case s.Modifier == ctrlShift && s.KeyName == fyne.KeyC:
+		t.Copy()
+		return
+	case s.Modifier == ctrlShift && s.KeyName == fyne.KeyV:
+		t.Paste()
+		return
+	}
+
+	// 方向键等功能键：xterm 以参数表示修饰键，例如 Ctrl+← 为 ESC [1;5D
+	if seq := modifiedKeySequence(s.KeyName, s.Modifier); seq != "" {
+		t.input([]byte(seq))
+		return
+	}
+
+	name := string(s.KeyName)
+	switch s.Modifier {
+	case fyne.KeyModifierControl, ctrlShift:
+		if b, ok := controlByte(s.KeyName); ok {
+			t.input([]byte{b})
+		}
+	case fyne.KeyModifierAlt, fyne.KeyModifierAlt | fyne.KeyModifierShift:
+		if len(name) == 1 {
+			if s.Modifier&fyne.KeyModifierShift == 0 {
+				name = strings.ToLower(name)
+			}
+			t.input([]byte("\x1b" + name))
+		}
+	}
+}
+
+// controlByte Ctrl+按键对应的控制字符
+func controlByte(key fyne.KeyName) (byte, bool) {
+	name := string(key)
+	if len(name) == 1 && name[0] >= 'A' && name[0] <= 'Z' {
+		return name[0] - 'A' + 1, true
+	}
+	switch key {
+	case fyne.KeySpace, fyne.Key2:
+		return 0x00, true
+	case fyne.KeyLeftBracket:
+		return 0x1b, true
+	case fyne.KeyBackslash:
+		return 0x1c, true
+	case fyne.KeyRightBracket:
+		return 0x1d, true
+	case fyne.KeySlash, fyne.KeyMinus:
+		return 0x1f, true
+	}
+	return 0, false
+}
+
+// cursorKeys 方向键与 Home / End 的最后一个字符
+var cursorKeys = map[fyne.KeyName]byte{
+	fyne.KeyUp: 'A', fyne.KeyDown: 'B', fyne.KeyRight: 'C', fyne.KeyLeft: 'D',
+	fyne.KeyHome: 'H', fyne.KeyEnd: 'F',
+}
+
+// tildeKeys 以 ESC [n~ 表示的按键
+var tildeKeys = map[fyne.KeyName]int{
+	fyne.KeyInsert: 2, fyne.KeyDelete: 3, fyne.KeyPageUp: 5, fyne.KeyPageDown: 6,
+	fyne.KeyF5: 15, fyne.KeyF6: 17, fyne.KeyF7: 18, fyne.KeyF8: 19,
+	fyne.KeyF9: 20, fyne.KeyF10: 21, fyne.KeyF11: 23, fyne.KeyF12: 24,
+}
+
+// functionKeys F1-F4 的 SS3 序列
+var functionKeys = map[fyne.KeyName]byte{
+	fyne.KeyF1: 'P', fyne.KeyF2: 'Q', fyne.KeyF3: 'R', fyne.KeyF4: 'S',
+}
+
+// keySequence 无修饰键时功能键对应的 xterm 序列，appCursor 为程序开启的应用光标键模式
+func keySequence(key fyne.KeyName, appCursor bool) string {
+	switch key {
+	case fyne.KeyReturn, fyne.KeyEnter:
+		return "\r"
+	case fyne.KeyBackspace:
+		return "\x7f"
+	case fyne.KeyTab:
+		return "\t"
+	case fyne.KeyEscape:
+		return "\x1b"
+	}
+	if c, ok := cursorKeys[key]; ok {
+		if appCursor {
+			return "\x1bO" + string(c)
+		}
+		return "\x1b[" + string(c)
+	}
+	if n, ok := tildeKeys[key]; ok {
+		return fmt.Sprintf("\x1b[%d~", n)
+	}
+	if c, ok := functionKeys[key]; ok {
+		return "\x1bO" + string(c)
+	}
+	return ""
+}
+
+// modifiedKeySequence 带修饰键的功能键序列，不是功能键时返回空字符串
+func modifiedKeySequence(key fyne.KeyName, modifier fyne.KeyModifier) string {
+	param := 1
+	if modifier&fyne.KeyModifierShift != 0 {
+		param += 1
+	}
+	if modifier&fyne.KeyModifierAlt != 0 {
+		param += 2
+	}
+	if modifier&fyne.KeyModifierControl != 0 {
+		param += 4
+	}
+	if c, ok := cursorKeys[key]; ok {
+		return fmt.Sprintf("\x1b[1;%d%c", param, c)
+	}
+	if c, ok := functionKeys[key]; ok {
+		return fmt.Sprintf("\x1b[1;%d%c", param, c)
+	}
+	if n, ok := tildeKeys[key]; ok {
+		return fmt.Sprintf("\x1b[%d;%d~", n, param)
+	}
+	return ""
+}
+
+// terminalRenderer 终端控件的渲染器，最小尺寸很小，窗口可自由缩放
+type terminalRenderer struct {
+	t       *terminalWidget
+	bg      *canvas.Rectangle
+	objects []fyne.CanvasObject
+}
+
+func (r *terminalRenderer) Layout(size fyne.Size) {
+	r.bg.Resize(size)
+	r.t.grid.Resize(size)
+	r.t.fit(size)
+}
+
+func (r *terminalRenderer) MinSize() fyne.Size {
+	cell := terminalCellSize()
+	return fyne.NewSize(cell.Width*20, cell.Height*5)
+}
+
+func (r *terminalRenderer) Refresh() {
+	r.bg.Refresh()
+	r.t.draw()
+}
+
+func (r *terminalRenderer) Objects() []fyne.CanvasObject {
+	return r.objects
+}
+
+func (r *terminalRenderer) Destroy() {}
+
+// terminalTab 终端窗口的一个标签页：一个会话与显示它的终端控件
+type terminalTab struct {
+	item    *container.TabItem
+	term    *terminalWidget
+	title   string
+	mu      sync.Mutex
+	session *adb.TerminalSession
+	closed  bool
+}
+
+// close 结束会话
+func (tab *terminalTab) close() {
+	tab.mu.Lock()
+	tab.closed = true
+	session := tab.session
+	tab.mu.Unlock()
+	if session != nil {
+		session.Close()
+	}
+}
+
+// openShellWindow 打开设备的终端窗口，每个标签页是一个独立的 shell 会话
+func (m *MainUI) openShellWindow(serial, model string) {
+	shellWindow := fyne.CurrentApp().NewWindow(fmt.Sprintf("终端 - %s (%s)", model, serial))
+	shellWindow.Resize(fyne.NewSize(900, 600))
+
+	var mu sync.Mutex
+	tabs := make(map[*container.TabItem]*terminalTab)
+	count := 0
+	docTabs := container.NewDocTabs()
+
+	newTab := func() *container.TabItem {
+		mu.Lock()
+		count++
+		title := fmt.Sprintf("Shell %d", count)
+		mu.Unlock()
+
+		tab := m.newTerminalTab(shellWindow, docTabs, serial, title)
+		mu.Lock()
+		tabs[tab.item] = tab
+		mu.Unlock()
+		return tab.item
+	}
+	current := func() *terminalTab {
+		mu.Lock()
+		defer mu.Unlock()
+		return tabs[docTabs.Selected()]
+	}
+
+	docTabs.CreateTab = newTab
+	docTabs.OnClosed = func(item *container.TabItem) {
+		mu.Lock()
+		tab := tabs[item]
+		delete(tabs, item)
+		mu.Unlock()
+		if tab != nil {
+			tab.close()
+		}
+	}
+	docTabs.OnSelected = func(item *container.TabItem) {
+		if tab := current(); tab != nil {
+			shellWindow.Canvas().Focus(tab.term)
+		}
+	}
+	shellWindow.SetOnClosed(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, tab := range tabs {
+			tab.close()
+		}
+	})
+
+	// 快捷命令输入到当前标签页
+	quickCommand := func(label, command string) *widget.Button {
+		btn := widget.NewButton(label, func() {
+			if tab := current(); tab != nil {
+				tab.term.input([]byte(command + "\r"))
+				shellWindow.Canvas().Focus(tab.term)
+			}
+		})
+		btn.Importance = widget.LowImportance
+		return btn
+	}
+	suBtn := quickCommand("⚡ su", "su")
+	suBtn.Importance = widget.WarningImportance
+
+	copyBtn := widget.NewButton("复制", func() {
+		if tab := current(); tab != nil {
+			tab.term.Copy()
+		}
+	})
+	pasteBtn := widget.NewButton("粘贴", func() {
+		if tab := current(); tab != nil {
+			tab.term.Paste()
+			shellWindow.Canvas().Focus(tab.term)
+		}
+	})
+
+	toolbar := container.NewHBox(
+		quickCommand("📁 ls -la", "ls -la"),
+		quickCommand("📊 top", "top"),
+		quickCommand("🔍 ps -A", "ps -A"),
+		suBtn,
+		widget.NewSeparator(),
+		copyBtn, pasteBtn,
+	)
+	hint := widget.NewLabel("Ctrl+Shift+C / Ctrl+Shift+V 复制粘贴，拖动鼠标选择，滚轮或 Shift+PageUp 查看历史输出")
+	hint.Importance = widget.LowImportance
+
+	shellWindow.SetContent(container.NewBorder(
+		container.NewVBox(toolbar, widget.NewSeparator()),
+		hint, nil, nil,
+		docTabs,
+	))
+
+	first := newTab()
+	docTabs.Append(first)
+	docTabs.Select(first)
+	shellWindow.Show()
+	if tab := current(); tab != nil {
+		shellWindow.Canvas().Focus(tab.term)
+	}
+}
+
+// newTerminalTab 创建标签页并在后台打开会话
+func (m *MainUI) newTerminalTab(w fyne.Window, docTabs *container.DocTabs, serial, title string) *terminalTab {
+	term := newTerminalWidget(w)
+	tab := &terminalTab{term: term, title: title, item: container.NewTabItem(title, term)}
+
+	go func() {
+		term.Output([]byte(fmt.Sprintf("正在打开 %s 的终端...\r\n", serial)))
+		cols, rows := term.TermSize()
+		session, err := m.adbMgr.OpenTerminal(serial, cols, rows)
+		if err != nil {
+			term.Output([]byte(fmt.Sprintf("\x1b[31m打开终端失败: %v\x1b[0m\r\n", err)))
+			return
+		}
+
+		tab.mu.Lock()
+		if tab.closed {
+			tab.mu.Unlock()
+			session.Close()
+			return
+		}
+		tab.session = session
+		tab.mu.Unlock()
+
+		term.Attach(func(data []byte) {
+			session.Write(data)
+		}, func(cols, rows int) {
+			session.Resize(cols, rows)
+		})
+		if !session.CanResize() {
+			term.Output([]byte("\x1b[33m设备不支持 shell_v2，终端大小固定为设备默认值\x1b[0m\r\n"))
+		}
+
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := session.Read(buf)
+			if n > 0 {
+				term.Output(buf[:n])
+			}
+			if err != nil {
+				break
+			}
+		}
+
+		code, err := session.Wait()
+		switch {
+		case err != nil:
+			term.Output([]byte(fmt.Sprintf("\r\n\x1b[31m[会话异常结束: %v]\x1b[0m\r\n", err)))
+		case code >= 0:
+			term.Output([]byte(fmt.Sprintf("\r\n[会话已结束，退出码 %d]\r\n", code)))
+		default:
+			term.Output([]byte("\r\n[会话已结束]\r\n"))
+		}
+		tab.item.Text = tab.title + "（已结束）"
+		docTabs.Refresh()
+	}()
+	return tab
+}
@@ -0,0 +1,352 @@
+package ui
+
+import (
+	"adbmanager/internal/adb"
+	"adbmanager/internal/tunnel"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// 转发方向选项
+const (
+	tunnelForward = "正向（本机 -> 设备）"
+	tunnelReverse = "反向（设备 -> 本机）"
+)
+
+// TunnelUI 端口转发界面
+type TunnelUI struct {
+	window     fyne.Window
+	adbMgr     *adb.ADBManager
+	tunnelMgr  *tunnel.Manager
+	getDevice  func() string
+	rules      []adb.ForwardRule
+	ruleList   *widget.List
+	selectedID int
+	presetList *widget.List
+	presetID   int
+}
+
+// NewTunnelUI 创建端口转发界面
+func NewTunnelUI(window fyne.Window, adbMgr *adb.ADBManager, tunnelMgr *tunnel.Manager, getDevice func() string) *TunnelUI {
+	return &TunnelUI{
+		window:     window,
+		adbMgr:     adbMgr,
+		tunnelMgr:  tunnelMgr,
+		getDevice:  getDevice,
+		rules:      make([]adb.ForwardRule, 0),
+		selectedID: -1,
+		presetID:   -1,
+	}
+}
+
+// Build 构建端口转发界面
+func (t *TunnelUI) Build() fyne.CanvasObject {
+	// 当前设备的转发规则
+	t.ruleList = widget.NewList(
+		func() int {
+			return len(t.rules)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("转发规则")
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id < widget.ListItemID(len(t.rules)) {
+				obj.(*widget.Label).SetText(t.rules[id].String())
+			}
+		},
+	)
+	t.ruleList.OnSelected = func(id widget.ListItemID) {
+		t.selectedID = int(id)
+	}
+
+	refreshBtn := widget.NewButton("🔄 刷新", func() {
+		t.refreshRules()
+	})
+
+	removeBtn := widget.NewButton("删除选中", func() {
+		if t.selectedID < 0 || t.selectedID >= len(t.rules) {
+			showError(t.window, "错误", fmt.Errorf("请先选择转发规则"))
+			return
+		}
+		rule := t.rules[t.selectedID]
+		device := t.getDevice()
+
+		var err error
+		if rule.Reverse {
+			err = t.adbMgr.RemoveReverse(device, rule.Local)
+		} else {
+			err = t.adbMgr.RemoveForward(device, rule.Local)
+		}
+		if err != nil {
+			showError(t.window, "删除失败", err)
+		}
+		t.refreshRules()
+	})
+
+	removeAllBtn := widget.NewButton("全部删除", func() {
+		device := t.getDevice()
+		if device == "" {
+			showError(t.window, "错误", fmt.Errorf("请先选择设备"))
+			return
+		}
+		dialog.ShowConfirm("确认删除", "确定要删除 "+device+" 的所有正向与反向转发吗？", func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			if err := t.adbMgr.RemoveAllForwards(device); err != nil {
+				showError(t.window, "删除失败", err)
+			}
+			if err := t.adbMgr.RemoveAllReverses(device); err != nil {
+				showError(t.window, "删除失败", err)
+			}
+			t.refreshRules()
+		}, t.window)
+	})
+
+	// 新建转发
+	directionSelect := widget.NewSelect([]string{tunnelForward, tunnelReverse}, nil)
+	directionSelect.SetSelected(tunnelForward)
+
+	localEntry := widget.NewEntry()
+	localEntry.SetPlaceHolder("tcp:8081（正向为本机端，反向为设备端；tcp:0 自动分配）")
+	remoteEntry := widget.NewEntry()
+	remoteEntry.SetPlaceHolder("tcp:8081、localabstract:chrome_devtools_remote、jdwp:PID")
+
+	jdwpBtn := widget.NewButton("选择 JDWP 进程", func() {
+		t.chooseJDWP(remoteEntry)
+	})
+
+	addBtn := widget.NewButton("添加转发", func() {
+		device := t.getDevice()
+		if device == "" {
+			showError(t.window, "错误", fmt.Errorf("请先选择设备"))
+			return
+		}
+
+		local, remote := strings.TrimSpace(localEntry.Text), strings.TrimSpace(remoteEntry.Text)
+		var resolved string
+		var err error
+		if directionSelect.Selected == tunnelReverse {
+			resolved, err = t.adbMgr.Reverse(device, local, remote, false)
+		} else {
+			resolved, err = t.adbMgr.Forward(device, local, remote, false)
+		}
+		if err != nil {
+			showError(t.window, "添加转发失败", err)
+			return
+		}
+		if resolved != local {
+			showInfo(t.window, "成功", "已分配端口 "+resolved)
+		}
+		t.refreshRules()
+	})
+
+	saveBtn := widget.NewButton("保存为预设", func() {
+		preset := tunnel.Preset{
+			Reverse: directionSelect.Selected == tunnelReverse,
+			Local:   strings.TrimSpace(localEntry.Text),
+			Remote:  strings.TrimSpace(remoteEntry.Text),
+			Enabled: true,
+		}
+		if strings.HasPrefix(preset.Remote, "jdwp:") {
+			showError(t.window, "错误", fmt.Errorf("JDWP 进程号每次启动都会变化，不适合保存为预设"))
+			return
+		}
+		t.showSavePresetDialog(preset)
+	})
+
+	addForm := widget.NewForm(
+		widget.NewFormItem("方向", directionSelect),
+		widget.NewFormItem("本端", localEntry),
+		widget.NewFormItem("对端", container.NewBorder(nil, nil, nil, jdwpBtn, remoteEntry)),
+	)
+
+	// 预设
+	t.presetList = widget.NewList(
+		func() int {
+			return len(t.tunnelMgr.Presets())
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("预设")
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			presets := t.tunnelMgr.Presets()
+			if id < widget.ListItemID(len(presets)) {
+				obj.(*widget.Label).SetText(formatPreset(presets[id]))
+			}
+		},
+	)
+	t.presetList.OnSelected = func(id widget.ListItemID) {
+		t.presetID = int(id)
+	}
+
+	toggleBtn := widget.NewButton("启用/停用", func() {
+		presets := t.tunnelMgr.Presets()
+		if t.presetID < 0 || t.presetID >= len(presets) {
+			showError(t.window, "错误", fmt.Errorf("请先选择预设"))
+			return
+		}
+		if err := t.tunnelMgr.SetEnabled(t.presetID, !presets[t.presetID].Enabled); err != nil {
+			showError(t.window, "保存失败", err)
+		}
+		t.presetList.Refresh()
+	})
+
+	deletePresetBtn := widget.NewButton("删除预设", func() {
+		if t.presetID < 0 || t.presetID >= len(t.tunnelMgr.Presets()) {
+			showError(t.window, "错误", fmt.Errorf("请先选择预设"))
+			return
+		}
+		if err := t.tunnelMgr.RemovePreset(t.presetID); err != nil {
+			showError(t.window, "删除失败", err)
+		}
+		t.presetID = -1
+		t.presetList.UnselectAll()
+		t.presetList.Refresh()
+	})
+
+	applyBtn := widget.NewButton("应用到当前设备", func() {
+		device := t.getDevice()
+		if device == "" {
+			showError(t.window, "错误", fmt.Errorf("请先选择设备"))
+			return
+		}
+		if err := t.tunnelMgr.Apply(device); err != nil {
+			showError(t.window, "部分预设应用失败", err)
+		}
+		t.refreshRules()
+	})
+
+	rulesPanel := container.NewBorder(
+		container.NewBorder(nil, nil, widget.NewLabel("当前设备的转发:"), refreshBtn),
+		container.NewVBox(
+			container.NewHBox(removeBtn, removeAllBtn),
+			widget.NewSeparator(),
+			addForm,
+			container.NewHBox(addBtn, saveBtn),
+		),
+		nil, nil,
+		t.ruleList,
+	)
+
+	presetsPanel := container.NewBorder(
+		widget.NewLabel("预设（设备连接或重连后自动应用已启用的预设）:"),
+		container.NewHBox(toggleBtn, deletePresetBtn, applyBtn),
+		nil, nil,
+		t.presetList,
+	)
+
+	split := container.NewHSplit(rulesPanel, presetsPanel)
+	split.SetOffset(0.55)
+	return split
+}
+
+// refreshRules 刷新当前设备的正向与反向转发
+func (t *TunnelUI) refreshRules() {
+	t.rules = make([]adb.ForwardRule, 0)
+	t.selectedID = -1
+	if t.ruleList != nil {
+		t.ruleList.UnselectAll()
+	}
+
+	device := t.getDevice()
+	if device == "" {
+		t.ruleList.Refresh()
+		return
+	}
+
+	forwards, err := t.adbMgr.ListForwards(device)
+	if err != nil {
+		showError(t.window, "获取转发列表失败", err)
+	}
+	reverses, err := t.adbMgr.ListReverses(device)
+	if err != nil {
+		showError(t.window, "获取转发列表失败", err)
+	}
+	t.rules = append(forwards, reverses...)
+	t.ruleList.Refresh()
+}
+
+// chooseJDWP 列出设备上可调试的进程，选中后填入 jdwp:PID
+func (t *TunnelUI) chooseJDWP(remoteEntry *widget.Entry) {
+	device := t.getDevice()
+	if device == "" {
+		showError(t.window, "错误", fmt.Errorf("请先选择设备"))
+		return
+	}
+
+	pids, err := t.adbMgr.ListJDWP(device)
+	if err != nil {
+		showError(t.window, "获取 JDWP 进程失败", err)
+		return
+	}
+	if len(pids) == 0 {
+		showInfo(t.window, "提示", "设备上没有可调试的进程（应用需为 debuggable）")
+		return
+	}
+
+	options := make([]string, 0, len(pids))
+	for _, pid := range pids {
+		options = append(options, strconv.Itoa(pid))
+	}
+	pidSelect := widget.NewSelect(options, nil)
+	pidSelect.SetSelected(options[0])
+
+	dialog.ShowCustomConfirm("选择 JDWP 进程", "确定", "取消", pidSelect, func(confirmed bool) {
+		if confirmed && pidSelect.Selected != "" {
+			remoteEntry.SetText("jdwp:" + pidSelect.Selected)
+		}
+	}, t.window)
+}
+
+// showSavePresetDialog 填写预设名称与适用设备后保存
+func (t *TunnelUI) showSavePresetDialog(preset tunnel.Preset) {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("例如 Metro、gRPC 调试")
+
+	deviceEntry := widget.NewEntry()
+	deviceEntry.SetPlaceHolder("留空应用到所有设备")
+	if device := t.getDevice(); device != "" {
+		// 优先使用 ro.serialno，同一台设备换 IP 或改用 USB 后预设仍然适用
+		if hardwareSerial, err := t.adbMgr.ResolveIdentity(device); err == nil && hardwareSerial != "" {
+			device = hardwareSerial
+		}
+		deviceEntry.SetText(device)
+	}
+
+	form := widget.NewForm(
+		widget.NewFormItem("名称", nameEntry),
+		widget.NewFormItem("设备", deviceEntry),
+	)
+	dialog.ShowCustomConfirm("保存为预设", "保存", "取消", form, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		preset.Name = strings.TrimSpace(nameEntry.Text)
+		preset.Device = strings.TrimSpace(deviceEntry.Text)
+		if err := t.tunnelMgr.AddPreset(preset); err != nil {
+			showError(t.window, "保存预设失败", err)
+			return
+		}
+		t.presetList.Refresh()
+	}, t.window)
+}
+
+// formatPreset 预设的显示文字
+func formatPreset(preset tunnel.Preset) string {
+	state := "✓"
+	if !preset.Enabled {
+		state = "✗"
+	}
+	device := preset.Device
+	if device == "" {
+		device = "所有设备"
+	}
+	return fmt.Sprintf("%s %s  %s  [%s]", state, preset.Name, preset.Rule(), device)
+}